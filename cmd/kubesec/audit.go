@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/audit"
+	kubesecmetrics "github.com/controlplaneio/kubesec-webhook/pkg/metrics"
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+	"github.com/controlplaneio/kubesec-webhook/pkg/sarif"
+	"github.com/controlplaneio/kubesec-webhook/pkg/webhook"
+)
+
+// Output formats of the audit.
+const (
+	auditOutputTable = "table"
+	auditOutputJSON  = "json"
+)
+
+// runAudit reviews the workloads of the cluster against the given policy
+// flags, and prints those that would be denied, or compares two audits.
+func runAudit(args []string) error {
+	flags := &Flags{}
+	fl := flag.NewFlagSet("audit", flag.ExitOnError)
+	fl.Usage = func() {
+		fmt.Fprintf(fl.Output(), "Usage: %s audit [flags]\n\n", os.Args[0])
+		fmt.Fprintf(fl.Output(), "Reviews the workloads of the cluster and prints those that would be denied, or compares\n")
+		fmt.Fprintf(fl.Output(), "the audits of two reports or two contexts.\n\n")
+		fl.PrintDefaults()
+	}
+	fl.BoolVar(&flags.Debug, "debug", debugDef, "log the reviews")
+	fl.StringVar(&flags.Kubeconfig, "kubeconfig", "", "path to a kubeconfig, the in-cluster configuration or $KUBECONFIG when empty")
+	namespace := fl.String("namespace", "", "namespace audited, all when empty")
+	output := fl.String("output", auditOutputTable, "output format: table, json or sarif")
+	all := fl.Bool("all", false, "list the passing workloads too")
+	failOnDeny := fl.Bool("fail-on-deny", false, "exit with an error when a workload would be denied, newly fails when comparing")
+	compareReports := fl.String("compare-reports", "", "base and target JSON reports compared, written with -output=json -all, e.g. staging.json,production.json")
+	compareContexts := fl.String("compare-contexts", "", "base and target kubeconfig contexts audited and compared, e.g. staging,production")
+	ignoreNamespaces := fl.Bool("ignore-namespaces", false, "match the compared workloads by kind and name only, e.g. to compare two namespaces")
+	registerPolicyFlags(fl, flags)
+	if err := fl.Parse(args); err != nil {
+		return err
+	}
+	if *compareReports != "" && *compareContexts != "" {
+		return fmt.Errorf("-compare-reports and -compare-contexts are mutually exclusive")
+	}
+	compare := *compareReports != "" || *compareContexts != ""
+	switch *output {
+	case auditOutputTable, auditOutputJSON:
+	case outputSARIF:
+		if compare {
+			return fmt.Errorf("output must be %s or %s when comparing, got %q", auditOutputTable, auditOutputJSON, *output)
+		}
+	default:
+		return fmt.Errorf("output must be %s, %s or %s, got %q", auditOutputTable, auditOutputJSON, outputSARIF, *output)
+	}
+
+	m := Main{flags: flags, logger: log.Dummy}
+	if flags.Debug {
+		m.logger = &log.Std{Debug: true}
+	}
+
+	if *compareReports != "" {
+		reports, err := readReports(splitList(*compareReports))
+		if err != nil {
+			return err
+		}
+		return writeComparison(audit.Compare(reports[0], reports[1], *ignoreNamespaces), *output, *all, *failOnDeny)
+	}
+
+	ctx := context.Background()
+	opts, _, err := m.policyOptions(ctx, kubesecmetrics.Dummy)
+	if err != nil {
+		return err
+	}
+
+	if *compareContexts != "" {
+		contexts := splitList(*compareContexts)
+		if len(contexts) != 2 {
+			return fmt.Errorf("-compare-contexts must be two contexts, got %q", *compareContexts)
+		}
+		var reports []audit.Report
+		for _, name := range contexts {
+			restCfg, err := m.contextConfig(name)
+			if err != nil {
+				return fmt.Errorf("could not load the context %q: %w", name, err)
+			}
+			rep, err := m.auditCluster(ctx, restCfg, *opts, *namespace)
+			if err != nil {
+				return fmt.Errorf("could not audit the context %q: %w", name, err)
+			}
+			reports = append(reports, rep)
+		}
+		return writeComparison(audit.Compare(reports[0], reports[1], *ignoreNamespaces), *output, *all, *failOnDeny)
+	}
+
+	restCfg, err := m.restConfig()
+	if err != nil {
+		return err
+	}
+	rep, err := m.auditCluster(ctx, restCfg, *opts, *namespace)
+	if err != nil {
+		return err
+	}
+	switch *output {
+	case auditOutputJSON:
+		err = rep.WriteJSON(os.Stdout, *all)
+	case outputSARIF:
+		objects := make([]sarif.Object, 0, len(rep.Decisions))
+		for _, rec := range rep.Decisions {
+			objects = append(objects, sarif.Object{Decision: rec})
+		}
+		err = sarif.Write(os.Stdout, objects)
+	default:
+		err = rep.WriteTable(os.Stdout, *all)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *failOnDeny && rep.Failing > 0 {
+		return fmt.Errorf("%d workloads would be denied", rep.Failing)
+	}
+	return nil
+}
+
+// auditCluster reviews the workloads of the cluster of restCfg with the
+// policy options, its namespaces, policies and exemptions read from the
+// cluster.
+func (m *Main) auditCluster(ctx context.Context, restCfg *rest.Config, opts webhook.Options, namespace string) (audit.Report, error) {
+	c, err := client.New(restCfg, client.Options{})
+	if err != nil {
+		return audit.Report{}, err
+	}
+	opts.Namespaces = c
+	if m.flags.KubesecPolicies {
+		opts.Policies = policy.KubesecPolicies{Reader: c}
+	}
+	if m.flags.KubesecExemptions {
+		opts.Exemptions = policy.KubesecExemptions{Reader: c}
+	}
+	return audit.Run(ctx, c, &opts, namespace, m.flags.MinScore, m.logger)
+}
+
+// contextConfig returns the configuration of the kubeconfig context name,
+// read from -kubeconfig, $KUBECONFIG or ~/.kube/config.
+func (m *Main) contextConfig(name string) (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = m.flags.Kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: name}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+}
+
+// readReports reads the base and target reports of the comparison.
+func readReports(paths []string) ([]audit.Report, error) {
+	if len(paths) != 2 {
+		return nil, fmt.Errorf("-compare-reports must be two reports, got %d", len(paths))
+	}
+	var reports []audit.Report
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		rep, err := audit.ReadReport(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("could not read the report %s: %w", path, err)
+		}
+		reports = append(reports, rep)
+	}
+	return reports, nil
+}
+
+// writeComparison prints the comparison in the output format.
+func writeComparison(cmp audit.Comparison, output string, all, failOnDeny bool) error {
+	var err error
+	if output == auditOutputJSON {
+		err = cmp.WriteJSON(os.Stdout, all)
+	} else {
+		err = cmp.WriteTable(os.Stdout, all)
+	}
+	if err != nil {
+		return err
+	}
+
+	if failOnDeny && cmp.NewlyFailing > 0 {
+		return fmt.Errorf("%d workloads newly fail", cmp.NewlyFailing)
+	}
+	return nil
+}
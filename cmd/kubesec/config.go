@@ -0,0 +1,132 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// envPrefix prefixes the environment variables setting the flags.
+const envPrefix = "KUBESEC_WEBHOOK_"
+
+// envName returns the environment variable of a flag, e.g.
+// KUBESEC_WEBHOOK_MIN_SCORE for -min-score.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// repeatable is implemented by the flags that can be repeated, each Set
+// adding a value.
+type repeatable interface {
+	flag.Value
+	repeatable()
+}
+
+func (p *podTemplatePathsFlag) repeatable() {}
+func (f *filesFlag) repeatable()            {}
+
+// loadEnv sets the flags not set on the commandline from their environment
+// variable. The values of the flags that can be repeated are comma separated.
+func loadEnv(fl *flag.FlagSet) error {
+	set := map[string]bool{}
+	fl.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	var err error
+	fl.VisitAll(func(f *flag.Flag) {
+		v, ok := os.LookupEnv(envName(f.Name))
+		if !ok || set[f.Name] || err != nil {
+			return
+		}
+		args := []string{v}
+		if _, repeated := f.Value.(repeatable); repeated {
+			args = strings.Split(v, ",")
+		}
+		for _, a := range args {
+			if e := fl.Set(f.Name, strings.TrimSpace(a)); e != nil {
+				err = fmt.Errorf("invalid %s: %w", envName(f.Name), e)
+				return
+			}
+		}
+	})
+	return err
+}
+
+// loadConfig sets the flags not set on the commandline, nor by the
+// environment, from the YAML file at path, a map of flag names to values.
+// Lists are joined with commas, or set one element at a time for the flags
+// that can be repeated.
+func loadConfig(fl *flag.FlagSet, path string) error {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(raw, &values); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	set := map[string]bool{}
+	fl.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := fl.Lookup(name)
+		if f == nil || name == "config" {
+			return fmt.Errorf("invalid config file %s: unknown flag %q", path, name)
+		}
+		if set[name] {
+			continue
+		}
+
+		var args []string
+		switch v := values[name].(type) {
+		case []interface{}:
+			for _, e := range v {
+				args = append(args, configValue(e))
+			}
+			if _, repeated := f.Value.(repeatable); !repeated {
+				args = []string{strings.Join(args, ",")}
+			}
+		default:
+			args = []string{configValue(v)}
+		}
+		for _, a := range args {
+			if err := fl.Set(name, a); err != nil {
+				return fmt.Errorf("invalid config file %s: %s: %w", path, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// configValue returns the flag value of a YAML scalar.
+func configValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// effectiveConfig returns the value of every flag. Secrets are read from
+// files, only their paths are flags.
+func effectiveConfig(fl *flag.FlagSet) []string {
+	var res []string
+	fl.VisitAll(func(f *flag.Flag) {
+		res = append(res, fmt.Sprintf("%s=%s", f.Name, f.Value))
+	})
+	return res
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testFlags returns a flag set of a repeatable and a scalar flag.
+func testFlags() (*flag.FlagSet, *filesFlag, *string) {
+	fl := flag.NewFlagSet("test", flag.ContinueOnError)
+	plugins := &filesFlag{}
+	fl.Var(plugins, "wasm-plugin", "")
+	namespaces := fl.String("exclude-namespaces", "", "")
+	return fl, plugins, namespaces
+}
+
+// Test_loadConfig - tests the lists of the repeatable flags are set one element at a time
+func Test_loadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	raw := "wasm-plugin:\n- /plugins/a.wasm\n- /plugins/b.wasm\nexclude-namespaces:\n- kube-system\n- monitoring\n"
+	if err := os.WriteFile(path, []byte(raw), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fl, plugins, namespaces := testFlags()
+
+	if err := loadConfig(fl, path); err != nil {
+		t.Fatal(err)
+	}
+	if want := (filesFlag{"/plugins/a.wasm", "/plugins/b.wasm"}); !reflect.DeepEqual(*plugins, want) {
+		t.Fatalf("loadConfig - want plugins %v, got %v", want, *plugins)
+	}
+	if *namespaces != "kube-system,monitoring" {
+		t.Fatalf("loadConfig - want the list joined, got %q", *namespaces)
+	}
+}
+
+// Test_loadEnv - tests the values of the repeatable flags are comma separated
+func Test_loadEnv(t *testing.T) {
+	t.Setenv("KUBESEC_WEBHOOK_WASM_PLUGIN", "/plugins/a.wasm, /plugins/b.wasm")
+	t.Setenv("KUBESEC_WEBHOOK_EXCLUDE_NAMESPACES", "kube-system,monitoring")
+	fl, plugins, namespaces := testFlags()
+
+	if err := loadEnv(fl); err != nil {
+		t.Fatal(err)
+	}
+	if want := (filesFlag{"/plugins/a.wasm", "/plugins/b.wasm"}); !reflect.DeepEqual(*plugins, want) {
+		t.Fatalf("loadEnv - want plugins %v, got %v", want, *plugins)
+	}
+	if *namespaces != "kube-system,monitoring" {
+		t.Fatalf("loadEnv - want the value as is, got %q", *namespaces)
+	}
+}
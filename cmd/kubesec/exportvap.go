@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+)
+
+// runExportVAP prints the part of the policy expressible in CEL as native
+// ValidatingAdmissionPolicies, a fast first line of defense evaluated by the
+// API server before the webhook scores the workloads.
+func runExportVAP(args []string) error {
+	fl := flag.NewFlagSet("export-vap", flag.ExitOnError)
+	fl.Usage = func() {
+		fmt.Fprintf(fl.Output(), "Usage: %s export-vap [flags]\n\n", os.Args[0])
+		fmt.Fprintf(fl.Output(), "Prints ValidatingAdmissionPolicies enforcing the checks expressible in CEL.\n\n")
+		fl.PrintDefaults()
+	}
+	name := fl.String("name", "kubesec", "prefix of the names of the policies and bindings")
+	forbid := fl.String("forbid", strings.Join(policy.CriticalCELChecks(), ","), "comma separated critical checks the workloads must never match")
+	require := fl.String("require", "", "comma separated checks the workloads must pass")
+	imagePolicyFile := fl.String("image-policy-file", "", "image policy whose required checks of the * pattern are exported")
+	audit := fl.Bool("audit", false, "warn and audit instead of denying")
+	if err := fl.Parse(args); err != nil {
+		return err
+	}
+
+	cfg := policy.VAPConfig{
+		Name:      *name,
+		Forbidden: splitList(*forbid),
+		Required:  splitList(*require),
+		Audit:     *audit,
+	}
+	if *imagePolicyFile != "" {
+		images, err := policy.LoadImages(*imagePolicyFile)
+		if err != nil {
+			return err
+		}
+		cfg.Required = append(cfg.Required, images.GlobalRequiredChecks()...)
+		for _, r := range images.Rules {
+			if r.Pattern != "*" && len(r.RequiredChecks) > 0 {
+				fmt.Fprintf(os.Stderr, "skipping the required checks of image pattern %q, only * applies to every workload\n", r.Pattern)
+			}
+		}
+	}
+
+	out, skipped, err := policy.ValidatingAdmissionPolicy(cfg)
+	if err != nil {
+		return err
+	}
+	if len(skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "checks not expressible in CEL, left to the webhook: %s\n", strings.Join(skipped, ", "))
+	}
+
+	_, err = os.Stdout.Write(out)
+	return err
+}
@@ -1,174 +1,1766 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
+	"net/http/pprof"
 	"os"
-	"os/signal"
-	"syscall"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
-	
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+
 	whhttp "github.com/slok/kubewebhook/pkg/http"
 	"github.com/slok/kubewebhook/pkg/log"
 	"github.com/slok/kubewebhook/pkg/observability/metrics"
-	
+	kwebhook "github.com/slok/kubewebhook/pkg/webhook"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+	ctrlwebhook "sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/auditlog"
+	"github.com/controlplaneio/kubesec-webhook/pkg/bundle"
+	"github.com/controlplaneio/kubesec-webhook/pkg/certs"
+	"github.com/controlplaneio/kubesec-webhook/pkg/dashboard"
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/escalation"
+	"github.com/controlplaneio/kubesec-webhook/pkg/event"
+	"github.com/controlplaneio/kubesec-webhook/pkg/history"
+	"github.com/controlplaneio/kubesec-webhook/pkg/hook"
+	"github.com/controlplaneio/kubesec-webhook/pkg/logging"
+	"github.com/controlplaneio/kubesec-webhook/pkg/manifest"
+	kubesecmetrics "github.com/controlplaneio/kubesec-webhook/pkg/metrics"
+	"github.com/controlplaneio/kubesec-webhook/pkg/peer"
+	"github.com/controlplaneio/kubesec-webhook/pkg/plugin"
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+	"github.com/controlplaneio/kubesec-webhook/pkg/policyreport"
+	"github.com/controlplaneio/kubesec-webhook/pkg/publish"
+	"github.com/controlplaneio/kubesec-webhook/pkg/registration"
+	"github.com/controlplaneio/kubesec-webhook/pkg/report"
+	"github.com/controlplaneio/kubesec-webhook/pkg/requestid"
+	"github.com/controlplaneio/kubesec-webhook/pkg/rescan"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanresult"
+	"github.com/controlplaneio/kubesec-webhook/pkg/stream"
+	"github.com/controlplaneio/kubesec-webhook/pkg/syslog"
 	"github.com/controlplaneio/kubesec-webhook/pkg/webhook"
 )
 
 // Defaults.
 const (
-	lAddressDef     = ":8080"
-	lMetricsAddress = ":8081"
-	debugDef        = false
-	gracePeriod     = 3 * time.Second
+	lAddressDef      = ":8080"
+	lMetricsAddress  = ":8081"
+	lHealthAddress   = ":8082"
+	debugDef         = false
+	leaderElectionID = "kubesec-webhook-leader"
+	kubesecURLEnv    = "KUBESEC_URL"
+)
+
+// serviceAccountNamespace holds the namespace of the pod.
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// minDenyMessageMaxLength leaves room for the reasons next to the request ID
+// and the truncation pointer.
+const minDenyMessageMaxLength = 256
+
+// Scanners scoring the definitions.
+const (
+	scannerRemote   = "remote"
+	scannerEmbedded = "embedded"
+	scannerSidecar  = "sidecar"
 )
 
+// metricsPrometheus is the metrics backend served on the metrics listen
+// address, the others are kubesecmetrics.FormatStatsD and FormatDogStatsD.
+const metricsPrometheus = "prometheus"
+
 // Flags are the flags of the program.
 type Flags struct {
-	ListenAddress        string
-	MetricsListenAddress string
-	Debug                bool
-	CertFile             string
-	KeyFile              string
-	MinScore             int
+	Config                   string
+	ListenAddress            string
+	MetricsListenAddress     string
+	MetricsBackend           string
+	StatsDAddress            string
+	StatsDPrefix             string
+	HealthListenAddress      string
+	ShutdownDelay            time.Duration
+	ShutdownTimeout          time.Duration
+	ReadyzScanner            bool
+	ScannerProbe             bool
+	EnablePprof              bool
+	ReadyzScannerInterval    time.Duration
+	Debug                    bool
+	LogFormat                string
+	CertFile                 string
+	KeyFile                  string
+	TLSSelfSigned            bool
+	TLSSelfSignedSANs        string
+	TLSSecret                string
+	TLSMinVersion            string
+	TLSCipherSuites          string
+	ClientCAFile             string
+	TLSCAFile                string
+	InjectCABundle           string
+	SelfRegister             bool
+	WebhookName              string
+	WebhookService           string
+	WebhookPort              int
+	WebhookSelector          string
+	WebhookFailurePolicy     string
+	WebhookTimeout           time.Duration
+	MinScore                 int
+	NamespaceMinScore        bool
+	WarnScore                *int
+	Kubeconfig               string
+	LeaderElect              bool
+	LeaderElectionNamespace  string
+	LeaseDuration            time.Duration
+	RenewDeadline            time.Duration
+	RetryPeriod              time.Duration
+	SMTPHost                 string
+	SMTPPort                 int
+	SMTPUsername             string
+	SMTPPasswordFile         string
+	SMTPFrom                 string
+	SMTPTo                   string
+	SMTPTLSMode              string
+	ReportClusterName        string
+	ReportInterval           time.Duration
+	EscalationThreshold      int
+	EscalationWindow         time.Duration
+	EscalationTracker        string
+	EscalationURL            string
+	JiraProject              string
+	JiraIssueType            string
+	JiraUsername             string
+	JiraTokenFile            string
+	Scanner                  string
+	KubesecURL               string
+	SidecarAddress           string
+	SidecarStartupTimeout    time.Duration
+	ScanCacheSize            int
+	ScanCacheTTL             time.Duration
+	ScanRetry                scanner.RetryConfig
+	ScanBreakerThreshold     int
+	ScanBreakerCooldown      time.Duration
+	RedisAddress             string
+	RedisUsername            string
+	RedisPasswordFile        string
+	RedisDB                  int
+	RedisTLS                 bool
+	RedisCAFile              string
+	PeerService              string
+	PeerAddresses            string
+	PeerPort                 int
+	PeerSelf                 string
+	PeerTokenFile            string
+	PeerServerName           string
+	PeerRefresh              time.Duration
+	ScanTransport            scanner.TransportConfig
+	ImagePolicyFile          string
+	RuleWeightsFile          string
+	RegoPolicyFile           string
+	DecisionExpressionsFile  string
+	WASMPlugins              []string
+	WASMPluginTimeout        time.Duration
+	PolicyBundle             string
+	PolicyBundleRefresh      time.Duration
+	PolicyBundleUsername     string
+	PolicyBundlePasswordFile string
+	PolicyBundlePlainHTTP    bool
+	PolicyConfigMap          string
+	PolicyConfigMapRefresh   time.Duration
+	ExcludeNamespaces        string
+	TrustedUsers             string
+	TrustedGroups            string
+	ExemptPriorityClasses    string
+	ExemptionMode            string
+	SkipNamespaces           string
+	SkipSelector             string
+	DenyRules                string
+	RequiredChecks           string
+	FailureMode              string
+	Enforcement              string
+	DenyDetail               string
+	DenyMessageMaxLength     int
+	GrandfatherUpdates       bool
+	SkipUnchangedUpdates     bool
+	KubesecPolicies          bool
+	KubesecExemptions        bool
+	ExemptionExpiryWarning   time.Duration
+	StreamTokenFile          string
+	ScanAPITokenFile         string
+	GRPCListenAddress        string
+	DenialEvents             bool
+	PolicyReports            bool
+	ScanResults              bool
+	ScanResultsHistory       int
+	RescanInterval           time.Duration
+	HookExec                 string
+	HookURL                  string
+	HookWebhooksFile         string
+	AuditLogFile             string
+	AuditLogMaxSize          int
+	AuditLogMaxAge           time.Duration
+	AuditLogMaxBackups       int
+	SyslogAddress            string
+	SyslogTLS                bool
+	SyslogCAFile             string
+	SyslogFacility           string
+	SyslogSeverities         string
+	HistoryDriver            string
+	HistoryDSN               string
+	HistoryAPITokenFile      string
+	DashboardTokenFile       string
+	DashboardDecisions       int
+	CloudEventsSink          string
+	CloudEventsSource        string
+	KafkaBrokers             string
+	KafkaTopic               string
+	KafkaTLS                 bool
+	NATSURL                  string
+	NATSSubject              string
+	HookRate                 float64
+	HookTimeout              time.Duration
+	PodTemplatePaths         webhook.PodTemplatePaths
+	// EffectiveConfig holds the name=value of every flag once parsed.
+	EffectiveConfig []string
+}
+
+// podTemplatePathsFlag collects the repeated -pod-template-path flags.
+type podTemplatePathsFlag webhook.PodTemplatePaths
+
+func (p *podTemplatePathsFlag) String() string {
+	var res []string
+	for gvk, path := range *p {
+		res = append(res, fmt.Sprintf("%s=%s", gvk, path))
+	}
+	return strings.Join(res, ", ")
+}
+
+func (p *podTemplatePathsFlag) Set(v string) error {
+	gvk, path, err := webhook.ParsePodTemplatePath(v)
+	if err != nil {
+		return err
+	}
+	if *p == nil {
+		*p = podTemplatePathsFlag{}
+	}
+	(*p)[gvk] = path
+	return nil
+}
+
+// optionalIntFlag is an int flag left nil unless set.
+type optionalIntFlag struct {
+	v **int
+}
+
+func (f optionalIntFlag) String() string {
+	if f.v == nil || *f.v == nil {
+		return ""
+	}
+	return strconv.Itoa(**f.v)
+}
+
+func (f optionalIntFlag) Set(v string) error {
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	*f.v = &i
+	return nil
 }
 
 // NewFlags returns the flags of the commandline.
 func NewFlags() *Flags {
 	flags := &Flags{}
 	fl := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	fl.StringVar(&flags.Config, "config", "", "YAML file of flag names to values, e.g. /etc/kubesec-webhook/config.yaml, the commandline and the KUBESEC_WEBHOOK_* environment variables take precedence")
 	fl.StringVar(&flags.ListenAddress, "listen-address", lAddressDef, "webhook server listen address")
 	fl.StringVar(&flags.MetricsListenAddress, "metrics-listen-address", lMetricsAddress, "metrics server listen address")
+	fl.StringVar(&flags.MetricsBackend, "metrics-backend", metricsPrometheus, "where the webhook and kubesec metrics go: prometheus on the metrics listen address, or statsd or dogstatsd to the StatsD server")
+	fl.StringVar(&flags.StatsDAddress, "statsd-address", "", "host:port of the StatsD server the metrics are sent to over UDP with -metrics-backend=statsd or dogstatsd, e.g. localhost:8125")
+	fl.StringVar(&flags.StatsDPrefix, "statsd-prefix", "", "prefix of the names of the StatsD metrics, e.g. prod.")
+	fl.StringVar(&flags.HealthListenAddress, "health-listen-address", lHealthAddress, "health probes (/healthz, /readyz) listen address")
+	fl.BoolVar(&flags.EnablePprof, "enable-pprof", false, "serve the net/http/pprof profiles on /debug/pprof/ of the metrics listen address")
+	fl.BoolVar(&flags.ReadyzScanner, "readyz-scanner", false, "report not ready while the scanner can not score definitions, e.g. the Kubesec API is unreachable")
+	fl.DurationVar(&flags.ReadyzScannerInterval, "readyz-scanner-interval", 30*time.Second, "interval between two probes of the scanner, by the readiness check and for the kubesec_webhook_backend_up metric")
+	fl.BoolVar(&flags.ScannerProbe, "scanner-probe", false, "probe the scanner every -readyz-scanner-interval for the kubesec_webhook_backend_up metric, implied by -readyz-scanner")
+	fl.DurationVar(&flags.ShutdownDelay, "shutdown-delay", 5*time.Second, "time the webhook keeps serving, reporting not ready, after SIGTERM so the endpoints are updated first")
+	fl.DurationVar(&flags.ShutdownTimeout, "shutdown-timeout", 20*time.Second, "time the reviews in flight are given to finish once the servers stop, keep the sum with -shutdown-delay below the termination grace period")
 	fl.BoolVar(&flags.Debug, "debug", debugDef, "enable debug mode")
+	fl.StringVar(&flags.LogFormat, "log-format", logging.FormatText, "format of the logs, text or json")
+	registerPolicyFlags(fl, flags)
 	fl.StringVar(&flags.CertFile, "tls-cert-file", "certs/cert.pem", "TLS certificate file")
 	fl.StringVar(&flags.KeyFile, "tls-key-file", "certs/key.pem", "TLS key file")
-	fl.IntVar(&flags.MinScore, "min-score", 0, "Kubesec.io minimum score to validate against")
+	fl.StringVar(&flags.TLSMinVersion, "tls-min-version", "1.2", "minimum TLS version of the webhook server: 1.2 or 1.3")
+	fl.StringVar(&flags.TLSCipherSuites, "tls-cipher-suites", "", "comma separated TLS 1.2 cipher suites of the webhook server, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, the Go defaults when empty")
+	fl.StringVar(&flags.ClientCAFile, "client-ca-file", "", "CA verifying the client certificates the webhook server then requires, e.g. that of the API server")
+	fl.StringVar(&flags.TLSSecret, "tls-secret", "", "name, or namespace/name, of a kubernetes.io/tls Secret of the webhook namespace read through the API instead of -tls-cert-file")
+	fl.StringVar(&flags.TLSCAFile, "tls-ca-file", "", "CA of the TLS certificate injected with -inject-ca-bundle, defaults to the last certificate of the chain")
+	fl.StringVar(&flags.InjectCABundle, "inject-ca-bundle", "", "name of the ValidatingWebhookConfiguration whose caBundle is set to the CA of the TLS certificate on startup")
+	fl.BoolVar(&flags.SelfRegister, "self-register", false, "create or update the ValidatingWebhookConfiguration of the served webhooks on startup")
+	fl.StringVar(&flags.WebhookName, "webhook-name", "kubesec-webhook", "name of the ValidatingWebhookConfiguration created by -self-register")
+	fl.StringVar(&flags.WebhookService, "webhook-service", "kubesec-webhook", "name, or namespace/name, of the Service in front of the webhook")
+	fl.IntVar(&flags.WebhookPort, "webhook-port", 443, "port of the Service in front of the webhook")
+	fl.StringVar(&flags.WebhookSelector, "webhook-namespace-selector", "kubesec-validation=enabled", "label selector of the namespaces whose objects are sent to the self-registered webhooks")
+	fl.StringVar(&flags.WebhookFailurePolicy, "webhook-failure-policy", "Fail", "failure policy of the self-registered webhooks: Fail or Ignore")
+	fl.DurationVar(&flags.WebhookTimeout, "webhook-timeout", 15*time.Second, "timeout of the self-registered webhooks")
+	fl.BoolVar(&flags.TLSSelfSigned, "tls-self-signed", false, "serve a self-signed certificate generated on startup instead of -tls-cert-file, for local development")
+	fl.StringVar(&flags.TLSSelfSignedSANs, "tls-self-signed-sans", "localhost,127.0.0.1,kubesec-webhook.kubesec.svc", "comma separated DNS names and IP addresses of the self-signed certificate")
+	fl.StringVar(&flags.Kubeconfig, "kubeconfig", "", "path to a kubeconfig, only required when running out of cluster")
+	fl.BoolVar(&flags.LeaderElect, "leader-elect", false, "enable leader election for the controllers running next to the webhooks")
+	fl.StringVar(&flags.PolicyConfigMap, "policy-configmap", "", "name, or namespace/name, of a ConfigMap of the webhook namespace whose policy settings are applied live")
+	fl.DurationVar(&flags.PolicyConfigMapRefresh, "policy-configmap-refresh", 10*time.Second, "interval between two reads of the policy ConfigMap")
+	fl.StringVar(&flags.LeaderElectionNamespace, "leader-election-namespace", "", "namespace holding the leader election lease, defaults to the pod namespace")
+	fl.DurationVar(&flags.LeaseDuration, "leader-election-lease-duration", 15*time.Second, "how long the replicas wait before taking over the leader election lease of a leader gone")
+	fl.DurationVar(&flags.RenewDeadline, "leader-election-renew-deadline", 10*time.Second, "how long the leader retries renewing its lease before giving it up")
+	fl.DurationVar(&flags.RetryPeriod, "leader-election-retry-period", 2*time.Second, "interval between two attempts to acquire or renew the leader election lease")
+	fl.StringVar(&flags.SMTPHost, "smtp-host", "", "SMTP server used to email decision summaries, each replica emails the summary of its own decisions, reports are disabled when empty")
+	fl.IntVar(&flags.SMTPPort, "smtp-port", 587, "SMTP server port")
+	fl.StringVar(&flags.SMTPUsername, "smtp-username", "", "SMTP username, authentication is disabled when empty")
+	fl.StringVar(&flags.SMTPPasswordFile, "smtp-password-file", "", "file containing the SMTP password")
+	fl.StringVar(&flags.SMTPFrom, "smtp-from", "", "sender address of the summary emails")
+	fl.StringVar(&flags.SMTPTo, "smtp-to", "", "comma separated recipients of the summary emails")
+	fl.StringVar(&flags.SMTPTLSMode, "smtp-tls", report.TLSModeStartTLS, "SMTP transport security: none, starttls or tls")
+	fl.StringVar(&flags.ReportClusterName, "report-cluster-name", "kubernetes", "cluster name shown in the summary emails")
+	fl.DurationVar(&flags.ReportInterval, "report-interval", 24*time.Hour, "interval between two summary emails")
+	fl.StringVar(&flags.StreamTokenFile, "stream-token-file", "", "file containing the bearer token of the /decisions/stream endpoint, disabled when empty")
+	fl.StringVar(&flags.ScanAPITokenFile, "scan-api-token-file", "", "file containing the bearer token of the /scan and /scan/batch endpoints reviewing the posted manifests, disabled when empty")
+	fl.StringVar(&flags.GRPCListenAddress, "grpc-listen-address", "", "listen address of the Scanner gRPC API, e.g. :9443, served with the webhook certificate to the clients of the scan API token, disabled when empty")
+	fl.BoolVar(&flags.DenialEvents, "denial-events", false, "record a Warning Event of the denied object for every denial")
+	fl.BoolVar(&flags.PolicyReports, "policy-reports", false, "keep a wgpolicyk8s.io PolicyReport of every reviewed object up to date with its latest scan")
+	fl.BoolVar(&flags.ScanResults, "scan-results", false, "write a KubesecScanResult of every decision in the namespace of the workload, their CRD must be installed")
+	fl.DurationVar(&flags.RescanInterval, "rescan-interval", 0, "interval between two rescans of the existing workloads, Deployments, DaemonSets, StatefulSets, ReplicaSets, Jobs, CronJobs, Knative Services and Pods, reported without blocking anything, disabled when 0")
+	fl.IntVar(&flags.ScanResultsHistory, "scan-results-history", 10, "number of KubesecScanResults kept per workload, the oldest are deleted")
+	fl.StringVar(&flags.AuditLogFile, "audit-log-file", "", "JSON lines file every decision is appended to, apart from the logs, disabled when empty")
+	fl.IntVar(&flags.AuditLogMaxSize, "audit-log-max-size", 100, "size in MiB past which the audit log is rotated, unlimited when 0")
+	fl.DurationVar(&flags.AuditLogMaxAge, "audit-log-max-age", 24*time.Hour, "age past which the audit log is rotated, unlimited when 0")
+	fl.IntVar(&flags.AuditLogMaxBackups, "audit-log-max-backups", 0, "number of rotated audit logs kept, all of them when 0")
+	fl.StringVar(&flags.HistoryDriver, "history-driver", history.DriverSQLite, "database of the scan history: sqlite or postgres")
+	fl.StringVar(&flags.HistoryDSN, "history-dsn", "", "SQLite file or Postgres connection string of the database every scan is recorded in, disabled when empty")
+	fl.StringVar(&flags.HistoryAPITokenFile, "history-api-token-file", "", "file containing the bearer token of the /api/v1/results endpoint querying the scan history, disabled when empty")
+	fl.StringVar(&flags.DashboardTokenFile, "dashboard-token-file", "", "file containing the token of the /dashboard web page, as bearer token or basic authentication password, disabled when empty")
+	fl.IntVar(&flags.DashboardDecisions, "dashboard-decisions", 1000, "number of recent decisions kept by the dashboard")
+	fl.StringVar(&flags.SyslogAddress, "syslog-address", "", "host:port of the syslog server the decisions are sent to over TCP, disabled when empty")
+	fl.BoolVar(&flags.SyslogTLS, "syslog-tls", false, "connect to the syslog server over TLS")
+	fl.StringVar(&flags.SyslogCAFile, "syslog-ca-file", "", "CA bundle verifying the syslog server certificate, the system roots when empty")
+	fl.StringVar(&flags.SyslogFacility, "syslog-facility", "local0", "facility of the syslog messages")
+	fl.StringVar(&flags.SyslogSeverities, "syslog-severities", "", "comma separated outcome=severity pairs overriding the severities of the syslog messages, e.g. allowed=none,denied=crit")
+	fl.StringVar(&flags.HookExec, "hook-exec", "", "binary run after every decision with the decision JSON on its standard input")
+	fl.StringVar(&flags.HookURL, "hook-url", "", "endpoint the decision JSON is posted to after every decision")
+	fl.StringVar(&flags.HookWebhooksFile, "hook-webhooks-file", "", "YAML file of the outbound webhooks posting templated, signed payloads of the decisions, with retries")
+	fl.StringVar(&flags.KafkaBrokers, "kafka-brokers", "", "comma separated Kafka brokers the decisions are published to, disabled when empty")
+	fl.StringVar(&flags.KafkaTopic, "kafka-topic", "kubesec-decisions", "Kafka topic the decisions are published to")
+	fl.BoolVar(&flags.KafkaTLS, "kafka-tls", false, "connect to the Kafka brokers over TLS")
+	fl.StringVar(&flags.NATSURL, "nats-url", "", "URL of the NATS server the decisions are published to, disabled when empty")
+	fl.StringVar(&flags.NATSSubject, "nats-subject", "kubesec.decisions", "NATS subject the decisions are published to")
+	fl.StringVar(&flags.CloudEventsSink, "cloudevents-sink", "", "URL the decisions are posted to as CloudEvents, e.g. of a Knative broker, disabled when empty")
+	fl.StringVar(&flags.CloudEventsSource, "cloudevents-source", hook.DefaultEventSource, "source of the CloudEvents of the decisions")
+	fl.Float64Var(&flags.HookRate, "hook-rate", 10, "maximum number of decisions per second handed to the hooks")
+	fl.DurationVar(&flags.HookTimeout, "hook-timeout", 10*time.Second, "timeout of the hooks of a decision")
+	fl.IntVar(&flags.EscalationThreshold, "escalation-threshold", 0, "open a ticket when a workload is denied more than this many times within the escalation window, disabled when 0")
+	fl.DurationVar(&flags.EscalationWindow, "escalation-window", time.Hour, "window the denials of a workload are counted in")
+	fl.StringVar(&flags.EscalationTracker, "escalation-tracker", "jira", "issue tracker escalations are filed in: jira or rest")
+	fl.StringVar(&flags.EscalationURL, "escalation-url", "", "base URL of the Jira instance, or endpoint of the generic REST tracker")
+	fl.StringVar(&flags.JiraProject, "jira-project", "", "key of the Jira project escalations are opened in")
+	fl.StringVar(&flags.JiraIssueType, "jira-issue-type", "Bug", "type of the Jira issues opened")
+	fl.StringVar(&flags.JiraUsername, "jira-username", "", "Jira user opening the issues")
+	fl.StringVar(&flags.JiraTokenFile, "jira-token-file", "", "file containing the Jira API token")
 
 	if err := fl.Parse(os.Args[1:]); err != nil {
 		fmt.Fprintf(os.Stderr, "%s", err)
 		os.Exit(1)
 	}
+	if err := loadEnv(fl); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+	if flags.Config != "" {
+		if err := loadConfig(fl, flags.Config); err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+			os.Exit(1)
+		}
+	}
+	flags.EffectiveConfig = effectiveConfig(fl)
 
 	return flags
 }
 
+// registerPolicyFlags registers the flags deciding the verdicts, they are
+// shared by the webhook and the replay command.
+func registerPolicyFlags(fl *flag.FlagSet, flags *Flags) {
+	transport := scanner.DefaultTransportConfig()
+	fl.IntVar(&flags.MinScore, "min-score", 0, "Kubesec.io minimum score to validate against")
+	fl.BoolVar(&flags.NamespaceMinScore, "namespace-min-score", false, "override the minimum score with the kubesec.io/min-score annotation of the namespaces, read through an informer")
+	fl.Var(optionalIntFlag{&flags.WarnScore}, "warn-score", "Kubesec.io score below which allowed objects get an admission warning, none when unset")
+	fl.StringVar(&flags.Scanner, "scanner", scannerRemote, "how definitions are scored: remote with the Kubesec API, embedded in process with the Kubesec ruleset, or sidecar with a kubesec container of the pod")
+	fl.StringVar(&flags.SidecarAddress, "sidecar-address", "127.0.0.1:8090", "loopback address of the kubesec sidecar, e.g. running kubesec http 8090")
+	fl.DurationVar(&flags.SidecarStartupTimeout, "sidecar-startup-timeout", time.Minute, "how long to wait for the kubesec sidecar to be healthy on startup")
+	fl.StringVar(&flags.KubesecURL, "kubesec-url", envOr(kubesecURLEnv, webhook.DefaultScanURL), "URL of the Kubesec API the definitions are scanned with, e.g. a self-hosted instance, defaults to $"+kubesecURLEnv)
+	fl.StringVar(&flags.ImagePolicyFile, "image-policy-file", "", "YAML file overriding the minimum score and required checks per image pattern")
+	fl.StringVar(&flags.RuleWeightsFile, "rule-weights-file", "", "YAML file overriding the points of Kubesec checks in the effective score")
+	fl.StringVar(&flags.RegoPolicyFile, "rego-policy-file", "", "Rego module whose data.kubesec.decision rule allows, denies or warns about the scanned objects, in place of their scan")
+	fl.Var((*filesFlag)(&flags.WASMPlugins), "wasm-plugin", "WASI module adding its checks to the scans, reading the object and its scan on stdin and writing its findings on stdout, can be repeated")
+	fl.DurationVar(&flags.WASMPluginTimeout, "wasm-plugin-timeout", 250*time.Millisecond, "how long a WASM plugin may check an object before the scan fails")
+	fl.StringVar(&flags.DecisionExpressionsFile, "decision-expressions-file", "", "YAML file of CEL expressions allowing, denying or warning about the scanned objects they match, in place of their scan")
+	fl.StringVar(&flags.PolicyBundle, "policy-bundle", "", "OCI reference of a bundle holding the image policy, e.g. ghcr.io/org/kubesec-policy:v1 or pinned by @sha256 digest")
+	fl.DurationVar(&flags.PolicyBundleRefresh, "policy-bundle-refresh", 5*time.Minute, "interval between two pulls of a policy bundle referenced by tag")
+	fl.StringVar(&flags.PolicyBundleUsername, "policy-bundle-username", "", "username authenticating to the policy bundle registry")
+	fl.StringVar(&flags.PolicyBundlePasswordFile, "policy-bundle-password-file", "", "file containing the password of the policy bundle registry")
+	fl.BoolVar(&flags.PolicyBundlePlainHTTP, "policy-bundle-plain-http", false, "pull the policy bundle without TLS")
+	fl.StringVar(&flags.ExcludeNamespaces, "exclude-namespaces", strings.Join(webhook.DefaultExcludeNamespaces, ","), "comma separated namespaces whose objects are never scanned nor denied, none when empty")
+	fl.StringVar(&flags.TrustedUsers, "trusted-users", "", "comma separated users, e.g. system:serviceaccount:ops:deployer, whose requests are never scanned nor denied")
+	fl.StringVar(&flags.TrustedGroups, "trusted-groups", "", "comma separated groups whose requests are never scanned nor denied")
+	fl.StringVar(&flags.ExemptPriorityClasses, "exempt-priority-classes", strings.Join(webhook.DefaultExemptPriorityClasses, ","), "comma separated priority classes of the workloads never denied, none when empty")
+	fl.Var((*podTemplatePathsFlag)(&flags.PodTemplatePaths), "pod-template-path", "group/version/Kind=jsonpath of the pod template of a custom resource served on "+customResourcePath+", can be repeated")
+	fl.StringVar(&flags.ExemptionMode, "exemption-mode", webhook.ExemptionAllow, "how exempted workloads are handled: allow without scanning, or warn when they would be denied")
+	fl.StringVar(&flags.RequiredChecks, "required-checks", "", "comma separated Kubesec checks, e.g. ReadOnlyRootFilesystem,RunAsNonRoot, every object must pass whatever its score")
+	fl.StringVar(&flags.DenyRules, "deny-rules", "", "comma separated critical Kubesec checks, e.g. Privileged,HostNetwork, denying the objects failing them whatever their score")
+	fl.StringVar(&flags.SkipSelector, "skip-selector", "", "label selector, e.g. kubesec.io/enforce=false, of the objects opting out of scanning, none when empty")
+	fl.StringVar(&flags.SkipNamespaces, "skip-namespaces", "", "comma separated namespaces whose workloads may opt out of scanning with the kubesec.io/skip annotation, any when empty")
+	fl.StringVar(&flags.FailureMode, "failure-mode", webhook.FailOpen, "fate of the objects that could not be scanned: open allows them, closed denies them")
+	fl.StringVar(&flags.Enforcement, "enforcement", webhook.EnforcementEnforce, "enforce denies the objects falling short of the admission bar, audit only warns about them")
+	fl.StringVar(&flags.DenyDetail, "deny-message-detail", webhook.DenyDetailFull, "detail of the denial messages: full appends the scan result, summary the failed critical checks, minimal only gives the reasons")
+	fl.IntVar(&flags.DenyMessageMaxLength, "deny-message-max-length", 0, "maximum length in bytes of the denial messages, longer ones are truncated, 0 disables the cap")
+	fl.BoolVar(&flags.GrandfatherUpdates, "grandfather-updates", false, "allow the updates of objects scoring below the minimum score when their score does not decrease")
+	fl.BoolVar(&flags.SkipUnchangedUpdates, "skip-unchanged-updates", false, "allow without scanning the updates leaving the pod template unchanged, e.g. scaling")
+	fl.BoolVar(&flags.KubesecPolicies, "kubesec-policies", false, "apply the KubesecPolicy cluster policies, their CRD must be installed")
+	fl.BoolVar(&flags.KubesecExemptions, "kubesec-exemptions", false, "apply the KubesecExemption exemptions, their CRD must be installed")
+	fl.DurationVar(&flags.ExemptionExpiryWarning, "exemption-expiry-warning", 7*24*time.Hour, "warn about the KubesecExemptions expiring within this duration")
+	fl.IntVar(&flags.ScanCacheSize, "scan-cache-size", 1024, "number of scan results cached by definition hash, disabled when 0")
+	fl.DurationVar(&flags.ScanCacheTTL, "scan-cache-ttl", 5*time.Minute, "how long a scan result is cached")
+	fl.IntVar(&flags.ScanRetry.Retries, "scan-retries", 2, "retries of a scan after a network error or a 5xx response of the kubesec backend")
+	fl.DurationVar(&flags.ScanRetry.Backoff, "scan-retry-backoff", 200*time.Millisecond, "delay before the first retry of a scan, doubled on every retry")
+	fl.DurationVar(&flags.ScanRetry.MaxBackoff, "scan-retry-max-backoff", 2*time.Second, "maximum delay between two retries of a scan")
+	fl.Float64Var(&flags.ScanRetry.Jitter, "scan-retry-jitter", 0.2, "fraction of the retry delays randomized, between 0 and 1")
+	fl.IntVar(&flags.ScanBreakerThreshold, "scan-breaker-threshold", 5, "consecutive failed scans opening the circuit breaker, scans then fail fast according to the failure mode, disabled when 0")
+	fl.DurationVar(&flags.ScanBreakerCooldown, "scan-breaker-cooldown", 30*time.Second, "how long the circuit breaker stays open before probing the kubesec backend")
+	fl.StringVar(&flags.RedisAddress, "redis-address", "", "host:port of a Redis server sharing the scan results between the replicas, disabled when empty")
+	fl.StringVar(&flags.RedisUsername, "redis-username", "", "Redis ACL username")
+	fl.StringVar(&flags.RedisPasswordFile, "redis-password-file", "", "file containing the Redis password, authentication is disabled when empty")
+	fl.IntVar(&flags.RedisDB, "redis-db", 0, "Redis database the scan results are stored in")
+	fl.BoolVar(&flags.RedisTLS, "redis-tls", false, "connect to Redis over TLS")
+	fl.StringVar(&flags.RedisCAFile, "redis-ca-file", "", "CA bundle verifying the Redis server certificate, the system roots when empty")
+	fl.StringVar(&flags.PeerService, "peer-service", "", "headless Service resolving to the replicas the scan results and circuit breaker state are synced with instead of Redis, e.g. kubesec-webhook-peers.kubesec.svc, disabled when empty")
+	fl.StringVar(&flags.PeerAddresses, "peer-addresses", "", "comma separated host:port of replicas synced with, apart from those of -peer-service")
+	fl.IntVar(&flags.PeerPort, "peer-port", 0, "webhook server port of the replicas of -peer-service, the port of -listen-address when 0")
+	fl.StringVar(&flags.PeerSelf, "peer-self", envOr("POD_IP", ""), "address of this replica, not synced with, defaults to $POD_IP")
+	fl.StringVar(&flags.PeerTokenFile, "peer-token-file", "", "file containing the bearer token the replicas present to each other on /peers/sync")
+	fl.StringVar(&flags.PeerServerName, "peer-server-name", "", "name the webhook certificate of the replicas is verified for, the DNS name of -webhook-service when empty")
+	fl.DurationVar(&flags.PeerRefresh, "peer-refresh", 30*time.Second, "interval of the resolution of -peer-service")
+	fl.IntVar(&flags.ScanTransport.MaxIdleConnsPerHost, "scan-max-idle-conns-per-host", transport.MaxIdleConnsPerHost, "idle connections kept open to the kubesec backend")
+	fl.DurationVar(&flags.ScanTransport.IdleConnTimeout, "scan-idle-conn-timeout", transport.IdleConnTimeout, "how long an idle connection to the kubesec backend is kept open")
+	fl.DurationVar(&flags.ScanTransport.TLSHandshakeTimeout, "scan-tls-handshake-timeout", transport.TLSHandshakeTimeout, "timeout of the TLS handshake with the kubesec backend")
+	fl.DurationVar(&flags.ScanTransport.KeepAlive, "scan-keep-alive", transport.KeepAlive, "interval of the TCP keep-alive probes to the kubesec backend, negative disables them")
+	fl.BoolVar(&flags.ScanTransport.DisableKeepAlives, "scan-disable-keep-alives", transport.DisableKeepAlives, "open a new connection to the kubesec backend for every scan")
+}
+
 type Main struct {
 	flags  *Flags
 	logger log.Logger
-	stopC  chan struct{}
 }
 
 // Run will run the main program.
 func (m *Main) Run() error {
 
-	m.logger = &log.Std{
-		Debug: m.flags.Debug,
-	}
-
-	// Register metrics
-	promReg := prometheus.NewRegistry()
-	metricsRec := metrics.NewPrometheus(promReg)
-
-	// Create webhooks
-	pw, err := webhook.NewPodWebhook(m.flags.MinScore, metricsRec, m.logger)
+	logger, err := logging.New(m.flags.LogFormat, os.Stderr, m.flags.Debug)
 	if err != nil {
 		return err
 	}
-	pwd, err := whhttp.HandlerFor(pw)
+	m.logger = logger
+	zapOpts := []zap.Opts{zap.UseDevMode(m.flags.Debug)}
+	if m.flags.LogFormat == logging.FormatJSON {
+		zapOpts = append(zapOpts, zap.JSONEncoder())
+	}
+	ctrl.SetLogger(zap.New(zapOpts...))
+	m.logger.Infof("effective configuration: %s", strings.Join(m.flags.EffectiveConfig, " "))
+
+	restCfg, err := m.restConfig()
 	if err != nil {
 		return err
 	}
-	vdw, err := webhook.NewDeploymentWebhook(m.flags.MinScore, metricsRec, m.logger)
+
+	if m.flags.TLSSelfSigned && m.flags.TLSSecret != "" {
+		return fmt.Errorf("self-signed certificate and TLS secret are mutually exclusive")
+	}
+	if m.flags.GRPCListenAddress != "" && m.flags.ScanAPITokenFile == "" {
+		return fmt.Errorf("gRPC scan API needs a scan API token file")
+	}
+	peers := m.flags.PeerService != "" || m.flags.PeerAddresses != ""
+	if peers && m.flags.RedisAddress != "" {
+		return fmt.Errorf("peer sync and Redis are mutually exclusive")
+	}
+	if peers && m.flags.PeerTokenFile == "" {
+		return fmt.Errorf("peer sync needs a peer token file")
+	}
+	if m.flags.HistoryAPITokenFile != "" && m.flags.HistoryDSN == "" {
+		return fmt.Errorf("history API needs a history database")
+	}
+	if m.flags.TLSSelfSigned {
+		if err := m.selfSigned(); err != nil {
+			return err
+		}
+	}
+	// The manager is not created yet, its client needs the webhook server.
+	var apiClient client.Client
+	if m.flags.TLSSecret != "" || m.flags.InjectCABundle != "" || m.flags.SelfRegister || m.flags.PolicyReports || m.flags.ScanResults {
+		if apiClient, err = client.New(restCfg, client.Options{}); err != nil {
+			return err
+		}
+	}
+	var secretSync *certs.SecretSync
+	if m.flags.TLSSecret != "" {
+		if secretSync, err = m.tlsSecret(apiClient); err != nil {
+			return err
+		}
+	}
+	if m.flags.SelfRegister {
+		if err := m.selfRegister(apiClient); err != nil {
+			return err
+		}
+	}
+	if m.flags.InjectCABundle != "" {
+		if err := m.injectCABundle(apiClient); err != nil {
+			return err
+		}
+	}
+	reloader, err := certs.NewReloader(m.flags.CertFile, m.flags.KeyFile, time.Minute, m.logger)
 	if err != nil {
 		return err
 	}
-	vdwh, err := whhttp.HandlerFor(vdw)
+	whServer, err := m.webhookServer(reloader)
 	if err != nil {
 		return err
 	}
-	dw, err := webhook.NewDaemonSetWebhook(m.flags.MinScore, metricsRec, m.logger)
+
+	if m.flags.LeaderElect && !(m.flags.LeaseDuration > m.flags.RenewDeadline && m.flags.RenewDeadline > m.flags.RetryPeriod && m.flags.RetryPeriod > 0) {
+		return fmt.Errorf("leader election lease duration must exceed the renew deadline, which must exceed the retry period")
+	}
+	if !m.flags.LeaderElect && m.flags.RescanInterval > 0 {
+		m.logger.Warningf("every replica rescans the workloads, enable -leader-elect when running several replicas")
+	}
+
+	grace := m.flags.ShutdownTimeout
+	mgr, err := ctrl.NewManager(restCfg, manager.Options{
+		MetricsBindAddress:      m.flags.MetricsListenAddress,
+		HealthProbeBindAddress:  m.flags.HealthListenAddress,
+		LeaderElection:          m.flags.LeaderElect,
+		LeaderElectionID:        leaderElectionID,
+		LeaderElectionNamespace: m.flags.LeaderElectionNamespace,
+		// The lease is released on shutdown, once the leader-only tasks are
+		// stopped, so a replica takes over without waiting for it to expire.
+		LeaderElectionReleaseOnCancel: true,
+		LeaseDuration:                 &m.flags.LeaseDuration,
+		RenewDeadline:                 &m.flags.RenewDeadline,
+		RetryPeriod:                   &m.flags.RetryPeriod,
+		WebhookServer:                 whServer,
+		GracefulShutdownTimeout:       &grace,
+	})
 	if err != nil {
-		return err
+		return fmt.Errorf("could not create manager: %w", err)
 	}
-	dwd, err := whhttp.HandlerFor(dw)
+
+	metricsRec, kubesecRec, err := m.metricsRecorders(mgr)
 	if err != nil {
 		return err
 	}
-	sw, err := webhook.NewStatefulSetWebhook(m.flags.MinScore, metricsRec, m.logger)
+
+	ctx, draining := drain(ctrl.SetupSignalHandler(), m.flags.ShutdownDelay, m.logger)
+
+	opts, refresher, err := m.policyOptions(ctx, kubesecRec)
 	if err != nil {
 		return err
 	}
-	swd, err := whhttp.HandlerFor(sw)
-	if err != nil {
+	if err := mgr.Add(reloader); err != nil {
 		return err
 	}
-	errC := make(chan error)
+	if secretSync != nil {
+		if err := mgr.Add(secretSync); err != nil {
+			return err
+		}
+	}
 
-	// Serve webhooks
-	go func() {
+	// The manager cache starts a namespace informer on the first lookup, only
+	// the features reading the namespaces need it.
+	if opts.NamespaceMinScore || m.flags.KubesecPolicies || opts.DecisionPolicy != nil {
+		opts.Namespaces = mgr.GetCache()
+	}
+	if m.flags.KubesecPolicies {
+		opts.Policies = policy.KubesecPolicies{Reader: mgr.GetCache()}
+	}
+	if m.flags.PolicyConfigMap != "" {
+		watcher, err := m.policyConfigMap(ctx, opts, mgr.GetAPIReader())
+		if err != nil {
+			return err
+		}
+		policies := webhook.PolicyListers{watcher}
+		if opts.Policies != nil {
+			policies = append(policies, opts.Policies)
+		}
+		opts.Policies = policies
+		if err := mgr.Add(watcher); err != nil {
+			return err
+		}
+	}
+	if m.flags.KubesecExemptions {
+		exemptions := policy.KubesecExemptions{Reader: mgr.GetCache()}
+		opts.Exemptions = exemptions
+		monitor, err := policy.NewExemptionMonitor(exemptions, time.Minute, m.flags.ExemptionExpiryWarning, kubesecRec, m.logger)
+		if err != nil {
+			return err
+		}
+		if err := mgr.Add(monitor); err != nil {
+			return err
+		}
+	}
+	if refresher != nil {
+		if err := mgr.Add(refresher); err != nil {
+			return err
+		}
+	}
 
-		m.logger.Infof("webhooks listening on %s...", m.flags.ListenAddress)
-		mux := http.NewServeMux()
-		mux.Handle("/pod", pwd)
-		mux.Handle("/deployment", vdwh)
-		mux.Handle("/daemonset", dwd)
-		mux.Handle("/statefulset", swd)
-		errC <- http.ListenAndServeTLS(
-			m.flags.ListenAddress,
-			m.flags.CertFile,
-			m.flags.KeyFile,
-			mux,
-		)
-	}()
+	var sinks decision.Sinks
+	if m.flags.SMTPHost != "" {
+		reporter, summary, err := m.smtpReporter()
+		if err != nil {
+			return err
+		}
+		if err := mgr.Add(reporter); err != nil {
+			return err
+		}
+		sinks = append(sinks, summary)
+	}
+	if m.flags.EscalationThreshold > 0 {
+		escalator, err := m.escalator()
+		if err != nil {
+			return err
+		}
+		if err := mgr.Add(escalator); err != nil {
+			return err
+		}
+		sinks = append(sinks, escalator)
+	}
+	if m.flags.DenialEvents {
+		sinks = append(sinks, event.NewRecorder(mgr.GetEventRecorderFor("kubesec-webhook")))
+	}
+	if m.flags.PolicyReports {
+		reporter := policyreport.NewReporter(apiClient, m.logger)
+		if err := mgr.Add(reporter); err != nil {
+			return err
+		}
+		sinks = append(sinks, reporter)
+	}
+	if m.flags.ScanResults {
+		writer, err := scanresult.NewWriter(apiClient, m.flags.ScanResultsHistory, m.logger)
+		if err != nil {
+			return err
+		}
+		if err := mgr.Add(writer); err != nil {
+			return err
+		}
+		sinks = append(sinks, writer)
+	}
+	if m.flags.StreamTokenFile != "" {
+		token, err := os.ReadFile(m.flags.StreamTokenFile)
+		if err != nil {
+			return fmt.Errorf("could not read stream token: %w", err)
+		}
+		broker, err := stream.NewBroker(strings.TrimSpace(string(token)))
+		if err != nil {
+			return err
+		}
+		whServer.Register("/decisions/stream", broker)
+		sinks = append(sinks, broker)
+	}
+	if m.flags.HookExec != "" || m.flags.HookURL != "" || m.flags.HookWebhooksFile != "" || m.flags.CloudEventsSink != "" {
+		var hooks []hook.Hook
+		if m.flags.HookExec != "" {
+			hooks = append(hooks, &hook.Exec{Path: m.flags.HookExec})
+		}
+		if m.flags.HookURL != "" {
+			hooks = append(hooks, &hook.HTTP{URL: m.flags.HookURL, Client: &http.Client{Timeout: m.flags.HookTimeout}})
+		}
+		if m.flags.CloudEventsSink != "" {
+			hooks = append(hooks, &hook.CloudEvent{Sink: m.flags.CloudEventsSink, Source: m.flags.CloudEventsSource, Client: &http.Client{Timeout: m.flags.HookTimeout}})
+		}
+		if m.flags.HookWebhooksFile != "" {
+			whs, err := hook.LoadWebhooks(m.flags.HookWebhooksFile)
+			if err != nil {
+				return fmt.Errorf("could not load webhooks: %w", err)
+			}
+			for _, wh := range whs {
+				wh.Client = &http.Client{Timeout: m.flags.HookTimeout}
+				hooks = append(hooks, wh)
+			}
+		}
+		dispatcher, err := hook.NewDispatcher(hooks, m.flags.HookRate, m.flags.HookTimeout, m.logger)
+		if err != nil {
+			return err
+		}
+		if err := mgr.Add(dispatcher); err != nil {
+			return err
+		}
+		sinks = append(sinks, dispatcher)
+	}
+	if m.flags.AuditLogFile != "" {
+		auditLog, err := auditlog.NewWriter(m.flags.AuditLogFile, int64(m.flags.AuditLogMaxSize)<<20, m.flags.AuditLogMaxAge, m.flags.AuditLogMaxBackups)
+		if err != nil {
+			return err
+		}
+		if err := mgr.Add(auditLog); err != nil {
+			return err
+		}
+		sinks = append(sinks, auditLog)
+	}
+	if m.flags.DashboardTokenFile != "" {
+		token, err := os.ReadFile(m.flags.DashboardTokenFile)
+		if err != nil {
+			return fmt.Errorf("could not read dashboard token: %w", err)
+		}
+		board, err := dashboard.New(m.policySettings(), m.flags.DashboardDecisions, strings.TrimSpace(string(token)))
+		if err != nil {
+			return err
+		}
+		whServer.Register("/dashboard", board)
+		sinks = append(sinks, board)
+	}
+	if m.flags.HistoryDSN != "" {
+		store, err := history.Open(ctx, m.flags.HistoryDriver, m.flags.HistoryDSN, m.logger)
+		if err != nil {
+			return err
+		}
+		if err := mgr.Add(store); err != nil {
+			return err
+		}
+		sinks = append(sinks, store)
 
-	// Serve metrics.
-	metricsHandler := promhttp.HandlerFor(promReg, promhttp.HandlerOpts{})
-	go func() {
-		m.logger.Infof("metrics listening on %s...", m.flags.MetricsListenAddress)
-		errC <- http.ListenAndServe(m.flags.MetricsListenAddress, metricsHandler)
-	}()
+		if m.flags.HistoryAPITokenFile != "" {
+			token, err := os.ReadFile(m.flags.HistoryAPITokenFile)
+			if err != nil {
+				return fmt.Errorf("could not read history API token: %w", err)
+			}
+			h, err := history.NewHandler(store, strings.TrimSpace(string(token)))
+			if err != nil {
+				return err
+			}
+			whServer.Register("/api/v1/results", h)
+		}
+	}
+	if m.flags.SyslogAddress != "" {
+		syslogWriter, err := m.syslogWriter()
+		if err != nil {
+			return err
+		}
+		if err := mgr.Add(syslogWriter); err != nil {
+			return err
+		}
+		sinks = append(sinks, syslogWriter)
+	}
+	if m.flags.KafkaBrokers != "" {
+		var tlsConfig *tls.Config
+		if m.flags.KafkaTLS {
+			tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		kafka, err := publish.NewKafka(strings.Split(m.flags.KafkaBrokers, ","), m.flags.KafkaTopic, tlsConfig)
+		if err != nil {
+			return err
+		}
+		publisher := publish.NewPublisher(kafka, m.logger)
+		if err := mgr.Add(publisher); err != nil {
+			return err
+		}
+		sinks = append(sinks, publisher)
+	}
+	if m.flags.NATSURL != "" {
+		nats, err := publish.NewNATS(m.flags.NATSURL, m.flags.NATSSubject)
+		if err != nil {
+			return err
+		}
+		publisher := publish.NewPublisher(nats, m.logger)
+		if err := mgr.Add(publisher); err != nil {
+			return err
+		}
+		sinks = append(sinks, publisher)
+	}
+	if len(sinks) > 0 {
+		opts.Sink = sinks
+	}
+	if m.flags.RescanInterval > 0 {
+		rescanner, err := rescan.NewRescanner(mgr.GetAPIReader(), opts, m.flags.MinScore, m.flags.RescanInterval, kubesecRec, m.logger)
+		if err != nil {
+			return err
+		}
+		if err := mgr.Add(rescanner); err != nil {
+			return err
+		}
+	}
+	if m.flags.ScanAPITokenFile != "" {
+		if err := m.registerScanAPI(mgr, whServer, reloader, opts); err != nil {
+			return err
+		}
+	}
+	if peers {
+		if err := m.registerPeerSync(mgr, whServer, opts); err != nil {
+			return err
+		}
+	}
 
-	// Run everything
-	defer m.stop()
+	if err := m.registerWebhooks(whServer, opts, metricsRec); err != nil {
+		return err
+	}
 
-	sigC := m.createSignalChan()
-	select {
-	case err := <-errC:
+	if m.flags.EnablePprof {
+		if err := addPprofHandlers(mgr); err != nil {
+			return err
+		}
+	}
+	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		return err
+	}
+	if err := mgr.AddReadyzCheck("webhook", whServer.StartedChecker()); err != nil {
+		return err
+	}
+	if err := mgr.AddReadyzCheck("shutdown", draining); err != nil {
+		return err
+	}
+	if m.flags.ScannerProbe || m.flags.ReadyzScanner {
+		probe, err := scanner.NewProbe(opts.Scanner, m.flags.ReadyzScannerInterval, m.flags.Scanner, kubesecRec)
 		if err != nil {
-			m.logger.Errorf("error received: %s", err)
 			return err
 		}
-		m.logger.Infof("app finished successfuly")
-	case s := <-sigC:
-		m.logger.Infof("signal %s received", s)
-		return nil
+		if err := mgr.Add(probe); err != nil {
+			return err
+		}
+		if m.flags.ReadyzScanner {
+			if err := mgr.AddReadyzCheck("kubesec-scanner", func(r *http.Request) error { return probe.Check(r.Context()) }); err != nil {
+				return err
+			}
+		}
+	}
+	if sidecar, ok := unwrapScanner(opts.Scanner).(*scanner.Sidecar); ok {
+		if err := mgr.AddReadyzCheck("kubesec-sidecar", func(r *http.Request) error { return sidecar.Healthy(r.Context()) }); err != nil {
+			return err
+		}
+	}
+
+	m.logger.Infof("webhooks listening on %s...", m.flags.ListenAddress)
+	m.logger.Infof("metrics listening on %s...", m.flags.MetricsListenAddress)
+
+	// Run everything until a termination signal is received.
+	if err := mgr.Start(ctx); err != nil {
+		m.logger.Errorf("error received: %s", err)
+		return err
 	}
+	m.logger.Infof("app finished successfuly")
 
 	return nil
 }
 
-func (m *Main) stop() {
-	m.logger.Infof("stopping everything, waiting %s...", gracePeriod)
-
-	close(m.stopC)
+// policySettings returns the effective values of the flags deciding the
+// verdicts.
+func (m *Main) policySettings() []dashboard.Setting {
+	fl := flag.NewFlagSet("policy", flag.ContinueOnError)
+	registerPolicyFlags(fl, &Flags{})
 
-	// Stop everything and let them time to stop.
-	time.Sleep(gracePeriod)
+	var res []dashboard.Setting
+	for _, kv := range m.flags.EffectiveConfig {
+		name, value, _ := strings.Cut(kv, "=")
+		if fl.Lookup(name) != nil {
+			res = append(res, dashboard.Setting{Name: name, Value: value})
+		}
+	}
+	return res
 }
 
-func (m *Main) createSignalChan() chan os.Signal {
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT)
-	return c
+// metricsRecorders returns the recorders of the webhook and kubesec metrics
+// of the metrics backend.
+func (m *Main) metricsRecorders(mgr manager.Manager) (metrics.Recorder, kubesecmetrics.Recorder, error) {
+	switch m.flags.MetricsBackend {
+	case metricsPrometheus:
+		// Register metrics on the manager registry so they are served with the controller ones.
+		return metrics.NewPrometheus(ctrlmetrics.Registry), kubesecmetrics.NewPrometheus(ctrlmetrics.Registry), nil
+	case kubesecmetrics.FormatStatsD, kubesecmetrics.FormatDogStatsD:
+		if m.flags.StatsDAddress == "" {
+			return nil, nil, fmt.Errorf("-statsd-address is required with -metrics-backend=%s", m.flags.MetricsBackend)
+		}
+		statsd, err := kubesecmetrics.NewStatsD(m.flags.StatsDAddress, m.flags.StatsDPrefix, m.flags.MetricsBackend)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := mgr.Add(statsd); err != nil {
+			return nil, nil, err
+		}
+		m.logger.Infof("sending metrics to the StatsD server %s", m.flags.StatsDAddress)
+		return statsd, statsd, nil
+	default:
+		return nil, nil, fmt.Errorf("invalid metrics backend %q", m.flags.MetricsBackend)
+	}
 }
 
-func main() {
+// policyOptions returns the validator options deciding the verdicts, and the
+// refresher of the policy bundle when one is used.
+func (m *Main) policyOptions(ctx context.Context, rec kubesecmetrics.Recorder) (*webhook.Options, *bundle.Refresher, error) {
+	sc, err := m.scanner(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	sc = scanner.NewTimer(sc, m.flags.Scanner, rec)
+	if m.flags.ScanRetry.Retries > 0 {
+		if sc, err = scanner.NewRetry(sc, m.flags.ScanRetry, rec); err != nil {
+			return nil, nil, err
+		}
+	}
+	if m.flags.ScanBreakerThreshold > 0 {
+		if sc, err = scanner.NewBreaker(sc, m.flags.ScanBreakerThreshold, m.flags.ScanBreakerCooldown, rec); err != nil {
+			return nil, nil, err
+		}
+	}
+	if m.flags.RedisAddress != "" {
+		if sc, err = m.redisCache(sc, rec); err != nil {
+			return nil, nil, err
+		}
+	}
+	if m.flags.ScanCacheSize > 0 {
+		if sc, err = scanner.NewCache(sc, m.flags.ScanCacheSize, m.flags.ScanCacheTTL, rec); err != nil {
+			return nil, nil, err
+		}
+	}
+	// The shared scans outlive the reviews giving up, not the webhook timeout.
+	dedup, err := scanner.NewDedup(sc, m.flags.WebhookTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	opts := &webhook.Options{
+		Scanner:               dedup,
+		ScannerVersion:        m.scannerVersion(),
+		Recorder:              rec,
+		ExcludeNamespaces:     splitList(m.flags.ExcludeNamespaces),
+		TrustedUsers:          splitList(m.flags.TrustedUsers),
+		TrustedGroups:         splitList(m.flags.TrustedGroups),
+		ExemptPriorityClasses: splitList(m.flags.ExemptPriorityClasses),
+		SkipNamespaces:        splitList(m.flags.SkipNamespaces),
+		RequiredChecks:        splitList(m.flags.RequiredChecks),
+		DeniedRules:           splitList(m.flags.DenyRules),
+		WarnScore:             m.flags.WarnScore,
+		NamespaceMinScore:     m.flags.NamespaceMinScore,
+		GrandfatherUpdates:    m.flags.GrandfatherUpdates,
+		SkipUnchangedUpdates:  m.flags.SkipUnchangedUpdates,
+	}
+
+	if m.flags.SkipSelector != "" {
+		selector, err := labels.Parse(m.flags.SkipSelector)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid skip selector %q: %w", m.flags.SkipSelector, err)
+		}
+		opts.SkipSelector = selector
+	}
+
+	switch m.flags.ExemptionMode {
+	case webhook.ExemptionAllow, webhook.ExemptionWarn:
+		opts.ExemptionMode = m.flags.ExemptionMode
+	default:
+		return nil, nil, fmt.Errorf("invalid exemption mode %q", m.flags.ExemptionMode)
+	}
+
+	switch m.flags.FailureMode {
+	case webhook.FailOpen, webhook.FailClosed:
+		opts.FailureMode = m.flags.FailureMode
+	default:
+		return nil, nil, fmt.Errorf("invalid failure mode %q", m.flags.FailureMode)
+	}
+
+	switch m.flags.Enforcement {
+	case webhook.EnforcementEnforce, webhook.EnforcementAudit:
+		opts.Enforcement = m.flags.Enforcement
+	default:
+		return nil, nil, fmt.Errorf("invalid enforcement %q", m.flags.Enforcement)
+	}
+
+	switch m.flags.DenyDetail {
+	case webhook.DenyDetailFull, webhook.DenyDetailSummary, webhook.DenyDetailMinimal:
+		opts.DenyDetail = m.flags.DenyDetail
+	default:
+		return nil, nil, fmt.Errorf("invalid deny message detail %q", m.flags.DenyDetail)
+	}
+	if m.flags.DenyMessageMaxLength != 0 && m.flags.DenyMessageMaxLength < minDenyMessageMaxLength {
+		return nil, nil, fmt.Errorf("deny message max length must be 0 or at least %d", minDenyMessageMaxLength)
+	}
+	opts.DenyMessageMaxLength = m.flags.DenyMessageMaxLength
+
+	if m.flags.RuleWeightsFile != "" {
+		weights, err := policy.LoadWeights(m.flags.RuleWeightsFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.Weights = weights
+	}
+
+	if m.flags.RegoPolicyFile != "" && m.flags.DecisionExpressionsFile != "" {
+		return nil, nil, fmt.Errorf("rego policy file and decision expressions file are mutually exclusive")
+	}
+	if m.flags.RegoPolicyFile != "" {
+		rp, err := policy.LoadRego(ctx, m.flags.RegoPolicyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.DecisionPolicy = rp
+	}
+	if m.flags.DecisionExpressionsFile != "" {
+		exprs, err := policy.LoadExpressions(m.flags.DecisionExpressionsFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.DecisionPolicy = exprs
+	}
+	for _, path := range m.flags.WASMPlugins {
+		p, err := plugin.Load(ctx, path, m.flags.WASMPluginTimeout)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.Plugins = append(opts.Plugins, p)
+	}
+
+	if m.flags.ImagePolicyFile != "" && m.flags.PolicyBundle != "" {
+		return nil, nil, fmt.Errorf("image policy file and policy bundle are mutually exclusive")
+	}
+
+	if m.flags.ImagePolicyFile != "" {
+		images, err := policy.LoadImages(m.flags.ImagePolicyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts.Images = images
+	}
+
+	if m.flags.PolicyBundle == "" {
+		return opts, nil, nil
+	}
+
+	refresher, err := m.policyBundle(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The webhooks do not start before the policy is known.
+	if err := refresher.Refresh(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	return opts, refresher, nil
+}
+
+// scanner returns the scanner scoring the definitions. A sidecar is waited
+// for until it is healthy.
+func (m *Main) scanner(ctx context.Context) (scanner.Scanner, error) {
+	switch m.flags.Scanner {
+	case scannerRemote:
+		return webhook.NewScanner(m.flags.KubesecURL, m.flags.ScanTransport)
+	case scannerEmbedded:
+		return scanner.NewEmbedded(), nil
+	case scannerSidecar:
+		sidecar, err := webhook.NewSidecarScanner(m.flags.SidecarAddress, m.flags.ScanTransport)
+		if err != nil {
+			return nil, err
+		}
+		m.logger.Infof("waiting for the kubesec sidecar on %s...", m.flags.SidecarAddress)
+		wctx, cancel := context.WithTimeout(ctx, m.flags.SidecarStartupTimeout)
+		defer cancel()
+		if err := sidecar.WaitHealthy(wctx, time.Second); err != nil {
+			return nil, err
+		}
+		return sidecar, nil
+	default:
+		return nil, fmt.Errorf("invalid scanner %q", m.flags.Scanner)
+	}
+}
+
+// scannerVersion identifies the scanner and its backend in the audit
+// annotations.
+func (m *Main) scannerVersion() string {
+	switch m.flags.Scanner {
+	case scannerRemote:
+		return scannerRemote + " " + m.flags.KubesecURL
+	case scannerSidecar:
+		return scannerSidecar + " " + m.flags.SidecarAddress
+	}
+	return m.flags.Scanner + " kubesec-v2"
+}
+
+// redisCache returns the Redis cache sharing the scan results of next.
+func (m *Main) redisCache(next scanner.Scanner, rec kubesecmetrics.Recorder) (*scanner.RedisCache, error) {
+	cfg := scanner.RedisConfig{
+		Address:  m.flags.RedisAddress,
+		Username: m.flags.RedisUsername,
+		DB:       m.flags.RedisDB,
+	}
+	if m.flags.RedisPasswordFile != "" {
+		password, err := os.ReadFile(m.flags.RedisPasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read Redis password: %w", err)
+		}
+		cfg.Password = strings.TrimSpace(string(password))
+	}
+	if m.flags.RedisTLS {
+		host, _, err := net.SplitHostPort(m.flags.RedisAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis address %q: %w", m.flags.RedisAddress, err)
+		}
+		cfg.TLS = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		if m.flags.RedisCAFile != "" {
+			ca, err := os.ReadFile(m.flags.RedisCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("could not read Redis CA: %w", err)
+			}
+			cfg.TLS.RootCAs = x509.NewCertPool()
+			if !cfg.TLS.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("no certificate found in %s", m.flags.RedisCAFile)
+			}
+		}
+	}
+
+	return scanner.NewRedisCache(next, cfg, m.flags.ScanCacheTTL, rec, m.logger)
+}
+
+// syslogWriter returns the writer of the decisions to the syslog server.
+func (m *Main) syslogWriter() (*syslog.Writer, error) {
+	sev, err := syslog.ParseSeverities(m.flags.SyslogSeverities)
+	if err != nil {
+		return nil, err
+	}
+
+	var tlsConfig *tls.Config
+	if m.flags.SyslogTLS {
+		host, _, err := net.SplitHostPort(m.flags.SyslogAddress)
+		if err != nil {
+			return nil, fmt.Errorf("invalid syslog address %q: %w", m.flags.SyslogAddress, err)
+		}
+		tlsConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+		if m.flags.SyslogCAFile != "" {
+			ca, err := os.ReadFile(m.flags.SyslogCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("could not read syslog CA: %w", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("no certificate found in %s", m.flags.SyslogCAFile)
+			}
+		}
+	}
+
+	return syslog.NewWriter(m.flags.SyslogAddress, tlsConfig, m.flags.SyslogFacility, sev, m.logger)
+}
+
+// unwrapScanner returns the scanner behind the caches.
+func unwrapScanner(sc scanner.Scanner) scanner.Scanner {
+	for {
+		cache, ok := sc.(interface{ Next() scanner.Scanner })
+		if !ok {
+			return sc
+		}
+		sc = cache.Next()
+	}
+}
+
+// policyBundle returns the refresher applying the policy bundle to opts.
+func (m *Main) policyBundle(opts *webhook.Options) (*bundle.Refresher, error) {
+	ref, err := bundle.ParseReference(m.flags.PolicyBundle)
+	if err != nil {
+		return nil, err
+	}
+
+	puller := &bundle.Puller{
+		Username:  m.flags.PolicyBundleUsername,
+		PlainHTTP: m.flags.PolicyBundlePlainHTTP,
+		Client:    &http.Client{Timeout: 30 * time.Second},
+	}
+	if m.flags.PolicyBundlePasswordFile != "" {
+		password, err := os.ReadFile(m.flags.PolicyBundlePasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read policy bundle password: %w", err)
+		}
+		puller.Password = strings.TrimSpace(string(password))
+	}
+
+	opts.Images = &policy.Images{}
+	apply := func(raw []byte) error {
+		images, err := policy.ParseImages(raw)
+		if err != nil {
+			return err
+		}
+		opts.Images.Replace(images)
+		return nil
+	}
+
+	return bundle.NewRefresher(puller, ref, m.flags.PolicyBundleRefresh, apply, m.logger)
+}
+
+// policyConfigMap returns the watcher applying the policy ConfigMap to opts.
+// It is read directly, rather than through the cache, so only the ConfigMap
+// is readable by the webhook.
+func (m *Main) policyConfigMap(ctx context.Context, opts *webhook.Options, reader client.Reader) (*policy.ConfigMapWatcher, error) {
+	if m.flags.ImagePolicyFile != "" || m.flags.PolicyBundle != "" {
+		return nil, fmt.Errorf("image policy file, policy bundle and policy configmap are mutually exclusive")
+	}
+
+	namespace, name, err := namespacedName(m.flags.PolicyConfigMap)
+	if err != nil {
+		return nil, fmt.Errorf("policy configmap: %w", err)
+	}
+
+	opts.Images = &policy.Images{}
+	watcher, err := policy.NewConfigMapWatcher(reader, namespace, name, m.flags.PolicyConfigMapRefresh, opts.Images, m.logger)
+	if err != nil {
+		return nil, err
+	}
+	// The webhooks do not start before the policy is known.
+	if err := watcher.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return watcher, nil
+}
+
+// webhookKinds are the kinds validated, with the path they are served on.
+var webhookKinds = []struct {
+	kind       schema.GroupKind
+	resource   string
+	path       string
+	newWebhook func(int, *webhook.Options, metrics.Recorder, log.Logger) (kwebhook.Webhook, error)
+}{
+	{kind: schema.GroupKind{Kind: "Pod"}, resource: "pods", path: "/pod", newWebhook: webhook.NewPodWebhook},
+	{kind: schema.GroupKind{Group: "apps", Kind: "Deployment"}, resource: "deployments", path: "/deployment", newWebhook: webhook.NewDeploymentWebhook},
+	{kind: schema.GroupKind{Group: "apps", Kind: "ReplicaSet"}, resource: "replicasets", path: "/replicaset", newWebhook: webhook.NewReplicaSetWebhook},
+	{kind: schema.GroupKind{Group: "apps", Kind: "DaemonSet"}, resource: "daemonsets", path: "/daemonset", newWebhook: webhook.NewDaemonSetWebhook},
+	{kind: schema.GroupKind{Group: "apps", Kind: "StatefulSet"}, resource: "statefulsets", path: "/statefulset", newWebhook: webhook.NewStatefulSetWebhook},
+	{kind: schema.GroupKind{Group: "batch", Kind: "Job"}, resource: "jobs", path: "/job", newWebhook: webhook.NewJobWebhook},
+	{kind: schema.GroupKind{Group: "batch", Kind: "CronJob"}, resource: "cronjobs", path: "/cronjob", newWebhook: webhook.NewCronJobWebhook},
+	{kind: schema.GroupKind{Group: "serving.knative.dev", Kind: "Service"}, resource: "services", path: "/knative-service", newWebhook: webhook.NewKnativeServiceWebhook},
+}
+
+// Paths of the webhooks not bound to a single kind.
+const (
+	// validatePath serves every kind, reviews are dispatched on their kind.
+	validatePath = "/validate"
+	// customResourcePath serves the custom resources with a pod template path.
+	customResourcePath = "/custom-resource"
+)
+
+// registerScanAPI serves the ad-hoc scans of the posted manifests on the
+// webhook server, and on the gRPC server when it listens. Their decisions are
+// returned to the client only, neither counted nor written to the sinks of
+// the admissions.
+func (m *Main) registerScanAPI(mgr manager.Manager, srv *ctrlwebhook.Server, reloader *certs.Reloader, opts *webhook.Options) error {
+	raw, err := os.ReadFile(m.flags.ScanAPITokenFile)
+	if err != nil {
+		return fmt.Errorf("could not read scan API token: %w", err)
+	}
+	token := strings.TrimSpace(string(raw))
+	apiOpts := *opts
+	apiOpts.Sink = manifest.Decisions
+	apiOpts.Recorder = kubesecmetrics.Dummy
+	whs, err := m.webhooks(&apiOpts, metrics.Dummy)
+	if err != nil {
+		return err
+	}
+	rv := &manifest.Reviewer{Webhooks: whs}
+
+	h, err := manifest.NewHandler(rv, token)
+	if err != nil {
+		return err
+	}
+	srv.Register("/scan", h)
+	srv.Register("/scan/batch", h.Batch())
+
+	if m.flags.GRPCListenAddress == "" {
+		return nil
+	}
+	svc, err := manifest.NewService(rv, token)
+	if err != nil {
+		return err
+	}
+	minVersion, suites, err := m.tlsSettings()
+	if err != nil {
+		return err
+	}
+	return mgr.Add(manifest.NewGRPCServer(m.flags.GRPCListenAddress, &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+		MinVersion:     minVersion,
+		CipherSuites:   suites,
+	}, svc))
+}
+
+// registerPeerSync syncs the scan cache and circuit breaker of opts with the
+// other replicas, their updates served on the webhook server.
+func (m *Main) registerPeerSync(mgr manager.Manager, srv *ctrlwebhook.Server, opts *webhook.Options) error {
+	raw, err := os.ReadFile(m.flags.PeerTokenFile)
+	if err != nil {
+		return fmt.Errorf("could not read peer token: %w", err)
+	}
+	ca, err := m.caBundle()
+	if err != nil {
+		return err
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(ca) {
+		return fmt.Errorf("no certificate found in the webhook CA")
+	}
+	serverName := m.flags.PeerServerName
+	if serverName == "" {
+		namespace, name, err := namespacedName(m.flags.WebhookService)
+		if err != nil {
+			return fmt.Errorf("webhook service: %w", err)
+		}
+		serverName = name + "." + namespace + ".svc"
+	}
+	port := m.flags.PeerPort
+	if port == 0 {
+		port = srv.Port
+	}
+
+	peerSync, err := peer.New(peer.Config{
+		Service:   m.flags.PeerService,
+		Port:      port,
+		Addresses: splitList(m.flags.PeerAddresses),
+		Self:      m.flags.PeerSelf,
+		Token:     strings.TrimSpace(string(raw)),
+		TLS:       &tls.Config{RootCAs: roots, ServerName: serverName, MinVersion: tls.VersionTLS12},
+		Refresh:   m.flags.PeerRefresh,
+	}, opts.Scanner, m.logger)
+	if err != nil {
+		return err
+	}
+	srv.Register(peer.Path, peerSync)
+	return mgr.Add(peerSync)
+}
+
+// registerWebhooks creates the kubesec webhooks and serves them on the webhook
+// server.
+func (m *Main) registerWebhooks(srv *ctrlwebhook.Server, opts *webhook.Options, metricsRec metrics.Recorder) error {
+	whs, err := m.webhooks(opts, metricsRec)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range webhookKinds {
+		h, err := whhttp.HandlerFor(whs[k.kind])
+		if err != nil {
+			return err
+		}
+		srv.Register(k.path, requestid.Handler(h))
+	}
+
+	// Every custom resource kind is served by the same webhook.
+	for gvk := range m.flags.PodTemplatePaths {
+		h, err := whhttp.HandlerFor(whs[gvk.GroupKind()])
+		if err != nil {
+			return err
+		}
+		srv.Register(customResourcePath, requestid.Handler(h))
+		break
+	}
+
+	h, err := whhttp.HandlerFor(webhook.NewDispatchWebhook(whs, m.logger))
+	if err != nil {
+		return err
+	}
+	srv.Register(validatePath, requestid.Handler(h))
+
+	return nil
+}
+
+// webhooks creates the kubesec webhooks by kind.
+func (m *Main) webhooks(opts *webhook.Options, metricsRec metrics.Recorder) (map[schema.GroupKind]kwebhook.Webhook, error) {
+	whs := map[schema.GroupKind]kwebhook.Webhook{}
+	for _, k := range webhookKinds {
+		wh, err := k.newWebhook(m.flags.MinScore, opts, metricsRec, m.logger)
+		if err != nil {
+			return nil, err
+		}
+		whs[k.kind] = wh
+	}
+
+	paths := m.flags.PodTemplatePaths
+	if len(paths) == 0 {
+		return whs, nil
+	}
+	wh, err := webhook.NewCustomResourceWebhook(m.flags.MinScore, paths, opts, metricsRec, m.logger)
+	if err != nil {
+		return nil, err
+	}
+	for gvk := range paths {
+		whs[gvk.GroupKind()] = wh
+	}
+
+	return whs, nil
+}
+
+// smtpReporter returns the reporter emailing the decision summaries and the
+// summary the webhooks should write their decisions to.
+func (m *Main) smtpReporter() (*report.SMTPReporter, *report.Summary, error) {
+	cfg := report.SMTPConfig{
+		Host:     m.flags.SMTPHost,
+		Port:     m.flags.SMTPPort,
+		Username: m.flags.SMTPUsername,
+		From:     m.flags.SMTPFrom,
+		TLSMode:  m.flags.SMTPTLSMode,
+	}
+	cfg.To = splitList(m.flags.SMTPTo)
+	if m.flags.SMTPPasswordFile != "" {
+		password, err := os.ReadFile(m.flags.SMTPPasswordFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read SMTP password: %w", err)
+		}
+		cfg.Password = strings.TrimSpace(string(password))
+	}
+
+	summary := report.NewSummary(m.flags.ReportClusterName)
+	reporter, err := report.NewSMTPReporter(summary, cfg, m.flags.ReportInterval, m.logger)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return reporter, summary, nil
+}
+
+// escalator returns the escalator filing repeatedly denied workloads in the
+// configured issue tracker.
+func (m *Main) escalator() (*escalation.Escalator, error) {
+	var tracker escalation.Tracker
+	switch m.flags.EscalationTracker {
+	case "jira":
+		cfg := escalation.JiraConfig{
+			URL:       m.flags.EscalationURL,
+			Project:   m.flags.JiraProject,
+			IssueType: m.flags.JiraIssueType,
+			Username:  m.flags.JiraUsername,
+			Labels:    []string{"kubesec"},
+		}
+		if m.flags.JiraTokenFile != "" {
+			token, err := os.ReadFile(m.flags.JiraTokenFile)
+			if err != nil {
+				return nil, fmt.Errorf("could not read Jira token: %w", err)
+			}
+			cfg.Token = strings.TrimSpace(string(token))
+		}
+		jira, err := escalation.NewJiraTracker(cfg)
+		if err != nil {
+			return nil, err
+		}
+		tracker = jira
+	case "rest":
+		rest, err := escalation.NewRESTTracker(m.flags.EscalationURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		tracker = rest
+	default:
+		return nil, fmt.Errorf("unknown escalation tracker %q", m.flags.EscalationTracker)
+	}
+
+	return escalation.NewEscalator(tracker, m.flags.EscalationThreshold, m.flags.EscalationWindow, m.logger)
+}
+
+// selfSigned generates a self-signed keypair served instead of the TLS files.
+// The webhook server reads its keypair from files, it is written in a
+// temporary directory.
+func (m *Main) selfSigned() error {
+	certPEM, keyPEM, err := certs.SelfSigned(splitList(m.flags.TLSSelfSignedSANs), 365*24*time.Hour)
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("", "kubesec-webhook-certs")
+	if err != nil {
+		return err
+	}
+	m.flags.CertFile, m.flags.KeyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(m.flags.CertFile, certPEM, 0o600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(m.flags.KeyFile, keyPEM, 0o600); err != nil {
+		return err
+	}
+
+	m.logger.Warningf("serving a self-signed certificate for %s, not suitable for production", m.flags.TLSSelfSignedSANs)
+	m.logger.Infof("caBundle of the webhook registrations: %s", base64.StdEncoding.EncodeToString(certPEM))
+	return nil
+}
+
+// tlsSecret returns the sync of the TLS secret to the files the webhook
+// server is given, synced once. They are written in a temporary directory.
+func (m *Main) tlsSecret(c client.Client) (*certs.SecretSync, error) {
+	namespace, name, err := namespacedName(m.flags.TLSSecret)
+	if err != nil {
+		return nil, fmt.Errorf("TLS secret: %w", err)
+	}
+	dir, err := os.MkdirTemp("", "kubesec-webhook-certs")
+	if err != nil {
+		return nil, err
+	}
+	m.flags.CertFile, m.flags.KeyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	secretSync, err := certs.NewSecretSync(c, namespace, name, m.flags.CertFile, m.flags.KeyFile, 10*time.Second, m.logger)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := secretSync.Sync(ctx); err != nil {
+		return nil, err
+	}
+	return secretSync, nil
+}
+
+// caBundle returns the CA of the TLS certificate, -tls-ca-file or the last
+// certificate of its chain.
+func (m *Main) caBundle() ([]byte, error) {
+	if m.flags.TLSCAFile != "" {
+		ca, err := os.ReadFile(m.flags.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read TLS CA: %w", err)
+		}
+		return ca, nil
+	}
+	raw, err := os.ReadFile(m.flags.CertFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not read TLS certificate: %w", err)
+	}
+	return certs.CABundle(raw)
+}
+
+// selfRegister creates or updates the ValidatingWebhookConfiguration of the
+// served webhooks, every kind on its own path.
+func (m *Main) selfRegister(c client.Client) error {
+	namespace, name, err := namespacedName(m.flags.WebhookService)
+	if err != nil {
+		return fmt.Errorf("webhook service: %w", err)
+	}
+	selector, err := metav1.ParseToLabelSelector(m.flags.WebhookSelector)
+	if err != nil {
+		return fmt.Errorf("invalid webhook namespace selector: %w", err)
+	}
+	ca, err := m.caBundle()
+	if err != nil {
+		return err
+	}
+	cfg := registration.Config{
+		Name:              m.flags.WebhookName,
+		Service:           types.NamespacedName{Namespace: namespace, Name: name},
+		Port:              int32(m.flags.WebhookPort),
+		CABundle:          ca,
+		NamespaceSelector: selector,
+		FailurePolicy:     admissionregistrationv1.FailurePolicyType(m.flags.WebhookFailurePolicy),
+		Timeout:           m.flags.WebhookTimeout,
+	}
+
+	var webhooks []registration.Webhook
+	for _, k := range webhookKinds {
+		webhooks = append(webhooks, registration.Webhook{
+			Path:      k.path,
+			Resources: []registration.Resource{{Group: k.kind.Group, Resource: k.resource}},
+		})
+	}
+	if len(m.flags.PodTemplatePaths) > 0 {
+		custom := registration.Webhook{Path: customResourcePath}
+		for gvk := range m.flags.PodTemplatePaths {
+			mapping, err := c.RESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+			if err != nil {
+				return fmt.Errorf("could not find the resource of %s: %w", gvk, err)
+			}
+			custom.Resources = append(custom.Resources, registration.Resource{Group: gvk.Group, Resource: mapping.Resource.Resource})
+		}
+		sort.Slice(custom.Resources, func(i, j int) bool {
+			return custom.Resources[i].Group+"/"+custom.Resources[i].Resource < custom.Resources[j].Group+"/"+custom.Resources[j].Resource
+		})
+		webhooks = append(webhooks, custom)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	op, err := registration.Register(ctx, c, cfg, webhooks)
+	if err != nil {
+		return err
+	}
+	m.logger.Infof("webhook configuration %s %s", cfg.Name, op)
+	return nil
+}
+
+// injectCABundle sets the caBundle of the webhook configuration to the CA of
+// the TLS certificate.
+func (m *Main) injectCABundle(c client.Client) error {
+	ca, err := m.caBundle()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	injected, err := certs.InjectCABundle(ctx, c, m.flags.InjectCABundle, ca)
+	if err != nil {
+		return err
+	}
+	if injected {
+		m.logger.Infof("injected the caBundle of webhook configuration %s", m.flags.InjectCABundle)
+	}
+	return nil
+}
+
+// webhookServer returns the TLS server the webhooks are served on, with the
+// keypair of the reloader so a rotated certificate is picked up without a
+// restart.
+func (m *Main) webhookServer(reloader *certs.Reloader) (*ctrlwebhook.Server, error) {
+	host, port, err := net.SplitHostPort(m.flags.ListenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address %q: %w", m.flags.ListenAddress, err)
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen port %q: %w", port, err)
+	}
+
+	certDir := filepath.Dir(m.flags.CertFile)
+	if filepath.Dir(m.flags.KeyFile) != certDir {
+		return nil, fmt.Errorf("TLS certificate and key must be in the same directory")
+	}
+
+	minVersion, suites, err := m.tlsSettings()
+	if err != nil {
+		return nil, err
+	}
+	var clientCAs *x509.CertPool
+	if m.flags.ClientCAFile != "" {
+		if clientCAs, err = certs.ClientCAs(m.flags.ClientCAFile); err != nil {
+			return nil, err
+		}
+	}
+
+	return &ctrlwebhook.Server{
+		Host:     host,
+		Port:     p,
+		CertDir:  certDir,
+		CertName: filepath.Base(m.flags.CertFile),
+		KeyName:  filepath.Base(m.flags.KeyFile),
+		TLSOpts: []func(*tls.Config){func(c *tls.Config) {
+			c.GetCertificate = reloader.GetCertificate
+			c.MinVersion = minVersion
+			c.CipherSuites = suites
+			if clientCAs != nil {
+				c.ClientCAs = clientCAs
+				c.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+		}},
+	}, nil
+}
+
+// tlsSettings returns the minimum TLS version and the cipher suites of the
+// servers.
+func (m *Main) tlsSettings() (uint16, []uint16, error) {
+	minVersion, err := certs.TLSVersion(m.flags.TLSMinVersion)
+	if err != nil {
+		return 0, nil, err
+	}
+	suites, err := certs.CipherSuites(splitList(m.flags.TLSCipherSuites))
+	if err != nil {
+		return 0, nil, err
+	}
+	return minVersion, suites, nil
+}
+
+// namespacedName splits a namespace/name reference, a name alone is in the
+// namespace of the pod.
+func namespacedName(ref string) (string, string, error) {
+	if namespace, name, ok := strings.Cut(ref, "/"); ok {
+		return namespace, name, nil
+	}
+	raw, err := os.ReadFile(serviceAccountNamespace)
+	if err != nil {
+		return "", "", fmt.Errorf("could not find the namespace of %s, use namespace/name: %w", ref, err)
+	}
+	return strings.TrimSpace(string(raw)), ref, nil
+}
+
+// addPprofHandlers serves the profiles next to the metrics.
+func addPprofHandlers(mgr manager.Manager) error {
+	handlers := map[string]http.HandlerFunc{
+		"/debug/pprof/":        pprof.Index,
+		"/debug/pprof/cmdline": pprof.Cmdline,
+		"/debug/pprof/profile": pprof.Profile,
+		"/debug/pprof/symbol":  pprof.Symbol,
+		"/debug/pprof/trace":   pprof.Trace,
+	}
+	for path, h := range handlers {
+		if err := mgr.AddMetricsExtraHandler(path, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// envOr returns the value of the environment variable, def when unset or empty.
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+// splitList returns the non empty items of a comma separated list.
+func splitList(list string) []string {
+	var res []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// restConfig returns the configuration used to reach the Kubernetes API.
+func (m *Main) restConfig() (*rest.Config, error) {
+	if m.flags.Kubeconfig != "" {
+		return clientcmd.BuildConfigFromFlags("", m.flags.Kubeconfig)
+	}
+	return ctrl.GetConfig()
+}
+
+// commands are the subcommands of the binary, which runs the webhook when
+// called without one.
+var commands = map[string]func(args []string) error{
+	"audit":      runAudit,
+	"replay":     runReplay,
+	"scan":       runScan,
+	"export-vap": runExportVAP,
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+	}
+
 	m := Main{
 		flags: NewFlags(),
-		stopC: make(chan struct{}),
 	}
 
 	err := m.Run()
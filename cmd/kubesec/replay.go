@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"github.com/slok/kubewebhook/pkg/observability/metrics"
+
+	kubesecmetrics "github.com/controlplaneio/kubesec-webhook/pkg/metrics"
+	"github.com/controlplaneio/kubesec-webhook/pkg/replay"
+)
+
+// runReplay re-evaluates recorded admission reviews, e.g. exported from the
+// API server audit logs, against this binary and the given policy flags.
+func runReplay(args []string) error {
+	flags := &Flags{}
+	fl := flag.NewFlagSet("replay", flag.ExitOnError)
+	fl.Usage = func() {
+		fmt.Fprintf(fl.Output(), "Usage: %s replay [flags] [file...]\n\n", os.Args[0])
+		fmt.Fprintf(fl.Output(), "Replays the admission reviews of the files, JSON objects with request and response, stdin when none.\n\n")
+		fl.PrintDefaults()
+	}
+	fl.BoolVar(&flags.Debug, "debug", debugDef, "log the reviews")
+	failOnChange := fl.Bool("fail-on-change", false, "exit with an error when a verdict changed")
+	registerPolicyFlags(fl, flags)
+	if err := fl.Parse(args); err != nil {
+		return err
+	}
+
+	m := Main{flags: flags, logger: log.Dummy}
+	if flags.Debug {
+		m.logger = &log.Std{Debug: true}
+	}
+
+	opts, _, err := m.policyOptions(context.Background(), kubesecmetrics.Dummy)
+	if err != nil {
+		return err
+	}
+	whs, err := m.webhooks(opts, metrics.Dummy)
+	if err != nil {
+		return err
+	}
+	rp := &replay.Replayer{Webhooks: whs}
+
+	var in io.Reader = os.Stdin
+	if fl.NArg() > 0 {
+		var readers []io.Reader
+		for _, path := range fl.Args() {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			readers = append(readers, f)
+		}
+		in = io.MultiReader(readers...)
+	}
+
+	rep, err := rp.Replay(context.Background(), in)
+	if err != nil {
+		return err
+	}
+	rep.WriteText(os.Stdout)
+
+	if *failOnChange && len(rep.Changes) > 0 {
+		return fmt.Errorf("%d verdicts changed", len(rep.Changes))
+	}
+	return nil
+}
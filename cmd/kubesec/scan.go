@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"github.com/slok/kubewebhook/pkg/observability/metrics"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/checkrun"
+	"github.com/controlplaneio/kubesec-webhook/pkg/junit"
+	"github.com/controlplaneio/kubesec-webhook/pkg/manifest"
+	kubesecmetrics "github.com/controlplaneio/kubesec-webhook/pkg/metrics"
+	"github.com/controlplaneio/kubesec-webhook/pkg/sarif"
+)
+
+// Output formats of the scan, it shares the SARIF one with the audit.
+const (
+	scanOutputText  = "text"
+	scanOutputJUnit = "junit"
+	outputSARIF     = "sarif"
+)
+
+// filesFlag is a repeatable flag of paths.
+type filesFlag []string
+
+func (f *filesFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *filesFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// runScan reviews the objects of manifest files with the webhooks and the
+// given policy flags, as the webhook would on their creation, e.g. in CI.
+func runScan(args []string) error {
+	flags := &Flags{}
+	var files filesFlag
+	fl := flag.NewFlagSet("scan", flag.ExitOnError)
+	fl.Usage = func() {
+		fmt.Fprintf(fl.Output(), "Usage: %s scan [flags] -f path...\n\n", os.Args[0])
+		fmt.Fprintf(fl.Output(), "Reviews the objects of the manifests as the webhook would on their creation, and fails when one is denied.\n\n")
+		fl.PrintDefaults()
+	}
+	fl.BoolVar(&flags.Debug, "debug", debugDef, "log the reviews")
+	fl.Var(&files, "f", "manifest file, directory walked for .yaml, .yml and .json files, or - for stdin, can be repeated")
+	namespace := fl.String("namespace", "", "namespace of the objects without one, default when empty")
+	output := fl.String("output", scanOutputText, "output format: text, sarif or junit")
+	checkRun := fl.Bool("github-check-run", false, "also report the reviews as a GitHub Check Run annotating the manifests, needs a token, the repository and the commit")
+	checkRunCfg := checkrun.Config{}
+	fl.StringVar(&checkRunCfg.URL, "github-api-url", envOr("GITHUB_API_URL", checkrun.DefaultURL), "URL of the GitHub API, GITHUB_API_URL by default")
+	fl.StringVar(&checkRunCfg.Repository, "github-repository", os.Getenv("GITHUB_REPOSITORY"), "owner/name of the repository of the check run, GITHUB_REPOSITORY by default")
+	fl.StringVar(&checkRunCfg.SHA, "github-sha", os.Getenv("GITHUB_SHA"), "commit of the check run, the head of the pull request, GITHUB_SHA by default")
+	fl.StringVar(&checkRunCfg.Name, "github-check-name", checkrun.DefaultName, "name of the check run")
+	tokenFile := fl.String("github-token-file", "", "file containing the token creating the check run, GITHUB_TOKEN when empty")
+	registerPolicyFlags(fl, flags)
+	if err := fl.Parse(args); err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no manifest given, use -f")
+	}
+	switch *output {
+	case scanOutputText, outputSARIF, scanOutputJUnit:
+	default:
+		return fmt.Errorf("output must be %s, %s or %s, got %q", scanOutputText, outputSARIF, scanOutputJUnit, *output)
+	}
+
+	var reporter *checkrun.Reporter
+	if *checkRun {
+		checkRunCfg.Token = os.Getenv("GITHUB_TOKEN")
+		if *tokenFile != "" {
+			token, err := os.ReadFile(*tokenFile)
+			if err != nil {
+				return fmt.Errorf("could not read GitHub token: %w", err)
+			}
+			checkRunCfg.Token = strings.TrimSpace(string(token))
+		}
+		var err error
+		if reporter, err = checkrun.NewReporter(checkRunCfg); err != nil {
+			return err
+		}
+	}
+
+	m := Main{flags: flags, logger: log.Dummy}
+	if flags.Debug {
+		m.logger = &log.Std{Debug: true}
+	}
+
+	ctx := context.Background()
+	opts, _, err := m.policyOptions(ctx, kubesecmetrics.Dummy)
+	if err != nil {
+		return err
+	}
+	opts.Sink = manifest.Decisions
+	whs, err := m.webhooks(opts, metrics.Dummy)
+	if err != nil {
+		return err
+	}
+	rv := &manifest.Reviewer{Webhooks: whs, Namespace: *namespace, Stdin: os.Stdin}
+
+	rep, err := rv.Review(ctx, files...)
+	if err != nil {
+		return err
+	}
+	switch *output {
+	case outputSARIF:
+		var objects []sarif.Object
+		for _, res := range rep.Results {
+			if res.Decision != nil {
+				objects = append(objects, sarif.Object{File: res.File, Decision: *res.Decision})
+			}
+		}
+		if err := sarif.Write(os.Stdout, objects); err != nil {
+			return err
+		}
+	case scanOutputJUnit:
+		cases := make([]junit.Case, 0, len(rep.Results))
+		for _, res := range rep.Results {
+			c := junit.Case{Suite: res.File, Name: fmt.Sprintf("%s %s/%s", res.Kind, res.Namespace, res.Name)}
+			if !res.Allowed {
+				c.Failure = res.Message
+			} else if res.Decision != nil && res.Decision.Error != "" {
+				c.Error = res.Decision.Error
+			}
+			cases = append(cases, c)
+		}
+		if err := junit.Write(os.Stdout, "kubesec", cases); err != nil {
+			return err
+		}
+	default:
+		rep.WriteText(os.Stdout)
+	}
+
+	if reporter != nil {
+		var objects []checkrun.Object
+		for _, res := range rep.Results {
+			if res.Decision != nil {
+				objects = append(objects, checkrun.Object{File: res.File, Document: res.Document, Decision: *res.Decision})
+			}
+		}
+		url, err := reporter.Report(ctx, objects)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "check run reported at %s\n", url)
+	}
+
+	if rep.Denied > 0 {
+		return fmt.Errorf("%d objects denied", rep.Denied)
+	}
+	return nil
+}
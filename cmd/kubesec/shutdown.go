@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+// drain returns a context done delay after parent, and a readiness check
+// failing as soon as parent is done. On SIGTERM the pod is taken out of the
+// Service endpoints while it keeps serving the reviews still routed to it,
+// then the servers stop taking new connections and finish the reviews in
+// flight, within the shutdown timeout of the manager.
+func drain(parent context.Context, delay time.Duration, logger log.Logger) (context.Context, healthz.Checker) {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-parent.Done()
+		logger.Infof("shutting down, serving for %s while the endpoints are updated", delay)
+		t := time.NewTimer(delay)
+		defer t.Stop()
+		<-t.C
+		cancel()
+	}()
+
+	ready := func(*http.Request) error {
+		if parent.Err() != nil {
+			return errors.New("shutting down")
+		}
+		return nil
+	}
+	return ctx, ready
+}
@@ -28,7 +28,31 @@ webhooks:
     namespaceSelector:
       matchLabels:
         kubesec-validation: enabled
-    sideEffects: None
+    sideEffects: NoneOnDryRun
+    timeoutSeconds: 15
+    admissionReviewVersions: ["v1beta1"]
+  - name: replicaset.admission.kubesc.io
+    clientConfig:
+      service:
+        name: kubesec-webhook
+        namespace: kubesec
+        path: "/replicaset"
+      caBundle: CA_BUNDLE
+    rules:
+      - operations:
+        - CREATE
+        - UPDATE
+        apiGroups:
+        - apps
+        apiVersions:
+        - "*"
+        resources:
+        - replicasets
+    failurePolicy: Fail
+    namespaceSelector:
+      matchLabels:
+        kubesec-validation: enabled
+    sideEffects: NoneOnDryRun
     timeoutSeconds: 15
     admissionReviewVersions: ["v1beta1"]
   - name: daemonset.admission.kubesc.io
@@ -52,7 +76,7 @@ webhooks:
     namespaceSelector:
       matchLabels:
         kubesec-validation: enabled
-    sideEffects: None
+    sideEffects: NoneOnDryRun
     timeoutSeconds: 15
     admissionReviewVersions: ["v1beta1"]
   - name: statefulset.admission.kubesc.io
@@ -76,6 +100,78 @@ webhooks:
     namespaceSelector:
       matchLabels:
         kubesec-validation: enabled
-    sideEffects: None
+    sideEffects: NoneOnDryRun
+    timeoutSeconds: 15
+    admissionReviewVersions: ["v1beta1"]
+  - name: job.admission.kubesc.io
+    clientConfig:
+      service:
+        name: kubesec-webhook
+        namespace: kubesec
+        path: "/job"
+      caBundle: CA_BUNDLE
+    rules:
+      - operations:
+        - CREATE
+        - UPDATE
+        apiGroups:
+        - batch
+        apiVersions:
+        - "*"
+        resources:
+        - jobs
+    failurePolicy: Fail
+    namespaceSelector:
+      matchLabels:
+        kubesec-validation: enabled
+    sideEffects: NoneOnDryRun
+    timeoutSeconds: 15
+    admissionReviewVersions: ["v1beta1"]
+  - name: cronjob.admission.kubesc.io
+    clientConfig:
+      service:
+        name: kubesec-webhook
+        namespace: kubesec
+        path: "/cronjob"
+      caBundle: CA_BUNDLE
+    rules:
+      - operations:
+        - CREATE
+        - UPDATE
+        apiGroups:
+        - batch
+        apiVersions:
+        - "*"
+        resources:
+        - cronjobs
+    failurePolicy: Fail
+    namespaceSelector:
+      matchLabels:
+        kubesec-validation: enabled
+    sideEffects: NoneOnDryRun
+    timeoutSeconds: 15
+    admissionReviewVersions: ["v1beta1"]
+  - name: knative-service.admission.kubesc.io
+    clientConfig:
+      service:
+        name: kubesec-webhook
+        namespace: kubesec
+        path: "/knative-service"
+      caBundle: CA_BUNDLE
+    rules:
+      - operations:
+        - CREATE
+        - UPDATE
+        apiGroups:
+        - serving.knative.dev
+        apiVersions:
+        - "*"
+        resources:
+        - services
+    failurePolicy: Fail
+    namespaceSelector:
+      matchLabels:
+        kubesec-validation: enabled
+    sideEffects: NoneOnDryRun
     timeoutSeconds: 15
     admissionReviewVersions: ["v1beta1"]
@@ -0,0 +1,148 @@
+// Package audit reviews the existing workloads of a cluster against the policy
+// of the webhook and reports those that would be denied, e.g. before the
+// enforcement is enabled.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/rescan"
+)
+
+// Workload is the outcome of the review of a workload.
+type Workload struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	// Outcome is rescan.Passing, rescan.Failing or rescan.Errored.
+	Outcome       string   `json:"outcome"`
+	Score         int      `json:"score"`
+	MinScore      int      `json:"minScore"`
+	FailedRules   []string `json:"failedRules,omitempty"`
+	MissingChecks []string `json:"missingChecks,omitempty"`
+	DeniedRules   []string `json:"deniedRules,omitempty"`
+	Exemption     string   `json:"exemption,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// Report sums up an audit.
+type Report struct {
+	Passing   int        `json:"passing"`
+	Failing   int        `json:"failing"`
+	Errored   int        `json:"errored"`
+	Workloads []Workload `json:"workloads"`
+	// Decisions are those of all the workloads, in the order of Workloads.
+	Decisions []decision.Record `json:"-"`
+}
+
+// Run reviews the workloads of namespace, of all the namespaces when empty,
+// read by reader.
+func Run(ctx context.Context, reader client.Reader, reviewer rescan.Reviewer, namespace string, minScore int, logger log.Logger) (Report, error) {
+	rep := Report{Workloads: []Workload{}}
+	err := rescan.Workloads(ctx, reader, namespace, func(obj client.Object) error {
+		rec := reviewer.Rescan(ctx, obj, minScore, logger)
+		w := workload(rec)
+		switch w.Outcome {
+		case rescan.Failing:
+			rep.Failing++
+		case rescan.Errored:
+			rep.Errored++
+		default:
+			rep.Passing++
+		}
+		rep.Workloads = append(rep.Workloads, w)
+		rep.Decisions = append(rep.Decisions, rec)
+		return nil
+	})
+	return rep, err
+}
+
+func workload(rec decision.Record) Workload {
+	return Workload{
+		Namespace:     rec.Namespace,
+		Kind:          rec.ObjectKind,
+		Name:          rec.Name,
+		Outcome:       rescan.Outcome(rec),
+		Score:         rec.Score,
+		MinScore:      rec.MinScore,
+		FailedRules:   rec.FailedRules,
+		MissingChecks: rec.MissingChecks,
+		DeniedRules:   rec.DeniedRules,
+		Exemption:     rec.Exemption,
+		Error:         rec.Error,
+	}
+}
+
+// filter returns the report with only the failing and errored workloads
+// unless all is set.
+func (r Report) filter(all bool) Report {
+	if all {
+		return r
+	}
+	workloads := []Workload{}
+	for _, w := range r.Workloads {
+		if w.Outcome != rescan.Passing {
+			workloads = append(workloads, w)
+		}
+	}
+	r.Workloads = workloads
+	return r
+}
+
+// WriteTable renders the report as a table of the failing and errored
+// workloads, of all of them when all is set, followed by the totals.
+func (r Report) WriteTable(w io.Writer, all bool) error {
+	r = r.filter(all)
+	if len(r.Workloads) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAMESPACE\tKIND\tNAME\tSCORE\tMIN SCORE\tOUTCOME\tDETAIL")
+		for _, wl := range r.Workloads {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%s\t%s\n", wl.Namespace, wl.Kind, wl.Name, wl.Score, wl.MinScore, wl.Outcome, detail(wl))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "audited %d workloads: %d passing, %d failing, %d not scanned\n", r.Passing+r.Failing+r.Errored, r.Passing, r.Failing, r.Errored)
+	return err
+}
+
+// WriteJSON renders the report as JSON, the workloads filtered as by
+// WriteTable.
+func (r Report) WriteJSON(w io.Writer, all bool) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r.filter(all))
+}
+
+// detail returns why the workload fails, or was not scanned.
+func detail(w Workload) string {
+	var details []string
+	if w.Error != "" {
+		details = append(details, "could not be scanned: "+w.Error)
+	} else if w.Outcome == rescan.Failing && w.Score < w.MinScore {
+		details = append(details, "score below the minimum score")
+	}
+	if len(w.FailedRules) > 0 {
+		details = append(details, "failed critical checks "+strings.Join(w.FailedRules, ","))
+	}
+	if len(w.MissingChecks) > 0 {
+		details = append(details, "missing required checks "+strings.Join(w.MissingChecks, ","))
+	}
+	if len(w.DeniedRules) > 0 {
+		details = append(details, "denied checks "+strings.Join(w.DeniedRules, ","))
+	}
+	if w.Exemption != "" {
+		details = append(details, w.Exemption)
+	}
+	return strings.Join(details, "; ")
+}
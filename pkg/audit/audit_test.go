@@ -0,0 +1,134 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// fakeReviewer denies the workloads named failing.
+type fakeReviewer struct{}
+
+func (fakeReviewer) Rescan(_ context.Context, obj client.Object, minScore int, _ log.Logger) decision.Record {
+	rec := decision.Record{
+		Time: time.Now(), Namespace: obj.GetNamespace(), Name: obj.GetName(), ObjectKind: obj.GetObjectKind().GroupVersionKind().Kind,
+		MinScore: minScore, Score: 3, Allowed: true,
+	}
+	if obj.GetName() == "failing" {
+		rec.Allowed, rec.Score, rec.FailedRules = false, -30, []string{"Privileged"}
+	}
+	return rec
+}
+
+// TestRun - tests the workloads of the namespace are audited and rendered
+func TestRun(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: "passing"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "foo", Name: "failing"}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "bar", Name: "failing"}},
+	).Build()
+
+	rep, err := Run(context.Background(), c, fakeReviewer{}, "foo", 0, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Passing != 1 || rep.Failing != 1 || rep.Errored != 0 || len(rep.Workloads) != 2 {
+		t.Fatalf("Run - want 1 passing and 1 failing workload of foo, got %+v", rep)
+	}
+
+	var table bytes.Buffer
+	if err := rep.WriteTable(&table, false); err != nil {
+		t.Fatal(err)
+	}
+	want := "NAMESPACE  KIND        NAME     SCORE  MIN SCORE  OUTCOME  DETAIL\n" +
+		"foo        Deployment  failing  -30    0          failing  score below the minimum score; failed critical checks Privileged\n" +
+		"audited 2 workloads: 1 passing, 1 failing, 0 not scanned\n"
+	if table.String() != want {
+		t.Fatalf("WriteTable - want\n%s\ngot\n%s", want, table.String())
+	}
+
+	var out bytes.Buffer
+	if err := rep.WriteJSON(&out, true); err != nil {
+		t.Fatal(err)
+	}
+	var got Report
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Workloads) != 2 || got.Failing != 1 {
+		t.Fatalf("WriteJSON - want every workload, got %+v", got)
+	}
+}
+
+// TestCompare - tests the score deltas, newly failing workloads and checks of two reports
+func TestCompare(t *testing.T) {
+	base := Report{Workloads: []Workload{
+		{Namespace: "staging", Kind: "Deployment", Name: "api", Outcome: "passing", Score: 5},
+		{Namespace: "staging", Kind: "Deployment", Name: "web", Outcome: "failing", Score: -30, FailedRules: []string{"Privileged"}},
+		{Namespace: "staging", Kind: "Deployment", Name: "worker", Outcome: "passing", Score: 3, DeniedRules: []string{"CapSysAdmin"}},
+		{Namespace: "staging", Kind: "Deployment", Name: "cron", Outcome: "passing", Score: 3},
+		{Namespace: "staging", Kind: "Deployment", Name: "legacy", Outcome: "passing", Score: 1},
+	}}
+	target := Report{Workloads: []Workload{
+		{Namespace: "production", Kind: "Deployment", Name: "api", Outcome: "failing", Score: -30, FailedRules: []string{"Privileged"}},
+		{Namespace: "production", Kind: "Deployment", Name: "web", Outcome: "passing", Score: 4},
+		{Namespace: "production", Kind: "Deployment", Name: "worker", Outcome: "passing", Score: 3, MissingChecks: []string{"RunAsNonRoot"}},
+		{Namespace: "production", Kind: "Deployment", Name: "cron", Outcome: "passing", Score: 3},
+		{Namespace: "production", Kind: "Deployment", Name: "batch", Outcome: "error", Error: "unreachable"},
+	}}
+
+	cmp := Compare(base, target, true)
+	if cmp.NewlyFailing != 1 || cmp.Fixed != 1 || cmp.Changed != 1 || cmp.Unchanged != 1 || cmp.OnlyInBase != 1 || cmp.OnlyInTarget != 1 {
+		t.Fatalf("Compare - want one workload of each change, got %+v", cmp)
+	}
+	if c := Compare(base, target, false); c.OnlyInBase != 5 || c.OnlyInTarget != 5 {
+		t.Fatalf("Compare - want the workloads of other namespaces unmatched, got %+v", c)
+	}
+
+	var table bytes.Buffer
+	if err := cmp.WriteTable(&table, false); err != nil {
+		t.Fatal(err)
+	}
+	want := "NAMESPACE   KIND        NAME    SCORE     DELTA  OUTCOME             CHANGE          DETAIL\n" +
+		"production  Deployment  api     5 -> -30  -35    passing -> failing  newly failing   newly failed checks Privileged\n" +
+		"production  Deployment  batch   - -> -    +0     - -> error          only in target  \n" +
+		"production  Deployment  web     -30 -> 4  +34    failing -> passing  fixed           fixed checks Privileged\n" +
+		"production  Deployment  worker  3 -> 3    +0     passing -> passing  changed         newly failed checks RunAsNonRoot; fixed checks CapSysAdmin\n" +
+		"staging     Deployment  legacy  1 -> -    +0     passing -> -        only in base    \n" +
+		"compared 6 workloads: 1 newly failing, 1 fixed, 1 changed, 1 unchanged, 1 only in base, 1 only in target\n"
+	if table.String() != want {
+		t.Fatalf("WriteTable - want\n%s\ngot\n%s", want, table.String())
+	}
+
+	var out bytes.Buffer
+	if err := cmp.WriteJSON(&out, true); err != nil {
+		t.Fatal(err)
+	}
+	var got Comparison
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Deltas) != 6 || got.Deltas[0].ScoreDelta != -35 {
+		t.Fatalf("WriteJSON - want every workload, got %+v", got)
+	}
+
+	var rep bytes.Buffer
+	if err := base.WriteJSON(&rep, true); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadReport(&rep)
+	if err != nil || len(read.Workloads) != 5 {
+		t.Fatalf("ReadReport - want the workloads written, got %+v, %v", read, err)
+	}
+}
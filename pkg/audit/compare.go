@@ -0,0 +1,276 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/rescan"
+)
+
+// Changes of the workloads between two audits.
+const (
+	// NewlyFailing is a workload failing in the target, not in the base.
+	NewlyFailing = "newly failing"
+	// Fixed is a workload failing in the base, not in the target.
+	Fixed = "fixed"
+	// Changed is a workload whose score or checks changed.
+	Changed = "changed"
+	// Unchanged is a workload audited alike in both.
+	Unchanged = "unchanged"
+	// OnlyInBase is a workload missing from the target.
+	OnlyInBase = "only in base"
+	// OnlyInTarget is a workload missing from the base.
+	OnlyInTarget = "only in target"
+)
+
+// Delta is the difference of a workload between two audits. The outcome and
+// score of the side it is missing from are empty.
+type Delta struct {
+	Namespace     string `json:"namespace"`
+	Kind          string `json:"kind"`
+	Name          string `json:"name"`
+	Change        string `json:"change"`
+	BaseOutcome   string `json:"baseOutcome,omitempty"`
+	TargetOutcome string `json:"targetOutcome,omitempty"`
+	BaseScore     *int   `json:"baseScore,omitempty"`
+	TargetScore   *int   `json:"targetScore,omitempty"`
+	// ScoreDelta is the target score minus the base score, 0 when either
+	// is empty.
+	ScoreDelta int `json:"scoreDelta"`
+	// NewRules are the checks the workload fails in the target only, and
+	// FixedRules those it fails in the base only.
+	NewRules   []string `json:"newRules,omitempty"`
+	FixedRules []string `json:"fixedRules,omitempty"`
+}
+
+// Comparison sums up the differences between a base and a target audit, e.g.
+// staging and production.
+type Comparison struct {
+	NewlyFailing int     `json:"newlyFailing"`
+	Fixed        int     `json:"fixed"`
+	Changed      int     `json:"changed"`
+	Unchanged    int     `json:"unchanged"`
+	OnlyInBase   int     `json:"onlyInBase"`
+	OnlyInTarget int     `json:"onlyInTarget"`
+	Deltas       []Delta `json:"deltas"`
+}
+
+// ReadReport reads a report written by WriteJSON, with -all for the passing
+// workloads to be compared too.
+func ReadReport(r io.Reader) (Report, error) {
+	var rep Report
+	if err := json.NewDecoder(r).Decode(&rep); err != nil {
+		return Report{}, err
+	}
+	return rep, nil
+}
+
+// Compare returns the differences of the workloads of target with those of
+// base, matched by namespace, kind and name, or by kind and name only when
+// ignoreNamespaces is set, e.g. to compare two namespaces.
+func Compare(base, target Report, ignoreNamespaces bool) Comparison {
+	key := func(w Workload) string {
+		if ignoreNamespaces {
+			return w.Kind + "/" + w.Name
+		}
+		return w.Namespace + "/" + w.Kind + "/" + w.Name
+	}
+	targets := map[string]Workload{}
+	for _, w := range target.Workloads {
+		targets[key(w)] = w
+	}
+
+	cmp := Comparison{Deltas: []Delta{}}
+	seen := map[string]bool{}
+	for _, b := range base.Workloads {
+		k := key(b)
+		seen[k] = true
+		t, ok := targets[k]
+		if !ok {
+			cmp.add(Delta{Namespace: b.Namespace, Kind: b.Kind, Name: b.Name, Change: OnlyInBase, BaseOutcome: b.Outcome, BaseScore: score(b)})
+			continue
+		}
+		cmp.add(delta(b, t))
+	}
+	for _, t := range target.Workloads {
+		if !seen[key(t)] {
+			cmp.add(Delta{Namespace: t.Namespace, Kind: t.Kind, Name: t.Name, Change: OnlyInTarget, TargetOutcome: t.Outcome, TargetScore: score(t)})
+		}
+	}
+
+	sort.SliceStable(cmp.Deltas, func(i, j int) bool {
+		a, b := cmp.Deltas[i], cmp.Deltas[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return cmp
+}
+
+func (c *Comparison) add(d Delta) {
+	switch d.Change {
+	case NewlyFailing:
+		c.NewlyFailing++
+	case Fixed:
+		c.Fixed++
+	case Changed:
+		c.Changed++
+	case OnlyInBase:
+		c.OnlyInBase++
+	case OnlyInTarget:
+		c.OnlyInTarget++
+	default:
+		c.Unchanged++
+	}
+	c.Deltas = append(c.Deltas, d)
+}
+
+// delta returns the difference of the workload between the base and the
+// target, located in the target.
+func delta(b, t Workload) Delta {
+	d := Delta{
+		Namespace:     t.Namespace,
+		Kind:          t.Kind,
+		Name:          t.Name,
+		BaseOutcome:   b.Outcome,
+		TargetOutcome: t.Outcome,
+		BaseScore:     score(b),
+		TargetScore:   score(t),
+	}
+	if d.BaseScore != nil && d.TargetScore != nil {
+		d.ScoreDelta = *d.TargetScore - *d.BaseScore
+	}
+	baseRules, targetRules := rules(b), rules(t)
+	for _, r := range targetRules {
+		if !contains(baseRules, r) {
+			d.NewRules = append(d.NewRules, r)
+		}
+	}
+	for _, r := range baseRules {
+		if !contains(targetRules, r) {
+			d.FixedRules = append(d.FixedRules, r)
+		}
+	}
+
+	switch {
+	case t.Outcome == rescan.Failing && b.Outcome != rescan.Failing:
+		d.Change = NewlyFailing
+	case b.Outcome == rescan.Failing && t.Outcome != rescan.Failing:
+		d.Change = Fixed
+	case d.ScoreDelta != 0 || b.Outcome != t.Outcome || len(d.NewRules) > 0 || len(d.FixedRules) > 0:
+		d.Change = Changed
+	default:
+		d.Change = Unchanged
+	}
+	return d
+}
+
+func score(w Workload) *int {
+	if w.Outcome == rescan.Errored {
+		return nil
+	}
+	s := w.Score
+	return &s
+}
+
+// rules returns the checks the workload fails, the failed critical checks,
+// the missing required checks and the denied checks.
+func rules(w Workload) []string {
+	var res []string
+	for _, list := range [][]string{w.FailedRules, w.MissingChecks, w.DeniedRules} {
+		for _, r := range list {
+			if !contains(res, r) {
+				res = append(res, r)
+			}
+		}
+	}
+	return res
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// filter returns the comparison with only the workloads that differ unless
+// all is set.
+func (c Comparison) filter(all bool) Comparison {
+	if all {
+		return c
+	}
+	deltas := []Delta{}
+	for _, d := range c.Deltas {
+		if d.Change != Unchanged {
+			deltas = append(deltas, d)
+		}
+	}
+	c.Deltas = deltas
+	return c
+}
+
+// WriteTable renders the comparison as a table of the workloads that differ,
+// of all of them when all is set, followed by the totals.
+func (c Comparison) WriteTable(w io.Writer, all bool) error {
+	c = c.filter(all)
+	if len(c.Deltas) > 0 {
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAMESPACE\tKIND\tNAME\tSCORE\tDELTA\tOUTCOME\tCHANGE\tDETAIL")
+		for _, d := range c.Deltas {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s -> %s\t%+d\t%s -> %s\t%s\t%s\n", d.Namespace, d.Kind, d.Name,
+				orNone(d.BaseScore), orNone(d.TargetScore), d.ScoreDelta, orDash(d.BaseOutcome), orDash(d.TargetOutcome), d.Change, deltaDetail(d))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	total := c.NewlyFailing + c.Fixed + c.Changed + c.Unchanged + c.OnlyInBase + c.OnlyInTarget
+	_, err := fmt.Fprintf(w, "compared %d workloads: %d newly failing, %d fixed, %d changed, %d unchanged, %d only in base, %d only in target\n",
+		total, c.NewlyFailing, c.Fixed, c.Changed, c.Unchanged, c.OnlyInBase, c.OnlyInTarget)
+	return err
+}
+
+// WriteJSON renders the comparison as JSON, the workloads filtered as by
+// WriteTable.
+func (c Comparison) WriteJSON(w io.Writer, all bool) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c.filter(all))
+}
+
+func orNone(score *int) string {
+	if score == nil {
+		return "-"
+	}
+	return fmt.Sprint(*score)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// deltaDetail returns the checks the workload newly fails and passes.
+func deltaDetail(d Delta) string {
+	var details []string
+	if len(d.NewRules) > 0 {
+		details = append(details, "newly failed checks "+strings.Join(d.NewRules, ","))
+	}
+	if len(d.FixedRules) > 0 {
+		details = append(details, "fixed checks "+strings.Join(d.FixedRules, ","))
+	}
+	return strings.Join(details, "; ")
+}
@@ -0,0 +1,153 @@
+// Package auditlog appends the admission decisions to a JSON lines file,
+// apart from the operational logs, for the compliance retention.
+package auditlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// backupTimeFormat is the format of the rotation times in the names of the
+// rotated files, sorting as the times.
+const backupTimeFormat = "20060102T150405.000"
+
+// Writer appends a JSON line per decision to a file, rotated once it grows
+// past its maximum size or age. The rotated files are renamed after the time
+// of the rotation, e.g. decisions-20221201T100000.000.jsonl. It satisfies
+// decision.Sink and must be started to close the file on shutdown.
+type Writer struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	now        func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// NewWriter returns a writer appending to the file at path, rotated past
+// maxSize bytes or maxAge, keeping the latest maxBackups rotated files, all
+// of them when 0. The size or the age is not limited when 0.
+func NewWriter(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*Writer, error) {
+	if path == "" {
+		return nil, fmt.Errorf("audit log path can't be empty")
+	}
+	if maxSize < 0 || maxAge < 0 || maxBackups < 0 {
+		return nil, fmt.Errorf("audit log limits must not be negative")
+	}
+	w := &Writer{path: path, maxSize: maxSize, maxAge: maxAge, maxBackups: maxBackups, now: time.Now}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write satisfies decision.Sink interface.
+func (w *Writer) Write(_ context.Context, rec decision.Record) error {
+	raw, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	raw = append(raw, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("audit log %s is closed", w.path)
+	}
+	if w.size > 0 && (w.maxSize > 0 && w.size+int64(len(raw)) > w.maxSize || w.maxAge > 0 && w.now().Sub(w.opened) >= w.maxAge) {
+		if err := w.rotate(); err != nil {
+			return fmt.Errorf("could not rotate audit log: %w", err)
+		}
+	}
+	n, err := w.file.Write(raw)
+	w.size += int64(n)
+	return err
+}
+
+// Start closes the file once the context is done.
+func (w *Writer) Start(ctx context.Context) error {
+	<-ctx.Done()
+	return w.Close()
+}
+
+// NeedLeaderElection tells the manager every replica logs the decisions it took.
+func (w *Writer) NeedLeaderElection() bool {
+	return false
+}
+
+// Close closes the file, the decisions written after are rejected.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// open opens the file for appending, the age of an existing file counts from
+// its opening.
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("could not open audit log: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("could not open audit log: %w", err)
+	}
+	w.file, w.size, w.opened = f, info.Size(), w.now()
+	return nil
+}
+
+// rotate renames the file after the time of the rotation, opens a new one and
+// deletes the oldest rotated files past the backups.
+func (w *Writer) rotate() error {
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.file = nil
+
+	ext := filepath.Ext(w.path)
+	base := strings.TrimSuffix(w.path, ext)
+	if err := os.Rename(w.path, base+"-"+w.now().UTC().Format(backupTimeFormat)+ext); err != nil {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	if w.maxBackups == 0 {
+		return nil
+	}
+
+	backups, err := filepath.Glob(base + "-*" + ext)
+	if err != nil {
+		return err
+	}
+	sort.Strings(backups)
+	for len(backups) > w.maxBackups {
+		if err := os.Remove(backups[0]); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package auditlog
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// TestWriter_Write - tests the decisions are appended and the file rotated
+func TestWriter_Write(t *testing.T) {
+	line, err := json.Marshal(decision.Record{Kind: "pod", Namespace: "foo", Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	size := int64(len(line) + 1)
+
+	tests := []struct {
+		name       string
+		maxSize    int64
+		maxAge     time.Duration
+		maxBackups int
+		writes     int
+		step       time.Duration
+		want       []int // lines of the current file then of the rotated ones, oldest first
+	}{
+		{name: "unlimited", writes: 5, want: []int{5}},
+		{name: "size", maxSize: 2 * size, writes: 5, want: []int{1, 2, 2}},
+		{name: "age", maxAge: time.Hour, writes: 4, step: 40 * time.Minute, want: []int{2, 2}},
+		{name: "backups", maxSize: size, maxBackups: 2, writes: 5, want: []int{1, 1, 1}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "decisions.jsonl")
+			now := time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC)
+
+			w, err := NewWriter(path, tt.maxSize, tt.maxAge, tt.maxBackups)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.now = func() time.Time { return now }
+			w.opened = now
+			for n := 0; n < tt.writes; n++ {
+				if err := w.Write(context.Background(), decision.Record{Kind: "pod", Namespace: "foo", Name: "test"}); err != nil {
+					t.Fatal(err)
+				}
+				now = now.Add(tt.step + time.Second)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			backups, err := filepath.Glob(filepath.Join(dir, "decisions-*.jsonl"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files := append([]string{path}, backups...)
+			if len(files) != len(tt.want) {
+				t.Fatalf("Write - want %d files, got %v", len(tt.want), files)
+			}
+			for n, f := range files {
+				if got := lines(t, f); got != tt.want[n] {
+					t.Fatalf("Write - want %d lines in %s, got %d", tt.want[n], f, got)
+				}
+			}
+		})
+	}
+}
+
+// TestWriter_Close - tests the decisions are rejected once closed, and
+// appended to the existing file on reopening
+func TestWriter_Close(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "decisions.jsonl")
+	for n := 0; n < 2; n++ {
+		w, err := NewWriter(path, 0, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(context.Background(), decision.Record{}); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(context.Background(), decision.Record{}); err == nil {
+			t.Fatal("Write - want error once closed")
+		}
+	}
+	if got := lines(t, path); got != 2 {
+		t.Fatalf("Close - want 2 lines, got %d", got)
+	}
+}
+
+func lines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var n int
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var rec decision.Record
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			t.Fatalf("%s: invalid line %q: %v", path, s.Text(), err)
+		}
+		n++
+	}
+	return n
+}
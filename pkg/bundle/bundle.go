@@ -0,0 +1,303 @@
+// Package bundle pulls policy bundles from OCI registries, so admission policy
+// is distributed and versioned the same way as images.
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+)
+
+const (
+	// PolicyMediaType is the media type of the layer holding the policy
+	// configuration. A bundle with a single layer is read whatever its type.
+	PolicyMediaType = "application/vnd.kubesec.policy.layer.v1+yaml"
+
+	// maxBlobSize bounds the size of the manifests and layers pulled.
+	maxBlobSize = 4 << 20
+)
+
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+type manifest struct {
+	Layers []descriptor `json:"layers"`
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// Puller pulls bundles from OCI registries.
+type Puller struct {
+	// Username and Password authenticate to the registry, anonymous when empty.
+	Username string
+	Password string
+	// PlainHTTP talks to the registry without TLS.
+	PlainHTTP bool
+	Client    *http.Client
+}
+
+// Bundle is the policy pulled from a registry.
+type Bundle struct {
+	// Digest is the digest of the manifest.
+	Digest string
+	Policy []byte
+}
+
+// Pull returns the policy of the bundle. The manifest digest is checked when
+// the reference is pinned, and every blob is checked against its digest.
+func (p *Puller) Pull(ctx context.Context, ref Reference) (Bundle, error) {
+	raw, err := p.get(ctx, ref, "manifests/"+ref.manifestRef(), strings.Join(manifestMediaTypes, ", "))
+	if err != nil {
+		return Bundle{}, fmt.Errorf("could not pull manifest of %s: %w", ref, err)
+	}
+	digest := sha256Digest(raw)
+	if ref.Digest != "" && digest != ref.Digest {
+		return Bundle{}, fmt.Errorf("manifest of %s has digest %s", ref, digest)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return Bundle{}, fmt.Errorf("invalid manifest of %s: %w", ref, err)
+	}
+	layer, err := policyLayer(m)
+	if err != nil {
+		return Bundle{}, fmt.Errorf("invalid bundle %s: %w", ref, err)
+	}
+
+	policy, err := p.get(ctx, ref, "blobs/"+layer.Digest, "")
+	if err != nil {
+		return Bundle{}, fmt.Errorf("could not pull policy of %s: %w", ref, err)
+	}
+	if got := sha256Digest(policy); got != layer.Digest {
+		return Bundle{}, fmt.Errorf("policy layer of %s has digest %s, want %s", ref, got, layer.Digest)
+	}
+
+	return Bundle{Digest: digest, Policy: policy}, nil
+}
+
+func policyLayer(m manifest) (descriptor, error) {
+	for _, l := range m.Layers {
+		if l.MediaType == PolicyMediaType {
+			return l, nil
+		}
+	}
+	if len(m.Layers) == 1 {
+		return m.Layers[0], nil
+	}
+	return descriptor{}, fmt.Errorf("no layer of type %s", PolicyMediaType)
+}
+
+func (p *Puller) get(ctx context.Context, ref Reference, path, accept string) ([]byte, error) {
+	scheme := "https"
+	if p.PlainHTTP {
+		scheme = "http"
+	}
+	u := fmt.Sprintf("%s://%s/v2/%s/%s", scheme, ref.Registry, ref.Repository, path)
+
+	resp, err := p.do(ctx, u, accept, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		token, err := p.token(ctx, challenge)
+		if err != nil {
+			return nil, err
+		}
+		if resp, err = p.do(ctx, u, accept, token); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got %v response from %v", resp.StatusCode, u)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxBlobSize))
+}
+
+func (p *Puller) do(ctx context.Context, u, accept, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	switch {
+	case token != "":
+		req.Header.Set("Authorization", "Bearer "+token)
+	case p.Username != "":
+		req.SetBasicAuth(p.Username, p.Password)
+	}
+	return p.client().Do(req)
+}
+
+// token answers a Bearer challenge of the registry.
+func (p *Puller) token(ctx context.Context, challenge string) (string, error) {
+	params, ok := parseChallenge(challenge)
+	if !ok || params["realm"] == "" {
+		return "", fmt.Errorf("unauthorized, unsupported challenge %q", challenge)
+	}
+
+	q := url.Values{}
+	for _, k := range []string{"service", "scope"} {
+		if v := params[k]; v != "" {
+			q.Set(k, v)
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params["realm"]+"?"+q.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	if p.Username != "" {
+		req.SetBasicAuth(p.Username, p.Password)
+	}
+	resp, err := p.client().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("got %v response from token endpoint %v", resp.StatusCode, params["realm"])
+	}
+
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBlobSize)).Decode(&t); err != nil {
+		return "", err
+	}
+	if t.Token != "" {
+		return t.Token, nil
+	}
+	return t.AccessToken, nil
+}
+
+func (p *Puller) client() *http.Client {
+	if p.Client == nil {
+		return http.DefaultClient
+	}
+	return p.Client
+}
+
+// parseChallenge parses Bearer realm="...",service="...",scope="...".
+func parseChallenge(challenge string) (map[string]string, bool) {
+	scheme, rest, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return nil, false
+	}
+	params := map[string]string{}
+	for rest != "" {
+		var kv string
+		// Values are quoted and may contain commas, e.g. in scopes.
+		k, v, found := strings.Cut(rest, "=")
+		if !found {
+			break
+		}
+		k = strings.TrimSpace(k)
+		if strings.HasPrefix(v, `"`) {
+			end := strings.Index(v[1:], `"`)
+			if end < 0 {
+				return nil, false
+			}
+			kv, rest = v[1:end+1], strings.TrimPrefix(v[end+2:], ",")
+		} else {
+			kv, rest, _ = strings.Cut(v, ",")
+		}
+		params[k] = kv
+	}
+	return params, true
+}
+
+func sha256Digest(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Refresher pulls a bundle periodically and applies its policy when the
+// manifest digest changed. It must be started to refresh.
+type Refresher struct {
+	puller   *Puller
+	ref      Reference
+	interval time.Duration
+	apply    func(policy []byte) error
+	logger   log.Logger
+
+	digest string
+}
+
+// NewRefresher returns a refresher applying the policy of ref every interval.
+func NewRefresher(puller *Puller, ref Reference, interval time.Duration, apply func(policy []byte) error, logger log.Logger) (*Refresher, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("bundle refresh interval must be positive")
+	}
+	return &Refresher{
+		puller:   puller,
+		ref:      ref,
+		interval: interval,
+		apply:    apply,
+		logger:   logger,
+	}, nil
+}
+
+// Refresh pulls the bundle and applies it when it changed.
+func (r *Refresher) Refresh(ctx context.Context) error {
+	b, err := r.puller.Pull(ctx, r.ref)
+	if err != nil {
+		return err
+	}
+	if b.Digest == r.digest {
+		return nil
+	}
+	if err := r.apply(b.Policy); err != nil {
+		return fmt.Errorf("could not apply bundle %s@%s: %w", r.ref, b.Digest, err)
+	}
+	r.digest = b.Digest
+	r.logger.Infof("applied policy bundle %s@%s", r.ref, b.Digest)
+	return nil
+}
+
+// Start refreshes the bundle every interval until the context is done. A
+// pinned reference never changes and is not refreshed.
+func (r *Refresher) Start(ctx context.Context) error {
+	if r.ref.Digest != "" {
+		return nil
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := r.Refresh(ctx); err != nil {
+				r.logger.Errorf("could not refresh policy bundle, keeping the current policy: %v", err)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager every replica refreshes its policy.
+func (r *Refresher) NeedLeaderElection() bool {
+	return false
+}
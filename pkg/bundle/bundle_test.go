@@ -0,0 +1,128 @@
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+)
+
+// testRegistry serves a single bundle behind a token challenge.
+func testRegistry(t *testing.T, policy string) (*httptest.Server, string) {
+	t.Helper()
+	layer := sha256Digest([]byte(policy))
+	m, _ := json.Marshal(manifest{Layers: []descriptor{{MediaType: PolicyMediaType, Digest: layer, Size: int64(len(policy))}}})
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if r.URL.Query().Get("scope") != "repository:org/policy:pull" {
+				t.Errorf("token - unexpected scope %q", r.URL.Query().Get("scope"))
+			}
+			fmt.Fprint(w, `{"token":"t0k3n"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0k3n" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:org/policy:pull"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/org/policy/manifests/v1", "/v2/org/policy/manifests/" + sha256Digest(m):
+			_, _ = w.Write(m)
+		case "/v2/org/policy/blobs/" + layer:
+			fmt.Fprint(w, policy)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	return srv, sha256Digest(m)
+}
+
+// TestPuller_Pull - tests the pull of a bundle by tag and by digest
+func TestPuller_Pull(t *testing.T) {
+	policy := "images:\n- pattern: '*'\n  minScore: 3\n"
+	srv, digest := testRegistry(t, policy)
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	p := &Puller{PlainHTTP: true}
+
+	tests := []struct {
+		name    string
+		ref     string
+		wantErr bool
+	}{
+		{name: "tag", ref: host + "/org/policy:v1"},
+		{name: "pinned digest", ref: host + "/org/policy@" + digest},
+		{name: "digest mismatch", ref: host + "/org/policy:v1@sha256:" + strings.Repeat("0", 64), wantErr: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ref, err := ParseReference(tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := p.Pull(context.Background(), ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Pull - wantErr=%v, got %v", tt.wantErr, err)
+			}
+			if !tt.wantErr && (string(b.Policy) != policy || b.Digest != digest) {
+				t.Fatalf("Pull - unexpected bundle %+v", b)
+			}
+		})
+	}
+}
+
+// TestRefresher_Refresh - tests the policy is only applied when the bundle changed
+func TestRefresher_Refresh(t *testing.T) {
+	srv, _ := testRegistry(t, "images: []\n")
+	defer srv.Close()
+	ref, err := ParseReference(strings.TrimPrefix(srv.URL, "http://") + "/org/policy:v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied := 0
+	r, err := NewRefresher(&Puller{PlainHTTP: true}, ref, 1, func([]byte) error { applied++; return nil }, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := r.Refresh(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if applied != 1 {
+		t.Fatalf("Refresh - want the bundle applied once, got %d", applied)
+	}
+}
+
+// TestParseReference - tests the parsing of bundle references
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    Reference
+		wantErr bool
+	}{
+		{ref: "ghcr.io/org/policy:v1", want: Reference{Registry: "ghcr.io", Repository: "org/policy", Tag: "v1"}},
+		{ref: "localhost:5000/policy", want: Reference{Registry: "localhost:5000", Repository: "policy", Tag: "latest"}},
+		{ref: "docker.io/org/policy:v2", want: Reference{Registry: "registry-1.docker.io", Repository: "org/policy", Tag: "v2"}},
+		{ref: "org/policy:v1", wantErr: true},
+		{ref: "ghcr.io/org/policy@md5:abc", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseReference(tt.ref)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParseReference(%q) - wantErr=%v, got %v", tt.ref, tt.wantErr, err)
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Fatalf("ParseReference(%q) - want=%+v, got=%+v", tt.ref, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package bundle
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Reference points to an artifact of an OCI registry, by tag or digest.
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	// Digest pins the manifest, it takes precedence over the tag.
+	Digest string
+}
+
+// ParseReference parses references such as ghcr.io/org/policy:v1 or
+// registry.internal/policy@sha256:... The registry is mandatory.
+func ParseReference(ref string) (Reference, error) {
+	var r Reference
+	registry, rest, found := strings.Cut(ref, "/")
+	if !found || !strings.ContainsAny(registry, ".:") && registry != "localhost" {
+		return r, fmt.Errorf("invalid reference %q: registry host is required", ref)
+	}
+	if registry == "docker.io" {
+		registry = "registry-1.docker.io"
+	}
+	r.Registry = registry
+
+	if name, digest, found := strings.Cut(rest, "@"); found {
+		if !strings.HasPrefix(digest, "sha256:") || len(digest) != len("sha256:")+64 {
+			return r, fmt.Errorf("invalid reference %q: only sha256 digests are supported", ref)
+		}
+		r.Digest = digest
+		rest = name
+	}
+
+	if i := strings.LastIndex(rest, ":"); i > strings.LastIndex(rest, "/") {
+		r.Tag = rest[i+1:]
+		rest = rest[:i]
+	}
+	if rest == "" {
+		return r, fmt.Errorf("invalid reference %q: repository is required", ref)
+	}
+	r.Repository = rest
+	if r.Tag == "" && r.Digest == "" {
+		r.Tag = "latest"
+	}
+
+	return r, nil
+}
+
+// manifestRef returns what the manifest is pulled by.
+func (r Reference) manifestRef() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// String returns the reference in its parsed form.
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
@@ -0,0 +1,58 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"fmt"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CABundle returns the last certificate of the PEM chain, its CA when the
+// chain goes up to it, the certificate itself when self-signed.
+func CABundle(chainPEM []byte) ([]byte, error) {
+	var last *pem.Block
+	for rest := chainPEM; ; {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			last = block
+		}
+	}
+	if last == nil {
+		return nil, fmt.Errorf("no certificate in the TLS certificate chain")
+	}
+	return pem.EncodeToMemory(last), nil
+}
+
+// InjectCABundle sets the caBundle of the webhooks of the
+// ValidatingWebhookConfiguration, it tells whether it patched any.
+func InjectCABundle(ctx context.Context, c client.Client, name string, caPEM []byte) (bool, error) {
+	cfg := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	if err := c.Get(ctx, types.NamespacedName{Name: name}, cfg); err != nil {
+		return false, fmt.Errorf("could not read webhook configuration %s: %w", name, err)
+	}
+
+	// The webhooks are patched as a whole, they must not have changed since.
+	patch := client.MergeFromWithOptions(cfg.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	changed := false
+	for i := range cfg.Webhooks {
+		if !bytes.Equal(cfg.Webhooks[i].ClientConfig.CABundle, caPEM) {
+			cfg.Webhooks[i].ClientConfig.CABundle = caPEM
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	if err := c.Patch(ctx, cfg, patch); err != nil {
+		return false, fmt.Errorf("could not inject the caBundle of webhook configuration %s: %w", name, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,72 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// TestCABundle - tests the CA is the last certificate of the chain
+func TestCABundle(t *testing.T) {
+	leaf, _, err := SelfSigned([]string{"leaf"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _, err := SelfSigned([]string{"ca"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := CABundle(append(append([]byte{}, leaf...), ca...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, ca) {
+		t.Errorf("CABundle - want the last certificate of the chain, got %s", got)
+	}
+	if got, err := CABundle(leaf); err != nil || !bytes.Equal(got, leaf) {
+		t.Errorf("CABundle - want the self-signed certificate, got %s, %v", got, err)
+	}
+	if _, err := CABundle([]byte("nope")); err == nil {
+		t.Error("CABundle - want an error without certificate")
+	}
+}
+
+// TestInjectCABundle - tests the caBundle of every webhook is set
+func TestInjectCABundle(t *testing.T) {
+	ctx := context.Background()
+	cfg := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: "kubesec-webhook"},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{Name: "deployment.admission.kubesc.io", ClientConfig: admissionregistrationv1.WebhookClientConfig{CABundle: []byte("old")}},
+			{Name: "pod.admission.kubesc.io"},
+		},
+	}
+	c := fake.NewClientBuilder().WithObjects(cfg).Build()
+
+	if injected, err := InjectCABundle(ctx, c, "kubesec-webhook", []byte("ca")); !injected || err != nil {
+		t.Fatalf("InjectCABundle - want the caBundle injected, got %v, %v", injected, err)
+	}
+	got := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "kubesec-webhook"}, got); err != nil {
+		t.Fatal(err)
+	}
+	for _, wh := range got.Webhooks {
+		if string(wh.ClientConfig.CABundle) != "ca" {
+			t.Errorf("InjectCABundle - %s: want caBundle ca, got %q", wh.Name, wh.ClientConfig.CABundle)
+		}
+	}
+
+	if injected, err := InjectCABundle(ctx, c, "kubesec-webhook", []byte("ca")); injected || err != nil {
+		t.Fatalf("InjectCABundle - want an up to date caBundle left alone, got %v, %v", injected, err)
+	}
+	if _, err := InjectCABundle(ctx, c, "missing", []byte("ca")); err == nil {
+		t.Fatal("InjectCABundle - want an error for a missing configuration")
+	}
+}
@@ -0,0 +1,130 @@
+// Package certs serves the TLS certificate of the webhook.
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/slok/kubewebhook/pkg/log"
+)
+
+// Reloader serves a TLS keypair read from files, reloaded as soon as they
+// change, so a rotated certificate is served without restarting. It watches
+// the directories of the files, which survives the symlink swaps of the
+// Secret volumes, and checks the files every interval in case an event was
+// missed. A keypair that does not load, e.g. a certificate written before its
+// key, is not served: the previous one is kept until the next change. It must
+// be started to reload.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	logger   log.Logger
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// NewReloader returns a reloader of the keypair of the files, loaded once.
+func NewReloader(certFile, keyFile string, interval time.Duration, logger log.Logger) (*Reloader, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("certificate check interval must be positive")
+	}
+	r := &Reloader{certFile: certFile, keyFile: keyFile, interval: interval, logger: logger}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate returns the current keypair, to be set as
+// tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload reads the files and serves their keypair when it changed, it tells
+// whether it did.
+func (r *Reloader) Reload() (bool, error) {
+	certPEM, err := os.ReadFile(r.certFile)
+	if err != nil {
+		return false, fmt.Errorf("could not read TLS certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("could not read TLS key: %w", err)
+	}
+
+	r.mu.RLock()
+	unchanged := bytes.Equal(certPEM, r.certPEM) && bytes.Equal(keyPEM, r.keyPEM)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return false, fmt.Errorf("invalid TLS keypair %s, %s: %w", r.certFile, r.keyFile, err)
+	}
+
+	r.mu.Lock()
+	r.cert, r.certPEM, r.keyPEM = &cert, certPEM, keyPEM
+	r.mu.Unlock()
+	return true, nil
+}
+
+// Start reloads the keypair as the files change until the context is done.
+func (r *Reloader) Start(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	dirs := map[string]bool{filepath.Dir(r.certFile): true, filepath.Dir(r.keyFile): true}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("could not watch %s: %w", dir, err)
+		}
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			r.logger.Warningf("TLS certificate watch error: %v", err)
+			continue
+		case <-watcher.Events:
+		case <-ticker.C:
+		}
+
+		reloaded, err := r.Reload()
+		if err != nil {
+			r.logger.Warningf("keeping the current TLS certificate: %v", err)
+			continue
+		}
+		if reloaded {
+			r.logger.Infof("reloaded TLS certificate %s", r.certFile)
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager every replica serves its certificate.
+func (r *Reloader) NeedLeaderElection() bool {
+	return false
+}
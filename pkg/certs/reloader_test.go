@@ -0,0 +1,134 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+)
+
+// writeKeypair writes a self-signed keypair of the common name.
+func writeKeypair(t *testing.T, certFile, keyFile, cn string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func servedName(t *testing.T, r *Reloader) string {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+// TestReloader_Reload - tests rotated keypairs are served and invalid ones are not
+func TestReloader_Reload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeKeypair(t, certFile, keyFile, "first")
+
+	r, err := NewReloader(certFile, keyFile, time.Minute, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := servedName(t, r); got != "first" {
+		t.Fatalf("GetCertificate - want first, got %s", got)
+	}
+	if reloaded, err := r.Reload(); reloaded || err != nil {
+		t.Fatalf("Reload - want unchanged files ignored, got %v, %v", reloaded, err)
+	}
+
+	writeKeypair(t, certFile, keyFile, "second")
+	if reloaded, err := r.Reload(); !reloaded || err != nil {
+		t.Fatalf("Reload - want the rotated keypair, got %v, %v", reloaded, err)
+	}
+	if got := servedName(t, r); got != "second" {
+		t.Fatalf("GetCertificate - want second, got %s", got)
+	}
+
+	// A certificate written before its key is not served.
+	other := t.TempDir()
+	writeKeypair(t, filepath.Join(other, "cert.pem"), filepath.Join(other, "key.pem"), "third")
+	raw, err := os.ReadFile(filepath.Join(other, "cert.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reload(); err == nil {
+		t.Fatal("Reload - want an invalid keypair error")
+	}
+	if got := servedName(t, r); got != "second" {
+		t.Fatalf("GetCertificate - want second kept, got %s", got)
+	}
+}
+
+// TestReloader_Start - tests the keypair is reloaded as the files change
+func TestReloader_Start(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeKeypair(t, certFile, keyFile, "first")
+
+	r, err := NewReloader(certFile, keyFile, time.Hour, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error)
+	go func() { done <- r.Start(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for servedName(t, r) != "second" {
+		if time.Now().After(deadline) {
+			t.Fatal("Start - the rotated keypair was not served")
+		}
+		// The watch may not be set up yet, rotate until it is seen.
+		writeKeypair(t, certFile, keyFile, "second")
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
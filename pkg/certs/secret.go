@@ -0,0 +1,120 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SecretSync copies the keypair of a kubernetes.io/tls Secret, read through
+// the API every interval, to the files a Reloader serves, so the webhook needs
+// no Secret volume and a rotated Secret is served without remounting it. It
+// must be started to sync.
+type SecretSync struct {
+	reader   client.Reader
+	key      types.NamespacedName
+	certFile string
+	keyFile  string
+	interval time.Duration
+	logger   log.Logger
+}
+
+// NewSecretSync returns a sync of the Secret to the files.
+func NewSecretSync(reader client.Reader, namespace, name, certFile, keyFile string, interval time.Duration, logger log.Logger) (*SecretSync, error) {
+	if namespace == "" || name == "" {
+		return nil, fmt.Errorf("TLS secret needs a namespace and a name")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("TLS secret refresh interval must be positive")
+	}
+	return &SecretSync{
+		reader:   reader,
+		key:      types.NamespacedName{Namespace: namespace, Name: name},
+		certFile: certFile,
+		keyFile:  keyFile,
+		interval: interval,
+		logger:   logger,
+	}, nil
+}
+
+// Sync reads the Secret and writes the files that changed, it tells whether
+// any did.
+func (s *SecretSync) Sync(ctx context.Context) (bool, error) {
+	secret := &corev1.Secret{}
+	if err := s.reader.Get(ctx, s.key, secret); err != nil {
+		return false, fmt.Errorf("could not read TLS secret %s: %w", s.key, err)
+	}
+	certPEM, keyPEM := secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]
+	if len(certPEM) == 0 || len(keyPEM) == 0 {
+		return false, fmt.Errorf("TLS secret %s has no %s or %s", s.key, corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+	}
+
+	changed := false
+	// The key goes first, the Reloader serves the pair once the certificate
+	// matches it.
+	for _, f := range []struct {
+		path string
+		data []byte
+	}{{s.keyFile, keyPEM}, {s.certFile, certPEM}} {
+		written, err := writeIfChanged(f.path, f.data)
+		if err != nil {
+			return changed, err
+		}
+		changed = changed || written
+	}
+	return changed, nil
+}
+
+// writeIfChanged replaces the file by data, atomically, unless it holds it.
+func writeIfChanged(path string, data []byte) (bool, error) {
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return false, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path))
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp.Name(), path)
+}
+
+// Start syncs the Secret every interval until the context is done.
+func (s *SecretSync) Start(ctx context.Context) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			synced, err := s.Sync(ctx)
+			if err != nil {
+				s.logger.Errorf("could not sync TLS secret, keeping the current certificate: %v", err)
+			} else if synced {
+				s.logger.Infof("synced TLS secret %s", s.key)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager every replica syncs its certificate.
+func (s *SecretSync) NeedLeaderElection() bool {
+	return false
+}
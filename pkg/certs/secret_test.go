@@ -0,0 +1,79 @@
+package certs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// TestSecretSync_Sync - tests the keypair of the Secret is written to the files the Reloader serves
+func TestSecretSync_Sync(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+
+	certPEM, keyPEM, err := SelfSigned([]string{"first"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kubesec", Name: "certs"},
+		Type:       corev1.SecretTypeTLS,
+		Data:       map[string][]byte{corev1.TLSCertKey: certPEM, corev1.TLSPrivateKeyKey: keyPEM},
+	}
+	c := fake.NewClientBuilder().WithObjects(secret).Build()
+
+	s, err := NewSecretSync(c, "kubesec", "certs", certFile, keyFile, time.Minute, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if synced, err := s.Sync(ctx); !synced || err != nil {
+		t.Fatalf("Sync - want the files written, got %v, %v", synced, err)
+	}
+	r, err := NewReloader(certFile, keyFile, time.Minute, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := servedName(t, r); got != "first" {
+		t.Fatalf("GetCertificate - want first, got %s", got)
+	}
+	if synced, err := s.Sync(ctx); synced || err != nil {
+		t.Fatalf("Sync - want an unchanged Secret ignored, got %v, %v", synced, err)
+	}
+
+	// Rotation.
+	if secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey], err = SelfSigned([]string{"second"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if synced, err := s.Sync(ctx); !synced || err != nil {
+		t.Fatalf("Sync - want the rotated files written, got %v, %v", synced, err)
+	}
+	if _, err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := servedName(t, r); got != "second" {
+		t.Fatalf("GetCertificate - want second, got %s", got)
+	}
+
+	// A Secret without keypair does not touch the files.
+	secret.Data = nil
+	if err := c.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Sync(ctx); err == nil {
+		t.Fatal("Sync - want an error without keypair")
+	}
+	if raw, err := os.ReadFile(certFile); err != nil || len(raw) == 0 {
+		t.Fatalf("Sync - want the certificate kept, got %v", err)
+	}
+}
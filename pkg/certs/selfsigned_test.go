@@ -0,0 +1,43 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestSelfSigned - tests the self-signed certificate is valid for its SANs and is its own CA
+func TestSelfSigned(t *testing.T) {
+	certPEM, keyPEM, err := SelfSigned([]string{"localhost", "127.0.0.1", "kubesec-webhook.kubesec.svc"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+	for _, name := range []string{"localhost", "127.0.0.1", "kubesec-webhook.kubesec.svc"} {
+		if _, err := leaf.Verify(x509.VerifyOptions{DNSName: name, Roots: roots}); err != nil {
+			t.Errorf("Verify %s - %v", name, err)
+		}
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: "example.com", Roots: roots}); err == nil {
+		t.Error("Verify example.com - want an error")
+	}
+	if len(leaf.IPAddresses) != 1 || !leaf.IPAddresses[0].Equal(net.ParseIP("127.0.0.1")) {
+		t.Errorf("IPAddresses - want 127.0.0.1, got %v", leaf.IPAddresses)
+	}
+
+	if _, _, err := SelfSigned(nil, time.Hour); err == nil {
+		t.Error("SelfSigned - want an error without SAN")
+	}
+}
@@ -0,0 +1,60 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSVersion returns the TLS version of its name, 1.2 or 1.3.
+func TLSVersion(name string) (uint16, error) {
+	switch name {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("invalid TLS version %q, want 1.2 or 1.3", name)
+}
+
+// CipherSuites returns the IDs of the cipher suites of their names, e.g.
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Insecure suites are rejected. They
+// only apply to TLS 1.2, the suites of TLS 1.3 are not configurable.
+func CipherSuites(names []string) ([]uint16, error) {
+	suites := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		suites[s.Name] = s.ID
+	}
+	insecure := map[string]bool{}
+	for _, s := range tls.InsecureCipherSuites() {
+		insecure[s.Name] = true
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		id, ok := suites[name]
+		if !ok {
+			if insecure[name] {
+				return nil, fmt.Errorf("insecure TLS cipher suite %s", name)
+			}
+			return nil, fmt.Errorf("unknown TLS cipher suite %s", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ClientCAs returns the pool of the PEM CAs of the file, verifying the client
+// certificates.
+func ClientCAs(path string) (*x509.CertPool, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read client CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(raw) {
+		return nil, fmt.Errorf("no certificate in client CA %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,86 @@
+package certs
+
+import (
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCipherSuites - tests the cipher suites are looked up by name and insecure ones rejected
+func TestCipherSuites(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  []uint16
+		err   string
+	}{
+		{name: "none"},
+		{
+			name:  "secure",
+			names: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"},
+			want:  []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256},
+		},
+		{name: "insecure", names: []string{"TLS_RSA_WITH_RC4_128_SHA"}, err: "insecure TLS cipher suite"},
+		{name: "unknown", names: []string{"TLS_NOPE"}, err: "unknown TLS cipher suite TLS_NOPE"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CipherSuites(tt.names)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("CipherSuites - want error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CipherSuites - want %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestTLSVersion - tests only TLS 1.2 and 1.3 are accepted
+func TestTLSVersion(t *testing.T) {
+	if v, err := TLSVersion("1.2"); err != nil || v != tls.VersionTLS12 {
+		t.Errorf("TLSVersion 1.2 - got %v, %v", v, err)
+	}
+	if v, err := TLSVersion("1.3"); err != nil || v != tls.VersionTLS13 {
+		t.Errorf("TLSVersion 1.3 - got %v, %v", v, err)
+	}
+	if _, err := TLSVersion("1.0"); err == nil {
+		t.Error("TLSVersion 1.0 - want an error")
+	}
+}
+
+// TestClientCAs - tests the client CAs are read from PEM
+func TestClientCAs(t *testing.T) {
+	dir := t.TempDir()
+	ca, _, err := SelfSigned([]string{"kube-apiserver"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ca.pem"), ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "empty.pem"), []byte("nope"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ClientCAs(filepath.Join(dir, "ca.pem")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ClientCAs(filepath.Join(dir, "empty.pem")); err == nil || !strings.Contains(err.Error(), "no certificate") {
+		t.Errorf("ClientCAs - want a no certificate error, got %v", err)
+	}
+	if _, err := ClientCAs(filepath.Join(dir, "missing.pem")); err == nil {
+		t.Error("ClientCAs - want an error for a missing file")
+	}
+}
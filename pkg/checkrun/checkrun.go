@@ -0,0 +1,375 @@
+// Package checkrun reports the decisions on manifests as a GitHub Check Run,
+// with an annotation on the manifest line of every failing check, so the
+// findings show inline in the pull requests.
+package checkrun
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+const (
+	// DefaultURL is the URL of the API of github.com.
+	DefaultURL = "https://api.github.com"
+	// DefaultName is the name of the check runs.
+	DefaultName = "kubesec"
+
+	// maxAnnotations is the number of annotations GitHub accepts per request,
+	// the next ones are added by updating the check run.
+	maxAnnotations = 50
+	// requestTimeout bounds every call to the GitHub API.
+	requestTimeout = 10 * time.Second
+)
+
+// Levels of the annotations.
+const (
+	LevelNotice  = "notice"
+	LevelWarning = "warning"
+	LevelFailure = "failure"
+)
+
+// Object is a reviewed object and its decision.
+type Object struct {
+	// File is the manifest of the object, relative to the root of the
+	// repository.
+	File string
+	// Document is the number of the document of the object in its file,
+	// starting at 1.
+	Document int
+	Decision decision.Record
+}
+
+// Annotation is a finding of the check run, located in a manifest.
+type Annotation struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Level     string `json:"annotation_level"`
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+}
+
+// Config is the configuration of the check runs.
+type Config struct {
+	// URL is the base URL of the GitHub API, DefaultURL when empty.
+	URL string
+	// Repository is the owner/name of the repository.
+	Repository string
+	// SHA is the commit the check run is reported on, the head of the pull
+	// request.
+	SHA string
+	// Token is a token allowed to write the checks of the repository, e.g.
+	// the GITHUB_TOKEN of a workflow with the checks: write permission.
+	Token string
+	// Name is the name of the check run, DefaultName when empty.
+	Name string
+}
+
+// Reporter creates the check runs.
+type Reporter struct {
+	cfg        Config
+	httpClient *http.Client
+	// readFile reads the manifests the annotations are located in.
+	readFile func(string) ([]byte, error)
+}
+
+// NewReporter returns a new reporter.
+func NewReporter(cfg Config) (*Reporter, error) {
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("github token can't be empty")
+	}
+	if strings.Count(cfg.Repository, "/") != 1 {
+		return nil, fmt.Errorf("github repository must be owner/name, got %q", cfg.Repository)
+	}
+	if cfg.SHA == "" {
+		return nil, fmt.Errorf("github commit SHA can't be empty")
+	}
+	if cfg.URL == "" {
+		cfg.URL = DefaultURL
+	}
+	if cfg.Name == "" {
+		cfg.Name = DefaultName
+	}
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+
+	return &Reporter{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: requestTimeout},
+		readFile:   os.ReadFile,
+	}, nil
+}
+
+type output struct {
+	Title       string       `json:"title"`
+	Summary     string       `json:"summary"`
+	Annotations []Annotation `json:"annotations"`
+}
+
+type checkRun struct {
+	Name        string `json:"name,omitempty"`
+	HeadSHA     string `json:"head_sha,omitempty"`
+	Status      string `json:"status,omitempty"`
+	Conclusion  string `json:"conclusion,omitempty"`
+	CompletedAt string `json:"completed_at,omitempty"`
+	Output      output `json:"output"`
+}
+
+// Report creates the completed check run of the objects: a failure when one
+// is denied, a success otherwise. It returns the URL of the check run.
+func (r *Reporter) Report(ctx context.Context, objects []Object) (string, error) {
+	annotations := r.Annotations(objects)
+
+	denied := 0
+	for _, obj := range objects {
+		if !obj.Decision.Allowed {
+			denied++
+		}
+	}
+	conclusion := "success"
+	if denied > 0 {
+		conclusion = "failure"
+	}
+	out := output{
+		Title:   fmt.Sprintf("%d objects denied out of %d reviewed", denied, len(objects)),
+		Summary: fmt.Sprintf("Kubesec reviewed %d objects: %d allowed, %d denied, with %d findings.", len(objects), len(objects)-denied, denied, len(annotations)),
+	}
+
+	batch := func() []Annotation {
+		n := len(annotations)
+		if n > maxAnnotations {
+			n = maxAnnotations
+		}
+		b := annotations[:n]
+		annotations = annotations[n:]
+		return append([]Annotation{}, b...)
+	}
+
+	out.Annotations = batch()
+	run := checkRun{
+		Name:        r.cfg.Name,
+		HeadSHA:     r.cfg.SHA,
+		Status:      "completed",
+		Conclusion:  conclusion,
+		CompletedAt: time.Now().UTC().Format(time.RFC3339),
+		Output:      out,
+	}
+	var created struct {
+		ID      int64  `json:"id"`
+		HTMLURL string `json:"html_url"`
+	}
+	path := "/repos/" + r.cfg.Repository + "/check-runs"
+	if err := r.send(ctx, http.MethodPost, path, run, &created); err != nil {
+		return "", fmt.Errorf("could not create the check run: %w", err)
+	}
+
+	for len(annotations) > 0 {
+		out.Annotations = batch()
+		if err := r.send(ctx, http.MethodPatch, fmt.Sprintf("%s/%d", path, created.ID), checkRun{Output: out}, nil); err != nil {
+			return "", fmt.Errorf("could not annotate the check run %d: %w", created.ID, err)
+		}
+	}
+
+	return created.HTMLURL, nil
+}
+
+// Annotations returns the annotations of the objects: a failure for the
+// objects scoring below the minimum score or that could not be scanned, one
+// per failed critical check and missing required check, failures for the
+// denied objects and warnings otherwise, and a notice per advised check
+// missed. The failed checks are located on the line of the field of their
+// selector, the others on the kind of the object.
+func (r *Reporter) Annotations(objects []Object) []Annotation {
+	files := map[string]*manifestFile{}
+	var res []Annotation
+	for _, obj := range objects {
+		if obj.File == "" || obj.File == "-" {
+			continue
+		}
+		f, ok := files[obj.File]
+		if !ok {
+			f = r.load(obj.File)
+			files[obj.File] = f
+		}
+		doc := f.document(obj.Document)
+		path := filepath.ToSlash(filepath.Clean(obj.File))
+
+		rec := obj.Decision
+		name := fmt.Sprintf("%s %s/%s", rec.ObjectKind, rec.Namespace, rec.Name)
+		add := func(line int, level, title, msg string) {
+			res = append(res, Annotation{Path: path, StartLine: line, EndLine: line, Level: level, Title: title, Message: msg})
+		}
+
+		if rec.Error != "" {
+			add(doc.kind, LevelFailure, "kubesec scan error", fmt.Sprintf("%s could not be scanned: %s", name, rec.Error))
+			continue
+		}
+		if rec.Scan == nil {
+			continue
+		}
+		level := LevelWarning
+		if !rec.Allowed || rec.Audit {
+			level = LevelFailure
+		}
+		if rec.Score < rec.MinScore {
+			add(doc.kind, LevelFailure, "kubesec minimum score", fmt.Sprintf("%s score is %d, the minimum score is %d", name, rec.Score, rec.MinScore))
+		}
+		for _, c := range rec.Scan.Scoring.Critical {
+			add(f.line(doc, c.Selector), level, "kubesec "+c.ID, fmt.Sprintf("%s fails the critical check %s: %s", name, c.ID, c.Reason))
+		}
+		for _, id := range rec.MissingChecks {
+			add(doc.kind, level, "kubesec "+id, fmt.Sprintf("%s does not pass the required check %s", name, id))
+		}
+		for _, c := range rec.Scan.Scoring.Advise {
+			add(f.line(doc, c.Selector), LevelNotice, "kubesec "+c.ID, fmt.Sprintf("%s misses the advised check %s: %s", name, c.ID, c.Reason))
+		}
+	}
+	return res
+}
+
+// manifestFile is the lines of a manifest, and the lines its YAML documents
+// start on.
+type manifestFile struct {
+	lines  []string
+	starts []int
+}
+
+// span is the lines of a document, numbered from 1, and the line of its kind.
+type span struct {
+	first, last, kind int
+}
+
+// load reads the manifest, a manifest that can't be read has its findings
+// on its first line. The documents are split as the manifests are decoded:
+// on the lines starting with ---, the empty documents skipped.
+func (r *Reporter) load(path string) *manifestFile {
+	raw, err := r.readFile(path)
+	if err != nil {
+		return &manifestFile{}
+	}
+	f := &manifestFile{lines: strings.Split(string(raw), "\n")}
+	// A JSON file is a single document, or an array of objects.
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		return f
+	}
+
+	start := 1
+	for i, l := range f.lines {
+		if n := i + 1; strings.HasPrefix(l, "---") {
+			if n > start {
+				f.starts = append(f.starts, start)
+			}
+			start = n + 1
+		}
+	}
+	if start < len(f.lines) || (start == len(f.lines) && f.lines[start-1] != "") {
+		f.starts = append(f.starts, start)
+	}
+	return f
+}
+
+// document returns the span of the document n, the whole file when it is
+// out of range.
+func (f *manifestFile) document(n int) span {
+	s := span{first: 1, last: len(f.lines)}
+	if n >= 1 && n <= len(f.starts) {
+		s.first = f.starts[n-1]
+		if n < len(f.starts) {
+			s.last = f.starts[n] - 2
+		}
+	}
+	if s.last < s.first {
+		s.last = s.first
+	}
+	s.kind = s.first
+	if l := f.find(s, "kind"); l > 0 {
+		s.kind = l
+	}
+	return s
+}
+
+// line returns the line of the field the selector checks in the document,
+// the line of its kind when the field is not found, e.g. it is missing.
+func (f *manifestFile) line(doc span, selector string) int {
+	if l := f.find(doc, selectorField(selector)); l > 0 {
+		return l
+	}
+	return doc.kind
+}
+
+// find returns the first line of the document setting the key, 0 when none.
+func (f *manifestFile) find(doc span, key string) int {
+	if key == "" {
+		return 0
+	}
+	re := regexp.MustCompile(`^\s*(- )?"?` + regexp.QuoteMeta(key) + `"?\s*:`)
+	for n := doc.first; n <= doc.last && n <= len(f.lines); n++ {
+		if re.MatchString(f.lines[n-1]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// selectorField returns the last field of a Kubesec selector, e.g.
+// privileged for containers[] .securityContext .privileged == true.
+func selectorField(selector string) string {
+	var field string
+	for _, tok := range strings.Fields(selector) {
+		if !strings.HasPrefix(tok, ".") {
+			if field != "" {
+				break
+			}
+			tok = "." + tok
+		}
+		if f := strings.Trim(strings.TrimSuffix(strings.TrimPrefix(tok, "."), "[]"), "."); f != "" {
+			field = f
+		}
+	}
+	if i := strings.LastIndex(field, "."); i >= 0 {
+		field = field[i+1:]
+	}
+	return field
+}
+
+func (r *Reporter) send(ctx context.Context, method, path string, body, into interface{}) error {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, r.cfg.URL+path, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+r.cfg.Token)
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("got %v response from %v: %s", resp.StatusCode, req.URL, strings.TrimSpace(string(data)))
+	}
+	if into == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, into)
+}
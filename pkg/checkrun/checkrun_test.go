@@ -0,0 +1,157 @@
+package checkrun
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+const manifests = `---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  template:
+    spec:
+      containers:
+        - name: api
+          image: api:1.0
+          securityContext:
+            privileged: true
+`
+
+// TestReporter_Annotations - tests the findings are located on the manifest lines of their fields
+func TestReporter_Annotations(t *testing.T) {
+	denied := decision.Record{
+		Namespace: "foo", ObjectKind: "Deployment", Name: "api", Score: -30, MinScore: 0,
+		MissingChecks: []string{"RunAsNonRoot"},
+		Scan: &scanner.Result{Scoring: scanner.Scoring{
+			Critical: []scanner.Rule{{ID: "Privileged", Selector: "containers[] .securityContext .privileged == true", Reason: "Privileged containers"}},
+			Advise:   []scanner.Rule{{ID: "ServiceAccountName", Selector: ".spec .serviceAccountName", Reason: "Service accounts restrict Kubernetes API access"}},
+		}},
+	}
+	errored := decision.Record{Namespace: "foo", ObjectKind: "Pod", Name: "down", Allowed: true, Error: "unreachable"}
+
+	r := &Reporter{readFile: func(path string) ([]byte, error) { return []byte(manifests), nil }}
+	got := r.Annotations([]Object{
+		{File: "./manifests/api.yaml", Document: 2, Decision: denied},
+		{File: "manifests/pod.json", Document: 1, Decision: errored},
+		{File: "-", Document: 1, Decision: errored},
+	})
+
+	type finding struct {
+		title, level, path string
+		line               int
+	}
+	var findings []finding
+	for _, a := range got {
+		findings = append(findings, finding{a.Title, a.Level, a.Path, a.StartLine})
+	}
+	want := []finding{
+		{"kubesec minimum score", LevelFailure, "manifests/api.yaml", 8},
+		{"kubesec Privileged", LevelFailure, "manifests/api.yaml", 18},
+		{"kubesec RunAsNonRoot", LevelFailure, "manifests/api.yaml", 8},
+		{"kubesec ServiceAccountName", LevelNotice, "manifests/api.yaml", 8},
+		{"kubesec scan error", LevelFailure, "manifests/pod.json", 3},
+	}
+	if !reflect.DeepEqual(findings, want) {
+		t.Fatalf("Annotations - want %v, got %v", want, findings)
+	}
+}
+
+// TestSelectorField - tests the field of the Kubesec selectors
+func TestSelectorField(t *testing.T) {
+	tests := map[string]string{
+		"containers[] .securityContext .privileged == true":       "privileged",
+		".spec .serviceAccountName":                               "serviceAccountName",
+		"containers[] .securityContext .runAsUser -gt 10000":      "runAsUser",
+		".spec .volumes[] .hostPath .path == \"/var/run/docker\"": "path",
+		"": "",
+	}
+	for selector, want := range tests {
+		if got := selectorField(selector); got != want {
+			t.Fatalf("selectorField - want %q for %q, got %q", want, selector, got)
+		}
+	}
+}
+
+// TestReporter_Report - tests the check run is created, then annotated by batches of 50
+func TestReporter_Report(t *testing.T) {
+	var calls []string
+	var annotations int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method+" "+r.URL.Path)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var run checkRun
+		if err := json.NewDecoder(r.Body).Decode(&run); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		annotations += len(run.Output.Annotations)
+		if r.Method == http.MethodPost {
+			if run.HeadSHA != "abc123" || run.Conclusion != "failure" || run.Status != "completed" {
+				w.WriteHeader(http.StatusUnprocessableEntity)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"id": 7, "html_url": "https://github.com/acme/app/runs/7"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	r, err := NewReporter(Config{URL: server.URL + "/", Repository: "acme/app", SHA: "abc123", Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.readFile = func(string) ([]byte, error) { return []byte(manifests), nil }
+
+	var critical []scanner.Rule
+	for i := 0; i < 60; i++ {
+		critical = append(critical, scanner.Rule{ID: "Privileged", Selector: ".spec .privileged"})
+	}
+	objects := []Object{{File: "api.yaml", Document: 2, Decision: decision.Record{
+		ObjectKind: "Deployment", Name: "api", Scan: &scanner.Result{Scoring: scanner.Scoring{Critical: critical}},
+	}}}
+
+	url, err := r.Report(context.Background(), objects)
+	if err != nil {
+		t.Fatalf("Report - unexpected error %v", err)
+	}
+	if url != "https://github.com/acme/app/runs/7" {
+		t.Fatalf("Report - want the check run URL, got %q", url)
+	}
+	wantCalls := []string{"POST /repos/acme/app/check-runs", "PATCH /repos/acme/app/check-runs/7"}
+	if !reflect.DeepEqual(calls, wantCalls) || annotations != 60 {
+		t.Fatalf("Report - want calls %v and 60 annotations, got %v and %d", wantCalls, calls, annotations)
+	}
+}
+
+// TestNewReporter - tests the incomplete configurations are rejected
+func TestNewReporter(t *testing.T) {
+	for _, cfg := range []Config{
+		{Repository: "acme/app", SHA: "abc123"},
+		{Token: "secret", Repository: "acme", SHA: "abc123"},
+		{Token: "secret", Repository: "acme/app"},
+	} {
+		if _, err := NewReporter(cfg); err == nil {
+			t.Fatalf("NewReporter - want an error for %+v", cfg)
+		}
+	}
+}
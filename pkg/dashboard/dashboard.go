@@ -0,0 +1,213 @@
+// Package dashboard serves a read-only web page of the policy of the webhook
+// and of its recent decisions, a quick operational view without Grafana.
+package dashboard
+
+import (
+	"context"
+	"crypto/subtle"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+const (
+	// shownDecisions is the number of recent decisions listed, the summaries
+	// cover all of those kept.
+	shownDecisions = 50
+	// shownRules is the number of top failing rules listed.
+	shownRules = 10
+)
+
+//go:embed dashboard.html
+var page string
+
+var tmpl = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"join": strings.Join,
+	"time": func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+}).Parse(page))
+
+// Setting is a setting of the policy, e.g. min-score=0.
+type Setting struct {
+	Name  string
+	Value string
+}
+
+// NamespaceSummary sums the decisions of a namespace up.
+type NamespaceSummary struct {
+	Namespace string
+	Decisions int
+	Denied    int
+	// Scanned is the number of decisions scored, MeanScore and LowestScore
+	// are theirs.
+	Scanned     int
+	MeanScore   float64
+	LowestScore int
+}
+
+// RuleCount is the number of decisions failing a critical check.
+type RuleCount struct {
+	ID    string
+	Count int
+}
+
+// View is what the page shows.
+type View struct {
+	Policy     []Setting
+	Kept       int
+	Since      time.Time
+	Recent     []decision.Record
+	Namespaces []NamespaceSummary
+	TopRules   []RuleCount
+}
+
+type entry struct {
+	rec     decision.Record
+	scanned bool
+}
+
+// Dashboard keeps the latest decisions and serves their page to the clients
+// presenting the token, as a bearer token or as the password of the basic
+// authentication of the browsers. It satisfies decision.Sink and
+// http.Handler.
+type Dashboard struct {
+	policy []Setting
+	token  string
+
+	mu      sync.Mutex
+	entries []entry
+	next    int
+	full    bool
+}
+
+// New returns a dashboard of the policy keeping the latest size decisions.
+func New(policy []Setting, size int, token string) (*Dashboard, error) {
+	if token == "" {
+		return nil, fmt.Errorf("dashboard token can't be empty")
+	}
+	if size < 1 {
+		return nil, fmt.Errorf("dashboard decisions must be at least 1")
+	}
+	return &Dashboard{policy: policy, token: token, entries: make([]entry, size)}, nil
+}
+
+// Write satisfies decision.Sink interface, the scans are not kept.
+func (d *Dashboard) Write(_ context.Context, rec decision.Record) error {
+	e := entry{rec: rec, scanned: rec.Scan != nil}
+	e.rec.Scan = nil
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[d.next] = e
+	d.next = (d.next + 1) % len(d.entries)
+	if d.next == 0 {
+		d.full = true
+	}
+	return nil
+}
+
+// ServeHTTP renders the page.
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !d.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="kubesec-webhook"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	if err := tmpl.Execute(w, d.View()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (d *Dashboard) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if _, password, ok := r.BasicAuth(); ok {
+		token = password
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(d.token)) == 1
+}
+
+// View returns the view of the decisions kept.
+func (d *Dashboard) View() View {
+	entries := d.latest()
+	v := View{Policy: d.policy, Kept: len(entries)}
+	if len(entries) > 0 {
+		v.Since = entries[len(entries)-1].rec.Time
+	}
+
+	namespaces := map[string]*NamespaceSummary{}
+	rules := map[string]int{}
+	for n, e := range entries {
+		if n < shownDecisions {
+			v.Recent = append(v.Recent, e.rec)
+		}
+
+		ns, ok := namespaces[e.rec.Namespace]
+		if !ok {
+			ns = &NamespaceSummary{Namespace: e.rec.Namespace}
+			namespaces[e.rec.Namespace] = ns
+		}
+		ns.Decisions++
+		if !e.rec.Allowed {
+			ns.Denied++
+		}
+		if e.scanned {
+			if ns.Scanned == 0 || e.rec.Score < ns.LowestScore {
+				ns.LowestScore = e.rec.Score
+			}
+			ns.MeanScore += (float64(e.rec.Score) - ns.MeanScore) / float64(ns.Scanned+1)
+			ns.Scanned++
+		}
+		for _, id := range e.rec.FailedRules {
+			rules[id]++
+		}
+	}
+
+	for _, ns := range namespaces {
+		v.Namespaces = append(v.Namespaces, *ns)
+	}
+	sort.Slice(v.Namespaces, func(i, j int) bool { return v.Namespaces[i].Namespace < v.Namespaces[j].Namespace })
+	for id, count := range rules {
+		v.TopRules = append(v.TopRules, RuleCount{ID: id, Count: count})
+	}
+	sort.Slice(v.TopRules, func(i, j int) bool {
+		if v.TopRules[i].Count != v.TopRules[j].Count {
+			return v.TopRules[i].Count > v.TopRules[j].Count
+		}
+		return v.TopRules[i].ID < v.TopRules[j].ID
+	})
+	if len(v.TopRules) > shownRules {
+		v.TopRules = v.TopRules[:shownRules]
+	}
+	return v
+}
+
+// latest returns the decisions kept, most recent first.
+func (d *Dashboard) latest() []entry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := d.next
+	if d.full {
+		n = len(d.entries)
+	}
+	res := make([]entry, 0, n)
+	for i := 1; i <= n; i++ {
+		res = append(res, d.entries[(d.next-i+len(d.entries))%len(d.entries)])
+	}
+	return res
+}
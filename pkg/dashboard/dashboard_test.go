@@ -0,0 +1,105 @@
+package dashboard
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// TestDashboard_View - tests the summaries of the decisions kept
+func TestDashboard_View(t *testing.T) {
+	d, err := New(nil, 4, "s3cr3t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC)
+	recs := []decision.Record{
+		// Dropped as only 4 decisions are kept.
+		{Time: at, Kind: "pod", Namespace: "old", Name: "test", Scan: &scanner.Result{}},
+		{Time: at.Add(time.Minute), Kind: "pod", Namespace: "foo", Name: "a", Allowed: true, Score: 4, Scan: &scanner.Result{}},
+		{Time: at.Add(2 * time.Minute), Kind: "pod", Namespace: "foo", Name: "b", Score: -30, FailedRules: []string{"Privileged", "HostPID"}, Scan: &scanner.Result{}},
+		{Time: at.Add(3 * time.Minute), Kind: "pod", Namespace: "foo", Name: "c", Allowed: true, Exemption: "namespace excluded"},
+		{Time: at.Add(4 * time.Minute), Kind: "deployment", Namespace: "bar", Name: "d", Score: -7, FailedRules: []string{"Privileged"}, Scan: &scanner.Result{}},
+	}
+	for _, rec := range recs {
+		if err := d.Write(context.Background(), rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	v := d.View()
+	if v.Kept != 4 || !v.Since.Equal(at.Add(time.Minute)) || v.Recent[0].Name != "d" || v.Recent[3].Name != "a" || v.Recent[0].Scan != nil {
+		t.Fatalf("View - unexpected recent decisions since %v: %+v", v.Since, v.Recent)
+	}
+	want := []NamespaceSummary{
+		{Namespace: "bar", Decisions: 1, Denied: 1, Scanned: 1, MeanScore: -7, LowestScore: -7},
+		{Namespace: "foo", Decisions: 3, Denied: 1, Scanned: 2, MeanScore: -13, LowestScore: -30},
+	}
+	if len(v.Namespaces) != len(want) {
+		t.Fatalf("View - want %+v, got %+v", want, v.Namespaces)
+	}
+	for n := range want {
+		if v.Namespaces[n] != want[n] {
+			t.Fatalf("View - want %+v, got %+v", want[n], v.Namespaces[n])
+		}
+	}
+	if len(v.TopRules) != 2 || v.TopRules[0] != (RuleCount{ID: "Privileged", Count: 2}) || v.TopRules[1].ID != "HostPID" {
+		t.Fatalf("View - unexpected top rules %+v", v.TopRules)
+	}
+}
+
+// TestDashboard_ServeHTTP - tests the page is served to the clients with the token
+func TestDashboard_ServeHTTP(t *testing.T) {
+	d, err := New([]Setting{{Name: "min-score", Value: "3"}}, 10, "s3cr3t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write(context.Background(), decision.Record{Kind: "pod", Namespace: "foo", Name: "<b>test</b>", FailedRules: []string{"Privileged"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		auth   func(r *http.Request)
+		method string
+		status int
+	}{
+		{name: "basic", auth: func(r *http.Request) { r.SetBasicAuth("admin", "s3cr3t") }, status: http.StatusOK},
+		{name: "bearer", auth: func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cr3t") }, status: http.StatusOK},
+		{name: "wrong password", auth: func(r *http.Request) { r.SetBasicAuth("admin", "nope") }, status: http.StatusUnauthorized},
+		{name: "anonymous", auth: func(*http.Request) {}, status: http.StatusUnauthorized},
+		{name: "method", auth: func(r *http.Request) { r.SetBasicAuth("", "s3cr3t") }, method: http.MethodPost, status: http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/dashboard", nil)
+			tt.auth(req)
+			w := httptest.NewRecorder()
+			d.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("ServeHTTP - want status %d, got %d", tt.status, w.Code)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			body := w.Body.String()
+			for _, want := range []string{"min-score", "Privileged", "&lt;b&gt;test&lt;/b&gt;", `class="denied">denied`} {
+				if !strings.Contains(body, want) {
+					t.Fatalf("ServeHTTP - want %q in page, got %s", want, body)
+				}
+			}
+		})
+	}
+}
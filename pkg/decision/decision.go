@@ -0,0 +1,92 @@
+// Package decision describes the outcome of the admission reviews so it can be
+// reported outside of the webhook logs.
+package decision
+
+import (
+	"context"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// OperationRescan is the operation of the decisions taken on the existing
+// objects by the rescans, outside of admission. Nothing is denied by them.
+const OperationRescan = "RESCAN"
+
+// Record is the outcome of reviewing one object.
+type Record struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	// APIVersion and ObjectKind are those of the reviewed object, e.g. apps/v1
+	// and Deployment, where Kind is the lower case kind of the logs.
+	APIVersion string `json:"apiVersion,omitempty"`
+	ObjectKind string `json:"objectKind,omitempty"`
+	// UID is the UID of the reviewed object, empty on CREATE.
+	UID types.UID `json:"uid,omitempty"`
+	// Controlled is set when the object is managed by a controller, e.g. the
+	// Pods of a ReplicaSet.
+	Controlled bool   `json:"controlled,omitempty"`
+	Operation  string `json:"operation,omitempty"`
+	// SpecHash is the SHA-256 of the Pod the object runs, telling the
+	// decisions of its different specs apart.
+	SpecHash string `json:"specHash,omitempty"`
+	// RequestID correlates the decision with the webhook logs.
+	RequestID string `json:"requestID,omitempty"`
+	Allowed   bool   `json:"allowed"`
+	Score     int    `json:"score"`
+	MinScore  int    `json:"minScore"`
+	// FailedRules are the IDs of the critical checks the object failed.
+	FailedRules []string `json:"failedRules,omitempty"`
+	// MissingChecks are the IDs of the required checks the object did not pass.
+	MissingChecks []string `json:"missingChecks,omitempty"`
+	// DeniedRules are the IDs of the failed critical checks that deny the
+	// object whatever its score.
+	DeniedRules []string `json:"deniedRules,omitempty"`
+	// Exemption is why the object was allowed regardless of its scan, empty
+	// when no exemption fired.
+	Exemption string `json:"exemption,omitempty"`
+	// Audit is set when the object would have been denied, and was allowed
+	// as the enforcement is audit.
+	Audit bool `json:"audit,omitempty"`
+	// Error is set when the object could not be scored.
+	Error string `json:"error,omitempty"`
+	// Scan is the full scan result, nil when the object could not be scored.
+	Scan *scanner.Result `json:"scan,omitempty"`
+}
+
+// Admission tells whether the decision was taken on admission, not by a
+// rescan. The sinks acting on the denials, e.g. the summaries, escalations and
+// hooks, skip the others as nothing was denied.
+func (r Record) Admission() bool {
+	return r.Operation != OperationRescan
+}
+
+// Key identifies the reviewed object across decisions.
+func (r Record) Key() string {
+	return r.Kind + "/" + r.Namespace + "/" + r.Name
+}
+
+// Sink receives the decisions taken by the validators. Implementations must be
+// safe for concurrent use and should not block the admission for long.
+type Sink interface {
+	Write(ctx context.Context, r Record) error
+}
+
+// Sinks fans a record out to several sinks.
+type Sinks []Sink
+
+// Write satisfies Sink interface, it returns the first error but always writes
+// to every sink.
+func (s Sinks) Write(ctx context.Context, r Record) error {
+	var first error
+	for _, sink := range s {
+		if err := sink.Write(ctx, r); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
@@ -0,0 +1,205 @@
+// Package escalation opens tickets for workloads that keep being denied, so
+// chronic violations get tracked instead of being retried forever.
+package escalation
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// queueSize is the number of escalations waiting for the tracker before new
+// ones are dropped.
+const queueSize = 100
+
+// Escalation is a workload denied too many times within the window.
+type Escalation struct {
+	// Key identifies the workload, see decision.Record.Key.
+	Key     string
+	Denials int
+	Window  time.Duration
+	// Last is the latest denial of the workload.
+	Last decision.Record
+}
+
+// Tracker files escalations in an issue tracker.
+type Tracker interface {
+	// Open creates a ticket for the escalation and returns its ID.
+	Open(ctx context.Context, e Escalation) (string, error)
+	// Update adds the escalation to an existing ticket.
+	Update(ctx context.Context, id string, e Escalation) error
+}
+
+// Finder is implemented by the trackers able to look up the open ticket of a
+// workload. The replicas then share the tickets instead of each opening its
+// own, and a ticket closed since is not updated.
+type Finder interface {
+	// Find returns the ID of the open ticket of the workload, empty when
+	// there is none.
+	Find(ctx context.Context, key string) (string, error)
+}
+
+// Escalator counts denials per workload and escalates to the tracker when a
+// workload is denied more than threshold times within window. It satisfies
+// decision.Sink and must be started to reach the tracker.
+type Escalator struct {
+	tracker   Tracker
+	threshold int
+	window    time.Duration
+	logger    log.Logger
+	queue     chan Escalation
+
+	mu      sync.Mutex
+	denials map[string][]time.Time
+	// tickets maps workloads to the ticket already opened for them, for the
+	// trackers that are not a Finder.
+	tickets map[string]string
+}
+
+// NewEscalator returns a new escalator.
+func NewEscalator(tracker Tracker, threshold int, window time.Duration, logger log.Logger) (*Escalator, error) {
+	if threshold < 1 {
+		return nil, fmt.Errorf("escalation threshold must be at least 1")
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("escalation window must be positive")
+	}
+
+	return &Escalator{
+		tracker:   tracker,
+		threshold: threshold,
+		window:    window,
+		logger:    logger,
+		queue:     make(chan Escalation, queueSize),
+		denials:   map[string][]time.Time{},
+		tickets:   map[string]string{},
+	}, nil
+}
+
+// Write satisfies decision.Sink interface, the rescans are not counted.
+func (e *Escalator) Write(_ context.Context, r decision.Record) error {
+	if r.Allowed || !r.Admission() {
+		return nil
+	}
+
+	esc, ok := e.count(r)
+	if !ok {
+		return nil
+	}
+
+	select {
+	case e.queue <- esc:
+		return nil
+	default:
+		return fmt.Errorf("escalation queue is full, dropping escalation of %s", esc.Key)
+	}
+}
+
+// count records the denial and returns the escalation once the threshold is
+// exceeded, the count then starts over.
+func (e *Escalator) count(r decision.Record) (Escalation, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := r.Key()
+	cutoff := r.Time.Add(-e.window)
+
+	times := e.denials[key][:0]
+	for _, t := range e.denials[key] {
+		if t.After(cutoff) {
+			times = append(times, t)
+		}
+	}
+	times = append(times, r.Time)
+
+	if len(times) <= e.threshold {
+		e.denials[key] = times
+		return Escalation{}, false
+	}
+
+	delete(e.denials, key)
+	return Escalation{Key: key, Denials: len(times), Window: e.window, Last: r}, true
+}
+
+// prune forgets the workloads whose denials are all older than the window.
+func (e *Escalator) prune(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cutoff := now.Add(-e.window)
+	for key, times := range e.denials {
+		if !times[len(times)-1].After(cutoff) {
+			delete(e.denials, key)
+		}
+	}
+}
+
+// Start files the escalations until the context is done, and forgets the
+// expired denials every window.
+func (e *Escalator) Start(ctx context.Context) error {
+	t := time.NewTicker(e.window)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-t.C:
+			e.prune(now)
+		case esc := <-e.queue:
+			if err := e.file(ctx, esc); err != nil {
+				e.logger.Errorf("could not escalate %s: %v", esc.Key, err)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager every replica escalates the denials it saw.
+func (e *Escalator) NeedLeaderElection() bool {
+	return false
+}
+
+// file updates the open ticket of the workload, looked up in the tracker when
+// it is a Finder, or opens a new one.
+func (e *Escalator) file(ctx context.Context, esc Escalation) error {
+	finder, found := e.tracker.(Finder)
+
+	var id string
+	if found {
+		var err error
+		if id, err = finder.Find(ctx, esc.Key); err != nil {
+			return err
+		}
+	} else {
+		e.mu.Lock()
+		id = e.tickets[esc.Key]
+		e.mu.Unlock()
+	}
+
+	if id != "" {
+		if err := e.tracker.Update(ctx, id, esc); err != nil {
+			return err
+		}
+		e.logger.Infof("escalation of %s added to ticket %s", esc.Key, id)
+		return nil
+	}
+
+	id, err := e.tracker.Open(ctx, esc)
+	if err != nil {
+		return err
+	}
+
+	if !found {
+		e.mu.Lock()
+		e.tickets[esc.Key] = id
+		e.mu.Unlock()
+	}
+	e.logger.Infof("escalated %s in ticket %s", esc.Key, id)
+
+	return nil
+}
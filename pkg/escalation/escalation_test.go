@@ -0,0 +1,217 @@
+package escalation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// fakeTracker records the calls made by the escalator.
+type fakeTracker struct {
+	mu      sync.Mutex
+	opened  []Escalation
+	updated map[string][]Escalation
+}
+
+func (f *fakeTracker) Open(_ context.Context, e Escalation) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.opened = append(f.opened, e)
+	return "SEC-1", nil
+}
+
+func (f *fakeTracker) Update(_ context.Context, id string, e Escalation) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.updated == nil {
+		f.updated = map[string][]Escalation{}
+	}
+	f.updated[id] = append(f.updated[id], e)
+	return nil
+}
+
+// TestEscalator - tests that workloads are escalated once denied more than threshold times within the window
+func TestEscalator(t *testing.T) {
+	tracker := &fakeTracker{}
+	e, err := NewEscalator(tracker, 2, time.Hour, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	denied := func(name string, at time.Duration) decision.Record {
+		return decision.Record{Kind: "deployment", Namespace: "team-a", Name: name, Time: start.Add(at)}
+	}
+
+	records := []decision.Record{
+		denied("web", 0),
+		denied("web", time.Minute),
+		// Allowed reviews and rescans are not counted.
+		{Kind: "deployment", Namespace: "team-a", Name: "web", Allowed: true, Time: start.Add(2 * time.Minute)},
+		{Kind: "deployment", Namespace: "team-a", Name: "api", Operation: decision.OperationRescan, Time: start.Add(2 * time.Minute)},
+		{Kind: "deployment", Namespace: "team-a", Name: "api", Operation: decision.OperationRescan, Time: start.Add(3 * time.Minute)},
+		// Outside of the window of the first denial.
+		denied("api", 0),
+		denied("api", 2*time.Hour),
+		denied("web", 3*time.Minute),
+	}
+	for _, r := range records {
+		if err := e.Write(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := len(e.queue); got != 1 {
+		t.Fatalf("Write - want 1 escalation queued, got %d", got)
+	}
+	esc := <-e.queue
+	if esc.Key != "deployment/team-a/web" || esc.Denials != 3 {
+		t.Fatalf("Write - escalation mismatch, got %+v", esc)
+	}
+
+	// The first escalation opens a ticket, the next ones update it.
+	if err := e.file(context.Background(), esc); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.file(context.Background(), esc); err != nil {
+		t.Fatal(err)
+	}
+	if len(tracker.opened) != 1 || len(tracker.updated["SEC-1"]) != 1 {
+		t.Fatalf("file - want one ticket opened then updated, got opened=%d updated=%v", len(tracker.opened), tracker.updated)
+	}
+}
+
+// TestJiraTracker - tests the Jira REST API calls
+func TestJiraTracker(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if user, token, ok := r.BasicAuth(); !ok || user != "bot" || token != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if r.URL.Path == "/rest/api/2/search" {
+			if jql := r.URL.Query().Get("jql"); !strings.Contains(jql, workloadLabel("deployment/team-a/web")) {
+				_, _ = w.Write([]byte(`{"issues": []}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"issues": [{"key": "SEC-42"}]}`))
+			return
+		}
+
+		var body map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Path == "/rest/api/2/issue" {
+			fields := body["fields"].(map[string]interface{})
+			labels := fields["labels"].([]interface{})
+			if !strings.Contains(fields["summary"].(string), "team-a/web denied 3 times") || labels[len(labels)-1] != workloadLabel("deployment/team-a/web") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"id": "10000", "key": "SEC-42"}`))
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	j, err := NewJiraTracker(JiraConfig{URL: server.URL + "/", Project: "SEC", Username: "bot", Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	esc := Escalation{
+		Key:     "deployment/team-a/web",
+		Denials: 3,
+		Window:  time.Hour,
+		Last:    decision.Record{Kind: "deployment", Namespace: "team-a", Name: "web", FailedRules: []string{"Privileged"}},
+	}
+
+	id, err := j.Open(context.Background(), esc)
+	if err != nil {
+		t.Fatalf("Open - unexpected error %v", err)
+	}
+	if id != "SEC-42" {
+		t.Fatalf("Open - want issue SEC-42, got %q", id)
+	}
+	if err := j.Update(context.Background(), id, esc); err != nil {
+		t.Fatalf("Update - unexpected error %v", err)
+	}
+	if len(paths) != 2 || paths[1] != "/rest/api/2/issue/SEC-42/comment" {
+		t.Fatalf("Update - unexpected calls %v", paths)
+	}
+
+	if id, err := j.Find(context.Background(), esc.Key); err != nil || id != "SEC-42" {
+		t.Fatalf("Find - want issue SEC-42, got %q (%v)", id, err)
+	}
+	if id, err := j.Find(context.Background(), "deployment/team-a/api"); err != nil || id != "" {
+		t.Fatalf("Find - want no issue, got %q (%v)", id, err)
+	}
+}
+
+// findingTracker is a fakeTracker finding the tickets opened by other replicas.
+type findingTracker struct {
+	fakeTracker
+	open map[string]string
+}
+
+func (f *findingTracker) Find(_ context.Context, key string) (string, error) {
+	return f.open[key], nil
+}
+
+// TestEscalator_find - tests the open ticket found in the tracker is updated rather than a new one opened
+func TestEscalator_find(t *testing.T) {
+	tracker := &findingTracker{open: map[string]string{"deployment/team-a/web": "SEC-7"}}
+	e, err := NewEscalator(tracker, 1, time.Hour, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"deployment/team-a/web", "deployment/team-a/api"} {
+		if err := e.file(context.Background(), Escalation{Key: key}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(tracker.updated["SEC-7"]) != 1 || len(tracker.opened) != 1 || tracker.opened[0].Key != "deployment/team-a/api" {
+		t.Fatalf("file - want SEC-7 updated and the api ticket opened, got opened=%v updated=%v", tracker.opened, tracker.updated)
+	}
+	if len(e.tickets) != 0 {
+		t.Fatalf("file - want no tickets kept by the replica, got %v", e.tickets)
+	}
+}
+
+// TestEscalator_prune - tests the workloads without denials within the window are forgotten
+func TestEscalator_prune(t *testing.T) {
+	e, err := NewEscalator(&fakeTracker{}, 5, time.Hour, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	for _, r := range []decision.Record{
+		{Kind: "deployment", Namespace: "team-a", Name: "web", Time: start},
+		{Kind: "deployment", Namespace: "team-a", Name: "api", Time: start.Add(30 * time.Minute)},
+	} {
+		if err := e.Write(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e.prune(start.Add(time.Hour))
+	if _, ok := e.denials["deployment/team-a/web"]; ok || len(e.denials) != 1 {
+		t.Fatalf("prune - want only the api denials kept, got %v", e.denials)
+	}
+}
@@ -0,0 +1,272 @@
+package escalation
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// requestTimeout bounds every call to a tracker API.
+const requestTimeout = 10 * time.Second
+
+// Title returns a one line summary of the escalation.
+func (e Escalation) Title() string {
+	return fmt.Sprintf("kubesec: %s %s/%s denied %d times", e.Last.Kind, e.Last.Namespace, e.Last.Name, e.Denials)
+}
+
+// Description returns the details of the escalation, scan included.
+func (e Escalation) Description() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s/%s was denied %d times within %s.\n", e.Last.Kind, e.Last.Namespace, e.Last.Name, e.Denials, e.Window)
+	fmt.Fprintf(&b, "Last denial at %s: score %d, minimum accepted score %d.\n", e.Last.Time.UTC().Format(time.RFC3339), e.Last.Score, e.Last.MinScore)
+	if e.Last.RequestID != "" {
+		fmt.Fprintf(&b, "Request ID: %s.\n", e.Last.RequestID)
+	}
+	if len(e.Last.FailedRules) > 0 {
+		fmt.Fprintf(&b, "Failed critical checks: %s.\n", strings.Join(e.Last.FailedRules, ", "))
+	}
+	if e.Last.Scan != nil {
+		scan, err := json.MarshalIndent(e.Last.Scan, "", "  ")
+		if err == nil {
+			fmt.Fprintf(&b, "\nScan result:\n%s\n", scan)
+		}
+	}
+	return b.String()
+}
+
+// JiraConfig is the configuration of the Jira project tickets are opened in.
+type JiraConfig struct {
+	// URL is the base URL of the Jira instance, e.g. https://example.atlassian.net.
+	URL       string
+	Project   string
+	IssueType string
+	Username  string
+	// Token is the API token (or password) of the user.
+	Token  string
+	Labels []string
+}
+
+// JiraTracker opens Jira issues through the REST API v2. The issues are
+// labelled with a digest of the workload key, so the open issue of a workload
+// is found by every replica.
+type JiraTracker struct {
+	cfg        JiraConfig
+	httpClient *http.Client
+}
+
+// NewJiraTracker returns a new Jira tracker.
+func NewJiraTracker(cfg JiraConfig) (*JiraTracker, error) {
+	if cfg.URL == "" || cfg.Project == "" {
+		return nil, fmt.Errorf("jira url and project can't be empty")
+	}
+	if cfg.IssueType == "" {
+		cfg.IssueType = "Bug"
+	}
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+
+	return &JiraTracker{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+// Open satisfies Tracker interface.
+func (j *JiraTracker) Open(ctx context.Context, e Escalation) (string, error) {
+	issue := map[string]interface{}{
+		"fields": map[string]interface{}{
+			"project":     map[string]string{"key": j.cfg.Project},
+			"issuetype":   map[string]string{"name": j.cfg.IssueType},
+			"summary":     e.Title(),
+			"description": e.Description(),
+			"labels":      append(append([]string{}, j.cfg.Labels...), workloadLabel(e.Key)),
+		},
+	}
+
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := j.post(ctx, "/rest/api/2/issue", issue, &created); err != nil {
+		return "", err
+	}
+	if created.Key == "" {
+		return "", fmt.Errorf("jira did not return the created issue key")
+	}
+
+	return created.Key, nil
+}
+
+// Update satisfies Tracker interface.
+func (j *JiraTracker) Update(ctx context.Context, id string, e Escalation) error {
+	comment := map[string]string{"body": e.Description()}
+	return j.post(ctx, "/rest/api/2/issue/"+id+"/comment", comment, nil)
+}
+
+// Find satisfies Finder interface, the issues done are not returned.
+func (j *JiraTracker) Find(ctx context.Context, key string) (string, error) {
+	jql := fmt.Sprintf("project = %q AND labels = %q AND statusCategory != Done ORDER BY created DESC", j.cfg.Project, workloadLabel(key))
+	query := url.Values{"jql": {jql}, "fields": {"key"}, "maxResults": {"1"}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.cfg.URL+"/rest/api/2/search?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if j.cfg.Username != "" {
+		req.SetBasicAuth(j.cfg.Username, j.cfg.Token)
+	}
+
+	var found struct {
+		Issues []struct {
+			Key string `json:"key"`
+		} `json:"issues"`
+	}
+	if err := do(j.httpClient, req, &found); err != nil {
+		return "", err
+	}
+	if len(found.Issues) == 0 {
+		return "", nil
+	}
+
+	return found.Issues[0].Key, nil
+}
+
+// workloadLabel returns the Jira label of the workload key, which has
+// characters the labels can't have.
+func workloadLabel(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "kubesec-" + hex.EncodeToString(sum[:8])
+}
+
+func (j *JiraTracker) post(ctx context.Context, path string, body, into interface{}) error {
+	req, err := newJSONRequest(ctx, j.cfg.URL+path, body)
+	if err != nil {
+		return err
+	}
+	if j.cfg.Username != "" {
+		req.SetBasicAuth(j.cfg.Username, j.cfg.Token)
+	}
+
+	return do(j.httpClient, req, into)
+}
+
+// RESTTracker posts escalations as JSON to a generic endpoint. Every payload
+// carries the workload key so the receiver can group repeated escalations.
+type RESTTracker struct {
+	url        string
+	headers    map[string]string
+	httpClient *http.Client
+}
+
+// NewRESTTracker returns a tracker posting to url with the given extra headers.
+func NewRESTTracker(url string, headers map[string]string) (*RESTTracker, error) {
+	if url == "" {
+		return nil, fmt.Errorf("rest tracker url can't be empty")
+	}
+	return &RESTTracker{
+		url:        url,
+		headers:    headers,
+		httpClient: &http.Client{Timeout: requestTimeout},
+	}, nil
+}
+
+type restPayload struct {
+	Action      string          `json:"action"`
+	ID          string          `json:"id,omitempty"`
+	Key         string          `json:"key"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Denials     int             `json:"denials"`
+	Window      string          `json:"window"`
+	Record      decision.Record `json:"record"`
+}
+
+// Open satisfies Tracker interface. The ticket ID is read from the "id" field
+// of the response, the workload key is used when there is none.
+func (r *RESTTracker) Open(ctx context.Context, e Escalation) (string, error) {
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := r.post(ctx, r.payload("open", "", e), &created); err != nil {
+		return "", err
+	}
+	if created.ID == "" {
+		return e.Key, nil
+	}
+	return created.ID, nil
+}
+
+// Update satisfies Tracker interface.
+func (r *RESTTracker) Update(ctx context.Context, id string, e Escalation) error {
+	return r.post(ctx, r.payload("update", id, e), nil)
+}
+
+func (r *RESTTracker) payload(action, id string, e Escalation) restPayload {
+	return restPayload{
+		Action:      action,
+		ID:          id,
+		Key:         e.Key,
+		Title:       e.Title(),
+		Description: e.Description(),
+		Denials:     e.Denials,
+		Window:      e.Window.String(),
+		Record:      e.Last,
+	}
+}
+
+func (r *RESTTracker) post(ctx context.Context, body, into interface{}) error {
+	req, err := newJSONRequest(ctx, r.url, body)
+	if err != nil {
+		return err
+	}
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+
+	return do(r.httpClient, req, into)
+}
+
+func newJSONRequest(ctx context.Context, url string, body interface{}) (*http.Request, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	return req, nil
+}
+
+// do sends the request and decodes a JSON response into into, when not nil.
+func do(client *http.Client, req *http.Request, into interface{}) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("got %v response from %v: %s", resp.StatusCode, req.URL, strings.TrimSpace(string(body)))
+	}
+	if into == nil || len(body) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(body, into)
+}
@@ -0,0 +1,95 @@
+// Package event reports the denials as Kubernetes Events of the denied
+// objects, so they show in kubectl get events and the alerting built on them.
+package event
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// Reasons of the Events.
+const (
+	// ReasonScoreTooLow is the reason of the denials for a score below the
+	// minimum score.
+	ReasonScoreTooLow = "KubesecScoreTooLow"
+	// ReasonChecksFailed is the reason of the denials for failed denied or
+	// required checks.
+	ReasonChecksFailed = "KubesecChecksFailed"
+	// ReasonScanFailed is the reason of the denials of objects that could not
+	// be scanned.
+	ReasonScanFailed = "KubesecScanFailed"
+)
+
+// Recorder records a Warning Event for every denial. It satisfies
+// decision.Sink, the Events are sent in the background by the recorder.
+type Recorder struct {
+	recorder record.EventRecorder
+}
+
+// NewRecorder returns a recorder of the denials with the Event recorder.
+func NewRecorder(recorder record.EventRecorder) *Recorder {
+	return &Recorder{recorder: recorder}
+}
+
+// Write satisfies decision.Sink interface.
+func (r *Recorder) Write(_ context.Context, rec decision.Record) error {
+	if rec.Allowed {
+		return nil
+	}
+	r.recorder.Event(reference(rec), corev1.EventTypeWarning, reason(rec), message(rec))
+	return nil
+}
+
+// reference returns the denied object.
+func reference(rec decision.Record) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		APIVersion: rec.APIVersion,
+		Kind:       rec.ObjectKind,
+		Namespace:  rec.Namespace,
+		Name:       rec.Name,
+		UID:        rec.UID,
+	}
+}
+
+func reason(rec decision.Record) string {
+	switch {
+	case rec.Error != "":
+		return ReasonScanFailed
+	case rec.Score < rec.MinScore:
+		return ReasonScoreTooLow
+	}
+	return ReasonChecksFailed
+}
+
+func message(rec decision.Record) string {
+	var b strings.Builder
+	switch rec.Operation {
+	case decision.OperationRescan:
+		b.WriteString("would be denied on rescan")
+	case "":
+		b.WriteString("denied")
+	default:
+		b.WriteString("denied " + rec.Operation)
+	}
+	if rec.Error != "" {
+		b.WriteString(", could not be scanned")
+	} else {
+		fmt.Fprintf(&b, ", score %d, minimum score %d", rec.Score, rec.MinScore)
+	}
+	if len(rec.FailedRules) > 0 {
+		fmt.Fprintf(&b, ", failed critical checks %s", strings.Join(rec.FailedRules, ", "))
+	}
+	if len(rec.MissingChecks) > 0 {
+		fmt.Fprintf(&b, ", missing required checks %s", strings.Join(rec.MissingChecks, ", "))
+	}
+	if rec.RequestID != "" {
+		fmt.Fprintf(&b, ", request ID %s", rec.RequestID)
+	}
+	return b.String()
+}
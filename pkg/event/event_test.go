@@ -0,0 +1,67 @@
+package event
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// TestRecorder_Write - tests the Events of the denials
+func TestRecorder_Write(t *testing.T) {
+	tests := []struct {
+		name string
+		rec  decision.Record
+		want string // empty when no Event is expected
+	}{
+		{
+			name: "allowed",
+			rec:  decision.Record{Kind: "deployment", APIVersion: "apps/v1", ObjectKind: "Deployment", Namespace: "foo", Name: "test", Allowed: true},
+		},
+		{
+			name: "score too low",
+			rec:  decision.Record{Kind: "deployment", APIVersion: "apps/v1", ObjectKind: "Deployment", Namespace: "foo", Name: "test", Operation: "CREATE", Score: -30, FailedRules: []string{"Privileged"}, RequestID: "abc"},
+			want: "Warning KubesecScoreTooLow denied CREATE, score -30, minimum score 0, failed critical checks Privileged, request ID abc" +
+				" involvedObject{kind=Deployment,apiVersion=apps/v1}",
+		},
+		{
+			name: "checks failed",
+			rec:  decision.Record{Kind: "deployment", APIVersion: "apps/v1", ObjectKind: "Deployment", Namespace: "foo", Name: "test", Score: 3, MissingChecks: []string{"RunAsNonRoot"}},
+			want: "Warning KubesecChecksFailed denied, score 3, minimum score 0, missing required checks RunAsNonRoot" +
+				" involvedObject{kind=Deployment,apiVersion=apps/v1}",
+		},
+		{
+			name: "rescan",
+			rec:  decision.Record{Kind: "deployment", APIVersion: "apps/v1", ObjectKind: "Deployment", Namespace: "foo", Name: "test", Operation: decision.OperationRescan, Score: -30},
+			want: "Warning KubesecScoreTooLow would be denied on rescan, score -30, minimum score 0 involvedObject{kind=Deployment,apiVersion=apps/v1}",
+		},
+		{
+			name: "scan failed",
+			rec:  decision.Record{Kind: "deployment", APIVersion: "apps/v1", ObjectKind: "Deployment", Namespace: "foo", Name: "test", Error: "kubesec.io scan failed"},
+			want: "Warning KubesecScanFailed denied, could not be scanned involvedObject{kind=Deployment,apiVersion=apps/v1}",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			fake := record.NewFakeRecorder(1)
+			fake.IncludeObject = true
+			if err := NewRecorder(fake).Write(context.Background(), tt.rec); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case got := <-fake.Events:
+				if got != tt.want {
+					t.Fatalf("Write - want=%q, got=%q", tt.want, got)
+				}
+			default:
+				if tt.want != "" {
+					t.Fatalf("Write - want an Event, got none")
+				}
+			}
+		})
+	}
+}
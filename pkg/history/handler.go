@@ -0,0 +1,131 @@
+package history
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultLimit is the number of scans of a page when the client sets
+	// none.
+	defaultLimit = 100
+	// maxLimit caps the number of scans of a page.
+	maxLimit = 1000
+)
+
+// Results is a page of the scans.
+type Results struct {
+	Items []Scan `json:"items"`
+	// Continue is the continue parameter of the next page, empty on the last
+	// one.
+	Continue string `json:"continue,omitempty"`
+}
+
+// Handler serves the scans of the store to the clients presenting the bearer
+// token, e.g. on /api/v1/results. It satisfies http.Handler.
+type Handler struct {
+	store *Store
+	token string
+}
+
+// NewHandler returns a handler serving the scans of the store to the clients
+// presenting the bearer token.
+func NewHandler(s *Store, token string) (*Handler, error) {
+	if token == "" {
+		return nil, fmt.Errorf("history API token can't be empty")
+	}
+	return &Handler{store: s, token: token}, nil
+}
+
+// ServeHTTP returns the Results of the scans, most recent first, selected by
+// the namespace, kind, name and decision query parameters, between the since
+// and until RFC 3339 times. The pages hold limit scans, 100 by default, the
+// next one is fetched with the continue parameter of the previous one.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="kubesec-webhook"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, err := parseQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// One more scan tells whether there is a next page.
+	limit := q.Limit
+	q.Limit++
+	scans, err := h.store.Scans(r.Context(), q)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res := Results{Items: scans}
+	if len(scans) > limit {
+		res.Items = scans[:limit]
+		res.Continue = strconv.Itoa(q.Offset + limit)
+	}
+	if res.Items == nil {
+		res.Items = []Scan{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+// parseQuery returns the query of the parameters of the request.
+func parseQuery(r *http.Request) (Query, error) {
+	params := r.URL.Query()
+	q := Query{
+		Kind:      strings.ToLower(params.Get("kind")),
+		Namespace: params.Get("namespace"),
+		Name:      params.Get("name"),
+		Decision:  params.Get("decision"),
+		Limit:     defaultLimit,
+	}
+	switch q.Decision {
+	case "", "allowed", "denied":
+	default:
+		return Query{}, fmt.Errorf("decision must be allowed or denied, got %q", q.Decision)
+	}
+
+	for name, t := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		v := params.Get(name)
+		if v == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return Query{}, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		*t = parsed
+	}
+
+	if v := params.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxLimit {
+			return Query{}, fmt.Errorf("limit must be between 1 and %d, got %q", maxLimit, v)
+		}
+		q.Limit = limit
+	}
+	if v := params.Get("continue"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			return Query{}, fmt.Errorf("invalid continue %q", v)
+		}
+		q.Offset = offset
+	}
+	return q, nil
+}
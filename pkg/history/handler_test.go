@@ -0,0 +1,102 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// TestHandler - tests the scans are filtered and paginated
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	s, err := Open(ctx, DriverSQLite, filepath.Join(t.TempDir(), "history.db"), log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	at := time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC)
+	for n := 0; n < 5; n++ {
+		rec := decision.Record{Time: at.Add(time.Duration(n) * time.Minute), Kind: "deployment", Namespace: "foo", Name: fmt.Sprintf("test-%d", n), Allowed: n%2 == 0}
+		if err := s.Record(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Record(ctx, decision.Record{Time: at, Kind: "pod", Namespace: "bar", Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	h, err := NewHandler(s, "s3cr3t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		query  string
+		status int
+		want   []string
+		next   string
+	}{
+		{name: "unauthorized", token: "nope", status: http.StatusUnauthorized},
+		{name: "method", method: http.MethodPost, status: http.StatusMethodNotAllowed},
+		{name: "all", want: []string{"test-4", "test-3", "test-2", "test-1", "test", "test-0"}},
+		{name: "namespace and kind", query: "namespace=bar&kind=Pod", want: []string{"test"}},
+		{name: "denials", query: "namespace=foo&decision=denied", want: []string{"test-3", "test-1"}},
+		{name: "since", query: "since=2022-12-01T10:03:00Z", want: []string{"test-4", "test-3"}},
+		{name: "first page", query: "namespace=foo&limit=2", want: []string{"test-4", "test-3"}, next: "2"},
+		{name: "last page", query: "namespace=foo&limit=2&continue=4", want: []string{"test-0"}},
+		{name: "empty", query: "namespace=baz", want: []string{}},
+		{name: "invalid limit", query: "limit=5000", status: http.StatusBadRequest},
+		{name: "invalid decision", query: "decision=maybe", status: http.StatusBadRequest},
+		{name: "invalid since", query: "since=yesterday", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			method, token, status := tt.method, tt.token, tt.status
+			if method == "" {
+				method = http.MethodGet
+			}
+			if token == "" {
+				token = "s3cr3t"
+			}
+			if status == 0 {
+				status = http.StatusOK
+			}
+			req := httptest.NewRequest(method, "/api/v1/results?"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != status {
+				t.Fatalf("ServeHTTP - want status %d, got %d: %s", status, w.Code, w.Body)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			var res Results
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			if len(res.Items) != len(tt.want) || res.Continue != tt.next {
+				t.Fatalf("ServeHTTP - want %v and continue %q, got %+v", tt.want, tt.next, res)
+			}
+			for n, name := range tt.want {
+				if res.Items[n].Name != name {
+					t.Fatalf("ServeHTTP - want %v, got %+v", tt.want, res.Items)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+// Package history records the scans in a SQL database, SQLite or Postgres,
+// for the historical queries, e.g. when the score of a workload dropped.
+package history
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	// The drivers of the databases.
+	_ "github.com/lib/pq"
+	"github.com/slok/kubewebhook/pkg/log"
+	_ "modernc.org/sqlite"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// Drivers of the databases.
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// queueSize is the number of decisions waiting to be recorded before new ones
+// are dropped.
+const queueSize = 1024
+
+// schema creates the scans table and its indexes, the type of the IDs is
+// that of the driver.
+const schema = `
+CREATE TABLE IF NOT EXISTS scans (
+	id %s PRIMARY KEY,
+	time TIMESTAMP NOT NULL,
+	kind TEXT NOT NULL,
+	api_version TEXT NOT NULL,
+	object_kind TEXT NOT NULL,
+	namespace TEXT NOT NULL,
+	name TEXT NOT NULL,
+	uid TEXT NOT NULL,
+	operation TEXT NOT NULL,
+	request_id TEXT NOT NULL,
+	spec_hash TEXT NOT NULL,
+	decision TEXT NOT NULL,
+	scanned BOOLEAN NOT NULL,
+	score INTEGER NOT NULL,
+	min_score INTEGER NOT NULL,
+	failed_rules TEXT NOT NULL,
+	missing_checks TEXT NOT NULL,
+	exemption TEXT NOT NULL,
+	audit BOOLEAN NOT NULL,
+	error TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS scans_object ON scans (namespace, kind, name, time);
+CREATE INDEX IF NOT EXISTS scans_time ON scans (time);
+`
+
+var idTypes = map[string]string{
+	DriverSQLite:   "INTEGER",
+	DriverPostgres: "BIGSERIAL",
+}
+
+// Scan is a recorded scan of an object.
+type Scan struct {
+	ID         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	Kind       string    `json:"kind"`
+	APIVersion string    `json:"apiVersion,omitempty"`
+	ObjectKind string    `json:"objectKind,omitempty"`
+	Namespace  string    `json:"namespace"`
+	Name       string    `json:"name"`
+	UID        string    `json:"uid,omitempty"`
+	Operation  string    `json:"operation,omitempty"`
+	RequestID  string    `json:"requestID,omitempty"`
+	SpecHash   string    `json:"specHash,omitempty"`
+	// Decision is allowed or denied.
+	Decision string `json:"decision"`
+	// Scanned is unset when the object was allowed without a scan, or could
+	// not be scanned, the score is then meaningless.
+	Scanned       bool     `json:"scanned"`
+	Score         int      `json:"score"`
+	MinScore      int      `json:"minScore"`
+	FailedRules   []string `json:"failedRules,omitempty"`
+	MissingChecks []string `json:"missingChecks,omitempty"`
+	Exemption     string   `json:"exemption,omitempty"`
+	Audit         bool     `json:"audit,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// Store records a scan per decision, asynchronously so an unavailable
+// database never delays the admissions. It satisfies decision.Sink and must
+// be started to record the scans.
+type Store struct {
+	db     *sql.DB
+	driver string
+	logger log.Logger
+	queue  chan decision.Record
+}
+
+// Open connects to the database of the driver at dsn, a file path for
+// SQLite, and creates the scans table when missing.
+func Open(ctx context.Context, driver, dsn string, logger log.Logger) (*Store, error) {
+	idType, ok := idTypes[driver]
+	if !ok {
+		return nil, fmt.Errorf("unknown history driver %q, want %s or %s", driver, DriverSQLite, DriverPostgres)
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("could not open history database: %w", err)
+	}
+	if driver == DriverSQLite {
+		// SQLite serializes the writes, concurrent connections would only
+		// fail on a busy database.
+		db.SetMaxOpenConns(1)
+	}
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(schema, idType)); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not create history schema: %w", err)
+	}
+	return &Store{db: db, driver: driver, logger: logger, queue: make(chan decision.Record, queueSize)}, nil
+}
+
+// Write satisfies decision.Sink interface.
+func (s *Store) Write(_ context.Context, rec decision.Record) error {
+	select {
+	case s.queue <- rec:
+		return nil
+	default:
+		return fmt.Errorf("history queue is full, dropping decision of %s", rec.Key())
+	}
+}
+
+// Start records the scans until the context is done, then closes the
+// database.
+func (s *Store) Start(ctx context.Context) error {
+	defer s.db.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case rec := <-s.queue:
+			if err := s.Record(ctx, rec); err != nil {
+				s.logger.Warningf("could not record the scan of %s: %v", rec.Key(), err)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager every replica records the decisions it took.
+func (s *Store) NeedLeaderElection() bool {
+	return false
+}
+
+// Record inserts the scan of the decision.
+func (s *Store) Record(ctx context.Context, rec decision.Record) error {
+	verdict := "denied"
+	if rec.Allowed {
+		verdict = "allowed"
+	}
+	_, err := s.db.ExecContext(ctx, `INSERT INTO scans (time, kind, api_version, object_kind, namespace, name, uid, operation,
+request_id, spec_hash, decision, scanned, score, min_score, failed_rules, missing_checks, exemption, audit, error)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)`,
+		rec.Time.UTC(), rec.Kind, rec.APIVersion, rec.ObjectKind, rec.Namespace, rec.Name, string(rec.UID), rec.Operation,
+		rec.RequestID, rec.SpecHash, verdict, rec.Scan != nil, rec.Score, rec.MinScore,
+		strings.Join(rec.FailedRules, ","), strings.Join(rec.MissingChecks, ","), rec.Exemption, rec.Audit, rec.Error)
+	return err
+}
+
+// Query selects the scans, most recent first.
+type Query struct {
+	// Kind, Namespace and Name select the scans of an object, any when empty.
+	Kind      string
+	Namespace string
+	Name      string
+	// Decision selects the allowed or the denied scans, any when empty.
+	Decision string
+	// Since and Until bound the times of the scans, unbounded when zero.
+	Since time.Time
+	Until time.Time
+	// Limit is the maximum number of scans returned, all of them when 0,
+	// Offset the number of scans skipped before.
+	Limit  int
+	Offset int
+}
+
+// Scans returns the scans selected by the query.
+func (s *Store) Scans(ctx context.Context, q Query) ([]Scan, error) {
+	var where []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+	if q.Kind != "" {
+		add("kind = $%d", q.Kind)
+	}
+	if q.Namespace != "" {
+		add("namespace = $%d", q.Namespace)
+	}
+	if q.Name != "" {
+		add("name = $%d", q.Name)
+	}
+	if q.Decision != "" {
+		add("decision = $%d", q.Decision)
+	}
+	if !q.Since.IsZero() {
+		add("time >= $%d", q.Since.UTC())
+	}
+	if !q.Until.IsZero() {
+		add("time < $%d", q.Until.UTC())
+	}
+
+	query := `SELECT id, time, kind, api_version, object_kind, namespace, name, uid, operation, request_id, spec_hash,
+decision, scanned, score, min_score, failed_rules, missing_checks, exemption, audit, error FROM scans`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY time DESC, id DESC"
+	switch {
+	case q.Limit > 0:
+		query += fmt.Sprintf(" LIMIT %d", q.Limit)
+	case q.Offset > 0 && s.driver == DriverSQLite:
+		// SQLite only skips rows after a limit.
+		query += " LIMIT -1"
+	}
+	if q.Offset > 0 {
+		query += fmt.Sprintf(" OFFSET %d", q.Offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("could not query scans: %w", err)
+	}
+	defer rows.Close()
+
+	var scans []Scan
+	for rows.Next() {
+		var sc Scan
+		var failed, missing string
+		if err := rows.Scan(&sc.ID, &sc.Time, &sc.Kind, &sc.APIVersion, &sc.ObjectKind, &sc.Namespace, &sc.Name, &sc.UID,
+			&sc.Operation, &sc.RequestID, &sc.SpecHash, &sc.Decision, &sc.Scanned, &sc.Score, &sc.MinScore,
+			&failed, &missing, &sc.Exemption, &sc.Audit, &sc.Error); err != nil {
+			return nil, fmt.Errorf("could not read scan: %w", err)
+		}
+		sc.FailedRules, sc.MissingChecks = split(failed), split(missing)
+		scans = append(scans, sc)
+	}
+	return scans, rows.Err()
+}
+
+// Close closes the database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func split(v string) []string {
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, ",")
+}
@@ -0,0 +1,105 @@
+package history
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// TestStore_Scans - tests the scans are recorded and queried
+func TestStore_Scans(t *testing.T) {
+	ctx := context.Background()
+	s, err := Open(ctx, DriverSQLite, filepath.Join(t.TempDir(), "history.db"), log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	at := time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC)
+	recs := []decision.Record{
+		{Time: at, Kind: "deployment", Namespace: "foo", Name: "test", SpecHash: "a", Allowed: true, Score: 3, Scan: &scanner.Result{}},
+		{Time: at.Add(time.Hour), Kind: "deployment", Namespace: "foo", Name: "test", SpecHash: "b", Score: -30, FailedRules: []string{"Privileged", "HostPID"}, Scan: &scanner.Result{}},
+		{Time: at.Add(2 * time.Hour), Kind: "pod", Namespace: "foo", Name: "test", Allowed: true, Exemption: "namespace excluded"},
+		{Time: at.Add(3 * time.Hour), Kind: "deployment", Namespace: "bar", Name: "test", Error: "scan failed"},
+	}
+	for _, rec := range recs {
+		if err := s.Record(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string // spec hashes, or names when empty
+		check func(t *testing.T, scans []Scan)
+	}{
+		{
+			name:  "object",
+			query: Query{Kind: "deployment", Namespace: "foo", Name: "test"},
+			check: func(t *testing.T, scans []Scan) {
+				if len(scans) != 2 {
+					t.Fatalf("want 2 scans, got %+v", scans)
+				}
+				latest, first := scans[0], scans[1]
+				if latest.SpecHash != "b" || latest.Decision != "denied" || latest.Score != -30 || !latest.Scanned ||
+					len(latest.FailedRules) != 2 || latest.FailedRules[1] != "HostPID" || !latest.Time.Equal(at.Add(time.Hour)) {
+					t.Fatalf("unexpected latest scan %+v", latest)
+				}
+				if first.SpecHash != "a" || first.Decision != "allowed" || first.FailedRules != nil {
+					t.Fatalf("unexpected first scan %+v", first)
+				}
+			},
+		},
+		{
+			name:  "namespace",
+			query: Query{Namespace: "foo"},
+			check: func(t *testing.T, scans []Scan) {
+				if len(scans) != 3 || scans[0].Kind != "pod" || scans[0].Scanned || scans[0].Exemption == "" {
+					t.Fatalf("unexpected scans %+v", scans)
+				}
+			},
+		},
+		{
+			name:  "time range",
+			query: Query{Since: at.Add(time.Hour), Until: at.Add(3 * time.Hour)},
+			check: func(t *testing.T, scans []Scan) {
+				if len(scans) != 2 || scans[0].Kind != "pod" || scans[1].SpecHash != "b" {
+					t.Fatalf("unexpected scans %+v", scans)
+				}
+			},
+		},
+		{
+			name:  "limit",
+			query: Query{Limit: 1},
+			check: func(t *testing.T, scans []Scan) {
+				if len(scans) != 1 || scans[0].Namespace != "bar" || scans[0].Error != "scan failed" {
+					t.Fatalf("unexpected scans %+v", scans)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			scans, err := s.Scans(ctx, tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, scans)
+		})
+	}
+}
+
+// TestOpen - tests the unknown drivers are rejected
+func TestOpen(t *testing.T) {
+	if _, err := Open(context.Background(), "mysql", "", log.Dummy); err == nil {
+		t.Fatal("Open - want error for unknown driver")
+	}
+}
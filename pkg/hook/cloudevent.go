@@ -0,0 +1,85 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/requestid"
+)
+
+// Types of the CloudEvents of the decisions.
+const (
+	EventTypeAllowed = "io.kubesec.decision.allowed"
+	EventTypeDenied  = "io.kubesec.decision.denied"
+)
+
+// DefaultEventSource is the source of the CloudEvents when none is set.
+const DefaultEventSource = "kubesec-webhook"
+
+// CloudEvent posts the decision as a CloudEvent in the binary mode of the
+// HTTP binding, e.g. to a Knative Eventing broker or an Argo Events source.
+// The type tells the denials from the allowed decisions, those of the audit
+// mode are denials, the subject is the key of the reviewed object.
+type CloudEvent struct {
+	Sink   string
+	Source string
+	Client *http.Client
+}
+
+// Run satisfies Hook interface.
+func (c *CloudEvent) Run(ctx context.Context, r decision.Record) error {
+	raw, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Sink, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	source := c.Source
+	if source == "" {
+		source = DefaultEventSource
+	}
+	eventType := EventTypeDenied
+	if r.Allowed && !r.Audit {
+		eventType = EventTypeAllowed
+	}
+	eventTime := r.Time
+	if eventTime.IsZero() {
+		eventTime = time.Now()
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Ce-Specversion", "1.0")
+	req.Header.Set("Ce-Id", requestid.New())
+	req.Header.Set("Ce-Source", source)
+	req.Header.Set("Ce-Type", eventType)
+	req.Header.Set("Ce-Subject", r.Key())
+	req.Header.Set("Ce-Time", eventTime.UTC().Format(time.RFC3339Nano))
+	if r.RequestID != "" {
+		req.Header.Set("Ce-Requestid", r.RequestID)
+	}
+
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("got %v response from %v: %s", resp.StatusCode, c.Sink, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// TestCloudEvent_Run - tests the decisions are posted as binary CloudEvents
+func TestCloudEvent_Run(t *testing.T) {
+	at := time.Date(2022, 12, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		rec  decision.Record
+		want map[string]string
+	}{
+		{
+			name: "denied",
+			rec:  decision.Record{Time: at, Kind: "deployment", Namespace: "foo", Name: "test", RequestID: "abc"},
+			want: map[string]string{
+				"Ce-Specversion": "1.0",
+				"Ce-Source":      DefaultEventSource,
+				"Ce-Type":        EventTypeDenied,
+				"Ce-Subject":     "deployment/foo/test",
+				"Ce-Time":        "2022-12-01T10:00:00Z",
+				"Ce-Requestid":   "abc",
+				"Content-Type":   "application/json",
+			},
+		},
+		{
+			name: "allowed",
+			rec:  decision.Record{Time: at, Kind: "pod", Namespace: "foo", Name: "test", Allowed: true},
+			want: map[string]string{"Ce-Type": EventTypeAllowed, "Ce-Requestid": ""},
+		},
+		{
+			name: "audit",
+			rec:  decision.Record{Time: at, Kind: "pod", Namespace: "foo", Name: "test", Allowed: true, Audit: true},
+			want: map[string]string{"Ce-Type": EventTypeDenied},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var header http.Header
+			var rec decision.Record
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				header = r.Header
+				if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
+					t.Errorf("sink - could not decode decision: %v", err)
+				}
+				w.WriteHeader(http.StatusAccepted)
+			}))
+			defer srv.Close()
+
+			if err := (&CloudEvent{Sink: srv.URL}).Run(context.Background(), tt.rec); err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.want {
+				if got := header.Get(k); got != v {
+					t.Errorf("Run - want %s=%q, got %q", k, v, got)
+				}
+			}
+			if header.Get("Ce-Id") == "" || rec.Key() != tt.rec.Key() {
+				t.Fatalf("Run - want an event ID and the decision, got %q and %+v", header.Get("Ce-Id"), rec)
+			}
+		})
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no broker", http.StatusNotFound)
+	}))
+	defer srv.Close()
+	if err := (&CloudEvent{Sink: srv.URL}).Run(context.Background(), decision.Record{}); err == nil {
+		t.Fatal("Run - want error on a 404 response")
+	}
+}
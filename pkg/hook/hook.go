@@ -0,0 +1,174 @@
+// Package hook runs custom side effects, e.g. ticketing or chat
+// notifications, after every admission decision.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"golang.org/x/time/rate"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// queueSize is the number of decisions waiting for the hooks before new ones
+// are dropped.
+const queueSize = 256
+
+// Hook is a side effect of a decision.
+type Hook interface {
+	Run(ctx context.Context, r decision.Record) error
+}
+
+// Exec runs a binary with the decision JSON on its standard input.
+type Exec struct {
+	Path string
+	Args []string
+}
+
+// Run satisfies Hook interface.
+func (e *Exec) Run(ctx context.Context, r decision.Record) error {
+	raw, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, e.Path, e.Args...)
+	cmd.Stdin = bytes.NewReader(raw)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %w: %s", e.Path, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// HTTP posts the decision JSON to an endpoint.
+type HTTP struct {
+	URL     string
+	Headers map[string]string
+	Client  *http.Client
+}
+
+// Run satisfies Hook interface.
+func (h *HTTP) Run(ctx context.Context, r decision.Record) error {
+	raw, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("got %v response from %v: %s", resp.StatusCode, h.URL, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Dispatcher runs the hooks asynchronously, at most at the rate of the
+// limiter, so slow or failing hooks never delay the admissions. It satisfies
+// decision.Sink and must be started to run the hooks.
+type Dispatcher struct {
+	hooks   []Hook
+	limiter *rate.Limiter
+	timeout time.Duration
+	logger  log.Logger
+	queue   chan decision.Record
+}
+
+// NewDispatcher returns a dispatcher running the hooks for at most perSecond
+// decisions per second, each run bounded by timeout.
+func NewDispatcher(hooks []Hook, perSecond float64, timeout time.Duration, logger log.Logger) (*Dispatcher, error) {
+	if len(hooks) == 0 {
+		return nil, fmt.Errorf("at least one hook is required")
+	}
+	if perSecond <= 0 {
+		return nil, fmt.Errorf("hook rate must be positive")
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("hook timeout must be positive")
+	}
+
+	burst := int(perSecond)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Dispatcher{
+		hooks:   hooks,
+		limiter: rate.NewLimiter(rate.Limit(perSecond), burst),
+		timeout: timeout,
+		logger:  logger,
+		queue:   make(chan decision.Record, queueSize),
+	}, nil
+}
+
+// Write satisfies decision.Sink interface, the rescans are not dispatched.
+func (d *Dispatcher) Write(_ context.Context, r decision.Record) error {
+	if !r.Admission() {
+		return nil
+	}
+
+	select {
+	case d.queue <- r:
+		return nil
+	default:
+		return fmt.Errorf("hook queue is full, dropping decision of %s", r.Key())
+	}
+}
+
+// Start runs the hooks until the context is done.
+func (d *Dispatcher) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case r := <-d.queue:
+			if err := d.limiter.Wait(ctx); err != nil {
+				return nil
+			}
+			d.run(ctx, r)
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager every replica runs the hooks of the decisions it took.
+func (d *Dispatcher) NeedLeaderElection() bool {
+	return false
+}
+
+func (d *Dispatcher) run(ctx context.Context, r decision.Record) {
+	ctx, cancel := context.WithTimeout(ctx, d.timeout)
+	defer cancel()
+
+	for _, h := range d.hooks {
+		if err := h.Run(ctx, r); err != nil {
+			d.logger.Warningf("hook failed for %s: %v", r.Key(), err)
+		}
+	}
+}
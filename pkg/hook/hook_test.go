@@ -0,0 +1,82 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// TestDispatcher - tests the decisions are handed to the hooks asynchronously
+func TestDispatcher(t *testing.T) {
+	received := make(chan decision.Record, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rec decision.Record
+		if err := json.NewDecoder(r.Body).Decode(&rec); err != nil {
+			t.Errorf("hook - could not decode decision: %v", err)
+		}
+		received <- rec
+	}))
+	defer srv.Close()
+
+	d, err := NewDispatcher([]Hook{&HTTP{URL: srv.URL}}, 10, time.Second, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = d.Start(ctx) }()
+
+	// Rescans are not dispatched.
+	if err := d.Write(ctx, decision.Record{Kind: "pod", Namespace: "foo", Name: "rescanned", Operation: decision.OperationRescan}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Write(ctx, decision.Record{Kind: "pod", Namespace: "foo", Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case rec := <-received:
+		if rec.Key() != "pod/foo/test" {
+			t.Fatalf("Dispatcher - unexpected decision %+v", rec)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Dispatcher - hook never called")
+	}
+}
+
+// TestExec_Run - tests the decision is written on the binary standard input
+func TestExec_Run(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "decision.json")
+	script := filepath.Join(dir, "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat > \"$1\"\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Exec{Path: script, Args: []string{out}}
+	if err := h.Run(context.Background(), decision.Record{Kind: "pod", Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec decision.Record
+	if err := json.Unmarshal(raw, &rec); err != nil || rec.Name != "test" {
+		t.Fatalf("Exec - unexpected stdin %q: %v", raw, err)
+	}
+
+	if err := (&Exec{Path: filepath.Join(dir, "missing")}).Run(context.Background(), rec); err == nil {
+		t.Fatal("Exec - want error for missing binary")
+	}
+}
@@ -0,0 +1,221 @@
+package hook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// Decisions posted by the webhooks.
+const (
+	EventsAll     = "all"
+	EventsDenials = "denials"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of the bodies posted by the webhooks
+// with a secret, as sha256=<hex>.
+const SignatureHeader = "X-Kubesec-Signature"
+
+// retryBackoff is the wait before the first retry, doubled on every retry.
+const retryBackoff = 500 * time.Millisecond
+
+// Webhook posts a payload per decision to an endpoint, e.g. of a ticketing
+// system or a chat.
+type Webhook struct {
+	URL string `json:"url"`
+	// Events are the decisions posted: all of them, or the denials only, with
+	// those of the audit mode. All when empty.
+	Events  string            `json:"events,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Template is the text/template of the body, executed on the decision
+	// with the json and join functions. The decision JSON when empty.
+	Template    string `json:"template,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	// SecretFile holds the secret the bodies are signed with in the
+	// SignatureHeader, unsigned when empty.
+	SecretFile string `json:"secretFile,omitempty"`
+	// Retries is the number of retries of the failed posts, on connection
+	// errors and on 429 and 5xx responses.
+	Retries int `json:"retries,omitempty"`
+
+	Client  *http.Client `json:"-"`
+	tmpl    *template.Template
+	secret  []byte
+	backoff time.Duration
+}
+
+// Webhooks are the outbound webhooks of the decisions.
+type Webhooks struct {
+	Webhooks []*Webhook `json:"webhooks"`
+}
+
+// LoadWebhooks reads the YAML webhooks from path, and the secrets they are
+// signed with.
+func LoadWebhooks(path string) ([]*Webhook, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	whs, err := ParseWebhooks(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for _, wh := range whs {
+		if wh.SecretFile == "" {
+			continue
+		}
+		secret, err := os.ReadFile(wh.SecretFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read secret of webhook %s: %w", wh.URL, err)
+		}
+		wh.secret = bytes.TrimSpace(secret)
+	}
+	return whs, nil
+}
+
+// ParseWebhooks parses the YAML webhooks and their templates.
+func ParseWebhooks(raw []byte) ([]*Webhook, error) {
+	whs := &Webhooks{}
+	if err := yaml.UnmarshalStrict(raw, whs); err != nil {
+		return nil, fmt.Errorf("invalid webhooks: %w", err)
+	}
+	for n, wh := range whs.Webhooks {
+		if err := wh.compile(); err != nil {
+			return nil, fmt.Errorf("invalid webhook %d: %w", n, err)
+		}
+	}
+	return whs.Webhooks, nil
+}
+
+func (w *Webhook) compile() error {
+	if w.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	switch w.Events {
+	case "":
+		w.Events = EventsAll
+	case EventsAll, EventsDenials:
+	default:
+		return fmt.Errorf("events must be %s or %s, got %q", EventsAll, EventsDenials, w.Events)
+	}
+	if w.Retries < 0 {
+		return fmt.Errorf("retries must not be negative")
+	}
+	if w.Template == "" {
+		return nil
+	}
+
+	tmpl, err := template.New(w.URL).Option("missingkey=error").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			raw, err := json.Marshal(v)
+			return string(raw), err
+		},
+		"join": strings.Join,
+	}).Parse(w.Template)
+	if err != nil {
+		return err
+	}
+	w.tmpl = tmpl
+	return nil
+}
+
+// Run satisfies Hook interface.
+func (w *Webhook) Run(ctx context.Context, r decision.Record) error {
+	if w.Events == EventsDenials && r.Allowed && !r.Audit {
+		return nil
+	}
+
+	body, err := w.body(r)
+	if err != nil {
+		return err
+	}
+
+	backoff := w.backoff
+	if backoff == 0 {
+		backoff = retryBackoff
+	}
+	for attempt := 0; ; attempt++ {
+		retry, err := w.post(ctx, body)
+		if err == nil || !retry || attempt >= w.Retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w, no retry left: %v", err, ctx.Err())
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// body returns the body posted for the decision.
+func (w *Webhook) body(r decision.Record) ([]byte, error) {
+	if w.tmpl == nil {
+		return json.Marshal(r)
+	}
+	var buf bytes.Buffer
+	if err := w.tmpl.Execute(&buf, r); err != nil {
+		return nil, fmt.Errorf("could not render body of webhook %s: %w", w.URL, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// post posts the body once, it tells whether a failure is worth a retry.
+func (w *Webhook) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	contentType := w.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range w.Headers {
+		req.Header.Set(k, v)
+	}
+	if len(w.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return retry, fmt.Errorf("got %v response from %v: %s", resp.StatusCode, w.URL, strings.TrimSpace(string(msg)))
+	}
+	return false, nil
+}
+
+// Sign returns the signature of the body with the secret, as set in the
+// SignatureHeader.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,166 @@
+package hook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// TestWebhook_Run - tests the bodies, signatures and retries of the webhooks
+func TestWebhook_Run(t *testing.T) {
+	denied := decision.Record{Kind: "pod", Namespace: "foo", Name: "test", Score: -30, FailedRules: []string{"Privileged", "HostPID"}}
+	tests := []struct {
+		name     string
+		config   string
+		rec      decision.Record
+		statuses []int
+		want     string // body, empty when nothing is posted
+		posts    int
+		wantErr  bool
+	}{
+		{
+			name:   "decision JSON",
+			config: "webhooks:\n- url: URL\n",
+			rec:    denied,
+			want:   `"name":"test"`,
+			posts:  1,
+		},
+		{
+			name:   "template",
+			config: "webhooks:\n- url: URL\n  template: '{\"text\": {{ printf \"%s/%s denied, failed %s\" .Namespace .Name (join .FailedRules \", \") | json }}}'\n",
+			rec:    denied,
+			want:   `{"text": "foo/test denied, failed Privileged, HostPID"}`,
+			posts:  1,
+		},
+		{
+			name:   "allowed skipped",
+			config: "webhooks:\n- url: URL\n  events: denials\n",
+			rec:    decision.Record{Kind: "pod", Name: "test", Allowed: true},
+		},
+		{
+			name:   "audit posted",
+			config: "webhooks:\n- url: URL\n  events: denials\n",
+			rec:    decision.Record{Kind: "pod", Name: "test", Allowed: true, Audit: true},
+			want:   `"audit":true`,
+			posts:  1,
+		},
+		{
+			name:     "retried",
+			config:   "webhooks:\n- url: URL\n  retries: 2\n",
+			rec:      denied,
+			statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests},
+			want:     `"name":"test"`,
+			posts:    3,
+		},
+		{
+			name:     "retries exhausted",
+			config:   "webhooks:\n- url: URL\n  retries: 1\n",
+			rec:      denied,
+			statuses: []int{http.StatusBadGateway, http.StatusBadGateway},
+			posts:    2,
+			wantErr:  true,
+		},
+		{
+			name:     "client error not retried",
+			config:   "webhooks:\n- url: URL\n  retries: 3\n",
+			rec:      denied,
+			statuses: []int{http.StatusBadRequest},
+			posts:    1,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var posts int
+			var body string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				raw, _ := io.ReadAll(r.Body)
+				if posts < len(tt.statuses) {
+					w.WriteHeader(tt.statuses[posts])
+				}
+				posts++
+				body = string(raw)
+			}))
+			defer srv.Close()
+
+			whs, err := ParseWebhooks([]byte(strings.ReplaceAll(tt.config, "URL", srv.URL)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			wh := whs[0]
+			wh.backoff = time.Millisecond
+
+			err = wh.Run(context.Background(), tt.rec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run - want error %v, got %v", tt.wantErr, err)
+			}
+			if posts != tt.posts {
+				t.Fatalf("Run - want %d posts, got %d", tt.posts, posts)
+			}
+			if !strings.Contains(body, tt.want) {
+				t.Fatalf("Run - want %q in body, got %q", tt.want, body)
+			}
+		})
+	}
+}
+
+// TestLoadWebhooks - tests the bodies are signed with the secrets
+func TestLoadWebhooks(t *testing.T) {
+	signatures := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signatures <- r.Header.Get(SignatureHeader)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "secret")
+	config := filepath.Join(dir, "webhooks.yaml")
+	if err := os.WriteFile(secret, []byte("s3cr3t\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config, []byte("webhooks:\n- url: "+srv.URL+"\n  template: '{{.Name}}'\n  secretFile: "+secret+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	whs, err := LoadWebhooks(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := whs[0].Run(context.Background(), decision.Record{Name: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := <-signatures, Sign([]byte("s3cr3t"), []byte("test")); got != want {
+		t.Fatalf("LoadWebhooks - want signature %q, got %q", want, got)
+	}
+}
+
+// TestParseWebhooks - tests the invalid webhooks are rejected
+func TestParseWebhooks(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+	}{
+		{name: "no url", config: "webhooks:\n- events: all\n"},
+		{name: "events", config: "webhooks:\n- url: http://localhost\n  events: some\n"},
+		{name: "retries", config: "webhooks:\n- url: http://localhost\n  retries: -1\n"},
+		{name: "template", config: "webhooks:\n- url: http://localhost\n  template: '{{.Name'\n"},
+		{name: "unknown field", config: "webhooks:\n- url: http://localhost\n  secret: s3cr3t\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseWebhooks([]byte(tt.config)); err == nil {
+				t.Fatal("ParseWebhooks - want error")
+			}
+		})
+	}
+}
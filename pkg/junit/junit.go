@@ -0,0 +1,99 @@
+// Package junit renders the reviews as a JUnit XML report, so CI servers such
+// as Jenkins and GitLab show the denials as failed tests.
+package junit
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// Case is a reviewed object.
+type Case struct {
+	// Suite groups the cases, e.g. by manifest file.
+	Suite string
+	Name  string
+	// Failure is the denial message, empty when the object was allowed.
+	Failure string
+	// Error is why the object could not be scanned, empty when scanned.
+	Error string
+}
+
+type testSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Suites   []testSuite `xml:"testsuite"`
+}
+
+type testSuite struct {
+	Name     string     `xml:"name,attr"`
+	Tests    int        `xml:"tests,attr"`
+	Failures int        `xml:"failures,attr"`
+	Errors   int        `xml:"errors,attr"`
+	Cases    []testCase `xml:"testcase"`
+}
+
+type testCase struct {
+	Name      string   `xml:"name,attr"`
+	ClassName string   `xml:"classname,attr"`
+	Failure   *failure `xml:"failure,omitempty"`
+	Error     *failure `xml:"error,omitempty"`
+}
+
+type failure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// Write writes the report named name of the cases, a test suite per suite in
+// the order they first appear.
+func Write(w io.Writer, name string, cases []Case) error {
+	report := testSuites{Name: name, Suites: []testSuite{}}
+	index := map[string]int{}
+	for _, c := range cases {
+		i, ok := index[c.Suite]
+		if !ok {
+			i = len(report.Suites)
+			index[c.Suite] = i
+			report.Suites = append(report.Suites, testSuite{Name: c.Suite})
+		}
+		suite := &report.Suites[i]
+
+		tc := testCase{Name: c.Name, ClassName: c.Suite}
+		switch {
+		case c.Failure != "":
+			tc.Failure = &failure{Message: firstLine(c.Failure), Text: c.Failure}
+			suite.Failures++
+			report.Failures++
+		case c.Error != "":
+			tc.Error = &failure{Message: firstLine(c.Error), Text: c.Error}
+			suite.Errors++
+			report.Errors++
+		}
+		suite.Tests++
+		report.Tests++
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func firstLine(s string) string {
+	for i, c := range s {
+		if c == '\n' {
+			return s[:i]
+		}
+	}
+	return s
+}
@@ -0,0 +1,37 @@
+package junit
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestWrite - tests the cases are grouped by suite with their failures
+func TestWrite(t *testing.T) {
+	cases := []Case{
+		{Suite: "manifests/api.yaml", Name: "Deployment default/api", Failure: "api score is -30, deployment minimum accepted score is 0\nScan Result: ..."},
+		{Suite: "manifests/api.yaml", Name: "Pod default/debug"},
+		{Suite: "manifests/db.yaml", Name: "StatefulSet default/db", Error: "kubesec.io scan failed"},
+	}
+	var out bytes.Buffer
+	if err := Write(&out, "kubesec", cases); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="kubesec" tests="3" failures="1" errors="1">
+  <testsuite name="manifests/api.yaml" tests="2" failures="1" errors="0">
+    <testcase name="Deployment default/api" classname="manifests/api.yaml">
+      <failure message="api score is -30, deployment minimum accepted score is 0">api score is -30, deployment minimum accepted score is 0&#xA;Scan Result: ...</failure>
+    </testcase>
+    <testcase name="Pod default/debug" classname="manifests/api.yaml"></testcase>
+  </testsuite>
+  <testsuite name="manifests/db.yaml" tests="1" failures="0" errors="1">
+    <testcase name="StatefulSet default/db" classname="manifests/db.yaml">
+      <error message="kubesec.io scan failed">kubesec.io scan failed</error>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	if out.String() != want {
+		t.Fatalf("Write - want\n%s\ngot\n%s", want, out.String())
+	}
+}
@@ -0,0 +1,156 @@
+// Package logging provides the loggers of the webhook.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+)
+
+// Log formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New returns a logger of the format writing to w, the debug messages only
+// logged when debug is set.
+func New(format string, w io.Writer, debug bool) (log.Logger, error) {
+	switch format {
+	case FormatText:
+		return &log.Std{Debug: debug}, nil
+	case FormatJSON:
+		return NewJSON(w, debug), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q, want %s or %s", format, FormatText, FormatJSON)
+}
+
+// fielder is a logger logging fields along with its messages.
+type fielder interface {
+	With(keysAndValues ...interface{}) log.Logger
+}
+
+// HasFields tells whether the logger logs fields along with its messages.
+func HasFields(logger log.Logger) bool {
+	_, ok := logger.(fielder)
+	return ok
+}
+
+// With returns a logger logging the key and value pairs along with the
+// messages, when the logger supports fields. Others are returned as is, the
+// messages are expected to hold what matters of the fields.
+func With(logger log.Logger, keysAndValues ...interface{}) log.Logger {
+	if f, ok := logger.(fielder); ok {
+		return f.With(keysAndValues...)
+	}
+	return logger
+}
+
+// Tag returns a logger logging the key and value pairs along with the
+// messages: as fields when the logger supports them, as a [key=value ...]
+// prefix of the messages otherwise.
+func Tag(logger log.Logger, keysAndValues ...interface{}) log.Logger {
+	if f, ok := logger.(fielder); ok {
+		return f.With(keysAndValues...)
+	}
+	var tags []string
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		tags = append(tags, fmt.Sprintf("%v=%v", keysAndValues[i], keysAndValues[i+1]))
+	}
+	if len(tags) == 0 {
+		return logger
+	}
+	return &prefixed{prefix: "[" + strings.Join(tags, " ") + "] ", logger: logger}
+}
+
+// prefixed prefixes the messages of a logger.
+type prefixed struct {
+	prefix string
+	logger log.Logger
+}
+
+func (p *prefixed) Infof(format string, args ...interface{}) {
+	p.logger.Infof(p.prefix+format, args...)
+}
+
+func (p *prefixed) Warningf(format string, args ...interface{}) {
+	p.logger.Warningf(p.prefix+format, args...)
+}
+
+func (p *prefixed) Errorf(format string, args ...interface{}) {
+	p.logger.Errorf(p.prefix+format, args...)
+}
+
+func (p *prefixed) Debugf(format string, args ...interface{}) {
+	p.logger.Debugf(p.prefix+format, args...)
+}
+
+// JSON logs one JSON object per line, with the time, level and message of the
+// entry and the fields of the logger.
+type JSON struct {
+	out    *output
+	debug  bool
+	fields []interface{}
+	now    func() time.Time
+}
+
+// output serializes the writes of a JSON logger and those derived from it.
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSON returns a JSON logger writing to w.
+func NewJSON(w io.Writer, debug bool) *JSON {
+	return &JSON{out: &output{w: w}, debug: debug, now: time.Now}
+}
+
+// With returns a logger logging the key and value pairs too.
+func (j *JSON) With(keysAndValues ...interface{}) log.Logger {
+	fields := make([]interface{}, 0, len(j.fields)+len(keysAndValues))
+	fields = append(fields, j.fields...)
+	fields = append(fields, keysAndValues...)
+	return &JSON{out: j.out, debug: j.debug, fields: fields, now: j.now}
+}
+
+func (j *JSON) Infof(format string, args ...interface{}) {
+	j.log("info", format, args...)
+}
+
+func (j *JSON) Warningf(format string, args ...interface{}) {
+	j.log("warning", format, args...)
+}
+
+func (j *JSON) Errorf(format string, args ...interface{}) {
+	j.log("error", format, args...)
+}
+
+func (j *JSON) Debugf(format string, args ...interface{}) {
+	if j.debug {
+		j.log("debug", format, args...)
+	}
+}
+
+func (j *JSON) log(level, format string, args ...interface{}) {
+	entry := map[string]interface{}{}
+	for i := 0; i+1 < len(j.fields); i += 2 {
+		entry[fmt.Sprint(j.fields[i])] = j.fields[i+1]
+	}
+	entry["time"] = j.now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level
+	entry["msg"] = fmt.Sprintf(format, args...)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(map[string]interface{}{"time": entry["time"], "level": level, "msg": entry["msg"], "error": err.Error()})
+	}
+
+	j.out.mu.Lock()
+	defer j.out.mu.Unlock()
+	_, _ = j.out.w.Write(append(line, '\n'))
+}
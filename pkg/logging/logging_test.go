@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+)
+
+// TestJSON - tests the entries of the JSON logger
+func TestJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		debug bool
+		log   func(l log.Logger)
+		want  []map[string]interface{}
+	}{
+		{
+			name: "message",
+			log:  func(l log.Logger) { l.Infof("scanned %d", 2) },
+			want: []map[string]interface{}{{"level": "info", "msg": "scanned 2"}},
+		},
+		{
+			name: "fields",
+			log: func(l log.Logger) {
+				With(With(l, "namespace", "default"), "score", 3).Warningf("denied")
+				l.Errorf("failed")
+			},
+			want: []map[string]interface{}{
+				{"level": "warning", "msg": "denied", "namespace": "default", "score": float64(3)},
+				{"level": "error", "msg": "failed"},
+			},
+		},
+		{
+			name: "fields do not override the entry",
+			log:  func(l log.Logger) { With(l, "msg", "forged", "level").Infof("real") },
+			want: []map[string]interface{}{{"level": "info", "msg": "real"}},
+		},
+		{
+			name: "debug disabled",
+			log:  func(l log.Logger) { l.Debugf("hidden") },
+		},
+		{
+			name:  "debug enabled",
+			debug: true,
+			log:   func(l log.Logger) { l.Debugf("shown") },
+			want:  []map[string]interface{}{{"level": "debug", "msg": "shown"}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			l := NewJSON(&buf, tt.debug)
+			l.now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+			tt.log(l)
+
+			dec := json.NewDecoder(&buf)
+			for i, want := range tt.want {
+				got := map[string]interface{}{}
+				if err := dec.Decode(&got); err != nil {
+					t.Fatalf("JSON - entry %d: %v", i, err)
+				}
+				want["time"] = "2020-01-02T03:04:05Z"
+				if len(got) != len(want) {
+					t.Fatalf("JSON - entry %d: want=%v, got=%v", i, want, got)
+				}
+				for k, v := range want {
+					if got[k] != v {
+						t.Fatalf("JSON - entry %d: want=%v, got=%v", i, want, got)
+					}
+				}
+			}
+			if dec.More() {
+				t.Fatalf("JSON - want %d entries, got more", len(tt.want))
+			}
+		})
+	}
+}
+
+// TestWith - tests the loggers without fields are returned as is
+func TestWith(t *testing.T) {
+	std := &log.Std{}
+	if HasFields(std) || With(std, "k", "v") != std {
+		t.Fatalf("With - want the text logger as is")
+	}
+	if !HasFields(NewJSON(&bytes.Buffer{}, false)) {
+		t.Fatalf("With - want the JSON logger to log fields")
+	}
+}
+
+// recorder records the messages logged.
+type recorder struct {
+	log.Logger
+	msgs []string
+}
+
+func (r *recorder) Infof(format string, args ...interface{}) {
+	r.msgs = append(r.msgs, fmt.Sprintf(format, args...))
+}
+
+// TestTag - tests the loggers without fields prefix the tags
+func TestTag(t *testing.T) {
+	r := &recorder{}
+	Tag(Tag(r, "request-id", "abc"), "uid", "123", "operation", "CREATE").Infof("denied %s", "test")
+	Tag(r).Infof("untagged")
+
+	want := []string{"[request-id=abc] [uid=123 operation=CREATE] denied test", "untagged"}
+	if !reflect.DeepEqual(r.msgs, want) {
+		t.Fatalf("Tag - want=%q, got=%q", want, r.msgs)
+	}
+}
+
+// TestNew - tests the loggers of the formats
+func TestNew(t *testing.T) {
+	if _, err := New("xml", &bytes.Buffer{}, false); err == nil {
+		t.Fatalf("New - want an error for an unknown format")
+	}
+	if l, err := New(FormatJSON, &bytes.Buffer{}, false); err != nil || !HasFields(l) {
+		t.Fatalf("New - want a JSON logger, got %T, %v", l, err)
+	}
+}
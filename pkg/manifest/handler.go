@@ -0,0 +1,106 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxManifestSize caps the size of the manifests posted, as the API server
+// caps the size of its requests.
+const maxManifestSize = 3 << 20
+
+// Handler reviews the manifests posted by the clients, as the webhooks would
+// review the creation of their object. It satisfies http.Handler.
+type Handler struct {
+	reviewer *Reviewer
+	token    string
+	// batch returns the Report of all the objects of the manifest.
+	batch bool
+}
+
+// NewHandler returns a handler reviewing with rv the manifests of the clients
+// presenting the bearer token.
+func NewHandler(rv *Reviewer, token string) (*Handler, error) {
+	if token == "" {
+		return nil, fmt.Errorf("scan API token can't be empty")
+	}
+	return &Handler{reviewer: rv, token: token}, nil
+}
+
+// Batch returns a handler reviewing all the objects of the manifests posted,
+// multi-document YAML or JSON arrays, and returning their Report as JSON.
+func (h *Handler) Batch() *Handler {
+	b := *h
+	b.batch = true
+	return &b
+}
+
+// ServeHTTP reviews the object of the manifest posted, YAML or JSON, and
+// returns its Result as JSON. The namespace query parameter is the namespace
+// of an object without one.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="kubesec-webhook"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxManifestSize))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("could not read the manifest: %v", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	rv := *h.reviewer
+	if ns := r.URL.Query().Get("namespace"); ns != "" {
+		rv.Namespace = ns
+	}
+	var rep Report
+	if err := rv.ReviewStream(r.Context(), "request", bytes.NewReader(body), &rep); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.batch {
+		for i := range rep.Results {
+			rep.Results[i].File = ""
+		}
+		if rep.Results == nil {
+			rep.Results = []Result{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(rep)
+		return
+	}
+	switch {
+	case rep.Skipped+rep.Reviewed != 1:
+		http.Error(w, fmt.Sprintf("expected one object, got %d", rep.Skipped+rep.Reviewed), http.StatusBadRequest)
+		return
+	case rep.Skipped == 1:
+		http.Error(w, "the object is of a kind the webhook does not review", http.StatusUnprocessableEntity)
+		return
+	}
+
+	res := rep.Results[0]
+	res.File = ""
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (h *Handler) authorized(r *http.Request) bool {
+	return validToken(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), h.token)
+}
+
+// validToken compares the tokens in constant time.
+func validToken(token, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
@@ -0,0 +1,98 @@
+package manifest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/webhook"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// TestHandler_ServeHTTP - tests the manifests posted are reviewed
+func TestHandler_ServeHTTP(t *testing.T) {
+	pod := "apiVersion: v1\nkind: Pod\nmetadata:\n  name: bad\n"
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		query  string
+		body   string
+		status int
+		want   Result
+	}{
+		{name: "unauthorized", method: http.MethodPost, token: "wrong", body: pod, status: http.StatusUnauthorized},
+		{name: "not a post", method: http.MethodGet, token: "secret", status: http.StatusMethodNotAllowed},
+		{
+			name: "reviewed", method: http.MethodPost, token: "secret", query: "?namespace=team-a", body: pod, status: http.StatusOK,
+			want: Result{Kind: "Pod", Namespace: "team-a", Name: "bad", Allowed: false, Message: "bad score is -30\nScan Result: ..."},
+		},
+		{name: "invalid", method: http.MethodPost, token: "secret", body: ": not yaml: [", status: http.StatusBadRequest},
+		{name: "several objects", method: http.MethodPost, token: "secret", body: pod + "---\n" + pod, status: http.StatusBadRequest},
+		{name: "no webhook", method: http.MethodPost, token: "secret", body: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n", status: http.StatusUnprocessableEntity},
+	}
+	h, err := NewHandler(&Reviewer{Webhooks: map[schema.GroupKind]webhook.Webhook{{Kind: "Pod"}: &denyBad{}}}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/scan"+tt.query, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("ServeHTTP - want status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var got Result
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Kind != tt.want.Kind || got.Namespace != tt.want.Namespace || got.Name != tt.want.Name || got.Allowed != tt.want.Allowed || got.Message != tt.want.Message {
+				t.Fatalf("ServeHTTP - want %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestNewHandler - tests the token is required
+func TestNewHandler(t *testing.T) {
+	if _, err := NewHandler(&Reviewer{}, ""); err == nil {
+		t.Fatal("NewHandler - want an error for an empty token")
+	}
+}
+
+// TestHandler_Batch - tests every object of the manifests posted is reviewed
+func TestHandler_Batch(t *testing.T) {
+	h, err := NewHandler(&Reviewer{Webhooks: map[schema.GroupKind]webhook.Webhook{{Kind: "Pod"}: &denyBad{}}}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := "apiVersion: v1\nkind: Pod\nmetadata:\n  name: good\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n---\n" +
+		`[{"apiVersion":"v1","kind":"Pod","metadata":{"name":"bad"}}]`
+	req := httptest.NewRequest(http.MethodPost, "/scan/batch", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+	h.Batch().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ServeHTTP - want status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var got Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Reviewed != 2 || got.Denied != 1 || got.Skipped != 1 || len(got.Results) != 2 {
+		t.Fatalf("ServeHTTP - counters mismatch, got %+v", got)
+	}
+	if r := got.Results[1]; r.Name != "bad" || r.Document != 3 || r.Allowed || r.File != "" {
+		t.Fatalf("ServeHTTP - want bad denied in document 3, got %+v", r)
+	}
+}
@@ -0,0 +1,261 @@
+// Package manifest reviews the objects of manifest files with the webhooks, so
+// CI pipelines hold the manifests to the admission policy of the cluster
+// before they are applied.
+package manifest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/slok/kubewebhook/pkg/webhook"
+	whcontext "github.com/slok/kubewebhook/pkg/webhook/context"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// defaultNamespace is the namespace of the objects without one, as kubectl
+// apply without -n would create them.
+const defaultNamespace = "default"
+
+// Result is the verdict of an object of a manifest.
+type Result struct {
+	File string `json:"file,omitempty"`
+	// Document is the number of the document of the object in its file,
+	// shared by the items of a List or of a JSON array.
+	Document  int      `json:"document"`
+	Kind      string   `json:"kind"`
+	Namespace string   `json:"namespace"`
+	Name      string   `json:"name"`
+	Allowed   bool     `json:"allowed"`
+	Message   string   `json:"message,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+	// Decision is the decision of the webhook, nil unless Decisions is the
+	// sink of its options.
+	Decision *decision.Record `json:"decision,omitempty"`
+}
+
+// Report sums up the review of manifests.
+type Report struct {
+	Reviewed int `json:"reviewed"`
+	Denied   int `json:"denied"`
+	// Skipped are the objects of kinds without webhook.
+	Skipped int      `json:"skipped"`
+	Results []Result `json:"results"`
+}
+
+// Reviewer reviews the objects of manifests.
+type Reviewer struct {
+	// Webhooks reviews the objects, by kind e.g. Pod or Deployment.apps.
+	Webhooks map[schema.GroupKind]webhook.Webhook
+	// Namespace is the namespace of the objects without one, default when
+	// empty.
+	Namespace string
+	// Stdin is read for the - path, optional.
+	Stdin io.Reader
+}
+
+// Decisions gives the results the decisions of the webhooks when it is the
+// sink of their options. It keeps the decision of each review in its context,
+// so concurrent reviews are safe.
+var Decisions decision.Sink = decisions{}
+
+type decisionKey struct{}
+
+// slot holds the decision of a review.
+type slot struct {
+	rec *decision.Record
+}
+
+type decisions struct{}
+
+// Write satisfies decision.Sink interface.
+func (decisions) Write(ctx context.Context, rec decision.Record) error {
+	if s, ok := ctx.Value(decisionKey{}).(*slot); ok {
+		s.rec = &rec
+	}
+	return nil
+}
+
+// Review reviews the manifests of the files, and of the YAML and JSON files
+// of the directories walked recursively.
+func (rv *Reviewer) Review(ctx context.Context, paths ...string) (Report, error) {
+	var rep Report
+	for _, root := range paths {
+		if root == "-" && rv.Stdin != nil {
+			if err := rv.ReviewStream(ctx, "stdin", rv.Stdin, &rep); err != nil {
+				return rep, err
+			}
+			continue
+		}
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || path != root && !manifestFile(path) {
+				return nil
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return rv.ReviewStream(ctx, path, f, &rep)
+		})
+		if err != nil {
+			return rep, err
+		}
+	}
+	return rep, nil
+}
+
+// manifestFile tells whether the file found in a directory is a manifest.
+func manifestFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// ReviewStream reviews the objects of r, YAML documents, JSON objects or
+// arrays, and of the Lists among them, adding their verdicts to rep. name
+// identifies r in the results.
+func (rv *Reviewer) ReviewStream(ctx context.Context, name string, r io.Reader, rep *Report) error {
+	dec := utilyaml.NewYAMLOrJSONDecoder(r, 4096)
+	for n := 1; ; n++ {
+		var doc interface{}
+		if err := dec.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("could not decode document %d of %s: %w", n, name, err)
+		}
+
+		docs, ok := doc.([]interface{})
+		if !ok {
+			docs = []interface{}{doc}
+		}
+		for _, d := range docs {
+			if err := rv.reviewDocument(ctx, name, n, d, rep); err != nil {
+				return fmt.Errorf("document %d of %s: %w", n, name, err)
+			}
+		}
+	}
+}
+
+// reviewDocument reviews the object of the document n of file, or the items
+// of the List.
+func (rv *Reviewer) reviewDocument(ctx context.Context, file string, n int, doc interface{}, rep *Report) error {
+	if doc == nil {
+		return nil
+	}
+	m, ok := doc.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("not an object")
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	obj := &unstructured.Unstructured{Object: m}
+	if !obj.IsList() {
+		return rv.review(ctx, file, n, obj, rep)
+	}
+	return obj.EachListItem(func(item runtime.Object) error {
+		return rv.review(ctx, file, n, item.(*unstructured.Unstructured), rep)
+	})
+}
+
+// review reviews obj as if it was created.
+func (rv *Reviewer) review(ctx context.Context, file string, n int, obj *unstructured.Unstructured, rep *Report) error {
+	gvk := obj.GroupVersionKind()
+	wh, ok := rv.Webhooks[gvk.GroupKind()]
+	if !ok {
+		rep.Skipped++
+		return nil
+	}
+
+	ns := obj.GetNamespace()
+	if ns == "" {
+		ns = rv.Namespace
+	}
+	if ns == "" {
+		ns = defaultNamespace
+	}
+	name := obj.GetName()
+	if name == "" {
+		name = obj.GetGenerateName()
+	}
+	raw, err := json.Marshal(obj.Object)
+	if err != nil {
+		return err
+	}
+	ar := &admissionv1beta1.AdmissionRequest{
+		UID:       types.UID(fmt.Sprintf("manifest-%d", rep.Reviewed+1)),
+		Kind:      metav1.GroupVersionKind(gvk),
+		Namespace: ns,
+		Name:      name,
+		Operation: admissionv1beta1.Create,
+		Object:    runtime.RawExtension{Raw: raw},
+	}
+
+	s := &slot{}
+	ctx = context.WithValue(whcontext.SetAdmissionRequest(ctx, ar), decisionKey{}, s)
+	resp := wh.Review(ctx, &admissionv1beta1.AdmissionReview{Request: ar})
+	res := Result{
+		File:      file,
+		Document:  n,
+		Kind:      gvk.Kind,
+		Namespace: ns,
+		Name:      name,
+		Allowed:   resp.Allowed,
+		Warnings:  resp.Warnings,
+		Decision:  s.rec,
+	}
+	if resp.Result != nil {
+		res.Message = resp.Result.Message
+	}
+	rep.Reviewed++
+	if !res.Allowed {
+		rep.Denied++
+	}
+	rep.Results = append(rep.Results, res)
+	return nil
+}
+
+// WriteText renders the report as plain text, with the denial messages and
+// the warnings.
+func (r Report) WriteText(w io.Writer) {
+	for _, res := range r.Results {
+		fmt.Fprintf(w, "%s %s/%s (%s): %s\n", res.Kind, res.Namespace, res.Name, res.File, verdict(res.Allowed))
+		if !res.Allowed && res.Message != "" {
+			for _, line := range strings.Split(res.Message, "\n") {
+				fmt.Fprintf(w, "  %s\n", line)
+			}
+		}
+		for _, warning := range res.Warnings {
+			fmt.Fprintf(w, "  warning: %s\n", warning)
+		}
+	}
+	fmt.Fprintf(w, "reviewed %d objects: %d allowed, %d denied, %d skipped\n", r.Reviewed, r.Reviewed-r.Denied, r.Denied, r.Skipped)
+}
+
+func verdict(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "denied"
+}
@@ -0,0 +1,131 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/webhook"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// denyBad denies the objects named bad, and writes the decisions to sink.
+type denyBad struct {
+	namespaces []string
+	sink       decision.Sink
+}
+
+func (d *denyBad) Review(ctx context.Context, ar *admissionv1beta1.AdmissionReview) *admissionv1beta1.AdmissionResponse {
+	d.namespaces = append(d.namespaces, ar.Request.Namespace)
+	if d.sink != nil {
+		_ = d.sink.Write(ctx, decision.Record{Name: ar.Request.Name, Allowed: ar.Request.Name != "bad"})
+	}
+	if ar.Request.Name == "bad" {
+		return &admissionv1beta1.AdmissionResponse{Allowed: false, Result: &metav1.Status{Message: "bad score is -30\nScan Result: ..."}}
+	}
+	return &admissionv1beta1.AdmissionResponse{Allowed: true, Warnings: []string{"kubesec: low score"}}
+}
+
+// TestReviewer_Review - tests the objects of the manifests are reviewed
+func TestReviewer_Review(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"pods.yaml": `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: good
+  namespace: team-a
+---
+# empty document
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: skipped
+`,
+		"nested/list.json": `{"apiVersion":"v1","kind":"List","items":[{"apiVersion":"v1","kind":"Pod","metadata":{"name":"bad"}}]}`,
+		"README.md":        "not a manifest",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wh := &denyBad{sink: Decisions}
+	rv := &Reviewer{Webhooks: map[schema.GroupKind]webhook.Webhook{{Kind: "Pod"}: wh}}
+	rep, err := rv.Review(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Reviewed != 2 || rep.Denied != 1 || rep.Skipped != 1 {
+		t.Fatalf("Review - counters mismatch, got %+v", rep)
+	}
+	if strings.Join(wh.namespaces, ",") != "default,team-a" {
+		t.Fatalf("Review - want the default namespace for the objects without one, got %v", wh.namespaces)
+	}
+	for _, res := range rep.Results {
+		if res.Decision == nil || res.Decision.Name != res.Name {
+			t.Fatalf("Review - want the decision of %s, got %+v", res.Name, res.Decision)
+		}
+	}
+
+	var out bytes.Buffer
+	rep.WriteText(&out)
+	want := "Pod default/bad (" + filepath.Join(dir, "nested/list.json") + "): denied\n" +
+		"  bad score is -30\n" +
+		"  Scan Result: ...\n" +
+		"Pod team-a/good (" + filepath.Join(dir, "pods.yaml") + "): allowed\n" +
+		"  warning: kubesec: low score\n" +
+		"reviewed 2 objects: 1 allowed, 1 denied, 1 skipped\n"
+	if out.String() != want {
+		t.Fatalf("WriteText - want\n%s\ngot\n%s", want, out.String())
+	}
+}
+
+// TestReviewer_ReviewStream - tests the invalid documents are reported
+func TestReviewer_ReviewStream(t *testing.T) {
+	rv := &Reviewer{Stdin: strings.NewReader("kind: Pod\n---\n: not yaml: [")}
+	_, err := rv.Review(context.Background(), "-")
+	if err == nil || !strings.Contains(err.Error(), "document 2 of stdin") {
+		t.Fatalf("ReviewStream - want an error for document 2, got %v", err)
+	}
+}
+
+// TestReviewer_ReviewStream_array - tests the objects of JSON arrays are reviewed
+func TestReviewer_ReviewStream_array(t *testing.T) {
+	rv := &Reviewer{Webhooks: map[schema.GroupKind]webhook.Webhook{{Kind: "Pod"}: &denyBad{}}}
+	var rep Report
+	in := `[{"apiVersion":"v1","kind":"Pod","metadata":{"name":"good"}},{"apiVersion":"v1","kind":"Pod","metadata":{"name":"bad"}}]` +
+		"\n---\n" + `{"apiVersion":"v1","kind":"Pod","metadata":{"name":"other"}}`
+	if err := rv.ReviewStream(context.Background(), "array.json", strings.NewReader(in), &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Reviewed != 3 || rep.Denied != 1 {
+		t.Fatalf("ReviewStream - counters mismatch, got %+v", rep)
+	}
+	var got []string
+	for _, res := range rep.Results {
+		got = append(got, fmt.Sprintf("%d:%s", res.Document, res.Name))
+	}
+	if strings.Join(got, ",") != "1:good,1:bad,2:other" {
+		t.Fatalf("ReviewStream - want the documents of the objects, got %v", got)
+	}
+
+	if err := rv.ReviewStream(context.Background(), "array.json", strings.NewReader(`["pod"]`), &Report{}); err == nil {
+		t.Fatal("ReviewStream - want an error for an array of strings")
+	}
+}
@@ -0,0 +1,185 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanpb"
+)
+
+// Service reviews the manifests of the gRPC clients presenting the bearer
+// token, as Handler does for the HTTP clients. It satisfies
+// scanpb.ScannerServer.
+type Service struct {
+	scanpb.UnimplementedScannerServer
+
+	reviewer *Reviewer
+	token    string
+}
+
+// NewService returns a service reviewing with rv the manifests of the clients
+// presenting the bearer token.
+func NewService(rv *Reviewer, token string) (*Service, error) {
+	if token == "" {
+		return nil, fmt.Errorf("scan API token can't be empty")
+	}
+	return &Service{reviewer: rv, token: token}, nil
+}
+
+// Scan satisfies scanpb.ScannerServer interface.
+func (s *Service) Scan(ctx context.Context, req *scanpb.ScanRequest) (*scanpb.ScanResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+	resp, err := s.scan(ctx, req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return resp, nil
+}
+
+// ScanStream satisfies scanpb.ScannerServer interface.
+func (s *Service) ScanStream(stream scanpb.Scanner_ScanStreamServer) error {
+	ctx := stream.Context()
+	if err := s.authorize(ctx); err != nil {
+		return err
+	}
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resp, err := s.scan(ctx, req)
+		if err != nil {
+			resp = &scanpb.ScanResponse{Name: req.GetName(), Error: err.Error()}
+		}
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Service) authorize(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, v := range md.Get("authorization") {
+		if validToken(strings.TrimPrefix(v, "Bearer "), s.token) {
+			return nil
+		}
+	}
+	return status.Error(codes.Unauthenticated, "unauthorized")
+}
+
+// scan reviews the objects of the manifest of the request.
+func (s *Service) scan(ctx context.Context, req *scanpb.ScanRequest) (*scanpb.ScanResponse, error) {
+	rv := *s.reviewer
+	if req.GetNamespace() != "" {
+		rv.Namespace = req.GetNamespace()
+	}
+	name := req.GetName()
+	if name == "" {
+		name = "request"
+	}
+	var rep Report
+	if err := rv.ReviewStream(ctx, name, bytes.NewReader(req.GetManifest()), &rep); err != nil {
+		return nil, err
+	}
+
+	resp := &scanpb.ScanResponse{
+		Name:     req.GetName(),
+		Reviewed: int32(rep.Reviewed),
+		Denied:   int32(rep.Denied),
+		Skipped:  int32(rep.Skipped),
+	}
+	for _, res := range rep.Results {
+		resp.Results = append(resp.Results, protoResult(res))
+	}
+	return resp, nil
+}
+
+// protoResult returns the result as a message, with the scan of its decision
+// when there is one.
+func protoResult(res Result) *scanpb.Result {
+	out := &scanpb.Result{
+		Document:  int32(res.Document),
+		Kind:      res.Kind,
+		Namespace: res.Namespace,
+		Name:      res.Name,
+		Allowed:   res.Allowed,
+		Message:   res.Message,
+		Warnings:  res.Warnings,
+	}
+	rec := res.Decision
+	if rec == nil {
+		return out
+	}
+	out.Score = int32(rec.Score)
+	out.MinScore = int32(rec.MinScore)
+	out.MissingChecks = rec.MissingChecks
+	out.DeniedRules = rec.DeniedRules
+	out.Exemption = rec.Exemption
+	out.Error = rec.Error
+	if rec.Scan != nil {
+		out.Scanned = true
+		out.Critical = protoChecks(rec.Scan.Scoring.Critical)
+		out.Advise = protoChecks(rec.Scan.Scoring.Advise)
+		out.Passed = protoChecks(rec.Scan.Scoring.Passed)
+	}
+	return out
+}
+
+func protoChecks(rules []scanner.Rule) []*scanpb.Check {
+	var res []*scanpb.Check
+	for _, r := range rules {
+		res = append(res, &scanpb.Check{Id: r.ID, Selector: r.Selector, Reason: r.Reason, Points: int32(r.Points)})
+	}
+	return res
+}
+
+// GRPCServer serves a Service over TLS. It must be started to serve.
+type GRPCServer struct {
+	addr    string
+	tls     *tls.Config
+	service *Service
+}
+
+// NewGRPCServer returns a server of the service listening on addr.
+func NewGRPCServer(addr string, tlsConfig *tls.Config, svc *Service) *GRPCServer {
+	return &GRPCServer{addr: addr, tls: tlsConfig, service: svc}
+}
+
+// Start serves until the context is done, then lets the calls in flight end.
+func (s *GRPCServer) Start(ctx context.Context) error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("could not listen on %s: %w", s.addr, err)
+	}
+	srv := grpc.NewServer(grpc.Creds(credentials.NewTLS(s.tls)))
+	scanpb.RegisterScannerServer(srv, s.service)
+
+	go func() {
+		<-ctx.Done()
+		srv.GracefulStop()
+	}()
+	return srv.Serve(lis)
+}
+
+// NeedLeaderElection tells the manager every replica serves the scans.
+func (s *GRPCServer) NeedLeaderElection() bool {
+	return false
+}
@@ -0,0 +1,111 @@
+package manifest
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/webhook"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanpb"
+)
+
+// dialService returns a client of the service, served in memory.
+func dialService(t *testing.T, svc *Service) scanpb.ScannerClient {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	scanpb.RegisterScannerServer(srv, svc)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return scanpb.NewScannerClient(conn)
+}
+
+// TestService_Scan - tests the manifests of the authorized clients are reviewed
+func TestService_Scan(t *testing.T) {
+	svc, err := NewService(&Reviewer{Webhooks: map[schema.GroupKind]webhook.Webhook{{Kind: "Pod"}: &denyBad{sink: Decisions}}}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := dialService(t, svc)
+	manifest := []byte("apiVersion: v1\nkind: Pod\nmetadata:\n  name: bad\n---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: c\n")
+
+	_, err = client.Scan(context.Background(), &scanpb.ScanRequest{Manifest: manifest})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Fatalf("Scan - want Unauthenticated without token, got %v", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	resp, err := client.Scan(ctx, &scanpb.ScanRequest{Manifest: manifest, Namespace: "team-a", Name: "pod.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Name != "pod.yaml" || resp.Reviewed != 1 || resp.Denied != 1 || resp.Skipped != 1 || len(resp.Results) != 1 {
+		t.Fatalf("Scan - counters mismatch, got %v", resp)
+	}
+	if r := resp.Results[0]; r.Name != "bad" || r.Namespace != "team-a" || r.Allowed || r.Document != 1 {
+		t.Fatalf("Scan - want bad denied in team-a, got %v", r)
+	}
+
+	_, err = client.Scan(ctx, &scanpb.ScanRequest{Manifest: []byte(": not yaml: [")})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Scan - want InvalidArgument for an invalid manifest, got %v", err)
+	}
+}
+
+// TestService_ScanStream - tests every manifest sent is answered in order
+func TestService_ScanStream(t *testing.T) {
+	svc, err := NewService(&Reviewer{Webhooks: map[schema.GroupKind]webhook.Webhook{{Kind: "Pod"}: &denyBad{}}}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := dialService(t, svc)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+	stream, err := client.ScanStream(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := []*scanpb.ScanRequest{
+		{Name: "good.yaml", Manifest: []byte("apiVersion: v1\nkind: Pod\nmetadata:\n  name: good\n")},
+		{Name: "invalid.yaml", Manifest: []byte(": not yaml: [")},
+		{Name: "bad.json", Manifest: []byte(`[{"apiVersion":"v1","kind":"Pod","metadata":{"name":"bad"}}]`)},
+	}
+	for _, req := range reqs {
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		name   string
+		denied int32
+		err    bool
+	}{{"good.yaml", 0, false}, {"invalid.yaml", 0, true}, {"bad.json", 1, false}}
+	for _, w := range want {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Name != w.name || resp.Denied != w.denied || (resp.Error != "") != w.err {
+			t.Fatalf("ScanStream - want %+v, got %v", w, resp)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Package metrics records the kubesec specific metrics of the webhooks, on top
+// of the generic admission review ones recorded by kubewebhook.
+package metrics
+
+import "time"
+
+// Recorder knows how to record metrics.
+type Recorder interface {
+	// IncScanThrottled will increment in one the counter of scans skipped because the backend is rate limiting.
+	IncScanThrottled(kind string)
+	// IncScanSkipped will increment in one the counter of objects admitted without scanning as they opted out.
+	IncScanSkipped(kind, namespace string)
+	// IncAuditDenied will increment in one the counter of objects allowed, that would have been denied if enforced.
+	IncAuditDenied(kind string)
+	// IncScanCache will increment in one the counter of lookups of a scan cache, memory or redis, hits or misses.
+	IncScanCache(cache string, hit bool)
+	// SetScanCacheSize will set the number of entries of the in-memory scan cache.
+	SetScanCacheSize(size int)
+	// IncScanRetry will increment in one the counter of scans retried after a transient failure.
+	IncScanRetry()
+	// SetScanBackendUp will set whether the kubesec backend passed its last probe.
+	SetScanBackendUp(backend string, up bool)
+	// SetScanBreakerState will set the state of the kubesec backend circuit breaker, 0 closed, 1 half-open and 2 open.
+	SetScanBreakerState(state int)
+	// SetExemptions will set the number of KubesecExemptions active, expiring soon and expired.
+	SetExemptions(active, expiring, expired int)
+	// SetRescanned will set the number of existing workloads of a kind passing, failing and not scanned by the last rescan.
+	SetRescanned(kind string, passing, failing, errored int)
+	// SetLastScore will set the score of the last scan of a workload, on admission or rescan.
+	SetLastScore(kind, namespace, name string, score int)
+	// ResetLastScores will forget the last scores, before a rescan sets those of the existing workloads again.
+	ResetLastScores()
+	// IncDenied will increment in one the counter of objects denied on admission.
+	IncDenied(kind, namespace string)
+	// IncRuleFailed will increment in one the counter of admission scans failing a critical check.
+	IncRuleFailed(rule string)
+	// ObserveScan will observe the duration of a scan of the kubesec backend, by outcome: success, error or throttled.
+	ObserveScan(backend, outcome string, d time.Duration)
+}
+
+// Dummy is a dummy recorder useful for tests.
+var Dummy = &dummy{}
+
+type dummy struct{}
+
+func (d *dummy) IncScanThrottled(kind string)                            {}
+func (d *dummy) IncScanSkipped(kind, namespace string)                   {}
+func (d *dummy) IncAuditDenied(kind string)                              {}
+func (d *dummy) IncScanCache(cache string, hit bool)                     {}
+func (d *dummy) SetScanCacheSize(size int)                               {}
+func (d *dummy) IncScanRetry()                                           {}
+func (d *dummy) SetScanBackendUp(backend string, up bool)                {}
+func (d *dummy) SetScanBreakerState(state int)                           {}
+func (d *dummy) SetExemptions(active, expiring, expired int)             {}
+func (d *dummy) SetRescanned(kind string, passing, failing, errored int) {}
+func (d *dummy) SetLastScore(kind, namespace, name string, score int)    {}
+func (d *dummy) ResetLastScores()                                        {}
+func (d *dummy) IncDenied(kind, namespace string)                        {}
+func (d *dummy) IncRuleFailed(rule string)                               {}
+func (d *dummy) ObserveScan(backend, outcome string, dur time.Duration)  {}
@@ -0,0 +1,252 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	promNamespace = "kubesec"
+	promSubsystem = "webhook"
+)
+
+// Prometheus is the implementation of a metrics Recorder for
+// Prometheus system.
+type Prometheus struct {
+	// Metrics.
+	scanThrottled *prometheus.CounterVec
+	scanSkipped   *prometheus.CounterVec
+	auditDenied   *prometheus.CounterVec
+	cacheHits     *prometheus.CounterVec
+	cacheMisses   *prometheus.CounterVec
+	cacheEntries  prometheus.Gauge
+	scanRetries   prometheus.Counter
+	scanBreaker   prometheus.Gauge
+	backendUp     *prometheus.GaugeVec
+	exemptions    *prometheus.GaugeVec
+	rescanned     *prometheus.GaugeVec
+	lastScore     *prometheus.GaugeVec
+	denied        *prometheus.CounterVec
+	ruleFailed    *prometheus.CounterVec
+	scanDuration  *prometheus.HistogramVec
+
+	reg prometheus.Registerer
+}
+
+// NewPrometheus returns a new Prometheus metrics backend.
+func NewPrometheus(registry prometheus.Registerer) *Prometheus {
+	p := &Prometheus{
+		reg: registry,
+
+		scanThrottled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Subsystem: promSubsystem,
+			Name:      "scans_throttled_total",
+			Help:      "Total number of scans skipped, and failed open, because the kubesec backend is rate limiting.",
+		}, []string{"kind"}),
+
+		scanSkipped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Subsystem: promSubsystem,
+			Name:      "scans_skipped_total",
+			Help:      "Total number of objects admitted without scanning as they opted out with the kubesec.io/skip annotation.",
+		}, []string{"kind", "namespace"}),
+
+		auditDenied: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Subsystem: promSubsystem,
+			Name:      "audit_denials_total",
+			Help:      "Total number of objects allowed as the enforcement is audit, that would have been denied otherwise.",
+		}, []string{"kind"}),
+
+		cacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "cache_hits_total",
+			Help:      "Total number of scan results found in a cache, by cache: memory or redis.",
+		}, []string{"cache"}),
+
+		cacheMisses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "cache_misses_total",
+			Help:      "Total number of scan results not found in a cache, by cache: memory or redis.",
+		}, []string{"cache"}),
+
+		cacheEntries: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "cache_entries",
+			Help:      "Number of scan results of the in-memory cache.",
+		}),
+
+		scanRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Subsystem: promSubsystem,
+			Name:      "scan_retries_total",
+			Help:      "Total number of scans retried after a transient failure of the kubesec backend.",
+		}),
+
+		scanBreaker: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Subsystem: promSubsystem,
+			Name:      "scan_breaker_state",
+			Help:      "State of the kubesec backend circuit breaker: 0 closed, 1 half-open, 2 open.",
+		}),
+
+		backendUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Subsystem: promSubsystem,
+			Name:      "backend_up",
+			Help:      "Whether the kubesec backend passed its last probe, 1, or not, 0, by backend.",
+		}, []string{"backend"}),
+
+		exemptions: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Subsystem: promSubsystem,
+			Name:      "exemptions",
+			Help:      "Number of KubesecExemptions, by state: active, expiring soon or expired.",
+		}, []string{"state"}),
+
+		rescanned: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Subsystem: promSubsystem,
+			Name:      "rescanned_workloads",
+			Help:      "Number of existing workloads of the last rescan, by kind and result: passing, failing or error.",
+		}, []string{"kind", "result"}),
+
+		lastScore: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: promNamespace,
+			Name:      "namespace_last_score",
+			Help:      "Score of the last scan of a workload, on admission or rescan, by namespace, kind and name.",
+		}, []string{"namespace", "kind", "name"}),
+
+		denied: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "admission_denied_total",
+			Help:      "Total number of objects denied on admission, by namespace and kind.",
+		}, []string{"namespace", "kind"}),
+
+		ruleFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: promNamespace,
+			Name:      "rule_failed_total",
+			Help:      "Total number of admission scans failing a critical check, by rule.",
+		}, []string{"rule"}),
+
+		scanDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: promNamespace,
+			Subsystem: promSubsystem,
+			Name:      "scan_duration_seconds",
+			Help:      "Duration of the scans of the kubesec backend, apart from the webhook overhead, by backend and outcome: success, error or throttled.",
+			Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}, []string{"backend", "outcome"}),
+	}
+
+	p.registerMetrics()
+	return p
+}
+
+func (p *Prometheus) registerMetrics() {
+	p.reg.MustRegister(
+		p.scanThrottled,
+		p.scanSkipped,
+		p.auditDenied,
+		p.cacheHits,
+		p.cacheMisses,
+		p.cacheEntries,
+		p.scanRetries,
+		p.scanBreaker,
+		p.backendUp,
+		p.exemptions,
+		p.rescanned,
+		p.lastScore,
+		p.denied,
+		p.ruleFailed,
+		p.scanDuration)
+}
+
+// IncScanThrottled satisfies Recorder interface.
+func (p *Prometheus) IncScanThrottled(kind string) {
+	p.scanThrottled.WithLabelValues(kind).Inc()
+}
+
+// IncScanSkipped satisfies Recorder interface.
+func (p *Prometheus) IncScanSkipped(kind, namespace string) {
+	p.scanSkipped.WithLabelValues(kind, namespace).Inc()
+}
+
+// IncAuditDenied satisfies Recorder interface.
+func (p *Prometheus) IncAuditDenied(kind string) {
+	p.auditDenied.WithLabelValues(kind).Inc()
+}
+
+// IncScanCache satisfies Recorder interface.
+func (p *Prometheus) IncScanCache(cache string, hit bool) {
+	if hit {
+		p.cacheHits.WithLabelValues(cache).Inc()
+	} else {
+		p.cacheMisses.WithLabelValues(cache).Inc()
+	}
+}
+
+// SetScanCacheSize satisfies Recorder interface.
+func (p *Prometheus) SetScanCacheSize(size int) {
+	p.cacheEntries.Set(float64(size))
+}
+
+// IncScanRetry satisfies Recorder interface.
+func (p *Prometheus) IncScanRetry() {
+	p.scanRetries.Inc()
+}
+
+// SetScanBackendUp satisfies Recorder interface.
+func (p *Prometheus) SetScanBackendUp(backend string, up bool) {
+	value := 0.0
+	if up {
+		value = 1
+	}
+	p.backendUp.WithLabelValues(backend).Set(value)
+}
+
+// SetScanBreakerState satisfies Recorder interface.
+func (p *Prometheus) SetScanBreakerState(state int) {
+	p.scanBreaker.Set(float64(state))
+}
+
+// SetExemptions satisfies Recorder interface.
+func (p *Prometheus) SetExemptions(active, expiring, expired int) {
+	p.exemptions.WithLabelValues("active").Set(float64(active))
+	p.exemptions.WithLabelValues("expiring").Set(float64(expiring))
+	p.exemptions.WithLabelValues("expired").Set(float64(expired))
+}
+
+// SetRescanned satisfies Recorder interface.
+func (p *Prometheus) SetRescanned(kind string, passing, failing, errored int) {
+	p.rescanned.WithLabelValues(kind, "passing").Set(float64(passing))
+	p.rescanned.WithLabelValues(kind, "failing").Set(float64(failing))
+	p.rescanned.WithLabelValues(kind, "error").Set(float64(errored))
+}
+
+// SetLastScore satisfies Recorder interface.
+func (p *Prometheus) SetLastScore(kind, namespace, name string, score int) {
+	p.lastScore.WithLabelValues(namespace, kind, name).Set(float64(score))
+}
+
+// ResetLastScores satisfies Recorder interface, the series of the deleted
+// workloads are removed.
+func (p *Prometheus) ResetLastScores() {
+	p.lastScore.Reset()
+}
+
+// IncDenied satisfies Recorder interface.
+func (p *Prometheus) IncDenied(kind, namespace string) {
+	p.denied.WithLabelValues(namespace, kind).Inc()
+}
+
+// IncRuleFailed satisfies Recorder interface.
+func (p *Prometheus) IncRuleFailed(rule string) {
+	p.ruleFailed.WithLabelValues(rule).Inc()
+}
+
+// ObserveScan satisfies Recorder interface.
+func (p *Prometheus) ObserveScan(backend, outcome string, d time.Duration) {
+	p.scanDuration.WithLabelValues(backend, outcome).Observe(d.Seconds())
+}
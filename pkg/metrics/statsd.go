@@ -0,0 +1,286 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/observability/metrics"
+)
+
+// Formats of the StatsD lines.
+const (
+	// FormatStatsD appends the label values to the metric names, e.g.
+	// kubesec.admission_denied.foo.pod:1|c.
+	FormatStatsD = "statsd"
+	// FormatDogStatsD tags the metrics with the labels, e.g.
+	// kubesec.admission_denied:1|c|#namespace:foo,kind:pod.
+	FormatDogStatsD = "dogstatsd"
+)
+
+const (
+	// statsdPacketSize caps the UDP packets so they are not fragmented.
+	statsdPacketSize = 1432
+	// statsdFlushInterval is the longest a line waits to be sent.
+	statsdFlushInterval = time.Second
+	// statsdQueueSize is the number of lines waiting to be sent, the next ones
+	// are dropped.
+	statsdQueueSize = 4096
+)
+
+// StatsD is the implementation of the metrics Recorders, the kubesec and the
+// kubewebhook ones, for StatsD and DogStatsD servers. The metrics are those of
+// Prometheus with dots between the namespace, subsystem and name and without
+// the _total suffix of the counters, the durations are timers in
+// milliseconds. The lines are sent over UDP in batches, and dropped when the
+// server can not keep up rather than slowing the admissions down.
+type StatsD struct {
+	conn   net.Conn
+	prefix string
+	format string
+	queue  chan string
+}
+
+// NewStatsD returns a recorder sending its metrics, prefixed, to the StatsD
+// server at address in the format.
+func NewStatsD(address, prefix, format string) (*StatsD, error) {
+	switch format {
+	case FormatStatsD, FormatDogStatsD:
+	default:
+		return nil, fmt.Errorf("invalid StatsD format %q", format)
+	}
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("could not dial StatsD server %s: %w", address, err)
+	}
+	return &StatsD{conn: conn, prefix: prefix, format: format, queue: make(chan string, statsdQueueSize)}, nil
+}
+
+// Start sends the metrics until the context is done.
+func (s *StatsD) Start(ctx context.Context) error {
+	defer s.conn.Close()
+	t := time.NewTicker(statsdFlushInterval)
+	defer t.Stop()
+
+	var packet []byte
+	flush := func() {
+		if len(packet) > 0 {
+			_, _ = s.conn.Write(packet)
+			packet = packet[:0]
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			for {
+				select {
+				case line := <-s.queue:
+					packet = s.append(packet, line, flush)
+				default:
+					flush()
+					return nil
+				}
+			}
+		case <-t.C:
+			flush()
+		case line := <-s.queue:
+			packet = s.append(packet, line, flush)
+		}
+	}
+}
+
+// append appends the line to the packet, flushing the packet first when the
+// line does not fit.
+func (s *StatsD) append(packet []byte, line string, flush func()) []byte {
+	if len(packet) > 0 && len(packet)+1+len(line) > statsdPacketSize {
+		flush()
+		packet = packet[:0]
+	}
+	if len(packet) > 0 {
+		packet = append(packet, '\n')
+	}
+	return append(packet, line...)
+}
+
+// NeedLeaderElection tells the manager every replica sends its metrics.
+func (s *StatsD) NeedLeaderElection() bool {
+	return false
+}
+
+// IncScanThrottled satisfies Recorder interface.
+func (s *StatsD) IncScanThrottled(kind string) {
+	s.count("kubesec.webhook.scans_throttled", "kind", kind)
+}
+
+// IncScanSkipped satisfies Recorder interface.
+func (s *StatsD) IncScanSkipped(kind, namespace string) {
+	s.count("kubesec.webhook.scans_skipped", "kind", kind, "namespace", namespace)
+}
+
+// IncAuditDenied satisfies Recorder interface.
+func (s *StatsD) IncAuditDenied(kind string) {
+	s.count("kubesec.webhook.audit_denials", "kind", kind)
+}
+
+// IncScanCache satisfies Recorder interface.
+func (s *StatsD) IncScanCache(cache string, hit bool) {
+	if hit {
+		s.count("kubesec.cache_hits", "cache", cache)
+	} else {
+		s.count("kubesec.cache_misses", "cache", cache)
+	}
+}
+
+// SetScanCacheSize satisfies Recorder interface.
+func (s *StatsD) SetScanCacheSize(size int) {
+	s.gauge("kubesec.cache_entries", size)
+}
+
+// IncScanRetry satisfies Recorder interface.
+func (s *StatsD) IncScanRetry() {
+	s.count("kubesec.webhook.scan_retries")
+}
+
+// SetScanBackendUp satisfies Recorder interface.
+func (s *StatsD) SetScanBackendUp(backend string, up bool) {
+	value := 0
+	if up {
+		value = 1
+	}
+	s.gauge("kubesec.webhook.backend_up", value, "backend", backend)
+}
+
+// SetScanBreakerState satisfies Recorder interface.
+func (s *StatsD) SetScanBreakerState(state int) {
+	s.gauge("kubesec.webhook.scan_breaker_state", state)
+}
+
+// SetExemptions satisfies Recorder interface.
+func (s *StatsD) SetExemptions(active, expiring, expired int) {
+	s.gauge("kubesec.webhook.exemptions", active, "state", "active")
+	s.gauge("kubesec.webhook.exemptions", expiring, "state", "expiring")
+	s.gauge("kubesec.webhook.exemptions", expired, "state", "expired")
+}
+
+// SetRescanned satisfies Recorder interface.
+func (s *StatsD) SetRescanned(kind string, passing, failing, errored int) {
+	s.gauge("kubesec.webhook.rescanned_workloads", passing, "kind", kind, "result", "passing")
+	s.gauge("kubesec.webhook.rescanned_workloads", failing, "kind", kind, "result", "failing")
+	s.gauge("kubesec.webhook.rescanned_workloads", errored, "kind", kind, "result", "error")
+}
+
+// SetLastScore satisfies Recorder interface.
+func (s *StatsD) SetLastScore(kind, namespace, name string, score int) {
+	s.gauge("kubesec.namespace_last_score", score, "namespace", namespace, "kind", kind, "name", name)
+}
+
+// ResetLastScores satisfies Recorder interface. The StatsD gauges can't be
+// deleted, the server expires those no longer sent, e.g. with the
+// deleteGauges setting of statsd.
+func (s *StatsD) ResetLastScores() {}
+
+// IncDenied satisfies Recorder interface.
+func (s *StatsD) IncDenied(kind, namespace string) {
+	s.count("kubesec.admission_denied", "namespace", namespace, "kind", kind)
+}
+
+// IncRuleFailed satisfies Recorder interface.
+func (s *StatsD) IncRuleFailed(rule string) {
+	s.count("kubesec.rule_failed", "rule", rule)
+}
+
+// ObserveScan satisfies Recorder interface.
+func (s *StatsD) ObserveScan(backend, outcome string, d time.Duration) {
+	s.timing("kubesec.webhook.scan_duration", d, "backend", backend, "outcome", outcome)
+}
+
+// IncAdmissionReview satisfies kubewebhook metrics.Recorder interface.
+func (s *StatsD) IncAdmissionReview(webhook, namespace, resource string, operation metrics.Operation, kind metrics.ReviewKind) {
+	s.count("kubewebhook.admission_webhook.admission_reviews", reviewTags(webhook, namespace, resource, operation, kind)...)
+}
+
+// IncAdmissionReviewError satisfies kubewebhook metrics.Recorder interface.
+func (s *StatsD) IncAdmissionReviewError(webhook, namespace, resource string, operation metrics.Operation, kind metrics.ReviewKind) {
+	s.count("kubewebhook.admission_webhook.admission_review_errors", reviewTags(webhook, namespace, resource, operation, kind)...)
+}
+
+// ObserveAdmissionReviewDuration satisfies kubewebhook metrics.Recorder interface.
+func (s *StatsD) ObserveAdmissionReviewDuration(webhook, namespace, resource string, operation metrics.Operation, kind metrics.ReviewKind, start time.Time) {
+	s.timing("kubewebhook.admission_webhook.admission_review_duration", time.Since(start), reviewTags(webhook, namespace, resource, operation, kind)...)
+}
+
+func reviewTags(webhook, namespace, resource string, operation metrics.Operation, kind metrics.ReviewKind) []string {
+	return []string{"webhook", webhook, "namespace", namespace, "resource", resource, "operation", string(operation), "kind", string(kind)}
+}
+
+func (s *StatsD) count(name string, labels ...string) {
+	s.send(s.line(name, "1|c", labels))
+}
+
+// gauge sets the gauge, a negative value is sent after a reset to 0 as StatsD
+// reads a signed value as a change of the gauge.
+func (s *StatsD) gauge(name string, value int, labels ...string) {
+	line := s.line(name, strconv.Itoa(value)+"|g", labels)
+	if value < 0 {
+		line = s.line(name, "0|g", labels) + "\n" + line
+	}
+	s.send(line)
+}
+
+func (s *StatsD) timing(name string, d time.Duration, labels ...string) {
+	s.send(s.line(name, strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', -1, 64)+"|ms", labels))
+}
+
+// line returns the line of the metric, labels are name and value pairs.
+func (s *StatsD) line(name, value string, labels []string) string {
+	var b strings.Builder
+	b.WriteString(s.prefix)
+	b.WriteString(name)
+	if s.format == FormatStatsD {
+		for i := 1; i < len(labels); i += 2 {
+			b.WriteByte('.')
+			b.WriteString(strings.ReplaceAll(sanitize(labels[i], "none"), ".", "_"))
+		}
+	}
+	b.WriteByte(':')
+	b.WriteString(value)
+	if s.format == FormatDogStatsD && len(labels) > 0 {
+		b.WriteString("|#")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i])
+			b.WriteByte(':')
+			b.WriteString(sanitize(labels[i+1], ""))
+		}
+	}
+	return b.String()
+}
+
+// send queues the line, dropping it when the queue is full.
+func (s *StatsD) send(line string) {
+	select {
+	case s.queue <- line:
+	default:
+	}
+}
+
+// sanitize replaces the characters of a label value breaking the StatsD
+// lines, an empty value is replaced with empty. The dots are kept, they only
+// break the names of the StatsD format.
+func sanitize(value, empty string) string {
+	if value == "" {
+		return empty
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', ',', '#', '@', '\n', ' ':
+			return '_'
+		}
+		return r
+	}, value)
+}
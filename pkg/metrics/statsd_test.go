@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestStatsD - tests the metrics are sent in the StatsD and DogStatsD formats
+func TestStatsD(t *testing.T) {
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{format: FormatStatsD, want: []string{
+			"test.kubesec.admission_denied.foo.pod:1|c",
+			"test.kubesec.rule_failed.Privileged:1|c",
+			"test.kubesec.namespace_last_score.foo.pod.my_app:0|g",
+			"test.kubesec.namespace_last_score.foo.pod.my_app:-7|g",
+			"test.kubesec.webhook.scan_duration.remote.success:12.5|ms",
+			"test.kubesec.webhook.scan_retries:1|c",
+		}},
+		{format: FormatDogStatsD, want: []string{
+			"test.kubesec.admission_denied:1|c|#namespace:foo,kind:pod",
+			"test.kubesec.rule_failed:1|c|#rule:Privileged",
+			"test.kubesec.namespace_last_score:0|g|#namespace:foo,kind:pod,name:my.app",
+			"test.kubesec.namespace_last_score:-7|g|#namespace:foo,kind:pod,name:my.app",
+			"test.kubesec.webhook.scan_duration:12.5|ms|#backend:remote,outcome:success",
+			"test.kubesec.webhook.scan_retries:1|c",
+		}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.format, func(t *testing.T) {
+			srv, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer srv.Close()
+
+			s, err := NewStatsD(srv.LocalAddr().String(), "test.", tt.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s.IncDenied("pod", "foo")
+			s.IncRuleFailed("Privileged")
+			s.SetLastScore("pod", "foo", "my.app", -7)
+			s.ObserveScan("remote", "success", 12500*time.Microsecond)
+			s.IncScanRetry()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := s.Start(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			_ = srv.SetReadDeadline(time.Now().Add(5 * time.Second))
+			buf := make([]byte, statsdPacketSize)
+			n, _, err := srv.ReadFrom(buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			lines := strings.Split(string(buf[:n]), "\n")
+			if len(lines) != len(tt.want) {
+				t.Fatalf("Start - want %q, got %q", tt.want, lines)
+			}
+			for i, want := range tt.want {
+				if lines[i] != want {
+					t.Fatalf("Start - want %q, got %q", want, lines[i])
+				}
+			}
+		})
+	}
+}
+
+// TestNewStatsD_format - tests the unknown formats are rejected
+func TestNewStatsD_format(t *testing.T) {
+	if _, err := NewStatsD("127.0.0.1:8125", "", "graphite"); err == nil {
+		t.Fatal("NewStatsD - want an error for an unknown format")
+	}
+}
@@ -0,0 +1,352 @@
+// Package peer syncs the scan cache and the circuit breaker of the replicas
+// of the webhook without Redis: the results a replica scans and the state of
+// its breaker are sent to the other replicas, discovered through a headless
+// Service, so all of them benefit from each other's scans during large
+// rollouts and stop reaching an unavailable backend together.
+package peer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Path is the path the replicas are synced on.
+const Path = "/peers/sync"
+
+const (
+	// queueSize is the number of updates waiting to be sent before new ones
+	// are dropped.
+	queueSize = 1024
+	// batchSize is the maximum number of scan results sent at once.
+	batchSize = 100
+	// sendTimeout bounds the sending of a batch to a replica.
+	sendTimeout = 5 * time.Second
+	// maxMessageSize bounds the batches received.
+	maxMessageSize = 8 << 20
+)
+
+// Config is the configuration of the sync.
+type Config struct {
+	// Service is the host name of a headless Service resolving to the
+	// addresses of the replicas, e.g. kubesec-webhook-peers.kubesec.svc.
+	Service string
+	// Port is the port of the webhook server of the replicas of Service.
+	Port int
+	// Addresses are host:port of replicas known in advance.
+	Addresses []string
+	// Self is the address of this replica, e.g. its Pod IP, not synced with.
+	Self string
+	// Token is the bearer token the replicas present to each other.
+	Token string
+	// TLS is the TLS configuration verifying the webhook certificate of the
+	// replicas.
+	TLS *tls.Config
+	// Refresh is the interval of the resolution of Service.
+	Refresh time.Duration
+}
+
+// Sync sends the scan results of the cache and the states of the breaker to
+// the other replicas, and applies theirs. It must be started to send them and
+// served on Path to receive them.
+type Sync struct {
+	cfg        Config
+	cache      *scanner.Cache
+	breaker    *scanner.Breaker
+	httpClient *http.Client
+	logger     log.Logger
+	lookup     func(ctx context.Context, host string) ([]string, error)
+	now        func() time.Time
+	queue      chan update
+
+	mu       sync.Mutex
+	peers    []string
+	resolved time.Time
+}
+
+// update is a scan result or a breaker state to send.
+type update struct {
+	scan    *scan
+	breaker *scanner.BreakerState
+}
+
+// message is a batch of updates as sent to the replicas.
+type message struct {
+	Scans []scan `json:"scans,omitempty"`
+	// Breaker is the last state of the breaker, open or closed.
+	Breaker string `json:"breaker,omitempty"`
+}
+
+type scan struct {
+	// Key is the hex encoded hash of the definition.
+	Key     string          `json:"key"`
+	Results scanner.Results `json:"results"`
+}
+
+// New returns the sync of the cache and breaker in the chain of sc.
+func New(cfg Config, sc scanner.Scanner, logger log.Logger) (*Sync, error) {
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("peer token can't be empty")
+	}
+	if cfg.Service == "" && len(cfg.Addresses) == 0 {
+		return nil, fmt.Errorf("peer service or addresses are required")
+	}
+	if cfg.Service != "" && (cfg.Port < 1 || cfg.Port > 65535) {
+		return nil, fmt.Errorf("invalid peer port %d", cfg.Port)
+	}
+	if cfg.Refresh <= 0 {
+		return nil, fmt.Errorf("peer refresh interval must be positive")
+	}
+
+	s := &Sync{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: sendTimeout, Transport: &http.Transport{TLSClientConfig: cfg.TLS}},
+		logger:     logger,
+		lookup:     net.DefaultResolver.LookupHost,
+		now:        time.Now,
+		queue:      make(chan update, queueSize),
+	}
+	for {
+		switch v := sc.(type) {
+		case *scanner.Cache:
+			s.cache = v
+		case *scanner.Breaker:
+			s.breaker = v
+		}
+		next, ok := sc.(interface{ Next() scanner.Scanner })
+		if !ok {
+			break
+		}
+		sc = next.Next()
+	}
+	if s.cache == nil && s.breaker == nil {
+		return nil, fmt.Errorf("peer sync needs the in-memory scan cache or the circuit breaker")
+	}
+
+	if s.cache != nil {
+		s.cache.SetPublisher(s)
+	}
+	if s.breaker != nil {
+		s.breaker.SetPublisher(s)
+	}
+	return s, nil
+}
+
+// PublishScan satisfies scanner.CachePublisher interface.
+func (s *Sync) PublishScan(key [sha256.Size]byte, results scanner.Results) {
+	s.enqueue(update{scan: &scan{Key: hex.EncodeToString(key[:]), Results: results}})
+}
+
+// PublishBreakerState satisfies scanner.BreakerPublisher interface.
+func (s *Sync) PublishBreakerState(state scanner.BreakerState) {
+	s.enqueue(update{breaker: &state})
+}
+
+func (s *Sync) enqueue(u update) {
+	select {
+	case s.queue <- u:
+	default:
+		s.logger.Debugf("peer sync queue is full, dropping an update")
+	}
+}
+
+// Start sends the updates until the context is done.
+func (s *Sync) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case u := <-s.queue:
+			s.send(ctx, s.batch(u))
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager every replica is synced.
+func (s *Sync) NeedLeaderElection() bool {
+	return false
+}
+
+// batch returns the message of the update and those already queued after it.
+func (s *Sync) batch(u update) message {
+	var msg message
+	add := func(u update) {
+		if u.scan != nil {
+			msg.Scans = append(msg.Scans, *u.scan)
+		}
+		if u.breaker != nil {
+			msg.Breaker = u.breaker.String()
+		}
+	}
+	add(u)
+	for len(msg.Scans) < batchSize {
+		select {
+		case u := <-s.queue:
+			add(u)
+		default:
+			return msg
+		}
+	}
+	return msg
+}
+
+// send sends the message to every other replica.
+func (s *Sync) send(ctx context.Context, msg message) {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		s.logger.Warningf("could not encode the peer sync: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, addr := range s.resolve(ctx) {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			if err := s.post(ctx, addr, raw); err != nil {
+				s.logger.Warningf("could not sync with peer %s: %v", addr, err)
+			}
+		}(addr)
+	}
+	wg.Wait()
+}
+
+func (s *Sync) post(ctx context.Context, addr string, raw []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+addr+Path, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.cfg.Token)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("got %v response", resp.StatusCode)
+	}
+	return nil
+}
+
+// resolve returns the addresses of the other replicas, Service resolved
+// again once the refresh interval elapsed. The last addresses are kept when
+// it can't be resolved.
+func (s *Sync) resolve(ctx context.Context) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cfg.Service != "" && (s.resolved.IsZero() || s.now().Sub(s.resolved) >= s.cfg.Refresh) {
+		s.resolved = s.now()
+		hosts, err := s.lookup(ctx, s.cfg.Service)
+		if err != nil {
+			s.logger.Warningf("could not resolve the peers of %s: %v", s.cfg.Service, err)
+		} else {
+			s.peers = s.peers[:0]
+			for _, h := range hosts {
+				s.peers = append(s.peers, net.JoinHostPort(h, strconv.Itoa(s.cfg.Port)))
+			}
+		}
+	}
+
+	var res []string
+	for _, addr := range append(append([]string{}, s.cfg.Addresses...), s.peers...) {
+		if host, _, err := net.SplitHostPort(addr); err == nil && host == s.cfg.Self {
+			continue
+		}
+		if !contains(res, addr) {
+			res = append(res, addr)
+		}
+	}
+	return res
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP applies the updates of the replicas presenting the token.
+func (s *Sync) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.Token)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="kubesec-webhook"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var msg message
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxMessageSize)).Decode(&msg); err != nil {
+		http.Error(w, fmt.Sprintf("could not decode the sync: %v", err), http.StatusBadRequest)
+		return
+	}
+	s.apply(msg)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apply caches the scan results and applies the breaker state of a replica,
+// the invalid ones ignored.
+func (s *Sync) apply(msg message) {
+	if s.cache != nil {
+		for _, sc := range msg.Scans {
+			raw, err := hex.DecodeString(sc.Key)
+			if err != nil || len(raw) != sha256.Size || !valid(sc.Results) {
+				continue
+			}
+			var key [sha256.Size]byte
+			copy(key[:], raw)
+			s.cache.Put(key, sc.Results)
+		}
+	}
+	if s.breaker != nil {
+		switch msg.Breaker {
+		case scanner.BreakerOpen.String():
+			s.breaker.Apply(scanner.BreakerOpen)
+		case scanner.BreakerClosed.String():
+			s.breaker.Apply(scanner.BreakerClosed)
+		}
+	}
+}
+
+// valid tells whether the results are cacheable, failed scans are not.
+func valid(results scanner.Results) bool {
+	if len(results) == 0 {
+		return false
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package peer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// stubScanner scores every definition with its length, or fails.
+type stubScanner struct {
+	scans int
+	err   error
+}
+
+func (s *stubScanner) Scan(_ context.Context, def []byte) (scanner.Results, error) {
+	s.scans++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return scanner.Results{{Score: len(def)}}, nil
+}
+
+type dummyRecorder struct{}
+
+func (dummyRecorder) IncScanCache(string, bool) {}
+func (dummyRecorder) SetScanCacheSize(int)      {}
+func (dummyRecorder) SetScanBreakerState(int)   {}
+
+// replica returns the cache and breaker of a replica, and its sync.
+func replica(t *testing.T, next scanner.Scanner, cfg Config) (*scanner.Cache, *scanner.Breaker, *Sync) {
+	t.Helper()
+	b, err := scanner.NewBreaker(next, 1, time.Minute, dummyRecorder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := scanner.NewCache(b, 8, time.Minute, dummyRecorder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(cfg, c, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, b, s
+}
+
+// TestSync - tests the scan results and breaker state of a replica are applied by the others
+func TestSync(t *testing.T) {
+	cfg := Config{Service: "kubesec-webhook-peers", Port: 443, Self: "10.0.0.1", Token: "secret", Refresh: time.Minute}
+
+	peerNext := &stubScanner{}
+	peerCache, peerBreaker, peerSync := replica(t, peerNext, cfg)
+	synced := make(chan struct{}, 8)
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		peerSync.ServeHTTP(w, r)
+		synced <- struct{}{}
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "https://"))
+
+	next := &stubScanner{}
+	cfg.Addresses = []string{"127.0.0.1:" + port}
+	cache, _, s := replica(t, next, cfg)
+	s.httpClient = server.Client()
+	// The Service resolves to the replica itself only.
+	s.lookup = func(context.Context, string) ([]string, error) { return []string{"10.0.0.1"}, nil }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = s.Start(ctx) }()
+
+	if _, err := cache.Scan(ctx, []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	wait(t, synced)
+	if res, err := peerCache.Scan(ctx, []byte("abc")); err != nil || peerNext.scans != 0 || !reflect.DeepEqual(res, scanner.Results{{Score: 3}}) {
+		t.Fatalf("Sync - want the scan result of the replica cached by its peer, got %v, %v after %d scans", res, err, peerNext.scans)
+	}
+
+	next.err = errors.New("connection refused")
+	if _, err := cache.Scan(ctx, []byte("down")); err == nil {
+		t.Fatal("Scan - want the backend error")
+	}
+	wait(t, synced)
+	if peerBreaker.State() != scanner.BreakerOpen {
+		t.Fatalf("Sync - want the breaker of the peer opened, got %s", peerBreaker.State())
+	}
+}
+
+func wait(t *testing.T, synced chan struct{}) {
+	t.Helper()
+	select {
+	case <-synced:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Sync - want the peer synced")
+	}
+}
+
+// TestSync_ServeHTTP - tests the updates of the replicas without the token or invalid are rejected
+func TestSync_ServeHTTP(t *testing.T) {
+	next := &stubScanner{}
+	cache, _, s := replica(t, next, Config{Addresses: []string{"10.0.0.2:443"}, Token: "secret", Refresh: time.Minute})
+
+	key := sha256.Sum256([]byte("abc"))
+	tests := map[string]struct {
+		token, body string
+		want        int
+	}{
+		"no token":     {body: `{}`, want: http.StatusUnauthorized},
+		"wrong token":  {token: "other", body: `{}`, want: http.StatusUnauthorized},
+		"invalid":      {token: "secret", body: `{`, want: http.StatusBadRequest},
+		"failed scans": {token: "secret", body: `{"scans": [{"key": "` + hex.EncodeToString(key[:]) + `", "results": [{"error": "invalid"}]}]}`, want: http.StatusNoContent},
+		"invalid keys": {token: "secret", body: `{"scans": [{"key": "abc", "results": [{"score": 9}]}]}`, want: http.StatusNoContent},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("ServeHTTP - want %d, got %d", tt.want, w.Code)
+			}
+		})
+	}
+
+	if _, err := cache.Scan(context.Background(), []byte("abc")); err != nil || next.scans != 1 {
+		t.Fatalf("ServeHTTP - want the invalid results ignored, got %v after %d scans", err, next.scans)
+	}
+}
+
+// TestNew - tests the incomplete configurations and chains without cache nor breaker are rejected
+func TestNew(t *testing.T) {
+	for _, cfg := range []Config{
+		{Addresses: []string{"10.0.0.2:443"}, Refresh: time.Minute},
+		{Token: "secret", Refresh: time.Minute},
+		{Service: "kubesec-webhook-peers", Token: "secret", Refresh: time.Minute},
+		{Addresses: []string{"10.0.0.2:443"}, Token: "secret"},
+	} {
+		if _, err := New(cfg, &stubScanner{}, log.Dummy); err == nil {
+			t.Fatalf("New - want an error for %+v", cfg)
+		}
+	}
+	if _, err := New(Config{Addresses: []string{"10.0.0.2:443"}, Token: "secret", Refresh: time.Minute}, &stubScanner{}, log.Dummy); err == nil {
+		t.Fatal("New - want an error without cache nor breaker")
+	}
+}
@@ -0,0 +1,185 @@
+// Package plugin extends the Kubesec checks with WASM modules, so teams add
+// their own checks to the scans without forking the webhook.
+//
+// A plugin is a WASI command: it reads an Input as JSON on its standard input
+// and writes an Output as JSON on its standard output. Its findings are added
+// to the checks of the scan and their points to its score.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Severities of the findings, the group of checks of the scan they are added
+// to.
+const (
+	SeverityCritical = "critical"
+	SeverityAdvise   = "advise"
+	SeverityPassed   = "passed"
+)
+
+// memoryLimitPages caps the memory of a plugin to 64 pages of 64 KiB, 4 MiB.
+const memoryLimitPages = 64
+
+// Input is what a plugin checks.
+type Input struct {
+	// Kind is the lower case kind of the object, e.g. deployment.
+	Kind   string          `json:"kind"`
+	Object json.RawMessage `json:"object"`
+	Scan   scanner.Result  `json:"scan"`
+}
+
+// Finding is a check of a plugin.
+type Finding struct {
+	ID       string `json:"id"`
+	Selector string `json:"selector,omitempty"`
+	Reason   string `json:"reason"`
+	// Points are added to the score of the scan, but for the advised checks
+	// which are missed checks, as in Kubesec.
+	Points int `json:"points"`
+	// Severity is SeverityCritical, SeverityAdvise or SeverityPassed.
+	Severity string `json:"severity"`
+}
+
+// Output is what a plugin found.
+type Output struct {
+	Findings []Finding `json:"findings"`
+}
+
+// WASM is a plugin run in a sandbox without access to the host, a fresh
+// instance per check.
+type WASM struct {
+	name    string
+	runtime wazero.Runtime
+	module  wazero.CompiledModule
+	timeout time.Duration
+}
+
+// Load compiles the plugin of path, whose checks are cut after timeout.
+func Load(ctx context.Context, path string, timeout time.Duration) (*WASM, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := New(ctx, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)), raw, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return w, nil
+}
+
+// New compiles the plugin of the WASM binary, name identifies it.
+func New(ctx context.Context, name string, binary []byte, timeout time.Duration) (*WASM, error) {
+	if timeout <= 0 {
+		return nil, fmt.Errorf("plugin timeout must be positive")
+	}
+	r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(memoryLimitPages))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	module, err := r.CompileModule(ctx, binary)
+	if err != nil {
+		_ = r.Close(ctx)
+		return nil, fmt.Errorf("invalid plugin: %w", err)
+	}
+	return &WASM{name: name, runtime: r, module: module, timeout: timeout}, nil
+}
+
+// Name returns the name of the plugin, that of its file.
+func (w *WASM) Name() string {
+	return w.name
+}
+
+// Check runs the plugin on the input and returns its findings.
+func (w *WASM) Check(ctx context.Context, in Input) ([]Finding, error) {
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, w.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cfg := wazero.NewModuleConfig().
+		WithName("").
+		WithStdin(bytes.NewReader(raw)).
+		WithStdout(&stdout).
+		WithStderr(&stderr)
+	mod, err := w.runtime.InstantiateModule(ctx, w.module, cfg)
+	if mod != nil {
+		_ = mod.Close(ctx)
+	}
+	var exit *sys.ExitError
+	if err != nil && !(errors.As(err, &exit) && exit.ExitCode() == 0) {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("plugin %s failed: %w: %s", w.name, err, msg)
+		}
+		return nil, fmt.Errorf("plugin %s failed: %w", w.name, err)
+	}
+
+	var out Output
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return nil, fmt.Errorf("plugin %s returned an invalid output: %w", w.name, err)
+	}
+	for _, f := range out.Findings {
+		if f.ID == "" {
+			return nil, fmt.Errorf("plugin %s returned a finding without ID", w.name)
+		}
+		switch f.Severity {
+		case SeverityCritical, SeverityAdvise, SeverityPassed:
+		default:
+			return nil, fmt.Errorf("plugin %s returned finding %s of invalid severity %q", w.name, f.ID, f.Severity)
+		}
+	}
+	return out.Findings, nil
+}
+
+// Close releases the plugin.
+func (w *WASM) Close(ctx context.Context) error {
+	return w.runtime.Close(ctx)
+}
+
+// Apply returns the result with the findings added to its checks, and scored
+// again.
+func Apply(result scanner.Result, findings []Finding) scanner.Result {
+	if len(findings) == 0 {
+		return result
+	}
+	// The rules may be shared with a cached result, they are copied.
+	scoring := scanner.Scoring{
+		Critical: append([]scanner.Rule{}, result.Scoring.Critical...),
+		Passed:   append([]scanner.Rule{}, result.Scoring.Passed...),
+		Advise:   append([]scanner.Rule{}, result.Scoring.Advise...),
+	}
+	for _, f := range findings {
+		r := scanner.Rule{ID: f.ID, Selector: f.Selector, Reason: f.Reason, Points: f.Points}
+		switch f.Severity {
+		case SeverityCritical:
+			scoring.Critical = append(scoring.Critical, r)
+		case SeverityAdvise:
+			scoring.Advise = append(scoring.Advise, r)
+		default:
+			scoring.Passed = append(scoring.Passed, r)
+		}
+	}
+	result.Scoring = scoring
+	result.Score = scoring.Score()
+	return result
+}
@@ -0,0 +1,190 @@
+package plugin
+
+import (
+	"context"
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// uleb returns v as unsigned LEB128.
+func uleb(v int) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+func section(id byte, content ...byte) []byte {
+	return append(append([]byte{id}, uleb(len(content))...), content...)
+}
+
+func name(s string) []byte {
+	return append(uleb(len(s)), s...)
+}
+
+// command returns a WASI command whose _start runs code after writing out on
+// its standard output.
+func command(out string, code ...byte) []byte {
+	iovec := make([]byte, 8)
+	binary.LittleEndian.PutUint32(iovec, 16)
+	binary.LittleEndian.PutUint32(iovec[4:], uint32(len(out)))
+
+	// fd_write(1, iovec at 0, 1 iovec, written at 8), then code.
+	body := append([]byte{0x00, 0x41, 0x01, 0x41, 0x00, 0x41, 0x01, 0x41, 0x08, 0x10, 0x00, 0x1a}, code...)
+	body = append(body, 0x0b)
+
+	var m []byte
+	m = append(m, 0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00)
+	m = append(m, section(1, 0x02, 0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f, 0x60, 0x00, 0x00)...)
+	m = append(m, section(2, append(append(append([]byte{0x01}, name("wasi_snapshot_preview1")...), name("fd_write")...), 0x00, 0x00)...)...)
+	m = append(m, section(3, 0x01, 0x01)...)
+	m = append(m, section(5, 0x01, 0x00, 0x01)...)
+	m = append(m, section(7, append(append(append([]byte{0x02}, name("memory")...), 0x02, 0x00), append(name("_start"), 0x00, 0x01)...)...)...)
+	m = append(m, section(10, append(append([]byte{0x01}, uleb(len(body))...), body...)...)...)
+	data := []byte{0x02, 0x00, 0x41, 0x00, 0x0b}
+	data = append(append(data, uleb(len(iovec))...), iovec...)
+	data = append(data, 0x00, 0x41, 0x10, 0x0b)
+	data = append(append(data, uleb(len(out))...), out...)
+	return append(m, section(11, data...)...)
+}
+
+// TestWASM_Check - tests the findings of the plugins
+func TestWASM_Check(t *testing.T) {
+	tests := []struct {
+		name    string
+		binary  []byte
+		want    []Finding
+		wantErr string
+	}{
+		{
+			name:   "findings",
+			binary: command(`{"findings":[{"id":"NoLatestTag","reason":"pin the image","points":-3,"severity":"critical"}]}`),
+			want:   []Finding{{ID: "NoLatestTag", Reason: "pin the image", Points: -3, Severity: SeverityCritical}},
+		},
+		{name: "no finding", binary: command(`{}`)},
+		{name: "invalid output", binary: command(`not json`), wantErr: "invalid output"},
+		{name: "invalid severity", binary: command(`{"findings":[{"id":"X","severity":"high"}]}`), wantErr: "invalid severity"},
+		{name: "trap", binary: command(`{}`, 0x00), wantErr: "plugin test failed"},
+		// loop br 0 end, cut by the timeout.
+		{name: "timeout", binary: command(`{}`, 0x03, 0x40, 0x0c, 0x00, 0x0b), wantErr: "plugin test failed"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := New(context.Background(), "test", tt.binary, 100*time.Millisecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close(context.Background())
+
+			got, err := w.Check(context.Background(), Input{Kind: "pod", Object: []byte(`{}`)})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Check - want error %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Check - want %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestWASM_Check_concurrent - tests the checks run in their own instances
+func TestWASM_Check_concurrent(t *testing.T) {
+	w, err := New(context.Background(), "test", command(`{"findings":[{"id":"X","severity":"passed","points":1}]}`), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close(context.Background())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := w.Check(context.Background(), Input{Kind: "pod"}); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// TestApply - tests the findings are added to the scan, scored as Kubesec does
+func TestApply(t *testing.T) {
+	passed := []scanner.Rule{{ID: "RunAsNonRoot", Points: 1}}
+	critical := []scanner.Rule{{ID: "Privileged", Points: -30}}
+	tests := []struct {
+		name     string
+		result   scanner.Result
+		findings []Finding
+		want     scanner.Result
+	}{
+		{
+			name:     "passed",
+			result:   scanner.Result{Score: 1, Scoring: scanner.Scoring{Passed: passed}},
+			findings: []Finding{{ID: "SignedImage", Points: 2, Severity: SeverityPassed}, {ID: "Sbom", Points: 1, Severity: SeverityAdvise}},
+			want: scanner.Result{Score: 3, Scoring: scanner.Scoring{
+				Critical: []scanner.Rule{},
+				Passed:   []scanner.Rule{{ID: "RunAsNonRoot", Points: 1}, {ID: "SignedImage", Points: 2}},
+				Advise:   []scanner.Rule{{ID: "Sbom", Points: 1}},
+			}},
+		},
+		{
+			name:     "new critical",
+			result:   scanner.Result{Score: 1, Scoring: scanner.Scoring{Passed: passed}},
+			findings: []Finding{{ID: "NoLatestTag", Points: -3, Severity: SeverityCritical}, {ID: "SignedImage", Points: 2, Severity: SeverityPassed}},
+			want: scanner.Result{Score: -3, Scoring: scanner.Scoring{
+				Critical: []scanner.Rule{{ID: "NoLatestTag", Points: -3}},
+				Passed:   []scanner.Rule{{ID: "RunAsNonRoot", Points: 1}, {ID: "SignedImage", Points: 2}},
+				Advise:   []scanner.Rule{},
+			}},
+		},
+		{
+			name:     "passed on a critical result",
+			result:   scanner.Result{Score: -30, Scoring: scanner.Scoring{Critical: critical, Passed: passed}},
+			findings: []Finding{{ID: "SignedImage", Points: 2, Severity: SeverityPassed}},
+			want: scanner.Result{Score: -30, Scoring: scanner.Scoring{
+				Critical: []scanner.Rule{{ID: "Privileged", Points: -30}},
+				Passed:   []scanner.Rule{{ID: "RunAsNonRoot", Points: 1}, {ID: "SignedImage", Points: 2}},
+				Advise:   []scanner.Rule{},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := Apply(tt.result, tt.findings)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Apply - want %+v, got %+v", tt.want, got)
+			}
+			if len(passed) != 1 || len(critical) != 1 {
+				t.Fatalf("Apply - want the rules of the result unchanged, got %v and %v", passed, critical)
+			}
+		})
+	}
+}
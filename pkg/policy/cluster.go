@@ -0,0 +1,171 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// KubesecPolicyKind is the kind of the cluster policies, a cluster scoped
+// custom resource defined by deploy/kubesec-policy-crd.yaml.
+var KubesecPolicyKind = schema.GroupVersionKind{Group: "kubesec.io", Version: "v1alpha1", Kind: "KubesecPolicy"}
+
+var ruleID = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*$`)
+
+// KubesecPolicySpec is the admission bar of the namespaces a KubesecPolicy
+// selects. Fields left unset keep the webhook flags.
+type KubesecPolicySpec struct {
+	// MinScore replaces the minimum score when set.
+	MinScore *int `json:"minScore,omitempty"`
+	// FailureMode replaces the failure mode, open or closed, when set.
+	FailureMode string `json:"failureMode,omitempty"`
+	// ExcludedRules are the IDs of the checks whose points are not counted.
+	ExcludedRules []string `json:"excludedRules,omitempty"`
+	// NamespaceSelector selects the namespaces of the objects the policy
+	// applies to, all of them when empty.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+}
+
+// KubesecPolicy is a cluster policy.
+type KubesecPolicy struct {
+	Name string
+	Spec KubesecPolicySpec
+
+	selector labels.Selector
+}
+
+// NewKubesecPolicy validates the spec and returns the policy.
+func NewKubesecPolicy(name string, spec KubesecPolicySpec) (KubesecPolicy, error) {
+	switch spec.FailureMode {
+	case "", "open", "closed":
+	default:
+		return KubesecPolicy{}, fmt.Errorf("kubesec policy %q: invalid failure mode %q", name, spec.FailureMode)
+	}
+	for _, id := range spec.ExcludedRules {
+		if !ruleID.MatchString(id) {
+			return KubesecPolicy{}, fmt.Errorf("kubesec policy %q: invalid excluded rule %q", name, id)
+		}
+	}
+
+	selector := labels.Everything()
+	if spec.NamespaceSelector != nil {
+		s, err := metav1.LabelSelectorAsSelector(spec.NamespaceSelector)
+		if err != nil {
+			return KubesecPolicy{}, fmt.Errorf("kubesec policy %q: invalid namespace selector: %w", name, err)
+		}
+		selector = s
+	}
+
+	return KubesecPolicy{Name: name, Spec: spec, selector: selector}, nil
+}
+
+// matches tells whether the policy applies to a namespace of the labels, nil
+// when the namespace is unknown: only the policies selecting every namespace
+// apply then.
+func (p KubesecPolicy) matches(ns labels.Labels) bool {
+	if ns == nil {
+		return p.selector.Empty()
+	}
+	return p.selector.Matches(ns)
+}
+
+// Cluster is the outcome of the cluster policies applying to a namespace.
+type Cluster struct {
+	// Policies are the names of the policies applying.
+	Policies      []string
+	MinScore      *int
+	FailureMode   string
+	ExcludedRules []string
+}
+
+// Resolve returns the outcome of the policies applying to a namespace of the
+// labels, nil when none applies. When several policies apply the strictest
+// prevails: the highest minimum score, closed over open, and only the rules
+// every one of them excludes.
+func Resolve(policies []KubesecPolicy, ns labels.Labels) *Cluster {
+	var c *Cluster
+	var excluded map[string]bool
+	for _, p := range policies {
+		if !p.matches(ns) {
+			continue
+		}
+
+		rules := map[string]bool{}
+		for _, id := range p.Spec.ExcludedRules {
+			if c == nil || excluded[id] {
+				rules[id] = true
+			}
+		}
+		excluded = rules
+
+		if c == nil {
+			c = &Cluster{}
+		}
+		c.Policies = append(c.Policies, p.Name)
+		if s := p.Spec.MinScore; s != nil && (c.MinScore == nil || *s > *c.MinScore) {
+			score := *s
+			c.MinScore = &score
+		}
+		if p.Spec.FailureMode == "closed" || c.FailureMode == "" {
+			c.FailureMode = p.Spec.FailureMode
+		}
+	}
+	if c == nil {
+		return nil
+	}
+
+	for id := range excluded {
+		c.ExcludedRules = append(c.ExcludedRules, id)
+	}
+	sort.Strings(c.ExcludedRules)
+	return c
+}
+
+// KubesecPolicies lists the cluster policies through a client, typically the
+// manager cache so the policies are watched rather than fetched.
+type KubesecPolicies struct {
+	Reader client.Reader
+}
+
+// List returns the valid policies, sorted by name. The error names the
+// invalid ones, which are left out.
+func (k KubesecPolicies) List(ctx context.Context) ([]KubesecPolicy, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(KubesecPolicyKind.GroupVersion().WithKind(KubesecPolicyKind.Kind + "List"))
+	if err := k.Reader.List(ctx, list); err != nil {
+		return nil, fmt.Errorf("could not list kubesec policies: %w", err)
+	}
+
+	var policies []KubesecPolicy
+	var errs []string
+	for _, item := range list.Items {
+		var spec KubesecPolicySpec
+		if raw, ok := item.Object["spec"].(map[string]interface{}); ok {
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &spec); err != nil {
+				errs = append(errs, fmt.Sprintf("kubesec policy %q: %v", item.GetName(), err))
+				continue
+			}
+		}
+		p, err := NewKubesecPolicy(item.GetName(), spec)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		policies = append(policies, p)
+	}
+	sort.Slice(policies, func(i, j int) bool { return policies[i].Name < policies[j].Name })
+
+	if len(errs) > 0 {
+		return policies, fmt.Errorf("ignoring invalid kubesec policies: %s", strings.Join(errs, "; "))
+	}
+	return policies, nil
+}
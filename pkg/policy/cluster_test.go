@@ -0,0 +1,151 @@
+package policy
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func mustPolicy(t *testing.T, name string, spec KubesecPolicySpec) KubesecPolicy {
+	t.Helper()
+	p, err := NewKubesecPolicy(name, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// TestNewKubesecPolicy - tests the validation of the policy specs
+func TestNewKubesecPolicy(t *testing.T) {
+	tests := []struct {
+		name string
+		spec KubesecPolicySpec
+		err  string
+	}{
+		{name: "empty", spec: KubesecPolicySpec{}},
+		{name: "valid", spec: KubesecPolicySpec{MinScore: intPtr(3), FailureMode: "closed", ExcludedRules: []string{"RunAsNonRoot"}}},
+		{name: "invalid failure mode", spec: KubesecPolicySpec{FailureMode: "ajar"}, err: `invalid failure mode "ajar"`},
+		{name: "invalid rule", spec: KubesecPolicySpec{ExcludedRules: []string{"run as root"}}, err: `invalid excluded rule "run as root"`},
+		{
+			name: "invalid selector",
+			spec: KubesecPolicySpec{NamespaceSelector: &metav1.LabelSelector{
+				MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "env", Operator: "Near"}},
+			}},
+			err: "invalid namespace selector",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKubesecPolicy("test", tt.spec)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("NewKubesecPolicy - want error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// TestResolve - tests the strictest of the policies selecting a namespace prevails
+func TestResolve(t *testing.T) {
+	prod := &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}
+	policies := []KubesecPolicy{
+		mustPolicy(t, "all", KubesecPolicySpec{MinScore: intPtr(1), ExcludedRules: []string{"RunAsNonRoot", "ServiceAccountName"}}),
+		mustPolicy(t, "prod", KubesecPolicySpec{MinScore: intPtr(5), FailureMode: "closed", ExcludedRules: []string{"ServiceAccountName"}, NamespaceSelector: prod}),
+		mustPolicy(t, "prod-open", KubesecPolicySpec{MinScore: intPtr(3), FailureMode: "open", NamespaceSelector: prod}),
+	}
+
+	tests := []struct {
+		name     string
+		policies []KubesecPolicy
+		ns       labels.Labels
+		want     *Cluster
+	}{
+		{name: "no policy", ns: labels.Set{}, want: nil},
+		{
+			name:     "unselected namespace",
+			policies: policies[1:2],
+			ns:       labels.Set{"env": "dev"},
+			want:     nil,
+		},
+		{
+			name:     "every namespace",
+			policies: policies,
+			ns:       labels.Set{"env": "dev"},
+			want:     &Cluster{Policies: []string{"all"}, MinScore: intPtr(1), ExcludedRules: []string{"RunAsNonRoot", "ServiceAccountName"}},
+		},
+		{
+			name:     "strictest",
+			policies: policies[:2],
+			ns:       labels.Set{"env": "prod"},
+			want:     &Cluster{Policies: []string{"all", "prod"}, MinScore: intPtr(5), FailureMode: "closed", ExcludedRules: []string{"ServiceAccountName"}},
+		},
+		{
+			name:     "closed over open",
+			policies: policies[1:],
+			ns:       labels.Set{"env": "prod"},
+			want:     &Cluster{Policies: []string{"prod", "prod-open"}, MinScore: intPtr(5), FailureMode: "closed"},
+		},
+		{
+			name:     "unknown namespace",
+			policies: policies,
+			want:     &Cluster{Policies: []string{"all"}, MinScore: intPtr(1), ExcludedRules: []string{"RunAsNonRoot", "ServiceAccountName"}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := Resolve(tt.policies, tt.ns)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Resolve - want=%+v, got=%+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestKubesecPolicies_List - tests the policies are read from the cluster and validated
+func TestKubesecPolicies_List(t *testing.T) {
+	newPolicy := func(name string, spec map[string]interface{}) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{"spec": spec}}
+		u.SetGroupVersionKind(KubesecPolicyKind)
+		u.SetName(name)
+		return u
+	}
+
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(KubesecPolicyKind, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(KubesecPolicyKind.GroupVersion().WithKind("KubesecPolicyList"), &unstructured.UnstructuredList{})
+	reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newPolicy("prod", map[string]interface{}{"minScore": int64(5), "failureMode": "closed"}),
+		newPolicy("dev", map[string]interface{}{"minScore": int64(-10)}),
+		newPolicy("broken", map[string]interface{}{"failureMode": "ajar"}),
+	).Build()
+
+	policies, err := KubesecPolicies{Reader: reader}.List(context.Background())
+	if err == nil || !strings.Contains(err.Error(), `"broken"`) {
+		t.Fatalf("List - want an error naming the broken policy, got %v", err)
+	}
+
+	var names []string
+	for _, p := range policies {
+		names = append(names, p.Name)
+	}
+	if want := []string{"dev", "prod"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("List - want policies %v, got %v", want, names)
+	}
+	if s := policies[1].Spec; s.MinScore == nil || *s.MinScore != 5 || s.FailureMode != "closed" {
+		t.Fatalf("List - unexpected prod policy spec %+v", s)
+	}
+}
@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// Keys of the policy ConfigMap.
+const (
+	// ConfigMapPolicyKey holds a KubesecPolicySpec, applied as a cluster
+	// policy.
+	ConfigMapPolicyKey = "policy.yaml"
+	// ConfigMapImagesKey holds the image rules, in the format of LoadImages.
+	ConfigMapImagesKey = "images.yaml"
+)
+
+// ConfigMapWatcher applies the policy settings of a ConfigMap, read
+// periodically, so they can be changed without restarting the webhook. A
+// missing ConfigMap, or key, leaves the flags in effect. The cluster policy
+// is served by List, the image rules replace those of Images. It must be
+// started to refresh.
+type ConfigMapWatcher struct {
+	reader   client.Reader
+	key      types.NamespacedName
+	interval time.Duration
+	images   *Images
+	logger   log.Logger
+
+	mu       sync.RWMutex
+	policies []KubesecPolicy
+	version  string
+}
+
+// NewConfigMapWatcher returns a watcher reading the ConfigMap every interval
+// and replacing the rules of images.
+func NewConfigMapWatcher(reader client.Reader, namespace, name string, interval time.Duration, images *Images, logger log.Logger) (*ConfigMapWatcher, error) {
+	if namespace == "" || name == "" {
+		return nil, fmt.Errorf("policy configmap needs a namespace and a name")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("policy configmap refresh interval must be positive")
+	}
+	return &ConfigMapWatcher{
+		reader:   reader,
+		key:      types.NamespacedName{Namespace: namespace, Name: name},
+		interval: interval,
+		images:   images,
+		logger:   logger,
+		version:  "-",
+	}, nil
+}
+
+// List returns the cluster policy of the ConfigMap, if any.
+func (w *ConfigMapWatcher) List(ctx context.Context) ([]KubesecPolicy, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.policies, nil
+}
+
+// Refresh reads the ConfigMap and applies it when it changed. Invalid
+// settings are not applied, the current ones are kept.
+func (w *ConfigMapWatcher) Refresh(ctx context.Context) error {
+	cm := &corev1.ConfigMap{}
+	err := w.reader.Get(ctx, w.key, cm)
+	switch {
+	case apierrors.IsNotFound(err):
+		cm = &corev1.ConfigMap{}
+	case err != nil:
+		return fmt.Errorf("could not read policy configmap %s: %w", w.key, err)
+	}
+	if cm.ResourceVersion == w.version {
+		return nil
+	}
+
+	var policies []KubesecPolicy
+	if raw, ok := cm.Data[ConfigMapPolicyKey]; ok {
+		var spec KubesecPolicySpec
+		if err := yaml.UnmarshalStrict([]byte(raw), &spec); err != nil {
+			return fmt.Errorf("policy configmap %s: invalid %s: %w", w.key, ConfigMapPolicyKey, err)
+		}
+		p, err := NewKubesecPolicy("configmap/"+w.key.String(), spec)
+		if err != nil {
+			return fmt.Errorf("policy configmap %s: %w", w.key, err)
+		}
+		policies = []KubesecPolicy{p}
+	}
+
+	images := &Images{}
+	if raw, ok := cm.Data[ConfigMapImagesKey]; ok {
+		if images, err = ParseImages([]byte(raw)); err != nil {
+			return fmt.Errorf("policy configmap %s: %s: %w", w.key, ConfigMapImagesKey, err)
+		}
+	}
+
+	w.mu.Lock()
+	w.policies = policies
+	w.version = cm.ResourceVersion
+	w.mu.Unlock()
+	if w.images != nil {
+		w.images.Replace(images)
+	}
+
+	if cm.ResourceVersion == "" {
+		w.logger.Infof("policy configmap %s not found, applying the flags", w.key)
+	} else {
+		w.logger.Infof("applied policy configmap %s, resource version %s", w.key, cm.ResourceVersion)
+	}
+	return nil
+}
+
+// Start refreshes the ConfigMap every interval until the context is done.
+func (w *ConfigMapWatcher) Start(ctx context.Context) error {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := w.Refresh(ctx); err != nil {
+				w.logger.Errorf("could not refresh policy configmap, keeping the current policy: %v", err)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager every replica refreshes its policy.
+func (w *ConfigMapWatcher) NeedLeaderElection() bool {
+	return false
+}
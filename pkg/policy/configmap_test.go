@@ -0,0 +1,81 @@
+package policy
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// TestConfigMapWatcher_Refresh - tests the policy settings are applied as the ConfigMap changes
+func TestConfigMapWatcher_Refresh(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().Build()
+	images := &Images{}
+	w, err := NewConfigMapWatcher(c, "kubesec", "policy", time.Minute, images, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A missing ConfigMap keeps the flags.
+	if err := w.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := w.List(ctx); len(p) != 0 {
+		t.Fatalf("List - want no policy, got %v", p)
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "kubesec", Name: "policy"},
+		Data: map[string]string{
+			ConfigMapPolicyKey: "minScore: 5\nfailureMode: closed\n",
+			ConfigMapImagesKey: "images:\n- pattern: docker.io/*\n  minScore: 8\n",
+		},
+	}
+	if err := c.Create(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := w.List(ctx)
+	if len(p) != 1 || *p[0].Spec.MinScore != 5 || p[0].Spec.FailureMode != "closed" || p[0].Name != "configmap/kubesec/policy" {
+		t.Fatalf("List - want the policy of the configmap, got %+v", p)
+	}
+	if got := images.Requirement([]string{"docker.io/nginx"}, 0); got.MinScore != 8 {
+		t.Fatalf("Requirement - want the image rules of the configmap, got %+v", got)
+	}
+
+	// Invalid settings are not applied.
+	cm.Data[ConfigMapPolicyKey] = "minScore: 5\nfailureMode: ajar\n"
+	if err := c.Update(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Refresh(ctx); err == nil || !strings.Contains(err.Error(), `invalid failure mode "ajar"`) {
+		t.Fatalf("Refresh - want an invalid failure mode error, got %v", err)
+	}
+	if got, _ := w.List(ctx); !reflect.DeepEqual(got, p) {
+		t.Fatalf("List - want the previous policy kept, got %+v", got)
+	}
+
+	// Removing the keys restores the flags.
+	cm.Data = nil
+	if err := c.Update(ctx, cm); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := w.List(ctx); len(p) != 0 {
+		t.Fatalf("List - want no policy, got %v", p)
+	}
+	if got := images.Requirement([]string{"docker.io/nginx"}, 0); got.MinScore != 0 {
+		t.Fatalf("Requirement - want no image rule, got %+v", got)
+	}
+}
@@ -0,0 +1,52 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Results of the verdicts of the decision policies.
+const (
+	// VerdictAllow allows the object, whatever its scan.
+	VerdictAllow = "allow"
+	// VerdictDeny denies the object, whatever its scan.
+	VerdictDeny = "deny"
+	// VerdictWarn allows the object with an admission warning.
+	VerdictWarn = "warn"
+)
+
+// DecisionInput is what a decision policy decides on: a scanned object, its
+// namespace and the verdict of its scan.
+type DecisionInput struct {
+	// Object is the reviewed object and Namespace its namespace, nil when it
+	// could not be read.
+	Object    map[string]interface{} `json:"object"`
+	Namespace map[string]interface{} `json:"namespace"`
+	Operation string                 `json:"operation"`
+	// Kind is the lower case kind of the object, e.g. deployment.
+	Kind     string         `json:"kind"`
+	Score    int            `json:"score"`
+	MinScore int            `json:"minScore"`
+	Scan     scanner.Result `json:"scan"`
+	// Allowed is the verdict of the scan, and Reasons why it denies the
+	// object.
+	Allowed bool     `json:"allowed"`
+	Reasons []string `json:"reasons"`
+}
+
+// Verdict is the decision of a policy on an object.
+type Verdict struct {
+	// Result is VerdictAllow, VerdictDeny or VerdictWarn.
+	Result string `json:"result"`
+	// Message is returned to the user, with the denial or as the warning.
+	Message string `json:"message,omitempty"`
+}
+
+func (v Verdict) validate() error {
+	switch v.Result {
+	case VerdictAllow, VerdictDeny, VerdictWarn:
+		return nil
+	}
+	return fmt.Errorf("invalid verdict result %q, want %s, %s or %s", v.Result, VerdictAllow, VerdictDeny, VerdictWarn)
+}
@@ -0,0 +1,256 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// KubesecExemptionKind is the kind of the exemptions, a namespaced custom
+// resource defined by deploy/kubesec-exemption-crd.yaml.
+var KubesecExemptionKind = schema.GroupVersionKind{Group: "kubesec.io", Version: "v1alpha1", Kind: "KubesecExemption"}
+
+// ExemptionWorkload names an exempted workload.
+type ExemptionWorkload struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// KubesecExemptionSpec names the workloads of its namespace exempted from
+// enforcement until it expires.
+type KubesecExemptionSpec struct {
+	// Workloads are the exempted workloads.
+	Workloads []ExemptionWorkload `json:"workloads,omitempty"`
+	// Selector exempts the workloads and Pods of matching labels, those of
+	// the object or of its pod template.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// ExpiresAt is the end of the exemption, enforcement resumes after it.
+	ExpiresAt metav1.Time `json:"expiresAt"`
+	// Reason documents why the workloads are exempted.
+	Reason string `json:"reason,omitempty"`
+}
+
+// KubesecExemption is an exemption.
+type KubesecExemption struct {
+	Namespace string
+	Name      string
+	Spec      KubesecExemptionSpec
+
+	selector labels.Selector
+}
+
+// NewKubesecExemption validates the spec and returns the exemption.
+func NewKubesecExemption(namespace, name string, spec KubesecExemptionSpec) (KubesecExemption, error) {
+	if len(spec.Workloads) == 0 && spec.Selector == nil {
+		return KubesecExemption{}, fmt.Errorf("kubesec exemption %s/%s: no workloads nor selector", namespace, name)
+	}
+	if spec.ExpiresAt.IsZero() {
+		return KubesecExemption{}, fmt.Errorf("kubesec exemption %s/%s: no expiry date", namespace, name)
+	}
+	for _, w := range spec.Workloads {
+		if w.Kind == "" || w.Name == "" {
+			return KubesecExemption{}, fmt.Errorf("kubesec exemption %s/%s: workloads need a kind and a name", namespace, name)
+		}
+	}
+
+	e := KubesecExemption{Namespace: namespace, Name: name, Spec: spec}
+	if spec.Selector != nil {
+		s, err := metav1.LabelSelectorAsSelector(spec.Selector)
+		if err != nil {
+			return KubesecExemption{}, fmt.Errorf("kubesec exemption %s/%s: invalid selector: %w", namespace, name, err)
+		}
+		e.selector = s
+	}
+	return e, nil
+}
+
+// Expired tells whether the exemption expired at now.
+func (e KubesecExemption) Expired(now time.Time) bool {
+	return !now.Before(e.Spec.ExpiresAt.Time)
+}
+
+// Matches tells whether the exemption names the workload of the kind, or
+// selects one of the label sets. Expiry is not checked.
+func (e KubesecExemption) Matches(kind, name string, objLabels ...labels.Set) bool {
+	for _, w := range e.Spec.Workloads {
+		if strings.EqualFold(w.Kind, kind) && w.Name == name {
+			return true
+		}
+	}
+	if e.selector == nil {
+		return false
+	}
+	for _, l := range objLabels {
+		if e.selector.Matches(l) {
+			return true
+		}
+	}
+	return false
+}
+
+// KubesecExemptions lists the exemptions through a client, typically the
+// manager cache so the exemptions are watched rather than fetched.
+type KubesecExemptions struct {
+	Reader client.Reader
+}
+
+// List returns the valid exemptions of the namespace, of every namespace when
+// empty, sorted by namespace and name. The error names the invalid ones,
+// which are left out.
+func (k KubesecExemptions) List(ctx context.Context, namespace string) ([]KubesecExemption, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(KubesecExemptionKind.GroupVersion().WithKind(KubesecExemptionKind.Kind + "List"))
+	if err := k.Reader.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("could not list kubesec exemptions: %w", err)
+	}
+
+	var exemptions []KubesecExemption
+	var errs []string
+	for _, item := range list.Items {
+		var spec KubesecExemptionSpec
+		if raw, ok := item.Object["spec"].(map[string]interface{}); ok {
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(raw, &spec); err != nil {
+				errs = append(errs, fmt.Sprintf("kubesec exemption %s/%s: %v", item.GetNamespace(), item.GetName(), err))
+				continue
+			}
+		}
+		e, err := NewKubesecExemption(item.GetNamespace(), item.GetName(), spec)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		exemptions = append(exemptions, e)
+	}
+	sort.Slice(exemptions, func(i, j int) bool {
+		if exemptions[i].Namespace != exemptions[j].Namespace {
+			return exemptions[i].Namespace < exemptions[j].Namespace
+		}
+		return exemptions[i].Name < exemptions[j].Name
+	})
+
+	if len(errs) > 0 {
+		return exemptions, fmt.Errorf("ignoring invalid kubesec exemptions: %s", strings.Join(errs, "; "))
+	}
+	return exemptions, nil
+}
+
+// ExemptionRecorder records the exemptions by state.
+type ExemptionRecorder interface {
+	// SetExemptions sets the gauges of the active exemptions, of those
+	// expiring soon and of the expired ones.
+	SetExemptions(active, expiring, expired int)
+}
+
+// ExemptionMonitor checks the exemptions periodically, records them by state
+// and warns once as each of them approaches its expiry, and once it expired.
+// It must be started to check.
+type ExemptionMonitor struct {
+	exemptions KubesecExemptions
+	interval   time.Duration
+	warning    time.Duration
+	recorder   ExemptionRecorder
+	logger     log.Logger
+	now        func() time.Time
+
+	// warned holds the state last logged of every exemption.
+	warned map[string]string
+}
+
+// NewExemptionMonitor returns a monitor checking the exemptions every
+// interval, warning about those expiring within warning.
+func NewExemptionMonitor(exemptions KubesecExemptions, interval, warning time.Duration, recorder ExemptionRecorder, logger log.Logger) (*ExemptionMonitor, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("exemption check interval must be positive")
+	}
+	if warning < 0 {
+		return nil, fmt.Errorf("exemption expiry warning can't be negative")
+	}
+	return &ExemptionMonitor{
+		exemptions: exemptions,
+		interval:   interval,
+		warning:    warning,
+		recorder:   recorder,
+		logger:     logger,
+		now:        time.Now,
+		warned:     map[string]string{},
+	}, nil
+}
+
+// Check records the exemptions and warns about those expiring.
+func (m *ExemptionMonitor) Check(ctx context.Context) error {
+	exemptions, err := m.exemptions.List(ctx, "")
+	if err != nil && exemptions == nil {
+		return err
+	}
+	if err != nil {
+		m.logger.Warningf("%v", err)
+	}
+
+	now := m.now()
+	var active, expiring, expired int
+	warned := map[string]string{}
+	for _, e := range exemptions {
+		key := e.Namespace + "/" + e.Name
+		left := e.Spec.ExpiresAt.Sub(now)
+
+		state := "active"
+		switch {
+		case left <= 0:
+			state = "expired"
+			expired++
+		case left <= m.warning:
+			state = "expiring"
+			expiring++
+		default:
+			active++
+		}
+		warned[key] = state
+
+		if m.warned[key] == state || state == "active" {
+			continue
+		}
+		if state == "expired" {
+			m.logger.Warningf("kubesec exemption %s expired at %s, its workloads are enforced again", key, e.Spec.ExpiresAt.UTC().Format(time.RFC3339))
+		} else {
+			m.logger.Warningf("kubesec exemption %s expires in %s, at %s", key, left.Round(time.Minute), e.Spec.ExpiresAt.UTC().Format(time.RFC3339))
+		}
+	}
+	m.warned = warned
+
+	m.recorder.SetExemptions(active, expiring, expired)
+	return nil
+}
+
+// Start checks the exemptions every interval until the context is done.
+func (m *ExemptionMonitor) Start(ctx context.Context) error {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Check(ctx); err != nil {
+			m.logger.Errorf("could not check kubesec exemptions: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager only the leader warns about the
+// exemptions expiring.
+func (m *ExemptionMonitor) NeedLeaderElection() bool {
+	return true
+}
@@ -0,0 +1,181 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+type warningLogger struct {
+	log.Logger
+	warnings []string
+}
+
+func (l *warningLogger) Warningf(format string, args ...interface{}) {
+	l.warnings = append(l.warnings, fmt.Sprintf(format, args...))
+}
+
+type exemptionRecorder struct {
+	active, expiring, expired int
+}
+
+func (r *exemptionRecorder) SetExemptions(active, expiring, expired int) {
+	r.active, r.expiring, r.expired = active, expiring, expired
+}
+
+func newExemption(namespace, name string, expiresAt time.Time) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]interface{}{"spec": map[string]interface{}{
+		"expiresAt": expiresAt.UTC().Format(time.RFC3339),
+		"workloads": []interface{}{map[string]interface{}{"kind": "Deployment", "name": name}},
+	}}}
+	u.SetGroupVersionKind(KubesecExemptionKind)
+	u.SetNamespace(namespace)
+	u.SetName(name)
+	return u
+}
+
+func exemptionReader(objs ...*unstructured.Unstructured) KubesecExemptions {
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(KubesecExemptionKind, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(KubesecExemptionKind.GroupVersion().WithKind("KubesecExemptionList"), &unstructured.UnstructuredList{})
+	b := fake.NewClientBuilder().WithScheme(scheme)
+	for _, o := range objs {
+		b = b.WithObjects(o)
+	}
+	return KubesecExemptions{Reader: b.Build()}
+}
+
+// TestKubesecExemption_Matches - tests the exemptions match the named and selected workloads
+func TestKubesecExemption_Matches(t *testing.T) {
+	e, err := NewKubesecExemption("foo", "test", KubesecExemptionSpec{
+		Workloads: []ExemptionWorkload{{Kind: "Deployment", Name: "billing"}},
+		Selector:  &metav1.LabelSelector{MatchLabels: map[string]string{"app": "billing"}},
+		ExpiresAt: metav1.NewTime(time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		kind   string
+		obj    string
+		labels []labels.Set
+		want   bool
+	}{
+		{name: "named", kind: "deployment", obj: "billing", want: true},
+		{name: "other kind", kind: "StatefulSet", obj: "billing", want: false},
+		{name: "selected", kind: "Pod", obj: "billing-1234", labels: []labels.Set{{"app": "billing"}}, want: true},
+		{name: "template selected", kind: "Deployment", obj: "api", labels: []labels.Set{{}, {"app": "billing"}}, want: true},
+		{name: "unselected", kind: "Pod", obj: "api-1234", labels: []labels.Set{{"app": "api"}}, want: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := e.Matches(tt.kind, tt.obj, tt.labels...); got != tt.want {
+				t.Fatalf("Matches - want %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	if e.Expired(time.Date(2026, 12, 30, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal("Expired - want active before the expiry date")
+	}
+	if !e.Expired(time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Fatal("Expired - want expired at the expiry date")
+	}
+}
+
+// TestNewKubesecExemption - tests the validation of the exemption specs
+func TestNewKubesecExemption(t *testing.T) {
+	expiresAt := metav1.NewTime(time.Now())
+	tests := []struct {
+		name string
+		spec KubesecExemptionSpec
+		err  string
+	}{
+		{name: "no target", spec: KubesecExemptionSpec{ExpiresAt: expiresAt}, err: "no workloads nor selector"},
+		{name: "no expiry", spec: KubesecExemptionSpec{Selector: &metav1.LabelSelector{}}, err: "no expiry date"},
+		{name: "unnamed workload", spec: KubesecExemptionSpec{ExpiresAt: expiresAt, Workloads: []ExemptionWorkload{{Kind: "Pod"}}}, err: "need a kind and a name"},
+		{name: "valid", spec: KubesecExemptionSpec{ExpiresAt: expiresAt, Workloads: []ExemptionWorkload{{Kind: "Pod", Name: "test"}}}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewKubesecExemption("foo", "test", tt.spec)
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("NewKubesecExemption - want error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+// TestKubesecExemptions_List - tests the exemptions are listed by namespace
+func TestKubesecExemptions_List(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	reader := exemptionReader(newExemption("foo", "b", expiresAt), newExemption("foo", "a", expiresAt), newExemption("bar", "c", expiresAt))
+
+	exemptions, err := reader.List(context.Background(), "foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exemptions) != 2 || exemptions[0].Name != "a" || exemptions[1].Name != "b" {
+		t.Fatalf("List - want exemptions a and b, got %+v", exemptions)
+	}
+
+	all, err := reader.List(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].Namespace != "bar" {
+		t.Fatalf("List - want the 3 exemptions sorted by namespace, got %+v", all)
+	}
+}
+
+// TestExemptionMonitor_Check - tests the exemptions are counted by state and warned about once
+func TestExemptionMonitor_Check(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	reader := exemptionReader(
+		newExemption("foo", "active", now.Add(30*24*time.Hour)),
+		newExemption("foo", "expiring", now.Add(2*24*time.Hour)),
+		newExemption("foo", "expired", now.Add(-time.Hour)),
+	)
+	logger := &warningLogger{Logger: log.Dummy}
+	rec := &exemptionRecorder{}
+	m, err := NewExemptionMonitor(reader, time.Minute, 7*24*time.Hour, rec, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if err := m.Check(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if rec.active != 1 || rec.expiring != 1 || rec.expired != 1 {
+		t.Fatalf("Check - want 1 exemption of every state, got %+v", rec)
+	}
+	if len(logger.warnings) != 2 {
+		t.Fatalf("Check - want 2 warnings, got %q", logger.warnings)
+	}
+	if !strings.Contains(logger.warnings[0], "foo/expired expired") || !strings.Contains(logger.warnings[1], "foo/expiring expires in 48h0m0s") {
+		t.Fatalf("Check - unexpected warnings %q", logger.warnings)
+	}
+}
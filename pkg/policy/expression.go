@@ -0,0 +1,143 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/google/cel-go/cel"
+	"sigs.k8s.io/yaml"
+)
+
+// Expression gives its verdict on the objects it matches. Expression is a
+// CEL expression over the fields of the DecisionInput, e.g.
+// object.metadata.labels['team'] == 'platform' && score >= 3, where the
+// namespace, a reserved word of CEL, is namespaceObject as in the
+// ValidatingAdmissionPolicies.
+type Expression struct {
+	// Name identifies the expression in the errors, its index when empty.
+	Name       string `json:"name,omitempty"`
+	Expression string `json:"expression"`
+	// Result and Message are the verdict on the matched objects.
+	Result  string `json:"result"`
+	Message string `json:"message,omitempty"`
+
+	program cel.Program
+}
+
+// Expressions are decision expressions evaluated in order, the first one
+// matching an object decides, the scan decides when none does.
+type Expressions struct {
+	Expressions []Expression `json:"expressions"`
+}
+
+// LoadExpressions reads YAML decision expressions from path.
+func LoadExpressions(path string) (*Expressions, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	exprs, err := ParseExpressions(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return exprs, nil
+}
+
+// ParseExpressions parses and compiles YAML decision expressions.
+func ParseExpressions(raw []byte) (*Expressions, error) {
+	exprs := &Expressions{}
+	if err := yaml.UnmarshalStrict(raw, exprs); err != nil {
+		return nil, fmt.Errorf("invalid decision expressions: %w", err)
+	}
+	if err := exprs.compile(); err != nil {
+		return nil, fmt.Errorf("invalid decision expressions: %w", err)
+	}
+	return exprs, nil
+}
+
+func (e *Expressions) compile() error {
+	env, err := cel.NewEnv(
+		cel.Variable("object", cel.DynType),
+		cel.Variable("namespaceObject", cel.DynType),
+		cel.Variable("operation", cel.StringType),
+		cel.Variable("kind", cel.StringType),
+		cel.Variable("score", cel.IntType),
+		cel.Variable("minScore", cel.IntType),
+		cel.Variable("scan", cel.DynType),
+		cel.Variable("allowed", cel.BoolType),
+		cel.Variable("reasons", cel.ListType(cel.StringType)),
+	)
+	if err != nil {
+		return err
+	}
+
+	for n := range e.Expressions {
+		x := &e.Expressions[n]
+		if x.Name == "" {
+			x.Name = fmt.Sprintf("expression %d", n)
+		}
+		if err := (Verdict{Result: x.Result}).validate(); err != nil {
+			return fmt.Errorf("%s: %w", x.Name, err)
+		}
+		ast, iss := env.Compile(x.Expression)
+		if iss.Err() != nil {
+			return fmt.Errorf("%s: %w", x.Name, iss.Err())
+		}
+		if ast.OutputType() != cel.BoolType {
+			return fmt.Errorf("%s: want a bool expression, got %s", x.Name, ast.OutputType())
+		}
+		if x.program, err = env.Program(ast); err != nil {
+			return fmt.Errorf("%s: %w", x.Name, err)
+		}
+	}
+	return nil
+}
+
+// Decide returns the verdict of the first expression matching the input, nil
+// when none does. An expression failing to evaluate, e.g. on a missing label,
+// stops the evaluation, has() guards against it.
+func (e *Expressions) Decide(_ context.Context, input DecisionInput) (*Verdict, error) {
+	if len(e.Expressions) == 0 {
+		return nil, nil
+	}
+	// The variables are the JSON fields of the input.
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	vars := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &vars); err != nil {
+		return nil, err
+	}
+	vars["score"], vars["minScore"] = int64(input.Score), int64(input.MinScore)
+	vars["namespaceObject"] = vars["namespace"]
+	delete(vars, "namespace")
+	if input.Reasons == nil {
+		vars["reasons"] = []string{}
+	}
+	// The checks of the scan are omitted when empty, they are always set so
+	// the expressions need no has() guard on them.
+	if scan, ok := vars["scan"].(map[string]interface{}); ok {
+		if scoring, ok := scan["scoring"].(map[string]interface{}); ok {
+			for _, k := range []string{"critical", "passed", "advise"} {
+				if _, ok := scoring[k]; !ok {
+					scoring[k] = []interface{}{}
+				}
+			}
+		}
+	}
+
+	for _, x := range e.Expressions {
+		out, _, err := x.program.Eval(vars)
+		if err != nil {
+			return nil, fmt.Errorf("decision %s failed: %w", x.Name, err)
+		}
+		if matched, ok := out.Value().(bool); ok && matched {
+			return &Verdict{Result: x.Result, Message: x.Message}, nil
+		}
+	}
+	return nil, nil
+}
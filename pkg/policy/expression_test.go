@@ -0,0 +1,88 @@
+package policy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// TestExpressions_Decide - tests the first matching expression decides
+func TestExpressions_Decide(t *testing.T) {
+	exprs, err := ParseExpressions([]byte(`
+expressions:
+- name: platform
+  expression: has(object.metadata.labels) && object.metadata.labels['team'] == 'platform' && score >= 3
+  result: allow
+- expression: namespaceObject != null && namespaceObject.metadata.labels['tier'] == 'prod' && score < 5
+  result: deny
+  message: prod workloads must score 5
+- expression: scan.scoring.critical.exists(c, c.id == 'Privileged')
+  result: warn
+  message: privileged
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prod := map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]interface{}{"tier": "prod"}}}
+	platform := map[string]interface{}{"metadata": map[string]interface{}{"labels": map[string]interface{}{"team": "platform"}}}
+	tests := []struct {
+		name    string
+		input   DecisionInput
+		want    *Verdict
+		wantErr bool
+	}{
+		{
+			name:  "platform exemption",
+			input: DecisionInput{Object: platform, Namespace: prod, Score: 3},
+			want:  &Verdict{Result: VerdictAllow},
+		},
+		{
+			name:  "low score in prod",
+			input: DecisionInput{Object: map[string]interface{}{"metadata": map[string]interface{}{}}, Namespace: prod, Score: 3},
+			want:  &Verdict{Result: VerdictDeny, Message: "prod workloads must score 5"},
+		},
+		{
+			name:  "no match",
+			input: DecisionInput{Object: map[string]interface{}{"metadata": map[string]interface{}{}}, Score: 3},
+		},
+		{
+			name:    "evaluation error",
+			input:   DecisionInput{Object: map[string]interface{}{"metadata": map[string]interface{}{}}, Namespace: map[string]interface{}{}, Score: 3},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := exprs.Decide(context.Background(), tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decide - want error=%v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Decide - want %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestParseExpressions - tests the invalid expressions are rejected
+func TestParseExpressions(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{name: "syntax", raw: "expressions:\n- expression: score >=\n  result: deny\n"},
+		{name: "not a bool", raw: "expressions:\n- expression: score + 1\n  result: deny\n"},
+		{name: "unknown variable", raw: "expressions:\n- expression: points > 1\n  result: deny\n"},
+		{name: "invalid result", raw: "expressions:\n- expression: score > 1\n  result: maybe\n"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseExpressions([]byte(tt.raw)); err == nil {
+				t.Fatal("ParseExpressions - want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+// Package policy describes what a workload must achieve to be admitted.
+package policy
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/yaml"
+)
+
+// ImageRule overrides the admission bar of the workloads running images
+// matching Pattern. In Pattern, * matches any sequence of characters, / included.
+type ImageRule struct {
+	Pattern string `json:"pattern"`
+	// MinScore replaces the minimum score when set.
+	MinScore *int `json:"minScore,omitempty"`
+	// RequiredChecks are the IDs of checks the workload must pass, on top of
+	// reaching the minimum score.
+	RequiredChecks []string `json:"requiredChecks,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Images are image rules evaluated in order, the first rule matching an image
+// applies to it. The rules can be replaced while in use, see Replace.
+type Images struct {
+	Rules []ImageRule `json:"images"`
+
+	mu sync.RWMutex
+}
+
+// Requirement is the admission bar a workload is held to.
+type Requirement struct {
+	MinScore       int
+	RequiredChecks []string
+}
+
+// LoadImages reads the image rules from a YAML file.
+func LoadImages(path string) (*Images, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := ParseImages(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return images, nil
+}
+
+// ParseImages parses YAML image rules.
+func ParseImages(raw []byte) (*Images, error) {
+	images := &Images{}
+	if err := yaml.UnmarshalStrict(raw, images); err != nil {
+		return nil, fmt.Errorf("invalid image policy: %w", err)
+	}
+	if err := images.compile(); err != nil {
+		return nil, fmt.Errorf("invalid image policy: %w", err)
+	}
+
+	return images, nil
+}
+
+// Replace atomically replaces the rules by those of other.
+func (i *Images) Replace(other *Images) {
+	other.mu.RLock()
+	rules := other.Rules
+	other.mu.RUnlock()
+
+	i.mu.Lock()
+	i.Rules = rules
+	i.mu.Unlock()
+}
+
+// NewImages returns the policy made of the given rules.
+func NewImages(rules ...ImageRule) (*Images, error) {
+	images := &Images{Rules: rules}
+	if err := images.compile(); err != nil {
+		return nil, err
+	}
+	return images, nil
+}
+
+func (i *Images) compile() error {
+	for n := range i.Rules {
+		r := &i.Rules[n]
+		if r.Pattern == "" {
+			return fmt.Errorf("image rule %d has no pattern", n)
+		}
+		parts := strings.Split(r.Pattern, "*")
+		for j := range parts {
+			parts[j] = regexp.QuoteMeta(parts[j])
+		}
+		re, err := regexp.Compile("^" + strings.Join(parts, ".*") + "$")
+		if err != nil {
+			return err
+		}
+		r.re = re
+	}
+	return nil
+}
+
+// match returns the first rule matching the image, nil when none does.
+func (i *Images) match(image string) *ImageRule {
+	normalized := normalize(image)
+	for n := range i.Rules {
+		r := &i.Rules[n]
+		if r.re.MatchString(image) || r.re.MatchString(normalized) {
+			return r
+		}
+	}
+	return nil
+}
+
+// Requirement returns the strictest admission bar of the images: the highest
+// minimum score and every required check. Images without matching rule are
+// held to minScore. A nil policy returns minScore.
+func (i *Images) Requirement(images []string, minScore int) Requirement {
+	if i == nil || len(images) == 0 {
+		return Requirement{MinScore: minScore}
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	req := Requirement{}
+	first := true
+	checks := map[string]bool{}
+	for _, image := range images {
+		score := minScore
+		if r := i.match(image); r != nil {
+			if r.MinScore != nil {
+				score = *r.MinScore
+			}
+			for _, c := range r.RequiredChecks {
+				checks[c] = true
+			}
+		}
+		if first || score > req.MinScore {
+			req.MinScore = score
+			first = false
+		}
+	}
+
+	for c := range checks {
+		req.RequiredChecks = append(req.RequiredChecks, c)
+	}
+	sort.Strings(req.RequiredChecks)
+
+	return req
+}
+
+// normalize expands Docker Hub short names, nginx becomes
+// docker.io/library/nginx, so patterns can be written against the registry.
+func normalize(image string) string {
+	first, rest, found := strings.Cut(image, "/")
+	if !found {
+		return "docker.io/library/" + image
+	}
+	if strings.ContainsAny(first, ".:") || first == "localhost" {
+		return image
+	}
+	return "docker.io/" + first + "/" + rest
+}
@@ -0,0 +1,85 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func intPtr(i int) *int { return &i }
+
+// TestImages_Requirement - tests the strictest matching image rule is applied
+func TestImages_Requirement(t *testing.T) {
+	images, err := NewImages(
+		ImageRule{Pattern: "registry.internal/hardened/*", MinScore: intPtr(9)},
+		ImageRule{Pattern: "docker.io/*", MinScore: intPtr(5), RequiredChecks: []string{"RunAsNonRoot", "ReadOnlyRootFilesystem"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		images []string
+		want   Requirement
+	}{
+		{name: "no match", images: []string{"quay.io/app:1"}, want: Requirement{MinScore: 2}},
+		{name: "hardened", images: []string{"registry.internal/hardened/nginx:1.25"}, want: Requirement{MinScore: 9}},
+		{
+			name:   "docker hub short name",
+			images: []string{"nginx"},
+			want:   Requirement{MinScore: 5, RequiredChecks: []string{"ReadOnlyRootFilesystem", "RunAsNonRoot"}},
+		},
+		{
+			name:   "strictest of all containers",
+			images: []string{"registry.internal/hardened/nginx", "library/busybox:1"},
+			want:   Requirement{MinScore: 9, RequiredChecks: []string{"ReadOnlyRootFilesystem", "RunAsNonRoot"}},
+		},
+		{name: "unmatched container keeps the default bar", images: []string{"docker.io/app", "quay.io/app"}, want: Requirement{MinScore: 5, RequiredChecks: []string{"ReadOnlyRootFilesystem", "RunAsNonRoot"}}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := images.Requirement(tt.images, 2)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Requirement - want=%+v, got=%+v", tt.want, got)
+			}
+		})
+	}
+
+	var none *Images
+	if got := none.Requirement([]string{"nginx"}, 3); got.MinScore != 3 {
+		t.Fatalf("Requirement - nil policy want min score 3, got %d", got.MinScore)
+	}
+}
+
+// TestLoadImages - tests the parsing of the image policy file
+func TestLoadImages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "images.yaml")
+	content := `
+images:
+- pattern: registry.internal/hardened/*
+  minScore: 9
+- pattern: docker.io/*
+  requiredChecks: [RunAsNonRoot]
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	images, err := LoadImages(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := images.Requirement([]string{"docker.io/app"}, 1); !reflect.DeepEqual(got, Requirement{MinScore: 1, RequiredChecks: []string{"RunAsNonRoot"}}) {
+		t.Fatalf("LoadImages - unexpected requirement %+v", got)
+	}
+
+	if err := os.WriteFile(path, []byte("images:\n- minScore: 1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadImages(path); err == nil {
+		t.Fatal("LoadImages - want error for rule without pattern")
+	}
+}
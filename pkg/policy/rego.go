@@ -0,0 +1,68 @@
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// RegoQuery is the rule of the Rego decision policies, the verdict of the
+// object given as input.
+const RegoQuery = "data.kubesec.decision"
+
+// Rego is a decision policy written in Rego. Its kubesec package defines the
+// decision rule, a Verdict, for the DecisionInput given as input. The scan
+// decides when the rule is undefined.
+type Rego struct {
+	query rego.PreparedEvalQuery
+}
+
+// LoadRego reads the Rego module from path.
+func LoadRego(ctx context.Context, path string) (*Rego, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := ParseRego(ctx, path, raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// ParseRego compiles the Rego module, name identifies it in the errors.
+func ParseRego(ctx context.Context, name string, raw []byte) (*Rego, error) {
+	query, err := rego.New(rego.Query(RegoQuery), rego.Module(name, string(raw))).PrepareForEval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rego policy: %w", err)
+	}
+	return &Rego{query: query}, nil
+}
+
+// Decide returns the verdict of the policy on the input, nil when the
+// decision rule is undefined.
+func (r *Rego) Decide(ctx context.Context, input DecisionInput) (*Verdict, error) {
+	rs, err := r.query.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		return nil, fmt.Errorf("rego policy evaluation failed: %w", err)
+	}
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(rs[0].Expressions[0].Value)
+	if err != nil {
+		return nil, err
+	}
+	var v Verdict
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, fmt.Errorf("invalid rego verdict %s: %w", raw, err)
+	}
+	if err := v.validate(); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
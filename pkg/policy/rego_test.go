@@ -0,0 +1,55 @@
+package policy
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// TestRego_Decide - tests the verdicts of the Rego policies
+func TestRego_Decide(t *testing.T) {
+	tests := []struct {
+		name    string
+		module  string
+		want    *Verdict
+		wantErr bool
+	}{
+		{
+			name:   "deny",
+			module: "package kubesec\ndecision := {\"result\": \"deny\", \"message\": \"too low\"} { input.score < 5 }",
+			want:   &Verdict{Result: VerdictDeny, Message: "too low"},
+		},
+		{
+			name:   "undefined",
+			module: "package kubesec\ndecision := {\"result\": \"deny\"} { input.score > 5 }",
+		},
+		{
+			name:    "invalid result",
+			module:  "package kubesec\ndecision := {\"result\": \"maybe\"}",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := ParseRego(context.Background(), "test.rego", []byte(tt.module))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := r.Decide(context.Background(), DecisionInput{Score: 3})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decide - want error=%v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Decide - want %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestParseRego - tests the invalid modules are rejected
+func TestParseRego(t *testing.T) {
+	if _, err := ParseRego(context.Background(), "test.rego", []byte("package kubesec\ndecision := {")); err == nil {
+		t.Fatal("ParseRego - want an error for an invalid module")
+	}
+}
@@ -0,0 +1,212 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+
+	"sigs.k8s.io/yaml"
+)
+
+// vapTarget is a group of kinds sharing the path of their pod spec. CEL
+// expressions are type checked against each kind, so the kinds matched by a
+// policy must share the path.
+type vapTarget struct {
+	suffix    string
+	apiGroup  string
+	resources []string
+	spec      string
+}
+
+var vapTargets = []vapTarget{
+	{suffix: "pods", apiGroup: "", resources: []string{"pods"}, spec: "object.spec"},
+	{suffix: "workloads", apiGroup: "apps", resources: []string{"deployments", "daemonsets", "statefulsets", "replicasets"}, spec: "object.spec.template.spec"},
+	{suffix: "jobs", apiGroup: "batch", resources: []string{"jobs"}, spec: "object.spec.template.spec"},
+	{suffix: "cronjobs", apiGroup: "batch", resources: []string{"cronjobs"}, spec: "object.spec.jobTemplate.spec.template.spec"},
+}
+
+// celCheck is the CEL translation of a Kubesec.io check.
+type celCheck struct {
+	// critical checks lower the score when they match, the expression then
+	// forbids what they match. Other checks are required to match.
+	critical   bool
+	expression string
+}
+
+// celChecks are the Kubesec.io checks expressible in CEL, by ID.
+var celChecks = map[string]celCheck{
+	"Privileged":               {critical: true, expression: "variables.containers.all(c, !has(c.securityContext) || !has(c.securityContext.privileged) || !c.securityContext.privileged)"},
+	"AllowPrivilegeEscalation": {critical: true, expression: "variables.containers.all(c, !has(c.securityContext) || !has(c.securityContext.allowPrivilegeEscalation) || !c.securityContext.allowPrivilegeEscalation)"},
+	"HostNetwork":              {critical: true, expression: "!has(variables.spec.hostNetwork) || !variables.spec.hostNetwork"},
+	"HostPID":                  {critical: true, expression: "!has(variables.spec.hostPID) || !variables.spec.hostPID"},
+	"HostIPC":                  {critical: true, expression: "!has(variables.spec.hostIPC) || !variables.spec.hostIPC"},
+	"CapSysAdmin":              {critical: true, expression: "variables.containers.all(c, !has(c.securityContext) || !has(c.securityContext.capabilities) || !has(c.securityContext.capabilities.add) || !('SYS_ADMIN' in c.securityContext.capabilities.add))"},
+	"DockerSock":               {critical: true, expression: "!has(variables.spec.volumes) || variables.spec.volumes.all(v, !has(v.hostPath) || v.hostPath.path != '/var/run/docker.sock')"},
+
+	"RunAsNonRoot":                 {expression: "variables.containers.all(c, has(c.securityContext) && has(c.securityContext.runAsNonRoot) && c.securityContext.runAsNonRoot)"},
+	"ReadOnlyRootFilesystem":       {expression: "variables.containers.all(c, has(c.securityContext) && has(c.securityContext.readOnlyRootFilesystem) && c.securityContext.readOnlyRootFilesystem)"},
+	"RunAsUser":                    {expression: "variables.containers.all(c, has(c.securityContext) && has(c.securityContext.runAsUser) && c.securityContext.runAsUser > 10000)"},
+	"CapDropAll":                   {expression: "variables.containers.all(c, has(c.securityContext) && has(c.securityContext.capabilities) && has(c.securityContext.capabilities.drop) && ('ALL' in c.securityContext.capabilities.drop || 'all' in c.securityContext.capabilities.drop))"},
+	"LimitsCPU":                    {expression: "variables.containers.all(c, has(c.resources) && has(c.resources.limits) && 'cpu' in c.resources.limits)"},
+	"LimitsMemory":                 {expression: "variables.containers.all(c, has(c.resources) && has(c.resources.limits) && 'memory' in c.resources.limits)"},
+	"RequestsCPU":                  {expression: "variables.containers.all(c, has(c.resources) && has(c.resources.requests) && 'cpu' in c.resources.requests)"},
+	"RequestsMemory":               {expression: "variables.containers.all(c, has(c.resources) && has(c.resources.requests) && 'memory' in c.resources.requests)"},
+	"ServiceAccountName":           {expression: "has(variables.spec.serviceAccountName) && variables.spec.serviceAccountName != 'default'"},
+	"AutomountServiceAccountToken": {expression: "has(variables.spec.automountServiceAccountToken) && !variables.spec.automountServiceAccountToken"},
+}
+
+// CriticalCELChecks returns the IDs of the critical checks expressible in CEL.
+func CriticalCELChecks() []string {
+	var ids []string
+	for id, c := range celChecks {
+		if c.critical {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// GlobalRequiredChecks returns the required checks applying to every image,
+// those of the rules whose pattern is *. The other rules can't be exported.
+func (i *Images) GlobalRequiredChecks() []string {
+	if i == nil {
+		return nil
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var checks []string
+	for _, r := range i.Rules {
+		if r.Pattern == "*" {
+			checks = append(checks, r.RequiredChecks...)
+		}
+	}
+	return checks
+}
+
+// VAPConfig describes the ValidatingAdmissionPolicy to export.
+type VAPConfig struct {
+	// Name of the policy and of its binding.
+	Name string
+	// Forbidden are critical checks the workloads must never match.
+	Forbidden []string
+	// Required are checks the workloads must pass.
+	Required []string
+	// Audit makes the binding audit and warn instead of denying.
+	Audit bool
+}
+
+// ValidatingAdmissionPolicy translates the checks into native
+// ValidatingAdmissionPolicies and their bindings, one per path of the pod
+// spec, as a multi documents YAML. It returns the IDs of the checks that could
+// not be translated.
+func ValidatingAdmissionPolicy(cfg VAPConfig) ([]byte, []string, error) {
+	if cfg.Name == "" {
+		return nil, nil, fmt.Errorf("policy name can't be empty")
+	}
+
+	var validations []vapValidation
+	var skipped []string
+	seen := map[string]bool{}
+	add := func(id string, critical bool) {
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		c, ok := celChecks[id]
+		if !ok || c.critical != critical {
+			skipped = append(skipped, id)
+			return
+		}
+		msg := fmt.Sprintf("kubesec check %s must pass", id)
+		if critical {
+			msg = fmt.Sprintf("kubesec critical check %s failed", id)
+		}
+		validations = append(validations, vapValidation{Expression: c.expression, Message: msg})
+	}
+	for _, id := range cfg.Forbidden {
+		add(id, true)
+	}
+	for _, id := range cfg.Required {
+		add(id, false)
+	}
+	if len(validations) == 0 {
+		return nil, skipped, fmt.Errorf("no check can be exported")
+	}
+
+	actions := []string{"Deny"}
+	if cfg.Audit {
+		actions = []string{"Warn", "Audit"}
+	}
+
+	var out []byte
+	for _, t := range vapTargets {
+		name := cfg.Name + "-" + t.suffix
+		policy := vapObject{
+			APIVersion: "admissionregistration.k8s.io/v1",
+			Kind:       "ValidatingAdmissionPolicy",
+			Metadata:   vapMetadata{Name: name},
+			Spec: map[string]interface{}{
+				"failurePolicy": "Fail",
+				"matchConstraints": map[string]interface{}{
+					"resourceRules": []map[string]interface{}{{
+						"apiGroups":   []string{t.apiGroup},
+						"apiVersions": []string{"v1"},
+						"operations":  []string{"CREATE", "UPDATE"},
+						"resources":   t.resources,
+					}},
+				},
+				// The variables expose the pod spec and every container, so
+				// the check expressions are written once for all the kinds.
+				"variables": []vapVariable{
+					{Name: "spec", Expression: t.spec},
+					{Name: "containers", Expression: "variables.spec.containers + (has(variables.spec.initContainers) ? variables.spec.initContainers : [])"},
+				},
+				"validations": validations,
+			},
+		}
+		binding := vapObject{
+			APIVersion: "admissionregistration.k8s.io/v1",
+			Kind:       "ValidatingAdmissionPolicyBinding",
+			Metadata:   vapMetadata{Name: name},
+			Spec: map[string]interface{}{
+				"policyName":        name,
+				"validationActions": actions,
+			},
+		}
+
+		for _, obj := range []vapObject{policy, binding} {
+			raw, err := yaml.Marshal(obj)
+			if err != nil {
+				return nil, skipped, err
+			}
+			if len(out) > 0 {
+				out = append(out, []byte("---\n")...)
+			}
+			out = append(out, raw...)
+		}
+	}
+
+	return out, skipped, nil
+}
+
+type vapObject struct {
+	APIVersion string                 `json:"apiVersion"`
+	Kind       string                 `json:"kind"`
+	Metadata   vapMetadata            `json:"metadata"`
+	Spec       map[string]interface{} `json:"spec"`
+}
+
+type vapMetadata struct {
+	Name string `json:"name"`
+}
+
+type vapVariable struct {
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+}
+
+type vapValidation struct {
+	Expression string `json:"expression"`
+	Message    string `json:"message"`
+}
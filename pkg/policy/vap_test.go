@@ -0,0 +1,58 @@
+package policy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/yaml"
+)
+
+// TestValidatingAdmissionPolicy - tests the translation of the checks into policies
+func TestValidatingAdmissionPolicy(t *testing.T) {
+	out, skipped, err := ValidatingAdmissionPolicy(VAPConfig{
+		Name:      "kubesec",
+		Forbidden: []string{"Privileged", "HostNetwork", "Privileged"},
+		Required:  []string{"RunAsNonRoot", "ApparmorAny"},
+		Audit:     true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"ApparmorAny"}; !reflect.DeepEqual(skipped, want) {
+		t.Fatalf("ValidatingAdmissionPolicy - want skipped=%v, got %v", want, skipped)
+	}
+
+	docs := strings.Split(string(out), "---\n")
+	if len(docs) != 2*len(vapTargets) {
+		t.Fatalf("ValidatingAdmissionPolicy - want %d documents, got %d:\n%s", 2*len(vapTargets), len(docs), out)
+	}
+
+	var policy struct {
+		Kind     string
+		Metadata struct{ Name string }
+		Spec     struct {
+			Variables   []struct{ Name, Expression string }
+			Validations []struct{ Expression, Message string }
+		}
+	}
+	if err := yaml.Unmarshal([]byte(docs[2]), &policy); err != nil {
+		t.Fatal(err)
+	}
+	if policy.Kind != "ValidatingAdmissionPolicy" || policy.Metadata.Name != "kubesec-workloads" {
+		t.Fatalf("ValidatingAdmissionPolicy - unexpected policy %+v", policy)
+	}
+	if policy.Spec.Variables[0].Expression != "object.spec.template.spec" {
+		t.Fatalf("ValidatingAdmissionPolicy - want template spec variable, got %q", policy.Spec.Variables[0].Expression)
+	}
+	if len(policy.Spec.Validations) != 3 || policy.Spec.Validations[2].Message != "kubesec check RunAsNonRoot must pass" {
+		t.Fatalf("ValidatingAdmissionPolicy - unexpected validations %+v", policy.Spec.Validations)
+	}
+	if !strings.Contains(docs[3], "- Warn\n  - Audit\n") {
+		t.Fatalf("ValidatingAdmissionPolicy - want audit binding, got:\n%s", docs[3])
+	}
+
+	if _, _, err := ValidatingAdmissionPolicy(VAPConfig{Name: "kubesec", Required: []string{"ApparmorAny"}}); err == nil {
+		t.Fatal("ValidatingAdmissionPolicy - want error when nothing can be exported")
+	}
+}
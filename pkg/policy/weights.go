@@ -0,0 +1,45 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Weights override the points of Kubesec checks, by check ID, when computing
+// the effective score of an object.
+type Weights map[string]int
+
+type weightsFile struct {
+	Weights Weights `json:"weights"`
+}
+
+// LoadWeights reads YAML rule weights from path.
+func LoadWeights(path string) (Weights, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	weights, err := ParseWeights(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return weights, nil
+}
+
+// ParseWeights parses YAML rule weights.
+func ParseWeights(raw []byte) (Weights, error) {
+	f := weightsFile{}
+	if err := yaml.UnmarshalStrict(raw, &f); err != nil {
+		return nil, fmt.Errorf("invalid rule weights: %w", err)
+	}
+	for id := range f.Weights {
+		if !ruleID.MatchString(id) {
+			return nil, fmt.Errorf("invalid rule weights: invalid rule %q", id)
+		}
+	}
+
+	return f.Weights, nil
+}
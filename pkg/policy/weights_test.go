@@ -0,0 +1,48 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestLoadWeights - tests the parsing of the rule weights file
+func TestLoadWeights(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    Weights
+		err     string
+	}{
+		{name: "valid", content: "weights:\n  HostPID: -100\n  RequestsCPU: 0\n", want: Weights{"HostPID": -100, "RequestsCPU": 0}},
+		{name: "empty", content: "", want: nil},
+		{name: "unknown field", content: "weight:\n  HostPID: -100\n", err: "invalid rule weights"},
+		{name: "invalid rule", content: "weights:\n  host pid: -100\n", err: `invalid rule "host pid"`},
+		{name: "invalid weight", content: "weights:\n  HostPID: fatal\n", err: "invalid rule weights"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "weights.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadWeights(path)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("LoadWeights - want error %q, got %v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("LoadWeights - want=%v, got=%v", tt.want, got)
+			}
+		})
+	}
+}
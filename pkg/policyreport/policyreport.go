@@ -0,0 +1,224 @@
+// Package policyreport publishes the scans as wgpolicyk8s.io PolicyReports,
+// so they show in Policy Reporter and the other dashboards of the policy
+// engines.
+package policyreport
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// queueSize is the number of decisions waiting to be reported before new
+// ones are dropped.
+const queueSize = 256
+
+// source is the source of the results, and the policy they belong to.
+const source = "kubesec"
+
+// Kinds of the reports, cluster scoped objects are reported by a
+// ClusterPolicyReport.
+var (
+	PolicyReport        = schema.GroupVersionKind{Group: "wgpolicyk8s.io", Version: "v1alpha2", Kind: "PolicyReport"}
+	ClusterPolicyReport = schema.GroupVersionKind{Group: "wgpolicyk8s.io", Version: "v1alpha2", Kind: "ClusterPolicyReport"}
+)
+
+// Reporter keeps a report per reviewed object up to date with its latest
+// decision. It satisfies decision.Sink and must be started to write the
+// reports.
+type Reporter struct {
+	client client.Client
+	logger log.Logger
+	queue  chan decision.Record
+}
+
+// NewReporter returns a reporter writing the reports with the client.
+func NewReporter(c client.Client, logger log.Logger) *Reporter {
+	return &Reporter{client: c, logger: logger, queue: make(chan decision.Record, queueSize)}
+}
+
+// Write satisfies decision.Sink interface.
+func (r *Reporter) Write(_ context.Context, rec decision.Record) error {
+	select {
+	case r.queue <- rec:
+		return nil
+	default:
+		return fmt.Errorf("policy report queue is full, dropping decision of %s", rec.Key())
+	}
+}
+
+// Start writes the reports until the context is done.
+func (r *Reporter) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case rec := <-r.queue:
+			if err := r.Report(ctx, rec); err != nil {
+				r.logger.Warningf("could not report %s: %v", rec.Key(), err)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager every replica reports the decisions it took.
+func (r *Reporter) NeedLeaderElection() bool {
+	return false
+}
+
+// Report creates or updates the report of the object of the decision.
+func (r *Reporter) Report(ctx context.Context, rec decision.Record) error {
+	report := &unstructured.Unstructured{}
+	if rec.Namespace == "" {
+		report.SetGroupVersionKind(ClusterPolicyReport)
+	} else {
+		report.SetGroupVersionKind(PolicyReport)
+		report.SetNamespace(rec.Namespace)
+	}
+	report.SetName(Name(rec))
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		_, err := controllerutil.CreateOrUpdate(ctx, r.client, report, func() error {
+			fill(report, rec)
+			return nil
+		})
+		return err
+	})
+}
+
+// Name returns the name of the report of the object of the decision.
+func Name(rec decision.Record) string {
+	kind := strings.ToLower(rec.ObjectKind)
+	if gv, err := schema.ParseGroupVersion(rec.APIVersion); err == nil && gv.Group != "" {
+		kind += "." + gv.Group
+	}
+	name := fmt.Sprintf("kubesec-%s-%s", kind, rec.Name)
+	if len(name) > 253 {
+		sum := sha256.Sum256([]byte(name))
+		name = name[:236] + "-" + hex.EncodeToString(sum[:8])
+	}
+	return name
+}
+
+// fill sets the scope, results and summary of the report to those of the
+// decision.
+func fill(report *unstructured.Unstructured, rec decision.Record) {
+	labels := report.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels["app.kubernetes.io/managed-by"] = "kubesec-webhook"
+	report.SetLabels(labels)
+	// The report goes with the object, once its UID is known on UPDATE.
+	if rec.UID != "" && rec.APIVersion != "" && rec.ObjectKind != "" {
+		report.SetOwnerReferences([]metav1.OwnerReference{{
+			APIVersion: rec.APIVersion,
+			Kind:       rec.ObjectKind,
+			Name:       rec.Name,
+			UID:        rec.UID,
+		}})
+	}
+
+	scope := map[string]interface{}{"apiVersion": rec.APIVersion, "kind": rec.ObjectKind, "name": rec.Name}
+	if rec.Namespace != "" {
+		scope["namespace"] = rec.Namespace
+	}
+	if rec.UID != "" {
+		scope["uid"] = string(rec.UID)
+	}
+
+	summary := map[string]interface{}{"pass": int64(0), "fail": int64(0), "warn": int64(0), "error": int64(0), "skip": int64(0)}
+	var items []interface{}
+	for _, res := range results(rec) {
+		outcome := res["result"].(string)
+		summary[outcome] = summary[outcome].(int64) + 1
+		items = append(items, res)
+	}
+
+	report.Object["scope"] = scope
+	report.Object["summary"] = summary
+	report.Object["results"] = items
+}
+
+// results returns the results of the decision: the score against the minimum
+// score, then one per check of the scan.
+func results(rec decision.Record) []map[string]interface{} {
+	timestamp := map[string]interface{}{"seconds": rec.Time.Unix(), "nanos": int64(rec.Time.Nanosecond())}
+	result := func(rule, outcome, severity, message string, properties map[string]interface{}) map[string]interface{} {
+		res := map[string]interface{}{
+			"source":    source,
+			"policy":    source,
+			"rule":      rule,
+			"result":    outcome,
+			"severity":  severity,
+			"message":   message,
+			"scored":    true,
+			"timestamp": timestamp,
+		}
+		if properties != nil {
+			res["properties"] = properties
+		}
+		return res
+	}
+
+	switch {
+	case rec.Error != "":
+		return []map[string]interface{}{result("score", "error", "high", rec.Error, nil)}
+	case rec.Scan == nil:
+		message := "not scanned"
+		if rec.Exemption != "" {
+			message = "not scanned, " + rec.Exemption
+		}
+		return []map[string]interface{}{result("score", "skip", "info", message, nil)}
+	}
+
+	outcome := "pass"
+	if rec.Score < rec.MinScore {
+		outcome = "fail"
+	}
+	properties := map[string]interface{}{
+		"score":    strconv.Itoa(rec.Score),
+		"minScore": strconv.Itoa(rec.MinScore),
+		"decision": verdict(rec),
+	}
+	if rec.RequestID != "" {
+		properties["requestID"] = rec.RequestID
+	}
+	res := []map[string]interface{}{
+		result("score", outcome, "high", fmt.Sprintf("score %d, minimum score %d", rec.Score, rec.MinScore), properties),
+	}
+
+	add := func(rules []scanner.Rule, outcome, severity string) {
+		for _, rule := range rules {
+			res = append(res, result(rule.ID, outcome, severity, rule.Reason, map[string]interface{}{
+				"points":   strconv.Itoa(rule.Points),
+				"selector": rule.Selector,
+			}))
+		}
+	}
+	add(rec.Scan.Scoring.Critical, "fail", "high")
+	add(rec.Scan.Scoring.Advise, "warn", "low")
+	add(rec.Scan.Scoring.Passed, "pass", "info")
+	return res
+}
+
+func verdict(rec decision.Record) string {
+	if rec.Allowed {
+		return "allowed"
+	}
+	return "denied"
+}
@@ -0,0 +1,110 @@
+package policyreport
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+func testScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	for _, gvk := range []schema.GroupVersionKind{PolicyReport, ClusterPolicyReport} {
+		s.AddKnownTypeWithName(gvk, &unstructured.Unstructured{})
+		list := gvk
+		list.Kind += "List"
+		s.AddKnownTypeWithName(list, &unstructured.UnstructuredList{})
+	}
+	return s
+}
+
+// TestReporter_Report - tests the report follows the latest decision
+func TestReporter_Report(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(testScheme()).Build()
+	r := NewReporter(c, nil)
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec := decision.Record{
+		Time: now, Kind: "deployment", APIVersion: "apps/v1", ObjectKind: "Deployment", Namespace: "foo", Name: "test",
+		Score: -30, MinScore: 0,
+		Scan: &scanner.Result{Score: -30, Scoring: scanner.Scoring{
+			Critical: []scanner.Rule{{ID: "Privileged", Reason: "privileged", Points: -30}},
+			Advise:   []scanner.Rule{{ID: "RunAsNonRoot", Reason: "non root", Points: 1}},
+		}},
+	}
+	if err := r.Report(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+
+	got := &unstructured.Unstructured{}
+	got.SetGroupVersionKind(PolicyReport)
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "foo", Name: "kubesec-deployment.apps-test"}, got); err != nil {
+		t.Fatal(err)
+	}
+	wantSummary := map[string]interface{}{"pass": int64(0), "fail": int64(2), "warn": int64(1), "error": int64(0), "skip": int64(0)}
+	if summary, _, _ := unstructured.NestedMap(got.Object, "summary"); !reflect.DeepEqual(summary, wantSummary) {
+		t.Fatalf("Report - want summary %v, got %v", wantSummary, summary)
+	}
+	if kind, _, _ := unstructured.NestedString(got.Object, "scope", "kind"); kind != "Deployment" {
+		t.Fatalf("Report - want the Deployment scope, got %q", kind)
+	}
+	if len(got.GetOwnerReferences()) != 0 {
+		t.Fatalf("Report - want no owner without UID, got %v", got.GetOwnerReferences())
+	}
+
+	// The fixed object is reported over the previous decision, and owned.
+	rec.Score, rec.Allowed, rec.UID = 3, true, "uid-1"
+	rec.Scan = &scanner.Result{Score: 3, Scoring: scanner.Scoring{Passed: []scanner.Rule{{ID: "RunAsNonRoot", Points: 1}}}}
+	if err := r.Report(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "foo", Name: "kubesec-deployment.apps-test"}, got); err != nil {
+		t.Fatal(err)
+	}
+	wantSummary = map[string]interface{}{"pass": int64(2), "fail": int64(0), "warn": int64(0), "error": int64(0), "skip": int64(0)}
+	if summary, _, _ := unstructured.NestedMap(got.Object, "summary"); !reflect.DeepEqual(summary, wantSummary) {
+		t.Fatalf("Report - want summary %v, got %v", wantSummary, summary)
+	}
+	if owners := got.GetOwnerReferences(); len(owners) != 1 || owners[0].UID != "uid-1" {
+		t.Fatalf("Report - want the object as owner, got %v", owners)
+	}
+}
+
+// TestReporter_Report_cluster - tests the cluster scoped objects
+func TestReporter_Report_cluster(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(testScheme()).Build()
+	rec := decision.Record{APIVersion: "example.com/v1", ObjectKind: "Workload", Name: "test", Error: "kubesec.io scan failed"}
+	if err := NewReporter(c, nil).Report(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+
+	got := &unstructured.Unstructured{}
+	got.SetGroupVersionKind(ClusterPolicyReport)
+	if err := c.Get(context.Background(), types.NamespacedName{Name: "kubesec-workload.example.com-test"}, got); err != nil {
+		t.Fatal(err)
+	}
+	results, _, _ := unstructured.NestedSlice(got.Object, "results")
+	if len(results) != 1 || results[0].(map[string]interface{})["result"] != "error" {
+		t.Fatalf("Report - want an error result, got %v", results)
+	}
+}
+
+// TestName - tests the report names stay valid
+func TestName(t *testing.T) {
+	if got := Name(decision.Record{APIVersion: "v1", ObjectKind: "Pod", Name: "test"}); got != "kubesec-pod-test" {
+		t.Fatalf("Name - want kubesec-pod-test, got %s", got)
+	}
+	long := Name(decision.Record{APIVersion: "v1", ObjectKind: "Pod", Name: strings.Repeat("a", 253)})
+	if len(long) != 253 || long == Name(decision.Record{APIVersion: "v1", ObjectKind: "Pod", Name: strings.Repeat("a", 252)}) {
+		t.Fatalf("Name - want distinct names of 253 characters, got %s", long)
+	}
+}
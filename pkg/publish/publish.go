@@ -0,0 +1,207 @@
+// Package publish publishes the admission decisions on a message bus, a
+// Kafka topic or a NATS subject, for the security analytics pipelines to
+// consume them as they happen.
+package publish
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/segmentio/kafka-go"
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+const (
+	// queueSize is the number of decisions waiting to be published before
+	// new ones are dropped.
+	queueSize = 1024
+	// batchSize is the maximum number of decisions published at once.
+	batchSize = 100
+	// sendTimeout bounds the publication of a batch.
+	sendTimeout = 10 * time.Second
+)
+
+// Message is a decision as published on the bus.
+type Message struct {
+	// Key is the key of the reviewed object, so its decisions go to the same
+	// Kafka partition.
+	Key   string
+	Value []byte
+}
+
+// Transport sends the messages to a bus.
+type Transport interface {
+	Send(ctx context.Context, msgs []Message) error
+	Close() error
+}
+
+// Publisher publishes the decisions as JSON, asynchronously so an unavailable
+// bus never delays the admissions. It satisfies decision.Sink and must be
+// started to publish the decisions.
+type Publisher struct {
+	transport Transport
+	logger    log.Logger
+	queue     chan decision.Record
+}
+
+// NewPublisher returns a publisher sending the decisions with the transport.
+func NewPublisher(t Transport, logger log.Logger) *Publisher {
+	return &Publisher{transport: t, logger: logger, queue: make(chan decision.Record, queueSize)}
+}
+
+// Write satisfies decision.Sink interface, the rescans are not published.
+func (p *Publisher) Write(_ context.Context, rec decision.Record) error {
+	if !rec.Admission() {
+		return nil
+	}
+
+	select {
+	case p.queue <- rec:
+		return nil
+	default:
+		return fmt.Errorf("publish queue is full, dropping decision of %s", rec.Key())
+	}
+}
+
+// Start publishes the decisions until the context is done, then closes the
+// transport.
+func (p *Publisher) Start(ctx context.Context) error {
+	defer func() {
+		if err := p.transport.Close(); err != nil {
+			p.logger.Warningf("could not close the publisher: %v", err)
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case rec := <-p.queue:
+			recs := p.batch(rec)
+			if err := p.Publish(ctx, recs...); err != nil {
+				p.logger.Warningf("could not publish %d decisions: %v", len(recs), err)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager every replica publishes the decisions it took.
+func (p *Publisher) NeedLeaderElection() bool {
+	return false
+}
+
+// batch returns the decision and those already queued after it.
+func (p *Publisher) batch(rec decision.Record) []decision.Record {
+	recs := []decision.Record{rec}
+	for len(recs) < batchSize {
+		select {
+		case rec := <-p.queue:
+			recs = append(recs, rec)
+		default:
+			return recs
+		}
+	}
+	return recs
+}
+
+// Publish sends the decisions.
+func (p *Publisher) Publish(ctx context.Context, recs ...decision.Record) error {
+	msgs := make([]Message, 0, len(recs))
+	for _, rec := range recs {
+		raw, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, Message{Key: rec.Key(), Value: raw})
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	return p.transport.Send(ctx, msgs)
+}
+
+// Kafka produces the messages to a Kafka topic.
+type Kafka struct {
+	writer *kafka.Writer
+}
+
+// NewKafka returns a transport producing to the topic of the brokers, over
+// TLS when tlsConfig is set.
+func NewKafka(brokers []string, topic string, tlsConfig *tls.Config) (*Kafka, error) {
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("at least one Kafka broker is required")
+	}
+	if topic == "" {
+		return nil, fmt.Errorf("Kafka topic can't be empty")
+	}
+
+	w := &kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		RequiredAcks: kafka.RequireAll,
+		BatchTimeout: 10 * time.Millisecond,
+	}
+	if tlsConfig != nil {
+		w.Transport = &kafka.Transport{TLS: tlsConfig}
+	}
+	return &Kafka{writer: w}, nil
+}
+
+// Send satisfies Transport interface.
+func (k *Kafka) Send(ctx context.Context, msgs []Message) error {
+	kmsgs := make([]kafka.Message, 0, len(msgs))
+	for _, m := range msgs {
+		kmsgs = append(kmsgs, kafka.Message{Key: []byte(m.Key), Value: m.Value})
+	}
+	return k.writer.WriteMessages(ctx, kmsgs...)
+}
+
+// Close satisfies Transport interface.
+func (k *Kafka) Close() error {
+	return k.writer.Close()
+}
+
+// NATS publishes the messages on a NATS subject.
+type NATS struct {
+	conn    *nats.Conn
+	subject string
+}
+
+// NewNATS returns a transport publishing on the subject of the NATS server at
+// url, reconnecting to it for as long as it runs.
+func NewNATS(url, subject string) (*NATS, error) {
+	if subject == "" {
+		return nil, fmt.Errorf("NATS subject can't be empty")
+	}
+	conn, err := nats.Connect(url, nats.Name("kubesec-webhook"), nats.MaxReconnects(-1))
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to NATS: %w", err)
+	}
+	return &NATS{conn: conn, subject: subject}, nil
+}
+
+// Send satisfies Transport interface, it returns once the server got the
+// messages.
+func (n *NATS) Send(ctx context.Context, msgs []Message) error {
+	for _, m := range msgs {
+		msg := nats.NewMsg(n.subject)
+		msg.Header.Set("Kubesec-Key", m.Key)
+		msg.Data = m.Value
+		if err := n.conn.PublishMsg(msg); err != nil {
+			return err
+		}
+	}
+	return n.conn.FlushWithContext(ctx)
+}
+
+// Close satisfies Transport interface.
+func (n *NATS) Close() error {
+	return n.conn.Drain()
+}
@@ -0,0 +1,110 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+type fakeTransport struct {
+	mu     sync.Mutex
+	msgs   []Message
+	sent   chan struct{}
+	closed chan struct{}
+}
+
+func (f *fakeTransport) Send(_ context.Context, msgs []Message) error {
+	f.mu.Lock()
+	f.msgs = append(f.msgs, msgs...)
+	f.mu.Unlock()
+	f.sent <- struct{}{}
+	return nil
+}
+
+func (f *fakeTransport) Close() error {
+	close(f.closed)
+	return nil
+}
+
+// TestPublisher - tests the decisions are published as JSON keyed by object
+func TestPublisher(t *testing.T) {
+	f := &fakeTransport{sent: make(chan struct{}, 2), closed: make(chan struct{})}
+	p := NewPublisher(f, log.Dummy)
+
+	for _, name := range []string{"a", "b"} {
+		if err := p.Write(context.Background(), decision.Record{Kind: "pod", Namespace: "foo", Name: name}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		_ = p.Start(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-f.sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Publisher - nothing published")
+	}
+	cancel()
+	<-done
+	select {
+	case <-f.closed:
+	default:
+		t.Fatal("Publisher - transport not closed")
+	}
+
+	if len(f.msgs) != 2 {
+		t.Fatalf("Publisher - want both decisions in a batch, got %d messages", len(f.msgs))
+	}
+	for n, want := range []string{"pod/foo/a", "pod/foo/b"} {
+		var rec decision.Record
+		if err := json.Unmarshal(f.msgs[n].Value, &rec); err != nil {
+			t.Fatal(err)
+		}
+		if f.msgs[n].Key != want || rec.Key() != want {
+			t.Fatalf("Publisher - want %s, got key %s for %+v", want, f.msgs[n].Key, rec)
+		}
+	}
+}
+
+// TestPublisher_Write - tests the decisions are dropped when the queue is full
+func TestPublisher_Write(t *testing.T) {
+	p := NewPublisher(&fakeTransport{}, log.Dummy)
+	for n := 0; n < queueSize; n++ {
+		if err := p.Write(context.Background(), decision.Record{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.Write(context.Background(), decision.Record{}); err == nil {
+		t.Fatal("Write - want error on a full queue")
+	}
+	if err := p.Write(context.Background(), decision.Record{Operation: decision.OperationRescan}); err != nil {
+		t.Fatalf("Write - want the rescans skipped, got %v", err)
+	}
+}
+
+// TestNewKafka - tests the Kafka settings are required
+func TestNewKafka(t *testing.T) {
+	if _, err := NewKafka(nil, "decisions", nil); err == nil {
+		t.Fatal("NewKafka - want error without brokers")
+	}
+	if _, err := NewKafka([]string{"localhost:9092"}, "", nil); err == nil {
+		t.Fatal("NewKafka - want error without topic")
+	}
+	k, err := NewKafka([]string{"localhost:9092"}, "decisions", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
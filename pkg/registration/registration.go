@@ -0,0 +1,137 @@
+// Package registration registers the webhooks with the API server.
+package registration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// nameSuffix suffixes the names of the webhooks, after their path.
+const nameSuffix = ".admission.kubesc.io"
+
+// Webhook is a served webhook and the resources it validates.
+type Webhook struct {
+	// Path is the path the webhook is served on, e.g. /deployment.
+	Path      string
+	Resources []Resource
+}
+
+// Resource is a resource validated by a webhook.
+type Resource struct {
+	Group    string
+	Resource string
+}
+
+// Config is the configuration shared by the webhooks of the registration.
+type Config struct {
+	// Name is the name of the ValidatingWebhookConfiguration.
+	Name string
+	// Service is the Service in front of the webhook.
+	Service types.NamespacedName
+	Port    int32
+	// CABundle is the CA of the serving certificate.
+	CABundle          []byte
+	NamespaceSelector *metav1.LabelSelector
+	FailurePolicy     admissionregistrationv1.FailurePolicyType
+	Timeout           time.Duration
+}
+
+// Validate checks the configuration is complete.
+func (c Config) Validate() error {
+	if c.Name == "" || c.Service.Namespace == "" || c.Service.Name == "" {
+		return fmt.Errorf("webhook registration needs a name and a service")
+	}
+	switch c.FailurePolicy {
+	case admissionregistrationv1.Fail, admissionregistrationv1.Ignore:
+	default:
+		return fmt.Errorf("invalid webhook failure policy %q, want Fail or Ignore", c.FailurePolicy)
+	}
+	if c.Timeout < time.Second || c.Timeout > 30*time.Second {
+		return fmt.Errorf("webhook timeout must be between 1s and 30s")
+	}
+	return nil
+}
+
+// Webhooks returns the webhooks of the registration, named after their path.
+func (c Config) Webhooks(webhooks []Webhook) []admissionregistrationv1.ValidatingWebhook {
+	// The defaults of the API server are set so unchanged webhooks are not
+	// updated.
+	sideEffects := admissionregistrationv1.SideEffectClassNoneOnDryRun
+	matchPolicy := admissionregistrationv1.Equivalent
+	scope := admissionregistrationv1.AllScopes
+	failurePolicy := c.FailurePolicy
+	timeout := int32(c.Timeout / time.Second)
+	selector := c.NamespaceSelector
+	if selector == nil {
+		selector = &metav1.LabelSelector{}
+	}
+
+	var res []admissionregistrationv1.ValidatingWebhook
+	for _, wh := range webhooks {
+		path, port := wh.Path, c.Port
+		var rules []admissionregistrationv1.RuleWithOperations
+		for _, r := range wh.Resources {
+			rules = append(rules, admissionregistrationv1.RuleWithOperations{
+				Operations: []admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update},
+				Rule: admissionregistrationv1.Rule{
+					APIGroups:   []string{r.Group},
+					APIVersions: []string{"*"},
+					Resources:   []string{r.Resource},
+					Scope:       &scope,
+				},
+			})
+		}
+
+		res = append(res, admissionregistrationv1.ValidatingWebhook{
+			Name: wh.Path[1:] + nameSuffix,
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{
+				Service: &admissionregistrationv1.ServiceReference{
+					Namespace: c.Service.Namespace,
+					Name:      c.Service.Name,
+					Path:      &path,
+					Port:      &port,
+				},
+				CABundle: c.CABundle,
+			},
+			Rules:                   rules,
+			FailurePolicy:           &failurePolicy,
+			MatchPolicy:             &matchPolicy,
+			NamespaceSelector:       selector,
+			ObjectSelector:          &metav1.LabelSelector{},
+			SideEffects:             &sideEffects,
+			TimeoutSeconds:          &timeout,
+			AdmissionReviewVersions: []string{"v1beta1"},
+		})
+	}
+	return res
+}
+
+// Register creates or updates the ValidatingWebhookConfiguration so its
+// webhooks are those given, it returns what it did.
+func Register(ctx context.Context, c client.Client, cfg Config, webhooks []Webhook) (controllerutil.OperationResult, error) {
+	if err := cfg.Validate(); err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+
+	vwc := &admissionregistrationv1.ValidatingWebhookConfiguration{ObjectMeta: metav1.ObjectMeta{Name: cfg.Name}}
+	op, err := controllerutil.CreateOrUpdate(ctx, c, vwc, func() error {
+		if vwc.Labels == nil {
+			vwc.Labels = map[string]string{}
+		}
+		vwc.Labels["app"] = "kubesec-webhook"
+		vwc.Labels["kind"] = "validator"
+		vwc.Webhooks = cfg.Webhooks(webhooks)
+		return nil
+	})
+	if err != nil {
+		return op, fmt.Errorf("could not register webhook configuration %s: %w", cfg.Name, err)
+	}
+	return op, nil
+}
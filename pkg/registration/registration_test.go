@@ -0,0 +1,102 @@
+package registration
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func testConfig() Config {
+	return Config{
+		Name:              "kubesec-webhook",
+		Service:           types.NamespacedName{Namespace: "kubesec", Name: "kubesec-webhook"},
+		Port:              443,
+		CABundle:          []byte("ca"),
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"kubesec-validation": "enabled"}},
+		FailurePolicy:     admissionregistrationv1.Fail,
+		Timeout:           15 * time.Second,
+	}
+}
+
+var testWebhooks = []Webhook{
+	{Path: "/pod", Resources: []Resource{{Resource: "pods"}}},
+	{Path: "/deployment", Resources: []Resource{{Group: "apps", Resource: "deployments"}}},
+}
+
+// TestRegister - tests the webhook configuration is created, then updated as the webhooks change
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().Build()
+	cfg := testConfig()
+
+	if op, err := Register(ctx, c, cfg, testWebhooks); op != controllerutil.OperationResultCreated || err != nil {
+		t.Fatalf("Register - want created, got %s, %v", op, err)
+	}
+	got := &admissionregistrationv1.ValidatingWebhookConfiguration{}
+	if err := c.Get(ctx, types.NamespacedName{Name: "kubesec-webhook"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Webhooks) != 2 {
+		t.Fatalf("Register - want 2 webhooks, got %d", len(got.Webhooks))
+	}
+	wh := got.Webhooks[1]
+	if wh.Name != "deployment.admission.kubesc.io" || *wh.ClientConfig.Service.Path != "/deployment" ||
+		wh.Rules[0].APIGroups[0] != "apps" || wh.Rules[0].Resources[0] != "deployments" ||
+		*wh.TimeoutSeconds != 15 || *wh.FailurePolicy != admissionregistrationv1.Fail ||
+		string(wh.ClientConfig.CABundle) != "ca" || wh.NamespaceSelector.MatchLabels["kubesec-validation"] != "enabled" {
+		t.Errorf("Register - unexpected webhook %+v", wh)
+	}
+
+	if op, err := Register(ctx, c, cfg, testWebhooks); op != controllerutil.OperationResultNone || err != nil {
+		t.Fatalf("Register - want unchanged, got %s, %v", op, err)
+	}
+
+	cfg.Timeout = 5 * time.Second
+	if op, err := Register(ctx, c, cfg, testWebhooks[:1]); op != controllerutil.OperationResultUpdated || err != nil {
+		t.Fatalf("Register - want updated, got %s, %v", op, err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Name: "kubesec-webhook"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Webhooks) != 1 || *got.Webhooks[0].TimeoutSeconds != 5 {
+		t.Errorf("Register - want the pod webhook with a 5s timeout, got %+v", got.Webhooks)
+	}
+}
+
+// TestConfig_Validate - tests incomplete configurations are rejected
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		err    string
+	}{
+		{name: "valid", modify: func(*Config) {}},
+		{name: "no service", modify: func(c *Config) { c.Service.Name = "" }, err: "needs a name and a service"},
+		{name: "invalid failure policy", modify: func(c *Config) { c.FailurePolicy = "Retry" }, err: `invalid webhook failure policy "Retry"`},
+		{name: "long timeout", modify: func(c *Config) { c.Timeout = time.Minute }, err: "between 1s and 30s"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if tt.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("Validate - want error %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
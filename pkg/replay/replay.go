@@ -0,0 +1,117 @@
+// Package replay re-evaluates recorded admission reviews against the current
+// webhooks and reports the verdicts that changed, so an upgrade of the webhook
+// or of its policy can be validated before being deployed.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/slok/kubewebhook/pkg/webhook"
+	whcontext "github.com/slok/kubewebhook/pkg/webhook/context"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// Change is a review whose verdict differs from the recorded one.
+type Change struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Was is the recorded verdict, Now the verdict of the replay.
+	Was     bool
+	Now     bool
+	Message string
+}
+
+// Report sums up a replay.
+type Report struct {
+	Replayed  int
+	Unchanged int
+	// Skipped are reviews of kinds without webhook, or without recorded response.
+	Skipped int
+	Changes []Change
+}
+
+// Replayer replays admission reviews.
+type Replayer struct {
+	// Webhooks reviews the objects, by kind e.g. Pod or Deployment.apps.
+	Webhooks map[schema.GroupKind]webhook.Webhook
+}
+
+// Replay decodes the admission reviews from r, a stream of JSON objects such
+// as JSON lines, and reviews them again. The recorded verdict is read from the
+// response of the review.
+func (rp *Replayer) Replay(ctx context.Context, r io.Reader) (Report, error) {
+	var rep Report
+	dec := json.NewDecoder(r)
+	for n := 1; ; n++ {
+		ar := &admissionv1beta1.AdmissionReview{}
+		if err := dec.Decode(ar); err != nil {
+			if errors.Is(err, io.EOF) {
+				return rep, nil
+			}
+			return rep, fmt.Errorf("could not decode admission review %d: %w", n, err)
+		}
+
+		if ar.Request == nil || ar.Response == nil {
+			rep.Skipped++
+			continue
+		}
+		wh, ok := rp.Webhooks[schema.GroupKind{Group: ar.Request.Kind.Group, Kind: ar.Request.Kind.Kind}]
+		if !ok {
+			rep.Skipped++
+			continue
+		}
+
+		was := ar.Response.Allowed
+		resp := wh.Review(whcontext.SetAdmissionRequest(ctx, ar.Request), &admissionv1beta1.AdmissionReview{Request: ar.Request})
+		rep.Replayed++
+		if resp.Allowed == was {
+			rep.Unchanged++
+			continue
+		}
+
+		c := Change{
+			Kind:      ar.Request.Kind.Kind,
+			Namespace: ar.Request.Namespace,
+			Name:      ar.Request.Name,
+			Was:       was,
+			Now:       resp.Allowed,
+		}
+		if resp.Result != nil {
+			c.Message = resp.Result.Message
+		}
+		rep.Changes = append(rep.Changes, c)
+	}
+}
+
+// WriteText renders the report as plain text.
+func (r Report) WriteText(w io.Writer) {
+	for _, c := range r.Changes {
+		fmt.Fprintf(w, "%s %s/%s: %s -> %s\n", c.Kind, c.Namespace, c.Name, verdict(c.Was), verdict(c.Now))
+		if c.Message != "" {
+			fmt.Fprintf(w, "  %s\n", firstLine(c.Message))
+		}
+	}
+	fmt.Fprintf(w, "replayed %d reviews: %d unchanged, %d changed, %d skipped\n", r.Replayed, r.Unchanged, len(r.Changes), r.Skipped)
+}
+
+func verdict(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "denied"
+}
+
+func firstLine(s string) string {
+	for i, c := range s {
+		if c == '\n' {
+			return s[:i]
+		}
+	}
+	return s
+}
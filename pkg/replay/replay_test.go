@@ -0,0 +1,54 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/webhook"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// denyAll denies every review.
+type denyAll struct{}
+
+func (denyAll) Review(context.Context, *admissionv1beta1.AdmissionReview) *admissionv1beta1.AdmissionResponse {
+	return &admissionv1beta1.AdmissionResponse{Allowed: false, Result: &metav1.Status{Message: "score is -30\nScan Result: ..."}}
+}
+
+// TestReplayer_Replay - tests the verdict changes are reported
+func TestReplayer_Replay(t *testing.T) {
+	reviews := `
+{"request":{"uid":"1","kind":{"kind":"Pod"},"namespace":"foo","name":"was-allowed"},"response":{"uid":"1","allowed":true}}
+{"request":{"uid":"2","kind":{"kind":"Pod"},"namespace":"foo","name":"was-denied"},"response":{"uid":"2","allowed":false}}
+{"request":{"uid":"3","kind":{"group":"batch","kind":"CronJob"},"namespace":"foo","name":"unknown-kind"},"response":{"uid":"3","allowed":true}}
+{"request":{"uid":"4","kind":{"kind":"Pod"},"namespace":"foo","name":"no-response"}}
+`
+	rp := &Replayer{Webhooks: map[schema.GroupKind]webhook.Webhook{{Kind: "Pod"}: denyAll{}}}
+
+	rep, err := rp.Replay(context.Background(), strings.NewReader(reviews))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.Replayed != 2 || rep.Unchanged != 1 || rep.Skipped != 2 || len(rep.Changes) != 1 {
+		t.Fatalf("Replay - counters mismatch, got %+v", rep)
+	}
+	if c := rep.Changes[0]; c.Name != "was-allowed" || !c.Was || c.Now {
+		t.Fatalf("Replay - unexpected change %+v", c)
+	}
+
+	var out bytes.Buffer
+	rep.WriteText(&out)
+	for _, want := range []string{"Pod foo/was-allowed: allowed -> denied\n  score is -30\n", "1 changed, 2 skipped"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("WriteText - want %q in:\n%s", want, out.String())
+		}
+	}
+
+	if _, err := rp.Replay(context.Background(), strings.NewReader("{not json")); err == nil {
+		t.Fatal("Replay - want error for invalid input")
+	}
+}
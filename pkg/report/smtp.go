@@ -0,0 +1,193 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+)
+
+// SMTP transport security modes.
+const (
+	TLSModeNone     = "none"
+	TLSModeStartTLS = "starttls"
+	TLSModeTLS      = "tls"
+)
+
+// SMTPConfig is the configuration of the mail server the summaries are sent through.
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	To       []string
+	// TLSMode is one of none, starttls or tls (implicit TLS, usually port 465).
+	TLSMode string
+	// InsecureSkipVerify disables the verification of the server certificate.
+	InsecureSkipVerify bool
+}
+
+// Validate checks the configuration is usable.
+func (c SMTPConfig) Validate() error {
+	switch {
+	case c.Host == "":
+		return fmt.Errorf("smtp host can't be empty")
+	case c.From == "":
+		return fmt.Errorf("smtp sender can't be empty")
+	case len(c.To) == 0:
+		return fmt.Errorf("smtp recipients can't be empty")
+	}
+	switch c.TLSMode {
+	case TLSModeNone, TLSModeStartTLS, TLSModeTLS:
+	default:
+		return fmt.Errorf("invalid smtp TLS mode %q", c.TLSMode)
+	}
+	return nil
+}
+
+// SMTPReporter periodically emails the summary of the decisions. Every replica
+// emails the summary of the decisions it took, named after its host (the pod)
+// in the subject, as the decisions are not shared between the replicas.
+type SMTPReporter struct {
+	summary  *Summary
+	cfg      SMTPConfig
+	interval time.Duration
+	logger   log.Logger
+	replica  string
+}
+
+// NewSMTPReporter returns a reporter emailing the summary every interval.
+func NewSMTPReporter(summary *Summary, cfg SMTPConfig, interval time.Duration, logger log.Logger) (*SMTPReporter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("report interval must be positive")
+	}
+
+	replica, _ := os.Hostname()
+
+	return &SMTPReporter{
+		summary:  summary,
+		cfg:      cfg,
+		interval: interval,
+		logger:   logger,
+		replica:  replica,
+	}, nil
+}
+
+// Start sends a summary every interval until the context is done.
+func (r *SMTPReporter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			snap := r.summary.Flush()
+			if err := r.send(snap); err != nil {
+				r.logger.Errorf("could not email the decision summary: %v", err)
+				continue
+			}
+			r.logger.Infof("decision summary emailed to %s", strings.Join(r.cfg.To, ", "))
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager every replica reports the decisions it took.
+func (r *SMTPReporter) NeedLeaderElection() bool {
+	return false
+}
+
+func (r *SMTPReporter) send(snap Snapshot) error {
+	addr := net.JoinHostPort(r.cfg.Host, strconv.Itoa(r.cfg.Port))
+	tlsCfg := &tls.Config{
+		ServerName:         r.cfg.Host,
+		InsecureSkipVerify: r.cfg.InsecureSkipVerify, //nolint:gosec
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	var c *smtp.Client
+	if r.cfg.TLSMode == TLSModeTLS {
+		conn, err := tls.Dial("tcp", addr, tlsCfg)
+		if err != nil {
+			return err
+		}
+		if c, err = smtp.NewClient(conn, r.cfg.Host); err != nil {
+			conn.Close()
+			return err
+		}
+	} else {
+		var err error
+		if c, err = smtp.Dial(addr); err != nil {
+			return err
+		}
+	}
+	defer c.Close()
+
+	if r.cfg.TLSMode == TLSModeStartTLS {
+		if err := c.StartTLS(tlsCfg); err != nil {
+			return err
+		}
+	}
+
+	if r.cfg.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", r.cfg.Username, r.cfg.Password, r.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err := c.Mail(r.cfg.From); err != nil {
+		return err
+	}
+	for _, to := range r.cfg.To {
+		if err := c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(r.message(snap)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return c.Quit()
+}
+
+// message renders the email, headers included.
+func (r *SMTPReporter) message(snap Snapshot) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", r.cfg.From)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(r.cfg.To, ", "))
+	source := snap.Cluster
+	if r.replica != "" {
+		source += " (" + r.replica + ")"
+	}
+	fmt.Fprintf(&b, "Subject: [kubesec-webhook] %s: %d denials out of %d reviews\r\n", source, snap.Denials, snap.Reviews)
+	fmt.Fprintf(&b, "Date: %s\r\n", snap.To.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+
+	var body bytes.Buffer
+	snap.WriteText(&body)
+	b.WriteString(strings.ReplaceAll(body.String(), "\n", "\r\n"))
+
+	return b.Bytes()
+}
@@ -0,0 +1,151 @@
+// Package report aggregates the admission decisions into periodic summaries.
+package report
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// topN is the number of rules and namespaces listed in a summary.
+const topN = 10
+
+// Count is a named counter of a summary.
+type Count struct {
+	Name  string
+	Count int
+}
+
+// Snapshot is the summary of the decisions taken over a period.
+type Snapshot struct {
+	Cluster         string
+	From            time.Time
+	To              time.Time
+	Reviews         int
+	Denials         int
+	ScanErrors      int
+	TopFailingRules []Count
+	WorstNamespaces []Count
+}
+
+// Summary aggregates decision records, it satisfies decision.Sink.
+type Summary struct {
+	cluster string
+	now     func() time.Time
+
+	mu         sync.Mutex
+	from       time.Time
+	reviews    int
+	denials    int
+	scanErrors int
+	rules      map[string]int
+	namespaces map[string]int
+}
+
+// NewSummary returns an empty summary for the named cluster.
+func NewSummary(cluster string) *Summary {
+	s := &Summary{
+		cluster: cluster,
+		now:     time.Now,
+	}
+	s.reset()
+	return s
+}
+
+// Write satisfies decision.Sink interface, the rescans are not summed up.
+func (s *Summary) Write(_ context.Context, r decision.Record) error {
+	if !r.Admission() {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.reviews++
+	if r.Error != "" {
+		s.scanErrors++
+	}
+	for _, rule := range r.FailedRules {
+		s.rules[rule]++
+	}
+	if !r.Allowed {
+		s.denials++
+		s.namespaces[r.Namespace]++
+	}
+
+	return nil
+}
+
+// Flush returns the summary of the decisions written since the last flush and
+// starts a new period.
+func (s *Summary) Flush() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := Snapshot{
+		Cluster:         s.cluster,
+		From:            s.from,
+		To:              s.now(),
+		Reviews:         s.reviews,
+		Denials:         s.denials,
+		ScanErrors:      s.scanErrors,
+		TopFailingRules: top(s.rules),
+		WorstNamespaces: top(s.namespaces),
+	}
+	s.reset()
+
+	return snap
+}
+
+func (s *Summary) reset() {
+	s.from = s.now()
+	s.reviews, s.denials, s.scanErrors = 0, 0, 0
+	s.rules = map[string]int{}
+	s.namespaces = map[string]int{}
+}
+
+// top returns the highest counters, ties sorted by name.
+func top(counts map[string]int) []Count {
+	res := make([]Count, 0, len(counts))
+	for name, c := range counts {
+		res = append(res, Count{Name: name, Count: c})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Name < res[j].Name
+	})
+	if len(res) > topN {
+		res = res[:topN]
+	}
+	return res
+}
+
+// WriteText renders the snapshot as plain text.
+func (s Snapshot) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "Kubesec admission summary for cluster %s\n", s.Cluster)
+	fmt.Fprintf(w, "From %s to %s\n\n", s.From.UTC().Format(time.RFC1123), s.To.UTC().Format(time.RFC1123))
+	fmt.Fprintf(w, "Reviews:     %d\n", s.Reviews)
+	fmt.Fprintf(w, "Denials:     %d\n", s.Denials)
+	fmt.Fprintf(w, "Scan errors: %d\n", s.ScanErrors)
+
+	writeCounts(w, "Top failing rules", s.TopFailingRules)
+	writeCounts(w, "Namespaces with the most denials", s.WorstNamespaces)
+}
+
+func writeCounts(w io.Writer, title string, counts []Count) {
+	fmt.Fprintf(w, "\n%s:\n", title)
+	if len(counts) == 0 {
+		fmt.Fprintln(w, "  none")
+		return
+	}
+	for i, c := range counts {
+		fmt.Fprintf(w, "  %2d. %-40s %d\n", i+1, c.Name, c.Count)
+	}
+}
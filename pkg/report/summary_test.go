@@ -0,0 +1,101 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// TestSummary_Flush - tests the aggregation of decisions into a snapshot
+func TestSummary_Flush(t *testing.T) {
+	s := NewSummary("prod")
+
+	records := []decision.Record{
+		{Namespace: "team-a", Allowed: false, FailedRules: []string{"Privileged", "HostNetwork"}},
+		{Namespace: "team-a", Allowed: false, FailedRules: []string{"Privileged"}},
+		{Namespace: "team-b", Allowed: false, FailedRules: []string{"Privileged"}},
+		{Namespace: "team-b", Allowed: true},
+		{Namespace: "team-c", Allowed: true, Error: "kubesec.io scan failed"},
+		// Rescans are not summed up.
+		{Namespace: "team-c", Operation: decision.OperationRescan, FailedRules: []string{"Privileged"}},
+	}
+	for _, r := range records {
+		if err := s.Write(context.Background(), r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snap := s.Flush()
+
+	if snap.Cluster != "prod" || snap.Reviews != 5 || snap.Denials != 3 || snap.ScanErrors != 1 {
+		t.Fatalf("Flush - counters mismatch, got %+v", snap)
+	}
+	wantRules := []Count{{Name: "Privileged", Count: 3}, {Name: "HostNetwork", Count: 1}}
+	if !reflect.DeepEqual(snap.TopFailingRules, wantRules) {
+		t.Fatalf("Flush - rules mismatch, want=%v, got=%v", wantRules, snap.TopFailingRules)
+	}
+	wantNamespaces := []Count{{Name: "team-a", Count: 2}, {Name: "team-b", Count: 1}}
+	if !reflect.DeepEqual(snap.WorstNamespaces, wantNamespaces) {
+		t.Fatalf("Flush - namespaces mismatch, want=%v, got=%v", wantNamespaces, snap.WorstNamespaces)
+	}
+
+	if next := s.Flush(); next.Reviews != 0 || len(next.TopFailingRules) != 0 {
+		t.Fatalf("Flush - want an empty period after flush, got %+v", next)
+	}
+}
+
+// TestSMTPReporter_message - tests the rendering of the summary email
+func TestSMTPReporter_message(t *testing.T) {
+	r, err := NewSMTPReporter(NewSummary("prod"), SMTPConfig{
+		Host:    "smtp.example.com",
+		Port:    587,
+		From:    "kubesec@example.com",
+		To:      []string{"sec@example.com", "ops@example.com"},
+		TLSMode: TLSModeStartTLS,
+	}, 24*time.Hour, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.replica = "kubesec-webhook-0"
+
+	snap := Snapshot{Cluster: "prod", Reviews: 10, Denials: 2, TopFailingRules: []Count{{Name: "Privileged", Count: 2}}}
+	msg := string(r.message(snap))
+
+	for _, want := range []string{
+		"To: sec@example.com, ops@example.com\r\n",
+		"Subject: [kubesec-webhook] prod (kubesec-webhook-0): 2 denials out of 10 reviews\r\n",
+		"Privileged",
+	} {
+		if !strings.Contains(msg, want) {
+			t.Fatalf("message - want %q in:\n%s", want, msg)
+		}
+	}
+
+	var body bytes.Buffer
+	snap.WriteText(&body)
+	if !strings.Contains(body.String(), "Namespaces with the most denials:\n  none") {
+		t.Fatalf("WriteText - want empty namespaces section, got:\n%s", body.String())
+	}
+}
+
+// TestSMTPConfig_Validate - tests the rejection of incomplete configurations
+func TestSMTPConfig_Validate(t *testing.T) {
+	valid := SMTPConfig{Host: "smtp", From: "a@b", To: []string{"c@d"}, TLSMode: TLSModeTLS}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("Validate - unexpected error %v", err)
+	}
+
+	invalid := valid
+	invalid.TLSMode = "ssl"
+	if err := invalid.Validate(); err == nil {
+		t.Fatalf("Validate - want error for TLS mode %q", invalid.TLSMode)
+	}
+}
@@ -0,0 +1,70 @@
+// Package requestid correlates an admission request across the webhook logs,
+// the decision records and the denial message returned to the user.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/logging"
+)
+
+// Header is the HTTP header the request ID is read from and returned in.
+const Header = "X-Request-ID"
+
+// valid restricts the propagated IDs to something safe to log and echo back.
+var valid = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+type contextKey struct{}
+
+// WithID returns a copy of ctx carrying the request ID.
+func WithID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the request ID of ctx, empty when there is none.
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// New returns a random request ID.
+func New() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// Handler propagates the request ID received in the Header, or generates a new
+// one, sets it on the request context and returns it in the response.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !valid.MatchString(id) {
+			id = New()
+		}
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(WithID(r.Context(), id)))
+	})
+}
+
+// Logger returns a logger prefixing every line with the request ID of ctx, or
+// logging it as the requestID field when the logger supports fields, logger
+// itself when there is none.
+func Logger(ctx context.Context, logger log.Logger) log.Logger {
+	id := FromContext(ctx)
+	if id == "" || logger == nil {
+		return logger
+	}
+	if logging.HasFields(logger) {
+		return logging.With(logger, "requestID", id)
+	}
+	return logging.Tag(logger, "request-id", id)
+}
@@ -0,0 +1,61 @@
+package requestid
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/logging"
+)
+
+// TestHandler - tests the propagation and generation of request IDs
+func TestHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		received string
+		want     string // empty when a new ID must be generated
+	}{
+		{name: "propagated", received: "abc-123", want: "abc-123"},
+		{name: "missing", received: ""},
+		{name: "invalid", received: "bad id\r\nX-Injected: 1"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = FromContext(r.Context())
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/pod", nil)
+			if tt.received != "" {
+				req.Header.Set(Header, tt.received)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if seen == "" || rec.Header().Get(Header) != seen {
+				t.Fatalf("Handler - context ID %q and response ID %q mismatch", seen, rec.Header().Get(Header))
+			}
+			if tt.want != "" && seen != tt.want {
+				t.Fatalf("Handler - want=%q, got=%q", tt.want, seen)
+			}
+			if tt.want == "" && seen == tt.received {
+				t.Fatalf("Handler - want a generated ID, got the received one %q", seen)
+			}
+		})
+	}
+}
+
+// TestLoggerFields - tests the request ID is a field of the JSON logs
+func TestLoggerFields(t *testing.T) {
+	var buf bytes.Buffer
+	Logger(WithID(context.Background(), "abc-123"), logging.NewJSON(&buf, false)).Infof("scanned")
+
+	if got := buf.String(); !strings.Contains(got, `"requestID":"abc-123"`) || !strings.Contains(got, `"msg":"scanned"`) {
+		t.Fatalf("Logger - want the request ID field and an unprefixed message, got %s", got)
+	}
+}
@@ -0,0 +1,257 @@
+// Package rescan reviews the existing workloads periodically, those admitted
+// before the webhook was deployed included, and reports how they fare against
+// the admission bar without blocking anything.
+package rescan
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// pageSize is the number of objects listed per request.
+const pageSize = 500
+
+// KnativeService is the kind of the Knative Services, listed unstructured as
+// their API is not part of the scheme.
+var KnativeService = schema.GroupVersionKind{Group: "serving.knative.dev", Version: "v1", Kind: "Service"}
+
+// kind is a kind of workloads rescanned.
+type kind struct {
+	gvk schema.GroupVersionKind
+	// name is the kind of the logs and metrics, e.g. deployment.
+	name string
+	list func() client.ObjectList
+	// optional kinds are skipped when their API is not served.
+	optional bool
+}
+
+// kinds are the workloads rescanned, those the webhooks validate. The
+// objects controlled by one of them, e.g. the Pods of a ReplicaSet or the
+// Jobs of a CronJob, are left out as their controller is rescanned.
+var kinds = []kind{
+	{gvk: appsv1.SchemeGroupVersion.WithKind("Deployment"), name: "deployment", list: func() client.ObjectList { return &appsv1.DeploymentList{} }},
+	{gvk: appsv1.SchemeGroupVersion.WithKind("DaemonSet"), name: "daemonset", list: func() client.ObjectList { return &appsv1.DaemonSetList{} }},
+	{gvk: appsv1.SchemeGroupVersion.WithKind("StatefulSet"), name: "statefulset", list: func() client.ObjectList { return &appsv1.StatefulSetList{} }},
+	{gvk: appsv1.SchemeGroupVersion.WithKind("ReplicaSet"), name: "replicaset", list: func() client.ObjectList { return &appsv1.ReplicaSetList{} }},
+	{gvk: batchv1.SchemeGroupVersion.WithKind("Job"), name: "job", list: func() client.ObjectList { return &batchv1.JobList{} }},
+	{gvk: batchv1.SchemeGroupVersion.WithKind("CronJob"), name: "cronjob", list: func() client.ObjectList { return &batchv1.CronJobList{} }},
+	{gvk: KnativeService, name: "knative service", optional: true, list: func() client.ObjectList {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(KnativeService.GroupVersion().WithKind("ServiceList"))
+		return list
+	}},
+	{gvk: corev1.SchemeGroupVersion.WithKind("Pod"), name: "pod", list: func() client.ObjectList { return &corev1.PodList{} }},
+}
+
+// controlled tells whether obj is controlled by a workload rescanned.
+func controlled(obj client.Object) bool {
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil {
+		return false
+	}
+	gv, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil {
+		return false
+	}
+	for _, k := range kinds {
+		if k.gvk.Group == gv.Group && k.gvk.Kind == owner.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Reviewer reviews an existing object, webhook.Options satisfies it.
+type Reviewer interface {
+	Rescan(ctx context.Context, obj client.Object, minScore int, logger log.Logger) decision.Record
+}
+
+// Recorder records the outcome of the rescans.
+type Recorder interface {
+	// SetRescanned sets the gauges of the workloads of a kind passing, failing
+	// and not scanned by the last rescan.
+	SetRescanned(kind string, passing, failing, errored int)
+	// ResetLastScores forgets the last scores of the workloads, those still
+	// existing are set again by the rescan.
+	ResetLastScores()
+}
+
+// counts sums up the rescan of a kind of workloads by outcome.
+type counts struct {
+	Passing int
+	Failing int
+	Errored int
+}
+
+// Rescanner rescans the workloads periodically. It must be started to
+// rescan.
+type Rescanner struct {
+	reader   client.Reader
+	reviewer Reviewer
+	minScore int
+	interval time.Duration
+	recorder Recorder
+	logger   log.Logger
+}
+
+// NewRescanner returns a rescanner reviewing the workloads read by reader
+// every interval, against minScore.
+func NewRescanner(reader client.Reader, reviewer Reviewer, minScore int, interval time.Duration, recorder Recorder, logger log.Logger) (*Rescanner, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("rescan interval must be positive")
+	}
+	return &Rescanner{
+		reader:   reader,
+		reviewer: reviewer,
+		minScore: minScore,
+		interval: interval,
+		recorder: recorder,
+		logger:   logger,
+	}, nil
+}
+
+// Rescan reviews every workload once and records the outcome by kind. The
+// last scores are reset first, so the deleted workloads are forgotten.
+func (r *Rescanner) Rescan(ctx context.Context) error {
+	r.recorder.ResetLastScores()
+
+	var errs []string
+	for _, k := range kinds {
+		counts, err := r.rescanKind(ctx, k)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		r.recorder.SetRescanned(k.name, counts.Passing, counts.Failing, counts.Errored)
+		r.logger.Infof("rescanned %d %ss: %d passing, %d failing, %d not scanned", counts.Passing+counts.Failing+counts.Errored, k.name, counts.Passing, counts.Failing, counts.Errored)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("could not rescan: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// rescanKind reviews the workloads of kind k.
+func (r *Rescanner) rescanKind(ctx context.Context, k kind) (counts, error) {
+	var c counts
+	err := each(ctx, r.reader, "", k, func(obj client.Object) error {
+		switch Outcome(r.reviewer.Rescan(ctx, obj, r.minScore, r.logger)) {
+		case Failing:
+			c.Failing++
+		case Errored:
+			c.Errored++
+		default:
+			c.Passing++
+		}
+		return nil
+	})
+	return c, err
+}
+
+// Outcomes of the rescan of a workload.
+const (
+	Passing = "passing"
+	Failing = "failing"
+	Errored = "error"
+)
+
+// Outcome returns the outcome of the decision taken on rescan: Failing when
+// the workload would be denied, audited ones included, Errored when it could
+// not be scanned and Passing otherwise, exempted ones included.
+func Outcome(rec decision.Record) string {
+	switch {
+	case !rec.Allowed || rec.Audit:
+		return Failing
+	case rec.Error != "" || rec.Time.IsZero():
+		return Errored
+	}
+	return Passing
+}
+
+// Workloads calls fn with every workload rescanned of namespace, of all the
+// namespaces when empty, with its TypeMeta set. It stops at the first error.
+func Workloads(ctx context.Context, reader client.Reader, namespace string, fn func(obj client.Object) error) error {
+	for _, k := range kinds {
+		if err := each(ctx, reader, namespace, k, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// each calls fn with the workloads of kind k, listed page by page.
+func each(ctx context.Context, reader client.Reader, namespace string, k kind, fn func(obj client.Object) error) error {
+	var cont string
+	for {
+		list := k.list()
+		if err := reader.List(ctx, list, client.InNamespace(namespace), client.Limit(pageSize), client.Continue(cont)); err != nil {
+			if k.optional && (meta.IsNoMatchError(err) || apierrors.IsNotFound(err)) {
+				return nil
+			}
+			return fmt.Errorf("could not list %ss: %w", k.name, err)
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			obj, ok := item.(client.Object)
+			if !ok {
+				continue
+			}
+			if controlled(obj) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			obj.GetObjectKind().SetGroupVersionKind(k.gvk)
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+
+		if cont = list.GetContinue(); cont == "" {
+			return nil
+		}
+	}
+}
+
+// Start rescans the workloads every interval until the context is done.
+func (r *Rescanner) Start(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Rescan(ctx); err != nil && ctx.Err() == nil {
+			r.logger.Errorf("%v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager only the leader rescans the
+// workloads.
+func (r *Rescanner) NeedLeaderElection() bool {
+	return true
+}
@@ -0,0 +1,108 @@
+package rescan
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// fakeReviewer decides by name: the failing objects are denied, the errored
+// ones could not be scanned.
+type fakeReviewer struct {
+	reviewed []string
+}
+
+func (f *fakeReviewer) Rescan(_ context.Context, obj client.Object, minScore int, _ log.Logger) decision.Record {
+	f.reviewed = append(f.reviewed, obj.GetObjectKind().GroupVersionKind().Kind+"/"+obj.GetName())
+	rec := decision.Record{Time: time.Now(), Name: obj.GetName(), MinScore: minScore, Allowed: true}
+	switch obj.GetName() {
+	case "failing":
+		rec.Allowed = false
+	case "audited":
+		rec.Audit = true
+	case "errored":
+		rec.Error = "unreachable"
+	}
+	return rec
+}
+
+type fakeRecorder map[string]counts
+
+func (f fakeRecorder) SetRescanned(kind string, passing, failing, errored int) {
+	f[kind] = counts{Passing: passing, Failing: failing, Errored: errored}
+}
+
+func (f fakeRecorder) ResetLastScores() {}
+
+// TestRescanner_Rescan - tests every workload is reviewed and counted
+func TestRescanner_Rescan(t *testing.T) {
+	meta := func(name string) metav1.ObjectMeta { return metav1.ObjectMeta{Namespace: "foo", Name: name} }
+	yes := true
+	owned := func(name, apiVersion, kind string) metav1.ObjectMeta {
+		m := meta(name)
+		m.OwnerReferences = []metav1.OwnerReference{{APIVersion: apiVersion, Kind: kind, Name: "owner", UID: "uid-1", Controller: &yes}}
+		return m
+	}
+	c := fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).WithObjects(
+		&appsv1.Deployment{ObjectMeta: meta("passing")},
+		&appsv1.Deployment{ObjectMeta: meta("failing")},
+		&appsv1.Deployment{ObjectMeta: meta("audited")},
+		&appsv1.DaemonSet{ObjectMeta: meta("errored")},
+		&appsv1.ReplicaSet{ObjectMeta: meta("failing")},
+		&appsv1.ReplicaSet{ObjectMeta: owned("web-abc", "apps/v1", "Deployment")},
+		&batchv1.CronJob{ObjectMeta: meta("passing")},
+		&batchv1.Job{ObjectMeta: owned("nightly-123", "batch/v1", "CronJob")},
+		&corev1.Pod{ObjectMeta: meta("failing")},
+		&corev1.Pod{ObjectMeta: owned("web-abc12", "apps/v1", "ReplicaSet")},
+		&corev1.Pod{ObjectMeta: owned("job-xyz", "batch/v1", "Job")},
+		// The controller is not a workload of the webhooks.
+		&corev1.Pod{ObjectMeta: owned("operated", "example.com/v1", "Database")},
+	).Build()
+
+	reviewer := &fakeReviewer{}
+	recorder := fakeRecorder{}
+	r, err := NewRescanner(c, reviewer, 0, time.Hour, recorder, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Rescan(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	wantReviewed := []string{"Deployment/audited", "Deployment/failing", "Deployment/passing", "DaemonSet/errored", "ReplicaSet/failing", "CronJob/passing", "Pod/failing", "Pod/operated"}
+	if !reflect.DeepEqual(reviewer.reviewed, wantReviewed) {
+		t.Fatalf("Rescan - want reviewed %v, got %v", wantReviewed, reviewer.reviewed)
+	}
+	want := fakeRecorder{
+		"deployment":      {Passing: 1, Failing: 2},
+		"daemonset":       {Errored: 1},
+		"statefulset":     {},
+		"replicaset":      {Failing: 1},
+		"job":             {},
+		"cronjob":         {Passing: 1},
+		"knative service": {},
+		"pod":             {Passing: 1, Failing: 1},
+	}
+	if !reflect.DeepEqual(recorder, want) {
+		t.Fatalf("Rescan - want counts %v, got %v", want, recorder)
+	}
+}
+
+// TestNewRescanner - tests the interval is validated
+func TestNewRescanner(t *testing.T) {
+	if _, err := NewRescanner(nil, nil, 0, 0, nil, log.Dummy); err == nil {
+		t.Fatal("NewRescanner - want an error for a zero interval")
+	}
+}
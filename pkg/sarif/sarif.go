@@ -0,0 +1,184 @@
+// Package sarif renders the decisions as a SARIF 2.1.0 log, so they can be
+// uploaded to GitHub code scanning and the other SARIF consumers.
+package sarif
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+const (
+	version = "2.1.0"
+	schema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	toolURI = "https://github.com/controlplaneio/kubesec-webhook"
+)
+
+// Rules of the results that are not Kubesec checks.
+const (
+	// RuleMinScore is the rule of the objects scoring below the minimum score.
+	RuleMinScore = "kubesec.MinScore"
+	// RuleScanError is the rule of the objects that could not be scanned.
+	RuleScanError = "kubesec.ScanError"
+)
+
+// Object is a reviewed object and its decision.
+type Object struct {
+	// File is the manifest of the object, empty for the objects read from a
+	// cluster.
+	File     string
+	Decision decision.Record
+}
+
+type sarifLog struct {
+	Version string `json:"version"`
+	Schema  string `json:"$schema"`
+	Runs    []run  `json:"runs"`
+}
+
+type run struct {
+	Tool    tool     `json:"tool"`
+	Results []result `json:"results"`
+}
+
+type tool struct {
+	Driver driver `json:"driver"`
+}
+
+type driver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri"`
+	Rules          []rule `json:"rules"`
+}
+
+type rule struct {
+	ID               string  `json:"id"`
+	ShortDescription message `json:"shortDescription"`
+}
+
+type message struct {
+	Text string `json:"text"`
+}
+
+type result struct {
+	RuleID    string     `json:"ruleId"`
+	Level     string     `json:"level"`
+	Message   message    `json:"message"`
+	Locations []location `json:"locations"`
+}
+
+type location struct {
+	PhysicalLocation *physicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []logicalLocation `json:"logicalLocations"`
+}
+
+type physicalLocation struct {
+	ArtifactLocation artifactLocation `json:"artifactLocation"`
+}
+
+type artifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type logicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// Write writes the SARIF log of the objects: an error when the object scores
+// below the minimum score or could not be scanned, a result per failed
+// critical check and per missing required check, errors when the object is
+// denied and warnings otherwise, and a note per advised check missed.
+func Write(w io.Writer, objects []Object) error {
+	rules := map[string]string{
+		RuleMinScore:  "The object scores below the minimum score.",
+		RuleScanError: "The object could not be scanned.",
+	}
+	results := []result{}
+	for _, obj := range objects {
+		rec := obj.Decision
+		loc := locate(obj)
+		add := func(ruleID, level, text string) {
+			results = append(results, result{RuleID: ruleID, Level: level, Message: message{Text: text}, Locations: []location{loc}})
+		}
+		name := fmt.Sprintf("%s %s/%s", rec.ObjectKind, rec.Namespace, rec.Name)
+
+		if rec.Error != "" {
+			add(RuleScanError, "error", fmt.Sprintf("%s could not be scanned: %s", name, rec.Error))
+			continue
+		}
+		if rec.Scan == nil {
+			continue
+		}
+		level := "warning"
+		if !rec.Allowed || rec.Audit {
+			level = "error"
+		}
+		if rec.Score < rec.MinScore {
+			add(RuleMinScore, "error", fmt.Sprintf("%s score is %d, the minimum score is %d", name, rec.Score, rec.MinScore))
+		}
+		for _, r := range rec.Scan.Scoring.Critical {
+			describe(rules, r)
+			add(r.ID, level, fmt.Sprintf("%s fails the critical check %s: %s", name, r.ID, r.Reason))
+		}
+		for _, r := range rec.Scan.Scoring.Advise {
+			describe(rules, r)
+			add(r.ID, "note", fmt.Sprintf("%s misses the advised check %s: %s", name, r.ID, r.Reason))
+		}
+		for _, id := range rec.MissingChecks {
+			if _, ok := rules[id]; !ok {
+				rules[id] = "Required check " + id + "."
+			}
+			add(id, level, fmt.Sprintf("%s does not pass the required check %s", name, id))
+		}
+	}
+
+	ids := make([]string, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	drv := driver{Name: "kubesec-webhook", InformationURI: toolURI, Rules: []rule{}}
+	for _, id := range ids {
+		drv.Rules = append(drv.Rules, rule{ID: id, ShortDescription: message{Text: rules[id]}})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Version: version,
+		Schema:  schema,
+		Runs:    []run{{Tool: tool{Driver: drv}, Results: results}},
+	})
+}
+
+// describe adds the check to the rules, described by its reason.
+func describe(rules map[string]string, r scanner.Rule) {
+	if _, ok := rules[r.ID]; ok {
+		return
+	}
+	rules[r.ID] = r.Reason
+	if r.Reason == "" {
+		rules[r.ID] = "Kubesec check " + r.ID + "."
+	}
+}
+
+// locate returns the location of the object: its manifest when known, and
+// its namespace, kind and name.
+func locate(obj Object) location {
+	rec := obj.Decision
+	loc := location{LogicalLocations: []logicalLocation{{
+		FullyQualifiedName: strings.Join([]string{rec.Namespace, rec.ObjectKind, rec.Name}, "/"),
+		Kind:               "resource",
+	}}}
+	if obj.File != "" {
+		loc.PhysicalLocation = &physicalLocation{ArtifactLocation: artifactLocation{URI: filepath.ToSlash(obj.File)}}
+	}
+	return loc
+}
@@ -0,0 +1,65 @@
+package sarif
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// TestWrite - tests the findings of the decisions are rendered as SARIF results
+func TestWrite(t *testing.T) {
+	denied := decision.Record{
+		Namespace: "foo", ObjectKind: "Deployment", Name: "api", Score: -30, MinScore: 0,
+		MissingChecks: []string{"RunAsNonRoot"},
+		Scan: &scanner.Result{Scoring: scanner.Scoring{
+			Critical: []scanner.Rule{{ID: "Privileged", Reason: "Privileged containers can allow almost completely unrestricted host access"}},
+			Advise:   []scanner.Rule{{ID: "RunAsNonRoot", Reason: "Force the running image to run as a non-root user"}},
+		}},
+	}
+	allowed := decision.Record{Namespace: "foo", ObjectKind: "Pod", Name: "ok", Score: 3, Allowed: true, Scan: &scanner.Result{}}
+	errored := decision.Record{Namespace: "bar", ObjectKind: "Pod", Name: "down", Allowed: true, Error: "unreachable"}
+
+	var out bytes.Buffer
+	if err := Write(&out, []Object{{File: "manifests/api.yaml", Decision: denied}, {Decision: allowed}, {Decision: errored}}); err != nil {
+		t.Fatal(err)
+	}
+	var got sarifLog
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != "2.1.0" || len(got.Runs) != 1 {
+		t.Fatalf("Write - want a SARIF 2.1.0 log of one run, got %+v", got)
+	}
+
+	type finding struct{ rule, level, uri, name string }
+	var findings []finding
+	for _, res := range got.Runs[0].Results {
+		f := finding{rule: res.RuleID, level: res.Level, name: res.Locations[0].LogicalLocations[0].FullyQualifiedName}
+		if pl := res.Locations[0].PhysicalLocation; pl != nil {
+			f.uri = pl.ArtifactLocation.URI
+		}
+		findings = append(findings, f)
+	}
+	want := []finding{
+		{RuleMinScore, "error", "manifests/api.yaml", "foo/Deployment/api"},
+		{"Privileged", "error", "manifests/api.yaml", "foo/Deployment/api"},
+		{"RunAsNonRoot", "note", "manifests/api.yaml", "foo/Deployment/api"},
+		{"RunAsNonRoot", "error", "manifests/api.yaml", "foo/Deployment/api"},
+		{RuleScanError, "error", "", "bar/Pod/down"},
+	}
+	if !reflect.DeepEqual(findings, want) {
+		t.Fatalf("Write - want findings %v, got %v", want, findings)
+	}
+
+	var ids []string
+	for _, r := range got.Runs[0].Tool.Driver.Rules {
+		ids = append(ids, r.ID)
+	}
+	if wantIDs := []string{"Privileged", "RunAsNonRoot", RuleMinScore, RuleScanError}; !reflect.DeepEqual(ids, wantIDs) {
+		t.Fatalf("Write - want rules %v, got %v", wantIDs, ids)
+	}
+}
@@ -0,0 +1,199 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without scanning while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("kubesec backend circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker.
+type BreakerState int
+
+// Circuit breaker states.
+const (
+	// BreakerClosed lets the scans through.
+	BreakerClosed BreakerState = iota
+	// BreakerHalfOpen lets a single scan through to probe the backend.
+	BreakerHalfOpen
+	// BreakerOpen fails the scans without reaching the backend.
+	BreakerOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerHalfOpen:
+		return "half-open"
+	case BreakerOpen:
+		return "open"
+	}
+	return fmt.Sprintf("BreakerState(%d)", int(s))
+}
+
+// BreakerRecorder records the state of a circuit breaker.
+type BreakerRecorder interface {
+	// SetScanBreakerState sets the gauge of the breaker state, 0 closed, 1 half-open and 2 open.
+	SetScanBreakerState(state int)
+}
+
+// BreakerPublisher shares the state of a circuit breaker once it opens or
+// closes, e.g. with the other replicas of the webhook. It must not block.
+type BreakerPublisher interface {
+	PublishBreakerState(state BreakerState)
+}
+
+// Breaker is a scanner failing fast while the backend is down. It opens after
+// threshold consecutive failed scans, and lets one probe through once the
+// cooldown elapsed: the breaker closes when it succeeds and opens again
+// otherwise. Scans failed fast return ErrCircuitOpen, which the validators
+// handle as any scan error, according to their failure mode.
+type Breaker struct {
+	next      Scanner
+	threshold int
+	cooldown  time.Duration
+	recorder  BreakerRecorder
+	now       func() time.Time
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	publisher BreakerPublisher
+}
+
+// NewBreaker returns a circuit breaker in front of next.
+func NewBreaker(next Scanner, threshold int, cooldown time.Duration, recorder BreakerRecorder) (*Breaker, error) {
+	if threshold < 1 {
+		return nil, fmt.Errorf("circuit breaker threshold must be at least 1")
+	}
+	if cooldown <= 0 {
+		return nil, fmt.Errorf("circuit breaker cooldown must be positive")
+	}
+
+	recorder.SetScanBreakerState(int(BreakerClosed))
+	return &Breaker{
+		next:      next,
+		threshold: threshold,
+		cooldown:  cooldown,
+		recorder:  recorder,
+		now:       time.Now,
+	}, nil
+}
+
+// Next returns the scanner behind the breaker.
+func (b *Breaker) Next() Scanner {
+	return b.next
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// SetPublisher makes the breaker publish its state when it opens or closes.
+func (b *Breaker) SetPublisher(p BreakerPublisher) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.publisher = p
+}
+
+// Apply applies the state of the breaker of another replica without
+// publishing it: a closed breaker opens for the cooldown, an open one closes.
+// A half-open breaker waits for the outcome of its own probe.
+func (b *Breaker) Apply(state BreakerState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case state == BreakerOpen && b.state == BreakerClosed:
+		b.failures = b.threshold
+		b.openedAt = b.now()
+		b.set(BreakerOpen)
+	case state == BreakerClosed && b.state == BreakerOpen:
+		b.failures = 0
+		b.set(BreakerClosed)
+	}
+}
+
+// Scan satisfies Scanner interface.
+func (b *Breaker) Scan(ctx context.Context, def []byte) (Results, error) {
+	if !b.allow() {
+		return nil, ErrCircuitOpen
+	}
+
+	results, err := b.next.Scan(ctx, def)
+	// Callers giving up and throttled scans say nothing of the backend health.
+	if err != nil && (ctx.Err() != nil || errors.Is(err, ErrThrottled)) {
+		b.release()
+		return nil, err
+	}
+	b.done(err == nil)
+
+	return results, err
+}
+
+// allow tells whether a scan may reach the backend.
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.set(BreakerHalfOpen)
+		return true
+	case BreakerHalfOpen:
+		// A probe is already in flight.
+		return false
+	}
+	return true
+}
+
+// release gives the probe back when its outcome tells nothing.
+func (b *Breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.openedAt = b.now().Add(-b.cooldown)
+		b.set(BreakerOpen)
+	}
+}
+
+// done records the outcome of a scan that reached the backend.
+func (b *Breaker) done(ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	prev := b.state
+	if ok {
+		b.failures = 0
+		b.set(BreakerClosed)
+	} else {
+		b.failures++
+		if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+			b.openedAt = b.now()
+			b.set(BreakerOpen)
+		}
+	}
+	if b.publisher != nil && b.state != prev {
+		b.publisher.PublishBreakerState(b.state)
+	}
+}
+
+func (b *Breaker) set(state BreakerState) {
+	if b.state != state {
+		b.state = state
+		b.recorder.SetScanBreakerState(int(state))
+	}
+}
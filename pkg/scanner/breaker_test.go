@@ -0,0 +1,127 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// breakerStates records the successive states of a breaker.
+type breakerStates []int
+
+func (b *breakerStates) SetScanBreakerState(state int) {
+	*b = append(*b, state)
+}
+
+// TestBreaker_Scan - tests the breaker opens after consecutive failures and probes the backend after the cooldown
+func TestBreaker_Scan(t *testing.T) {
+	next := &stubScanner{err: errors.New("connection refused")}
+	states := &breakerStates{}
+	b, err := NewBreaker(next, 3, time.Minute, states)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	b.now = func() time.Time { return now }
+
+	scan := func() error {
+		_, err := b.Scan(context.Background(), []byte("abc"))
+		return err
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := scan(); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("Scan %d - want the backend error, got %v", i, err)
+		}
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("Scan - want the breaker open after 3 failures, got %s", b.State())
+	}
+	if err := scan(); !errors.Is(err, ErrCircuitOpen) || next.scans != 3 {
+		t.Fatalf("Scan - want to fail fast, got %v after %d scans", err, next.scans)
+	}
+
+	// The probe fails, the breaker opens again.
+	now = now.Add(time.Minute)
+	if err := scan(); errors.Is(err, ErrCircuitOpen) || next.scans != 4 {
+		t.Fatalf("Scan - want a probe, got %v after %d scans", err, next.scans)
+	}
+	if err := scan(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Scan - want the breaker open again after a failed probe, got %v", err)
+	}
+
+	// The probe succeeds, the breaker closes.
+	now = now.Add(time.Minute)
+	next.err = nil
+	if err := scan(); err != nil {
+		t.Fatalf("Scan - want the probe to succeed, got %v", err)
+	}
+	if b.State() != BreakerClosed {
+		t.Fatalf("Scan - want the breaker closed, got %s", b.State())
+	}
+
+	want := []int{int(BreakerClosed), int(BreakerOpen), int(BreakerHalfOpen), int(BreakerOpen), int(BreakerHalfOpen), int(BreakerClosed)}
+	if len(*states) != len(want) {
+		t.Fatalf("Scan - want states %v, got %v", want, *states)
+	}
+	for i := range want {
+		if (*states)[i] != want[i] {
+			t.Fatalf("Scan - want states %v, got %v", want, *states)
+		}
+	}
+}
+
+// TestBreaker_Scan_ignored - tests throttled and abandoned scans do not trip the breaker
+func TestBreaker_Scan_ignored(t *testing.T) {
+	next := &stubScanner{err: ErrThrottled}
+	b, err := NewBreaker(next, 1, time.Minute, &breakerStates{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		_, _ = b.Scan(context.Background(), []byte("abc"))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	next.err = context.Canceled
+	_, _ = b.Scan(ctx, []byte("abc"))
+
+	if b.State() != BreakerClosed || next.scans != 4 {
+		t.Fatalf("Scan - want the breaker closed, got %s after %d scans", b.State(), next.scans)
+	}
+}
+
+// breakerPublished records the states published by a breaker.
+type breakerPublished []BreakerState
+
+func (b *breakerPublished) PublishBreakerState(state BreakerState) {
+	*b = append(*b, state)
+}
+
+// TestBreaker_Apply - tests the breaker publishes its transitions and applies those of the other replicas
+func TestBreaker_Apply(t *testing.T) {
+	next := &stubScanner{err: errors.New("connection refused")}
+	b, err := NewBreaker(next, 2, time.Minute, &breakerStates{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := &breakerPublished{}
+	b.SetPublisher(pub)
+
+	for i := 0; i < 2; i++ {
+		_, _ = b.Scan(context.Background(), []byte("abc"))
+	}
+	b.Apply(BreakerClosed)
+	if b.State() != BreakerClosed {
+		t.Fatalf("Apply - want the breaker closed by a peer, got %s", b.State())
+	}
+	b.Apply(BreakerOpen)
+	if _, err := b.Scan(context.Background(), []byte("abc")); !errors.Is(err, ErrCircuitOpen) || next.scans != 2 {
+		t.Fatalf("Apply - want the breaker opened by a peer, got %v after %d scans", err, next.scans)
+	}
+	if len(*pub) != 1 || (*pub)[0] != BreakerOpen {
+		t.Fatalf("Scan - want the opening published only, got %v", *pub)
+	}
+}
@@ -0,0 +1,161 @@
+package scanner
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Caches of the scan results.
+const (
+	CacheMemory = "memory"
+	CacheRedis  = "redis"
+)
+
+// CacheRecorder records the lookups of a cache.
+type CacheRecorder interface {
+	// IncScanCache increments the counter of cache lookups, hits or misses.
+	IncScanCache(cache string, hit bool)
+	// SetScanCacheSize sets the number of entries of the in-memory cache.
+	SetScanCacheSize(size int)
+}
+
+// CachePublisher shares the results scanned by a cache, e.g. with the other
+// replicas of the webhook. It must not block.
+type CachePublisher interface {
+	PublishScan(key [sha256.Size]byte, results Results)
+}
+
+// Cache is a scanner remembering the results of the definitions it scanned,
+// so identical definitions, e.g. the Pods of a scaled up Deployment or retried
+// creations, are scored once. It is a LRU cache whose entries expire after a
+// TTL, keyed on the hash of the definition. Failed scans are not cached.
+type Cache struct {
+	next     Scanner
+	size     int
+	ttl      time.Duration
+	recorder CacheRecorder
+	now      func() time.Time
+
+	mu        sync.Mutex
+	publisher CachePublisher
+	entries   map[[sha256.Size]byte]*list.Element
+	// lru holds the entries, the most recently used first.
+	lru *list.List
+}
+
+type cacheEntry struct {
+	key     [sha256.Size]byte
+	results Results
+	expires time.Time
+}
+
+// NewCache returns a cache of size entries in front of next.
+func NewCache(next Scanner, size int, ttl time.Duration, recorder CacheRecorder) (*Cache, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("scan cache size must be at least 1")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("scan cache TTL must be positive")
+	}
+
+	return &Cache{
+		next:     next,
+		size:     size,
+		ttl:      ttl,
+		recorder: recorder,
+		now:      time.Now,
+		entries:  map[[sha256.Size]byte]*list.Element{},
+		lru:      list.New(),
+	}, nil
+}
+
+// Scan satisfies Scanner interface.
+func (c *Cache) Scan(ctx context.Context, def []byte) (Results, error) {
+	key := sha256.Sum256(def)
+	if results, ok := c.get(key); ok {
+		c.recorder.IncScanCache(CacheMemory, true)
+		return results, nil
+	}
+	c.recorder.IncScanCache(CacheMemory, false)
+
+	results, err := c.next.Scan(ctx, def)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			return results, nil
+		}
+	}
+
+	c.add(key, results)
+	c.mu.Lock()
+	publisher := c.publisher
+	c.mu.Unlock()
+	if publisher != nil {
+		publisher.PublishScan(key, results)
+	}
+	return results, nil
+}
+
+// SetPublisher makes the cache publish the results it scans.
+func (c *Cache) SetPublisher(p CachePublisher) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.publisher = p
+}
+
+// Put caches results scanned elsewhere, e.g. by another replica, without
+// publishing them. key is the hash of the definition.
+func (c *Cache) Put(key [sha256.Size]byte, results Results) {
+	c.add(key, results)
+}
+
+// Next returns the scanner behind the cache.
+func (c *Cache) Next() Scanner {
+	return c.next
+}
+
+func (c *Cache) get(key [sha256.Size]byte) (Results, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if c.now().After(entry.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		c.recorder.SetScanCacheSize(c.lru.Len())
+		return nil, false
+	}
+
+	c.lru.MoveToFront(el)
+	return entry.results, true
+}
+
+func (c *Cache) add(key [sha256.Size]byte, results Results) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{key: key, results: results, expires: c.now().Add(c.ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	c.recorder.SetScanCacheSize(c.lru.Len())
+}
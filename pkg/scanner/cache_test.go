@@ -0,0 +1,146 @@
+package scanner
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stubScanner counts the scans and scores every definition with its length.
+type stubScanner struct {
+	scans int
+	err   error
+	fail  bool
+}
+
+func (s *stubScanner) Scan(_ context.Context, def []byte) (Results, error) {
+	s.scans++
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.fail {
+		return Results{{Error: "invalid definition"}}, nil
+	}
+	return Results{{Score: len(def)}}, nil
+}
+
+// lookups counts the cache hits and misses.
+type lookups struct {
+	hits, misses, size int
+}
+
+func (l *lookups) SetScanCacheSize(size int) {
+	l.size = size
+}
+
+func (l *lookups) IncScanCache(_ string, hit bool) {
+	if hit {
+		l.hits++
+	} else {
+		l.misses++
+	}
+}
+
+// TestCache_Scan - tests identical definitions are scanned once until they expire or are evicted
+func TestCache_Scan(t *testing.T) {
+	next := &stubScanner{}
+	rec := &lookups{}
+	c, err := NewCache(next, 2, time.Minute, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	scan := func(def string) {
+		t.Helper()
+		res, err := c.Scan(context.Background(), []byte(def))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 || res[0].Score != len(def) {
+			t.Fatalf("Scan(%q) - unexpected results %+v", def, res)
+		}
+	}
+
+	scan("a")
+	scan("a")
+	if next.scans != 1 || rec.hits != 1 || rec.misses != 1 {
+		t.Fatalf("Scan - want 1 scan, 1 hit and 1 miss, got %d scans, %+v", next.scans, rec)
+	}
+
+	// "bb" is the least recently used once "a" is scanned again, and evicted by "ccc".
+	scan("bb")
+	scan("a")
+	scan("ccc")
+	scan("a")
+	if next.scans != 3 {
+		t.Fatalf("Scan - want the recently used entry kept, got %d scans", next.scans)
+	}
+	scan("bb")
+	if next.scans != 4 || rec.size != 2 {
+		t.Fatalf("Scan - want the least recently used entry evicted, got %d scans and %d entries", next.scans, rec.size)
+	}
+
+	now = now.Add(2 * time.Minute)
+	scan("a")
+	if next.scans != 5 {
+		t.Fatalf("Scan - want expired entry scanned again, got %d scans", next.scans)
+	}
+}
+
+// TestCache_Scan_failures - tests failed scans are not cached
+func TestCache_Scan_failures(t *testing.T) {
+	tests := []struct {
+		name string
+		next *stubScanner
+	}{
+		{name: "scan error", next: &stubScanner{err: errors.New("connection refused")}},
+		{name: "result error", next: &stubScanner{fail: true}},
+	}
+	for _, tt := range tests {
+		c, err := NewCache(tt.next, 10, time.Minute, &lookups{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			_, _ = c.Scan(context.Background(), []byte("a"))
+		}
+		if tt.next.scans != 2 {
+			t.Fatalf("%s - want every scan sent, got %d scans", tt.name, tt.next.scans)
+		}
+	}
+}
+
+// published records the results published by a cache.
+type published map[[sha256.Size]byte]Results
+
+func (p published) PublishScan(key [sha256.Size]byte, results Results) {
+	p[key] = results
+}
+
+// TestCache_Put - tests the scanned results are published and those put are served without scanning
+func TestCache_Put(t *testing.T) {
+	next := &stubScanner{}
+	c, err := NewCache(next, 2, time.Minute, &lookups{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub := published{}
+	c.SetPublisher(pub)
+
+	if _, err := c.Scan(context.Background(), []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if res, ok := pub[sha256.Sum256([]byte("abc"))]; !ok || res[0].Score != 3 {
+		t.Fatalf("Scan - want the result published, got %v", pub)
+	}
+
+	c.Put(sha256.Sum256([]byte("peer")), Results{{Score: 7}})
+	res, err := c.Scan(context.Background(), []byte("peer"))
+	if err != nil || res[0].Score != 7 || next.scans != 1 || len(pub) != 1 {
+		t.Fatalf("Put - want the result served unpublished without scanning, got %v, %v after %d scans", res, err, next.scans)
+	}
+}
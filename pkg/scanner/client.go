@@ -0,0 +1,239 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultBackoff is how long the client backs off when the backend throttles
+// without telling for how long.
+const defaultBackoff = 30 * time.Second
+
+// Client scores definitions against a remote Kubesec API such as https://v2.kubesec.io.
+// A client is safe for concurrent use and is meant to be shared: when the
+// backend signals a rate limit every caller backs off until it is lifted.
+type Client struct {
+	url        string
+	timeout    time.Duration
+	httpClient *http.Client
+
+	mu             sync.Mutex
+	throttledUntil time.Time
+	now            func() time.Time
+}
+
+// TransportConfig tunes the connections of the client to the backend. The
+// webhook sends many requests to a single host, so keeping connections idle
+// per host avoids a TLS handshake on most scans.
+type TransportConfig struct {
+	// MaxIdleConnsPerHost is the number of idle connections kept to the backend.
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout is how long an idle connection is kept before being closed.
+	IdleConnTimeout time.Duration
+	// TLSHandshakeTimeout bounds the TLS handshake of new connections.
+	TLSHandshakeTimeout time.Duration
+	// KeepAlive is the interval of the TCP keep-alive probes, negative disables them.
+	KeepAlive time.Duration
+	// DisableKeepAlives opens a new connection for every scan.
+	DisableKeepAlives bool
+}
+
+// DefaultTransportConfig returns a configuration suited to the traffic of the
+// webhook, a high rate of requests to a single host.
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConnsPerHost: 32,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 5 * time.Second,
+		KeepAlive:           30 * time.Second,
+	}
+}
+
+// NewTransport returns the HTTP transport described by the configuration.
+func NewTransport(cfg TransportConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: cfg.KeepAlive,
+	}
+
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        cfg.MaxIdleConnsPerHost,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		TLSHandshakeTimeout: cfg.TLSHandshakeTimeout,
+		DisableKeepAlives:   cfg.DisableKeepAlives,
+	}
+}
+
+// NewClient returns a new client for the Kubesec API at url using the default
+// transport configuration.
+func NewClient(url string, timeout time.Duration) *Client {
+	return NewClientWithTransport(url, timeout, DefaultTransportConfig())
+}
+
+// NewClientWithTransport returns a new client for the Kubesec API at url whose
+// connections are tuned by cfg.
+func NewClientWithTransport(url string, timeout time.Duration, cfg TransportConfig) *Client {
+	return &Client{
+		url:        url,
+		timeout:    timeout,
+		httpClient: &http.Client{Transport: NewTransport(cfg)},
+		now:        time.Now,
+	}
+}
+
+// StatusError is returned when the backend answers with an unexpected status.
+type StatusError struct {
+	Code int
+	URL  string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("got %v response from %v instead of 200 OK", e.Code, e.URL)
+}
+
+// ThrottledUntil returns the time until which scans are refused because the
+// backend rate limited the client, zero when not throttled.
+func (c *Client) ThrottledUntil() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.now().After(c.throttledUntil) {
+		return time.Time{}
+	}
+	return c.throttledUntil
+}
+
+// Scan satisfies Scanner interface.
+func (c *Client) Scan(ctx context.Context, def []byte) (Results, error) {
+	if until := c.ThrottledUntil(); !until.IsZero() {
+		return nil, fmt.Errorf("%w until %s", ErrThrottled, until.Format(time.RFC3339))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(def))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if backoff, limited := rateLimit(resp, c.now()); limited {
+		until := c.throttle(backoff)
+		return nil, fmt.Errorf("%w until %s", ErrThrottled, until.Format(time.RFC3339))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{Code: resp.StatusCode, URL: c.url}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) < 1 {
+		return nil, errors.New("failed to scan definition")
+	}
+
+	var results Results
+	if err := json.Unmarshal(body, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// throttle refuses scans for the given duration, never shortening a backoff
+// that is already in place.
+func (c *Client) throttle(backoff time.Duration) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	until := c.now().Add(backoff)
+	if until.After(c.throttledUntil) {
+		c.throttledUntil = until
+	}
+	return c.throttledUntil
+}
+
+// rateLimit tells whether the response is a rate limit signal and for how long
+// the client should back off. It understands 429 and 503 statuses with an
+// optional Retry-After header, and exhausted X-RateLimit-Remaining quotas.
+func rateLimit(resp *http.Response, now time.Time) (time.Duration, bool) {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+	case resp.StatusCode == http.StatusServiceUnavailable && resp.Header.Get("Retry-After") != "":
+	case resp.Header.Get("X-RateLimit-Remaining") == "0":
+	default:
+		return 0, false
+	}
+
+	if d, ok := retryAfter(resp.Header.Get("Retry-After"), now); ok {
+		return d, true
+	}
+	for _, h := range []string{"X-RateLimit-Reset", "RateLimit-Reset"} {
+		if d, ok := resetAfter(resp.Header.Get(h), now); ok {
+			return d, true
+		}
+	}
+
+	return defaultBackoff, true
+}
+
+// retryAfter parses a Retry-After header, either delay seconds or an HTTP date.
+func retryAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return positive(t.Sub(now)), true
+	}
+	return 0, false
+}
+
+// resetAfter parses a rate limit reset header, either delay seconds or a unix
+// timestamp.
+func resetAfter(v string, now time.Time) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	secs, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+	// Values this large can only be timestamps.
+	if secs > 1e9 {
+		return positive(time.Unix(secs, 0).Sub(now)), true
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
+func positive(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
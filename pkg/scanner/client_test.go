@@ -0,0 +1,151 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a Kubesec API double answering with the given status, headers and body.
+func newTestServer(code int, headers map[string]string, body string, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		for k, v := range headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+// TestClient_Scan - tests parsing of results and detection of rate limit responses
+func TestClient_Scan(t *testing.T) {
+	tests := []struct {
+		name          string            // name of the test
+		code          int               // response status code
+		headers       map[string]string // response headers
+		body          string            // response body
+		wantErr       bool              // are we expecting an error
+		wantThrottled time.Duration     // expected backoff, zero when not throttled
+		wantScore     int               // expected score of the first result
+	}{
+		{
+			name:      "Valid response",
+			code:      http.StatusOK,
+			body:      `[{"score": 6, "scoring": {"passed": [{"id": "RunAsNonRoot", "points": 1}]}}]`,
+			wantScore: 6,
+		},
+		{
+			name:    "Server error",
+			code:    http.StatusInternalServerError,
+			wantErr: true,
+		},
+		{
+			name:          "Too many requests with Retry-After seconds",
+			code:          http.StatusTooManyRequests,
+			headers:       map[string]string{"Retry-After": "120"},
+			wantErr:       true,
+			wantThrottled: 120 * time.Second,
+		},
+		{
+			name:          "Too many requests without hint",
+			code:          http.StatusTooManyRequests,
+			wantErr:       true,
+			wantThrottled: defaultBackoff,
+		},
+		{
+			name:          "Service unavailable with Retry-After",
+			code:          http.StatusServiceUnavailable,
+			headers:       map[string]string{"Retry-After": "10"},
+			wantErr:       true,
+			wantThrottled: 10 * time.Second,
+		},
+		{
+			name:          "Exhausted quota with reset delay",
+			code:          http.StatusForbidden,
+			headers:       map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "45"},
+			wantErr:       true,
+			wantThrottled: 45 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls int32
+			server := newTestServer(tt.code, tt.headers, tt.body, &calls)
+			defer server.Close()
+
+			now := time.Now()
+			c := NewClient(server.URL, 5*time.Second)
+			c.now = func() time.Time { return now }
+
+			results, err := c.Scan(context.Background(), []byte("kind: Pod"))
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan - got error %v, but wanted %v", err, tt.wantErr)
+			}
+			if throttled := errors.Is(err, ErrThrottled); throttled != (tt.wantThrottled != 0) {
+				t.Fatalf("Scan - throttled mismatch, want=%v, got=%v (%v)", tt.wantThrottled != 0, throttled, err)
+			}
+			if tt.wantThrottled != 0 {
+				if got := c.ThrottledUntil().Sub(now); got != tt.wantThrottled {
+					t.Fatalf("Scan - backoff mismatch, want=%v, got=%v", tt.wantThrottled, got)
+				}
+
+				// The next scan must not reach the backend.
+				if _, err := c.Scan(context.Background(), []byte("kind: Pod")); !errors.Is(err, ErrThrottled) {
+					t.Fatalf("Scan - want throttled error during backoff, got %v", err)
+				}
+				if calls != 1 {
+					t.Fatalf("Scan - backend called %d times during backoff", calls)
+				}
+			}
+			if !tt.wantErr && results[0].Score != tt.wantScore {
+				t.Fatalf("Scan - score mismatch, want=%d, got=%d", tt.wantScore, results[0].Score)
+			}
+		})
+	}
+}
+
+// TestClient_ThrottleExpires - tests that scans resume once the backoff elapsed
+func TestClient_ThrottleExpires(t *testing.T) {
+	var calls int32
+	server := newTestServer(http.StatusOK, nil, `[{"score": 1}]`, &calls)
+	defer server.Close()
+
+	now := time.Now()
+	c := NewClient(server.URL, 5*time.Second)
+	c.now = func() time.Time { return now }
+	c.throttle(time.Minute)
+
+	if _, err := c.Scan(context.Background(), nil); !errors.Is(err, ErrThrottled) {
+		t.Fatalf("Scan - want throttled error, got %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := c.Scan(context.Background(), nil); err != nil {
+		t.Fatalf("Scan - want no error after backoff, got %v", err)
+	}
+}
+
+// TestNewTransport - tests the transport settings are taken from the configuration
+func TestNewTransport(t *testing.T) {
+	cfg := DefaultTransportConfig()
+	cfg.MaxIdleConnsPerHost = 64
+	cfg.DisableKeepAlives = true
+
+	tr := NewTransport(cfg)
+	if tr.MaxIdleConnsPerHost != 64 || tr.MaxIdleConns != 64 {
+		t.Fatalf("NewTransport - want 64 idle connections, got per host=%d total=%d", tr.MaxIdleConnsPerHost, tr.MaxIdleConns)
+	}
+	if !tr.DisableKeepAlives || tr.IdleConnTimeout != cfg.IdleConnTimeout || tr.TLSHandshakeTimeout != cfg.TLSHandshakeTimeout {
+		t.Fatalf("NewTransport - settings mismatch, got %+v", tr)
+	}
+}
@@ -0,0 +1,66 @@
+package scanner
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Dedup is a scanner sharing one scan between the concurrent callers scanning
+// the same definition, e.g. a Deployment creating many identical Pods at once.
+// The shared scan runs detached from the callers, bounded by the timeout, so
+// a caller giving up does not fail the scan of the others; each caller stops
+// waiting when its own context is done.
+type Dedup struct {
+	next    Scanner
+	timeout time.Duration
+	group   singleflight.Group
+}
+
+// NewDedup returns a scanner deduplicating the concurrent scans of next, a
+// shared scan is given up after timeout.
+func NewDedup(next Scanner, timeout time.Duration) (*Dedup, error) {
+	if timeout <= 0 {
+		return nil, fmt.Errorf("dedup timeout must be positive")
+	}
+	return &Dedup{next: next, timeout: timeout}, nil
+}
+
+// Next returns the scanner behind the deduplication.
+func (d *Dedup) Next() Scanner {
+	return d.next
+}
+
+// Scan satisfies Scanner interface.
+func (d *Dedup) Scan(ctx context.Context, def []byte) (Results, error) {
+	sum := sha256.Sum256(def)
+	ch := d.group.DoChan(string(sum[:]), func() (interface{}, error) {
+		sctx, cancel := context.WithTimeout(detached{ctx}, d.timeout)
+		defer cancel()
+		return d.next.Scan(sctx, def)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.(Results), nil
+	}
+}
+
+// detached is a context keeping the values of its parent, e.g. the request
+// ID, but neither its deadline nor its cancellation.
+type detached struct {
+	parent context.Context
+}
+
+func (d detached) Deadline() (time.Time, bool)       { return time.Time{}, false }
+func (d detached) Done() <-chan struct{}             { return nil }
+func (d detached) Err() error                        { return nil }
+func (d detached) Value(key interface{}) interface{} { return d.parent.Value(key) }
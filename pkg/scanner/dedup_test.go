@@ -0,0 +1,133 @@
+package scanner
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingScanner counts the scans and answers once released, or fails once
+// the context of the scan is done.
+type blockingScanner struct {
+	scans   int32
+	release chan struct{}
+}
+
+func (b *blockingScanner) Scan(ctx context.Context, def []byte) (Results, error) {
+	atomic.AddInt32(&b.scans, 1)
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-b.release:
+		return Results{{Score: len(def)}}, nil
+	}
+}
+
+// TestDedup_Scan - tests concurrent scans of the same definition share one scan
+func TestDedup_Scan(t *testing.T) {
+	next := &blockingScanner{release: make(chan struct{})}
+	d, err := NewDedup(next, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	scores := make([]int, 10)
+	for i := range scores {
+		i := i
+		def := "abc"
+		if i%2 == 1 {
+			def = "abcd"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := d.Scan(context.Background(), []byte(def))
+			if err == nil && len(res) == 1 {
+				scores[i] = res[0].Score
+			}
+		}()
+	}
+
+	// Let every caller join its flight before the scans complete.
+	time.Sleep(50 * time.Millisecond)
+	close(next.release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&next.scans); got != 2 {
+		t.Fatalf("Scan - want one scan per definition, got %d", got)
+	}
+	for i, s := range scores {
+		if want := 3 + i%2; s != want {
+			t.Fatalf("Scan - caller %d want score %d, got %d", i, want, s)
+		}
+	}
+}
+
+// TestDedup_Scan_canceled - tests a caller stops waiting once its context is done
+func TestDedup_Scan_canceled(t *testing.T) {
+	next := &blockingScanner{release: make(chan struct{})}
+	defer close(next.release)
+	d, err := NewDedup(next, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := d.Scan(ctx, []byte("abc")); err == nil {
+		t.Fatalf("Scan - want error once the context is done")
+	}
+}
+
+// TestDedup_Scan_firstCanceled - tests the shared scan goes on for the other callers once the first one gave up
+func TestDedup_Scan_firstCanceled(t *testing.T) {
+	next := &blockingScanner{release: make(chan struct{})}
+	d, err := NewDedup(next, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error)
+	go func() {
+		_, err := d.Scan(ctx, []byte("abc"))
+		first <- err
+	}()
+	// Let the first caller start the scan before the second joins it.
+	time.Sleep(20 * time.Millisecond)
+	second := make(chan Results)
+	go func() {
+		res, _ := d.Scan(context.Background(), []byte("abc"))
+		second <- res
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	cancel()
+	if err := <-first; err == nil {
+		t.Fatalf("Scan - want error for the canceled caller")
+	}
+	close(next.release)
+	if res := <-second; len(res) != 1 || res[0].Score != 3 {
+		t.Fatalf("Scan - want the shared result for the second caller, got %v", res)
+	}
+	if got := atomic.LoadInt32(&next.scans); got != 1 {
+		t.Fatalf("Scan - want one shared scan, got %d", got)
+	}
+}
+
+// TestDedup_Scan_timeout - tests the shared scan is given up after the timeout
+func TestDedup_Scan_timeout(t *testing.T) {
+	next := &blockingScanner{release: make(chan struct{})}
+	defer close(next.release)
+	d, err := NewDedup(next, 20*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Scan(context.Background(), []byte("abc")); err == nil {
+		t.Fatalf("Scan - want error once the timeout is over")
+	}
+}
@@ -0,0 +1,301 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// Annotations read by the embedded checks.
+const (
+	apparmorAnnotationPrefix = "container.apparmor.security.beta.kubernetes.io/"
+	seccompPodAnnotation     = "seccomp.security.alpha.kubernetes.io/pod"
+)
+
+// check is a Kubesec check evaluated in process.
+type check struct {
+	Rule
+	match func(pod *corev1.Pod) bool
+}
+
+// checks mirror the Kubesec v2 ruleset. Container checks match when any
+// container, init containers included, matches.
+var checks = []check{
+	{
+		Rule: Rule{ID: "Privileged", Selector: "containers[] .securityContext .privileged == true", Reason: "Privileged containers can allow almost completely unrestricted host access", Points: -30},
+		match: anyContainer(func(c corev1.Container) bool {
+			return c.SecurityContext != nil && c.SecurityContext.Privileged != nil && *c.SecurityContext.Privileged
+		}),
+	},
+	{
+		Rule: Rule{ID: "CapSysAdmin", Selector: "containers[] .securityContext .capabilities .add == SYS_ADMIN", Reason: "CAP_SYS_ADMIN is the most privileged capability and should always be avoided", Points: -30},
+		match: anyContainer(func(c corev1.Container) bool {
+			return c.SecurityContext != nil && c.SecurityContext.Capabilities != nil && hasCapability(c.SecurityContext.Capabilities.Add, "SYS_ADMIN")
+		}),
+	},
+	{
+		Rule:  Rule{ID: "HostNetwork", Selector: ".spec .hostNetwork == true", Reason: "Sharing the host's network namespace permits processes in the pod to communicate with processes bound to the host's loopback adapter", Points: -9},
+		match: func(pod *corev1.Pod) bool { return pod.Spec.HostNetwork },
+	},
+	{
+		Rule:  Rule{ID: "HostPID", Selector: ".spec .hostPID == true", Reason: "Sharing the host's PID namespace allows visibility of processes on the host, potentially leaking information such as environment variables and configuration", Points: -9},
+		match: func(pod *corev1.Pod) bool { return pod.Spec.HostPID },
+	},
+	{
+		Rule:  Rule{ID: "HostIPC", Selector: ".spec .hostIPC == true", Reason: "Sharing the host's IPC namespace allows container processes to communicate with processes on the host", Points: -9},
+		match: func(pod *corev1.Pod) bool { return pod.Spec.HostIPC },
+	},
+	{
+		Rule: Rule{ID: "DockerSock", Selector: `.spec .volumes[] .hostPath .path == "/var/run/docker.sock"`, Reason: "Mounting the docker.socket leaks information about other containers and can allow container breakout", Points: -9},
+		match: func(pod *corev1.Pod) bool {
+			for _, v := range pod.Spec.Volumes {
+				if v.HostPath != nil && v.HostPath.Path == "/var/run/docker.sock" {
+					return true
+				}
+			}
+			return false
+		},
+	},
+	{
+		Rule: Rule{ID: "AllowPrivilegeEscalation", Selector: "containers[] .securityContext .allowPrivilegeEscalation == true", Reason: "Ensure a non-root process can not gain more privileges", Points: -7},
+		match: anyContainer(func(c corev1.Container) bool {
+			return c.SecurityContext != nil && c.SecurityContext.AllowPrivilegeEscalation != nil && *c.SecurityContext.AllowPrivilegeEscalation
+		}),
+	},
+	{
+		Rule: Rule{ID: "ApparmorAny", Selector: `.metadata .annotations ."container.apparmor.security.beta.kubernetes.io/nginx"`, Reason: "Well defined AppArmor policies may provide greater protection from unknown threats. WARNING: NOT PRODUCTION READY", Points: 3},
+		match: func(pod *corev1.Pod) bool {
+			for k := range pod.Annotations {
+				if strings.HasPrefix(k, apparmorAnnotationPrefix) {
+					return true
+				}
+			}
+			return false
+		},
+	},
+	{
+		Rule:  Rule{ID: "ServiceAccountName", Selector: ".spec .serviceAccountName", Reason: "Service accounts restrict Kubernetes API access and should be configured with least privilege", Points: 3},
+		match: func(pod *corev1.Pod) bool { return pod.Spec.ServiceAccountName != "" },
+	},
+	{
+		Rule: Rule{ID: "SeccompAny", Selector: `.metadata .annotations ."container.seccomp.security.alpha.kubernetes.io/pod"`, Reason: "Seccomp profiles set minimum privilege and secure against unknown threats", Points: 1},
+		match: func(pod *corev1.Pod) bool {
+			if _, ok := pod.Annotations[seccompPodAnnotation]; ok {
+				return true
+			}
+			if sc := pod.Spec.SecurityContext; sc != nil && confined(sc.SeccompProfile) {
+				return true
+			}
+			return anyContainer(func(c corev1.Container) bool {
+				return c.SecurityContext != nil && confined(c.SecurityContext.SeccompProfile)
+			})(pod)
+		},
+	},
+	{
+		Rule: Rule{ID: "LimitsCPU", Selector: "containers[] .resources .limits .cpu", Reason: "Enforcing CPU limits prevents DOS via resource exhaustion", Points: 1},
+		match: anyContainer(func(c corev1.Container) bool {
+			_, ok := c.Resources.Limits[corev1.ResourceCPU]
+			return ok
+		}),
+	},
+	{
+		Rule: Rule{ID: "LimitsMemory", Selector: "containers[] .resources .limits .memory", Reason: "Enforcing memory limits prevents DOS via resource exhaustion", Points: 1},
+		match: anyContainer(func(c corev1.Container) bool {
+			_, ok := c.Resources.Limits[corev1.ResourceMemory]
+			return ok
+		}),
+	},
+	{
+		Rule: Rule{ID: "RequestsCPU", Selector: "containers[] .resources .requests .cpu", Reason: "Enforcing CPU requests aids a fair balancing of resources across the cluster", Points: 1},
+		match: anyContainer(func(c corev1.Container) bool {
+			_, ok := c.Resources.Requests[corev1.ResourceCPU]
+			return ok
+		}),
+	},
+	{
+		Rule: Rule{ID: "RequestsMemory", Selector: "containers[] .resources .requests .memory", Reason: "Enforcing memory requests aids a fair balancing of resources across the cluster", Points: 1},
+		match: anyContainer(func(c corev1.Container) bool {
+			_, ok := c.Resources.Requests[corev1.ResourceMemory]
+			return ok
+		}),
+	},
+	{
+		Rule: Rule{ID: "CapDropAny", Selector: "containers[] .securityContext .capabilities .drop", Reason: "Reducing kernel capabilities available to a container limits its attack surface", Points: 1},
+		match: anyContainer(func(c corev1.Container) bool {
+			return c.SecurityContext != nil && c.SecurityContext.Capabilities != nil && len(c.SecurityContext.Capabilities.Drop) > 0
+		}),
+	},
+	{
+		Rule: Rule{ID: "CapDropAll", Selector: `containers[] .securityContext .capabilities .drop | index("ALL")`, Reason: "Drop all capabilities and add only those required to reduce syscall attack surface", Points: 1},
+		match: anyContainer(func(c corev1.Container) bool {
+			return c.SecurityContext != nil && c.SecurityContext.Capabilities != nil && hasCapability(c.SecurityContext.Capabilities.Drop, "ALL")
+		}),
+	},
+	{
+		Rule: Rule{ID: "ReadOnlyRootFilesystem", Selector: "containers[] .securityContext .readOnlyRootFilesystem == true", Reason: "An immutable root filesystem can prevent malicious binaries being added to PATH and increase attack cost", Points: 1},
+		match: anyContainer(func(c corev1.Container) bool {
+			return c.SecurityContext != nil && c.SecurityContext.ReadOnlyRootFilesystem != nil && *c.SecurityContext.ReadOnlyRootFilesystem
+		}),
+	},
+	{
+		Rule: Rule{ID: "RunAsNonRoot", Selector: "containers[] .securityContext .runAsNonRoot == true", Reason: "Force the running image to run as a non-root user to ensure least privilege", Points: 1},
+		match: anyContainer(func(c corev1.Container) bool {
+			return c.SecurityContext != nil && c.SecurityContext.RunAsNonRoot != nil && *c.SecurityContext.RunAsNonRoot
+		}),
+	},
+	{
+		Rule: Rule{ID: "RunAsUser", Selector: "containers[] .securityContext .runAsUser -gt 10000", Reason: "Run as a high-UID user to avoid conflicts with the host's user table", Points: 1},
+		match: anyContainer(func(c corev1.Container) bool {
+			return c.SecurityContext != nil && c.SecurityContext.RunAsUser != nil && *c.SecurityContext.RunAsUser > 10000
+		}),
+	},
+	{
+		Rule: Rule{ID: "AutomountServiceAccountToken", Selector: ".spec .automountServiceAccountToken == false", Reason: "Disabling the automounting of Service Account Token reduces the attack surface of the API server", Points: 1},
+		match: func(pod *corev1.Pod) bool {
+			return pod.Spec.AutomountServiceAccountToken != nil && !*pod.Spec.AutomountServiceAccountToken
+		},
+	},
+}
+
+// Embedded scores definitions in process with the Kubesec v2 ruleset, without
+// reaching a Kubesec API. It is safe for concurrent use.
+type Embedded struct{}
+
+// NewEmbedded returns a new embedded scanner.
+func NewEmbedded() *Embedded {
+	return &Embedded{}
+}
+
+// Scan satisfies Scanner interface. The definition is a Pod, or a workload
+// embedding a pod template.
+func (e *Embedded) Scan(_ context.Context, def []byte) (Results, error) {
+	pod, err := decodePod(def)
+	if err != nil {
+		return Results{{Valid: false, Error: err.Error()}}, nil
+	}
+
+	return Results{score(pod)}, nil
+}
+
+// score evaluates the checks against the pod. As with Kubesec, a pod matching
+// critical checks scores the sum of their points only.
+func score(pod *corev1.Pod) Result {
+	res := Result{
+		Object: fmt.Sprintf("Pod/%s.%s", pod.Name, namespaceOr(pod.Namespace)),
+		Valid:  true,
+	}
+
+	var critical, passed int
+	for _, c := range checks {
+		matched := c.match(pod)
+		switch {
+		case c.Points < 0 && matched:
+			res.Scoring.Critical = append(res.Scoring.Critical, c.Rule)
+			critical += c.Points
+		case c.Points >= 0 && matched:
+			res.Scoring.Passed = append(res.Scoring.Passed, c.Rule)
+			passed += c.Points
+		case c.Points >= 0:
+			res.Scoring.Advise = append(res.Scoring.Advise, c.Rule)
+		}
+	}
+
+	res.Score = passed
+	if len(res.Scoring.Critical) > 0 {
+		res.Score = critical
+	}
+	if res.Score < 0 {
+		res.Message = fmt.Sprintf("Failed with a score of %d points", res.Score)
+	} else {
+		res.Message = fmt.Sprintf("Passed with a score of %d points", res.Score)
+	}
+
+	return res
+}
+
+// decodePod returns the pod of the definition, the template of workloads.
+func decodePod(def []byte) (*corev1.Pod, error) {
+	var obj struct {
+		Kind string `json:"kind"`
+	}
+	if err := yaml.Unmarshal(def, &obj); err != nil {
+		return nil, fmt.Errorf("invalid definition: %w", err)
+	}
+
+	if obj.Kind == "Pod" {
+		pod := &corev1.Pod{}
+		if err := yaml.Unmarshal(def, pod); err != nil {
+			return nil, fmt.Errorf("invalid pod: %w", err)
+		}
+		return pod, nil
+	}
+
+	var workload struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Spec struct {
+			Template    *corev1.PodTemplateSpec `json:"template"`
+			JobTemplate *struct {
+				Spec struct {
+					Template *corev1.PodTemplateSpec `json:"template"`
+				} `json:"spec"`
+			} `json:"jobTemplate"`
+		} `json:"spec"`
+	}
+	if err := yaml.Unmarshal(def, &workload); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", obj.Kind, err)
+	}
+
+	tpl := workload.Spec.Template
+	if tpl == nil && workload.Spec.JobTemplate != nil {
+		tpl = workload.Spec.JobTemplate.Spec.Template
+	}
+	if tpl == nil {
+		return nil, fmt.Errorf("%s has no pod template", obj.Kind)
+	}
+
+	pod := &corev1.Pod{ObjectMeta: tpl.ObjectMeta, Spec: tpl.Spec}
+	pod.Name, pod.Namespace = workload.Metadata.Name, workload.Metadata.Namespace
+	return pod, nil
+}
+
+func anyContainer(match func(c corev1.Container) bool) func(pod *corev1.Pod) bool {
+	return func(pod *corev1.Pod) bool {
+		for _, c := range pod.Spec.InitContainers {
+			if match(c) {
+				return true
+			}
+		}
+		for _, c := range pod.Spec.Containers {
+			if match(c) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func hasCapability(caps []corev1.Capability, name string) bool {
+	for _, c := range caps {
+		if strings.EqualFold(string(c), name) {
+			return true
+		}
+	}
+	return false
+}
+
+func confined(p *corev1.SeccompProfile) bool {
+	return p != nil && p.Type != corev1.SeccompProfileTypeUnconfined
+}
+
+func namespaceOr(ns string) string {
+	if ns == "" {
+		return "default"
+	}
+	return ns
+}
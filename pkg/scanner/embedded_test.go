@@ -0,0 +1,162 @@
+package scanner
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// TestEmbedded_Scan - tests the in process scoring of definitions
+func TestEmbedded_Scan(t *testing.T) {
+	tests := []struct {
+		name         string   // name of the test
+		def          string   // scanned definition
+		wantScore    int      // expected score
+		wantCritical []string // expected critical checks
+		wantPassed   []string // expected passed checks
+		wantErr      bool     // is the result expected to carry an error
+	}{
+		{
+			name: "Privileged pod scores its critical checks only",
+			def: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: test
+spec:
+  hostNetwork: true
+  containers:
+  - name: app
+    image: nginx
+    resources:
+      limits:
+        cpu: 100m
+    securityContext:
+      privileged: true
+`,
+			wantScore:    -39,
+			wantCritical: []string{"Privileged", "HostNetwork"},
+			wantPassed:   []string{"LimitsCPU"},
+		},
+		{
+			name: "Hardened pod",
+			def: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: test
+  annotations:
+    container.apparmor.security.beta.kubernetes.io/app: runtime/default
+spec:
+  serviceAccountName: app
+  automountServiceAccountToken: false
+  securityContext:
+    seccompProfile:
+      type: RuntimeDefault
+  containers:
+  - name: app
+    image: nginx
+    securityContext:
+      runAsNonRoot: true
+      runAsUser: 10001
+      readOnlyRootFilesystem: true
+      capabilities:
+        drop: ["all"]
+`,
+			wantScore:  13,
+			wantPassed: []string{"ApparmorAny", "ServiceAccountName", "SeccompAny", "CapDropAny", "CapDropAll", "ReadOnlyRootFilesystem", "RunAsNonRoot", "RunAsUser", "AutomountServiceAccountToken"},
+		},
+		{
+			name: "Init containers are scanned",
+			def: `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: test
+spec:
+  initContainers:
+  - name: init
+    image: busybox
+    securityContext:
+      capabilities:
+        add: ["SYS_ADMIN"]
+  containers:
+  - name: app
+    image: nginx
+`,
+			wantScore:    -30,
+			wantCritical: []string{"CapSysAdmin"},
+		},
+		{
+			name: "Pod template of a CronJob",
+			def: `
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: test
+spec:
+  schedule: "* * * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          volumes:
+          - name: docker
+            hostPath:
+              path: /var/run/docker.sock
+          containers:
+          - name: app
+            image: nginx
+`,
+			wantScore:    -9,
+			wantCritical: []string{"DockerSock"},
+		},
+		{
+			name: "Kind without pod template",
+			def: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: test
+`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			results, err := NewEmbedded().Scan(context.Background(), []byte(tt.def))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 {
+				t.Fatalf("Scan - want 1 result, got %d", len(results))
+			}
+			res := results[0]
+			if got := res.Error != ""; got != tt.wantErr {
+				t.Fatalf("Scan - want error %v, got %q", tt.wantErr, res.Error)
+			}
+			if tt.wantErr {
+				return
+			}
+			if res.Score != tt.wantScore {
+				t.Fatalf("Scan - score mismatch, want=%d, got=%d", tt.wantScore, res.Score)
+			}
+			if got := ids(res.Scoring.Critical); !reflect.DeepEqual(got, tt.wantCritical) {
+				t.Fatalf("Scan - critical mismatch, want=%v, got=%v", tt.wantCritical, got)
+			}
+			if got := ids(res.Scoring.Passed); !reflect.DeepEqual(got, tt.wantPassed) {
+				t.Fatalf("Scan - passed mismatch, want=%v, got=%v", tt.wantPassed, got)
+			}
+		})
+	}
+}
+
+func ids(rules []Rule) []string {
+	var res []string
+	for _, r := range rules {
+		res = append(res, r.ID)
+	}
+	return res
+}
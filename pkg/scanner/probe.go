@@ -0,0 +1,115 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// probeDefinition is the minimal Pod the backends are probed with.
+var probeDefinition = []byte(`{"apiVersion":"v1","kind":"Pod","metadata":{"name":"kubesec-probe"},"spec":{"containers":[{"name":"probe","image":"probe"}]}}`)
+
+// ProbeRecorder records the availability of the backend.
+type ProbeRecorder interface {
+	// SetScanBackendUp sets whether the backend passed its last probe.
+	SetScanBackendUp(backend string, up bool)
+}
+
+// Probe checks a scanner can score definitions, to gate the readiness of the
+// webhook and to report the availability of the backend. It fails while a
+// circuit breaker of the scanner is open, and otherwise asks the innermost
+// scanner, past the caches: to be healthy when it tells, e.g. a Sidecar, or to
+// score a minimal Pod. The outcome is kept for interval, so the backend is not
+// probed by every readiness check.
+type Probe struct {
+	scanner  Scanner
+	interval time.Duration
+	backend  string
+	recorder ProbeRecorder
+	now      func() time.Time
+
+	mu      sync.Mutex
+	checked time.Time
+	err     error
+}
+
+// NewProbe returns a probe of the scanner of the backend.
+func NewProbe(scanner Scanner, interval time.Duration, backend string, recorder ProbeRecorder) (*Probe, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("scanner probe interval must be positive")
+	}
+	return &Probe{scanner: scanner, interval: interval, backend: backend, recorder: recorder, now: time.Now}, nil
+}
+
+// Check returns an error unless the scanner can score definitions.
+func (p *Probe) Check(ctx context.Context) error {
+	return p.check(ctx, false)
+}
+
+// Start probes the backend every interval until the context is done, so its
+// availability is recorded even when nothing is admitted.
+func (p *Probe) Start(ctx context.Context) error {
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+
+	for {
+		_ = p.check(ctx, true)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager every replica probes its backend.
+func (p *Probe) NeedLeaderElection() bool {
+	return false
+}
+
+// check returns the outcome of the last probe, or probes the backend when it
+// is older than the interval or when forced.
+func (p *Probe) check(ctx context.Context, force bool) error {
+	sc := p.scanner
+	for {
+		if b, ok := sc.(*Breaker); ok && b.State() == BreakerOpen {
+			p.recorder.SetScanBackendUp(p.backend, false)
+			return ErrCircuitOpen
+		}
+		next, ok := sc.(interface{ Next() Scanner })
+		if !ok {
+			break
+		}
+		sc = next.Next()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !force && !p.checked.IsZero() && p.now().Sub(p.checked) < p.interval {
+		return p.err
+	}
+	p.err = probe(ctx, sc)
+	p.checked = p.now()
+	p.recorder.SetScanBackendUp(p.backend, p.err == nil)
+	return p.err
+}
+
+func probe(ctx context.Context, sc Scanner) error {
+	if h, ok := sc.(interface{ Healthy(context.Context) error }); ok {
+		return h.Healthy(ctx)
+	}
+
+	results, err := sc.Scan(ctx, probeDefinition)
+	if err != nil {
+		return fmt.Errorf("kubesec backend probe failed: %w", err)
+	}
+	if len(results) == 0 {
+		return errors.New("kubesec backend probe failed: no result")
+	}
+	if results[0].Error != "" {
+		return fmt.Errorf("kubesec backend probe failed: %s", results[0].Error)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// availability records the outcomes of the probes.
+type availability []bool
+
+func (a *availability) SetScanBackendUp(_ string, up bool) {
+	*a = append(*a, up)
+}
+
+// TestProbe_Check - tests the innermost scanner is probed past the caches, at most once per interval
+func TestProbe_Check(t *testing.T) {
+	ctx := context.Background()
+	next := &stubScanner{}
+	cache, err := NewCache(next, 10, time.Hour, &lookups{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ups := &availability{}
+	p, err := NewProbe(cache, time.Minute, "remote", ups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	p.now = func() time.Time { return now }
+
+	if err := p.Check(ctx); err != nil {
+		t.Fatal(err)
+	}
+	next.err = errors.New("connection refused")
+	if err := p.Check(ctx); err != nil {
+		t.Fatalf("Check - want the outcome kept for the interval, got %v", err)
+	}
+	if next.scans != 1 {
+		t.Fatalf("Check - want 1 scan, got %d", next.scans)
+	}
+
+	now = now.Add(time.Minute)
+	if err := p.Check(ctx); err == nil {
+		t.Fatal("Check - want the backend error")
+	}
+	// The cache does not hide the failing backend.
+	if next.scans != 2 {
+		t.Fatalf("Check - want the backend probed past the cache, got %d scans", next.scans)
+	}
+	if len(*ups) != 2 || !(*ups)[0] || (*ups)[1] {
+		t.Fatalf("Check - want the backend up then down, got %v", *ups)
+	}
+
+	next.err, next.fail = nil, true
+	now = now.Add(time.Minute)
+	if err := p.Check(ctx); err == nil {
+		t.Fatal("Check - want the scan error")
+	}
+}
+
+// TestProbe_CheckBreaker - tests an open breaker fails the probe without reaching the backend
+func TestProbe_CheckBreaker(t *testing.T) {
+	next := &stubScanner{err: errors.New("connection refused")}
+	b, err := NewBreaker(next, 1, time.Minute, &breakerStates{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Scan(context.Background(), []byte("abc")); err == nil {
+		t.Fatal("Scan - want the backend error")
+	}
+	ups := &availability{}
+	p, err := NewProbe(b, time.Minute, "remote", ups)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Check(context.Background()); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Check - want ErrCircuitOpen, got %v", err)
+	}
+	if next.scans != 1 || len(*ups) != 1 || (*ups)[0] {
+		t.Fatalf("Check - want no probe of the backend and the backend down, got %d scans, %v", next.scans, *ups)
+	}
+}
+
+// TestProbe_Start - tests the backend is probed until the context is done
+func TestProbe_Start(t *testing.T) {
+	next := &stubScanner{}
+	ups := &availability{}
+	p, err := NewProbe(next, time.Hour, "embedded", ups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := p.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if next.scans != 1 || len(*ups) != 1 || !(*ups)[0] {
+		t.Fatalf("Start - want the backend probed up once, got %d scans, %v", next.scans, *ups)
+	}
+}
@@ -0,0 +1,265 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+)
+
+// redisKeyPrefix prefixes the keys of the scan results in Redis.
+const redisKeyPrefix = "kubesec:scan:"
+
+// errRedisNil is the nil reply of a GET of a missing key.
+var errRedisNil = errors.New("redis: nil")
+
+// RedisConfig is the configuration of the Redis server the scan results are
+// shared through.
+type RedisConfig struct {
+	// Address is the host:port of the server.
+	Address  string
+	Username string
+	Password string
+	DB       int
+	// TLS is the TLS configuration of the connections, plaintext when nil.
+	TLS *tls.Config
+	// PoolSize is the number of idle connections kept open.
+	PoolSize int
+	// Timeout bounds every command, Redis is skipped when it is slower.
+	Timeout time.Duration
+}
+
+// RedisCache is a scanner sharing the results of the definitions it scanned
+// through Redis, so all the replicas of the webhook benefit from each other's
+// scans. Keys are the hash of the definition and expire after a TTL. Redis
+// failures are logged and the definition is scanned as if it was not cached.
+type RedisCache struct {
+	next     Scanner
+	cfg      RedisConfig
+	ttl      time.Duration
+	recorder CacheRecorder
+	logger   log.Logger
+	pool     chan *redisConn
+}
+
+// NewRedisCache returns a Redis cache in front of next.
+func NewRedisCache(next Scanner, cfg RedisConfig, ttl time.Duration, recorder CacheRecorder, logger log.Logger) (*RedisCache, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("redis address can't be empty")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("scan cache TTL must be positive")
+	}
+	if cfg.PoolSize < 1 {
+		cfg.PoolSize = 8
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = time.Second
+	}
+
+	return &RedisCache{
+		next:     next,
+		cfg:      cfg,
+		ttl:      ttl,
+		recorder: recorder,
+		logger:   logger,
+		pool:     make(chan *redisConn, cfg.PoolSize),
+	}, nil
+}
+
+// Next returns the scanner behind the cache.
+func (r *RedisCache) Next() Scanner {
+	return r.next
+}
+
+// Scan satisfies Scanner interface.
+func (r *RedisCache) Scan(ctx context.Context, def []byte) (Results, error) {
+	sum := sha256.Sum256(def)
+	key := redisKeyPrefix + hex.EncodeToString(sum[:])
+
+	raw, err := r.do(ctx, "GET", key)
+	switch {
+	case err == nil:
+		var results Results
+		if err := json.Unmarshal(raw, &results); err == nil {
+			r.recorder.IncScanCache(CacheRedis, true)
+			return results, nil
+		}
+		r.logger.Warningf("ignoring invalid cached scan result %s", key)
+	case !errors.Is(err, errRedisNil):
+		r.logger.Warningf("could not read cached scan result: %v", err)
+	}
+	r.recorder.IncScanCache(CacheRedis, false)
+
+	results, err := r.next.Scan(ctx, def)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range results {
+		if res.Error != "" {
+			return results, nil
+		}
+	}
+
+	raw, err = json.Marshal(results)
+	if err != nil {
+		return results, nil
+	}
+	if _, err := r.do(ctx, "SET", key, string(raw), "PX", strconv.FormatInt(r.ttl.Milliseconds(), 10)); err != nil {
+		r.logger.Warningf("could not cache scan result: %v", err)
+	}
+
+	return results, nil
+}
+
+// do sends the command on a pooled connection and returns its reply.
+func (r *RedisCache) do(ctx context.Context, args ...string) ([]byte, error) {
+	conn, err := r.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(r.cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	reply, err := conn.do(args...)
+	if err != nil && !errors.Is(err, errRedisNil) && !isRedisError(err) {
+		// The state of the connection is unknown.
+		conn.Close()
+		return nil, err
+	}
+
+	select {
+	case r.pool <- conn:
+	default:
+		conn.Close()
+	}
+	return reply, err
+}
+
+// conn returns an idle connection of the pool, or a new one.
+func (r *RedisCache) conn(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-r.pool:
+		return conn, nil
+	default:
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+
+	var c net.Conn
+	var err error
+	if r.cfg.TLS != nil {
+		d := &tls.Dialer{Config: r.cfg.TLS}
+		c, err = d.DialContext(ctx, "tcp", r.cfg.Address)
+	} else {
+		var d net.Dialer
+		c, err = d.DialContext(ctx, "tcp", r.cfg.Address)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conn := &redisConn{Conn: c, r: bufio.NewReader(c)}
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(dl)
+	}
+	if r.cfg.Password != "" {
+		args := []string{"AUTH", r.cfg.Password}
+		if r.cfg.Username != "" {
+			args = []string{"AUTH", r.cfg.Username, r.cfg.Password}
+		}
+		if _, err := conn.do(args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis authentication failed: %w", err)
+		}
+	}
+	if r.cfg.DB != 0 {
+		if _, err := conn.do("SELECT", strconv.Itoa(r.cfg.DB)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// redisError is an error reply of the server.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+func isRedisError(err error) bool {
+	var re redisError
+	return errors.As(err, &re)
+}
+
+// redisConn speaks the RESP protocol, enough of it to get and set keys.
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *redisConn) do(args ...string) ([]byte, error) {
+	buf := []byte("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		buf = append(buf, "$"+strconv.Itoa(len(a))+"\r\n"...)
+		buf = append(buf, a...)
+		buf = append(buf, "\r\n"...)
+	}
+	if _, err := c.Write(buf); err != nil {
+		return nil, err
+	}
+
+	return c.reply()
+}
+
+// reply reads a reply, the value of bulk strings and the text of the others.
+func (c *redisConn) reply() ([]byte, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: invalid reply %q", line)
+	}
+	kind, text := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+', ':':
+		return []byte(text), nil
+	case '-':
+		return nil, redisError(text)
+	case '$':
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, fmt.Errorf("redis: invalid bulk length %q", text)
+		}
+		if n < 0 {
+			return nil, errRedisNil
+		}
+		data := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, data); err != nil {
+			return nil, err
+		}
+		return data[:n], nil
+	default:
+		return nil, fmt.Errorf("redis: unsupported reply %q", line)
+	}
+}
@@ -0,0 +1,183 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+)
+
+// fakeRedis is a Redis double supporting AUTH, SELECT, GET and SET.
+type fakeRedis struct {
+	password string
+
+	mu   sync.Mutex
+	keys map[string]string
+	ttls map[string]string
+}
+
+func newFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	f := &fakeRedis{password: password, keys: map[string]string{}, ttls: map[string]string{}}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(c)
+		}
+	}()
+	return f, l.Addr().String()
+}
+
+func (f *fakeRedis) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "AUTH":
+			authed = args[len(args)-1] == f.password
+			if authed {
+				fmt.Fprint(c, "+OK\r\n")
+			} else {
+				fmt.Fprint(c, "-WRONGPASS invalid password\r\n")
+			}
+		case !authed:
+			fmt.Fprint(c, "-NOAUTH Authentication required.\r\n")
+		case cmd == "SELECT":
+			fmt.Fprint(c, "+OK\r\n")
+		case cmd == "GET":
+			if v, ok := f.keys[args[1]]; ok {
+				fmt.Fprintf(c, "$%d\r\n%s\r\n", len(v), v)
+			} else {
+				fmt.Fprint(c, "$-1\r\n")
+			}
+		case cmd == "SET":
+			f.keys[args[1]] = args[2]
+			f.ttls[args[1]] = args[4]
+			fmt.Fprint(c, "+OK\r\n")
+		default:
+			fmt.Fprintf(c, "-ERR unknown command %s\r\n", cmd)
+		}
+		f.mu.Unlock()
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		l, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, l+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:l])
+	}
+	return args, nil
+}
+
+// TestRedisCache_Scan - tests the scan results are shared through Redis
+func TestRedisCache_Scan(t *testing.T) {
+	srv, addr := newFakeRedis(t, "secret")
+	cfg := RedisConfig{Address: addr, Password: "secret", DB: 2}
+
+	// Two replicas share the results.
+	first, second := &stubScanner{}, &stubScanner{}
+	rec := &lookups{}
+	c1, err := NewRedisCache(first, cfg, time.Minute, rec, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := NewRedisCache(second, cfg, time.Minute, rec, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []*RedisCache{c1, c2, c1} {
+		res, err := c.Scan(context.Background(), []byte("abc"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 1 || res[0].Score != 3 {
+			t.Fatalf("Scan - unexpected results %+v", res)
+		}
+	}
+	if first.scans != 1 || second.scans != 0 || rec.hits != 2 || rec.misses != 1 {
+		t.Fatalf("Scan - want a single scan and 2 hits, got %d and %d scans, %+v", first.scans, second.scans, rec)
+	}
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if len(srv.keys) != 1 {
+		t.Fatalf("Scan - want 1 key, got %v", srv.keys)
+	}
+	for k, ttl := range srv.ttls {
+		if !strings.HasPrefix(k, redisKeyPrefix) || ttl != "60000" {
+			t.Fatalf("Scan - unexpected key %q with TTL %s", k, ttl)
+		}
+	}
+}
+
+// TestRedisCache_Scan_unavailable - tests definitions are scanned when Redis fails
+func TestRedisCache_Scan_unavailable(t *testing.T) {
+	_, addr := newFakeRedis(t, "secret")
+	tests := []struct {
+		name string
+		cfg  RedisConfig
+	}{
+		{name: "wrong password", cfg: RedisConfig{Address: addr, Password: "wrong"}},
+		{name: "unreachable", cfg: RedisConfig{Address: "127.0.0.1:1", Timeout: 100 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		next := &stubScanner{}
+		c, err := NewRedisCache(next, tt.cfg, time.Minute, &lookups{}, log.Dummy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			res, err := c.Scan(context.Background(), []byte("abc"))
+			if err != nil || len(res) != 1 || res[0].Score != 3 {
+				t.Fatalf("%s - want the scan result, got %+v, %v", tt.name, res, err)
+			}
+		}
+		if next.scans != 2 {
+			t.Fatalf("%s - want every definition scanned, got %d scans", tt.name, next.scans)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// RetryRecorder records the retries of the scans.
+type RetryRecorder interface {
+	// IncScanRetry increments the counter of retried scans.
+	IncScanRetry()
+}
+
+// RetryConfig is the retry policy of the scans.
+type RetryConfig struct {
+	// Retries is the number of retries after a failed scan.
+	Retries int
+	// Backoff is the delay before the first retry, doubled on every retry.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between two retries.
+	MaxBackoff time.Duration
+	// Jitter randomizes the delays by up to this fraction, e.g. 0.2 for ±20%.
+	Jitter float64
+}
+
+// Retry is a scanner retrying the transient failures of next, network errors
+// and 5xx responses, with an exponential backoff. Rate limited scans are not
+// retried, the backend asked to back off for longer than a retry would wait.
+type Retry struct {
+	next     Scanner
+	cfg      RetryConfig
+	recorder RetryRecorder
+	sleep    func(ctx context.Context, d time.Duration) error
+}
+
+// NewRetry returns a scanner retrying the scans of next.
+func NewRetry(next Scanner, cfg RetryConfig, recorder RetryRecorder) (*Retry, error) {
+	if cfg.Retries < 0 {
+		return nil, fmt.Errorf("scan retries can't be negative")
+	}
+	if cfg.Backoff <= 0 {
+		return nil, fmt.Errorf("scan retry backoff must be positive")
+	}
+	if cfg.MaxBackoff < cfg.Backoff {
+		cfg.MaxBackoff = cfg.Backoff
+	}
+	if cfg.Jitter < 0 || cfg.Jitter > 1 {
+		return nil, fmt.Errorf("scan retry jitter must be between 0 and 1")
+	}
+
+	return &Retry{
+		next:     next,
+		cfg:      cfg,
+		recorder: recorder,
+		sleep:    sleep,
+	}, nil
+}
+
+// Next returns the scanner behind the retries.
+func (r *Retry) Next() Scanner {
+	return r.next
+}
+
+// Scan satisfies Scanner interface.
+func (r *Retry) Scan(ctx context.Context, def []byte) (Results, error) {
+	backoff := r.cfg.Backoff
+	for attempt := 0; ; attempt++ {
+		results, err := r.next.Scan(ctx, def)
+		if err == nil || attempt >= r.cfg.Retries || !transient(ctx, err) {
+			return results, err
+		}
+
+		if err := r.sleep(ctx, r.jitter(backoff)); err != nil {
+			return nil, err
+		}
+		r.recorder.IncScanRetry()
+
+		if backoff *= 2; backoff > r.cfg.MaxBackoff {
+			backoff = r.cfg.MaxBackoff
+		}
+	}
+}
+
+func (r *Retry) jitter(d time.Duration) time.Duration {
+	if r.cfg.Jitter == 0 {
+		return d
+	}
+	//nolint:gosec
+	return time.Duration(float64(d) * (1 + r.cfg.Jitter*(2*rand.Float64()-1)))
+}
+
+// transient tells whether the failed scan is worth retrying.
+func transient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrThrottled) {
+		return false
+	}
+
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.Code >= 500
+	}
+	var ne net.Error
+	return errors.As(err, &ne)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
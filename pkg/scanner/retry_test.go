@@ -0,0 +1,98 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// retries counts the retried scans.
+type retries int
+
+func (r *retries) IncScanRetry() {
+	*r++
+}
+
+// TestRetry_Scan - tests transient failures are retried with an exponential backoff
+func TestRetry_Scan(t *testing.T) {
+	tests := []struct {
+		name       string   // name of the test
+		codes      []int    // status of the successive responses
+		retries    int      // configured retries
+		wantErr    bool     // are we expecting an error
+		wantCalls  int32    // expected calls to the backend
+		wantDelays []string // expected backoff between the calls
+	}{
+		{name: "success", codes: []int{200}, retries: 3, wantCalls: 1},
+		{name: "5xx then success", codes: []int{502, 500, 200}, retries: 3, wantCalls: 3, wantDelays: []string{"100ms", "200ms"}},
+		{name: "retries exhausted", codes: []int{500, 500, 500, 500, 500}, retries: 3, wantErr: true, wantCalls: 4, wantDelays: []string{"100ms", "200ms", "250ms"}},
+		{name: "4xx not retried", codes: []int{400, 200}, retries: 3, wantErr: true, wantCalls: 1},
+		{name: "rate limit not retried", codes: []int{429, 200}, retries: 3, wantErr: true, wantCalls: 1},
+		{name: "no retries", codes: []int{500, 200}, retries: 0, wantErr: true, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var calls int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				code := tt.codes[atomic.AddInt32(&calls, 1)-1]
+				w.WriteHeader(code)
+				if code == http.StatusOK {
+					_, _ = w.Write([]byte(`[{"score": 1}]`))
+				}
+			}))
+			defer srv.Close()
+
+			var rec retries
+			r, err := NewRetry(NewClient(srv.URL, time.Second), RetryConfig{Retries: tt.retries, Backoff: 100 * time.Millisecond, MaxBackoff: 250 * time.Millisecond}, &rec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var delays []string
+			r.sleep = func(_ context.Context, d time.Duration) error {
+				delays = append(delays, d.String())
+				return nil
+			}
+
+			_, err = r.Scan(context.Background(), []byte("kind: Pod"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan - want error %v, got %v", tt.wantErr, err)
+			}
+			if calls != tt.wantCalls || int(rec) != len(tt.wantDelays) {
+				t.Fatalf("Scan - want %d calls and %d retries, got %d and %d", tt.wantCalls, len(tt.wantDelays), calls, rec)
+			}
+			if len(delays) != len(tt.wantDelays) {
+				t.Fatalf("Scan - want delays %v, got %v", tt.wantDelays, delays)
+			}
+			for i := range delays {
+				if delays[i] != tt.wantDelays[i] {
+					t.Fatalf("Scan - want delays %v, got %v", tt.wantDelays, delays)
+				}
+			}
+		})
+	}
+}
+
+// TestRetry_Scan_network - tests network errors are retried and the context bounds the retries
+func TestRetry_Scan_network(t *testing.T) {
+	var rec retries
+	r, err := NewRetry(NewClient("http://127.0.0.1:1", time.Second), RetryConfig{Retries: 2, Backoff: time.Millisecond, Jitter: 0.5}, &rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Scan(context.Background(), []byte("kind: Pod")); err == nil || rec != 2 {
+		t.Fatalf("Scan - want an error after 2 retries, got %v after %d", err, rec)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec = 0
+	if _, err := r.Scan(ctx, []byte("kind: Pod")); !errors.Is(err, context.Canceled) || rec != 0 {
+		t.Fatalf("Scan - want the context error without retries, got %v after %d", err, rec)
+	}
+}
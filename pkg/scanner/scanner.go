@@ -0,0 +1,60 @@
+// Package scanner scores Kubernetes resource definitions with Kubesec.
+package scanner
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrThrottled is returned while the Kubesec backend asked clients to back off.
+var ErrThrottled = errors.New("kubesec scan throttled by the backend")
+
+// Scanner knows how to score a resource definition.
+type Scanner interface {
+	// Scan scores the YAML or JSON definition of one resource.
+	Scan(ctx context.Context, def []byte) (Results, error)
+}
+
+// Rule is a single Kubesec check reported in a scan result.
+type Rule struct {
+	ID       string `json:"id"`
+	Selector string `json:"selector"`
+	Reason   string `json:"reason"`
+	Points   int    `json:"points"`
+	Href     string `json:"href,omitempty"`
+}
+
+// Scoring groups the checks of a scan result by outcome.
+type Scoring struct {
+	Critical []Rule `json:"critical,omitempty"`
+	Passed   []Rule `json:"passed,omitempty"`
+	Advise   []Rule `json:"advise,omitempty"`
+}
+
+// Score returns the score of the checks as Kubesec scores them: the sum of the
+// points of the critical checks when one failed, of the passed ones
+// otherwise. The results whose checks are changed are scored again with it.
+func (s Scoring) Score() int {
+	rules := s.Passed
+	if len(s.Critical) > 0 {
+		rules = s.Critical
+	}
+	score := 0
+	for _, r := range rules {
+		score += r.Points
+	}
+	return score
+}
+
+// Result is the outcome of scanning one object.
+type Result struct {
+	Object  string  `json:"object,omitempty"`
+	Valid   bool    `json:"valid"`
+	Message string  `json:"message,omitempty"`
+	Error   string  `json:"error,omitempty"`
+	Score   int     `json:"score"`
+	Scoring Scoring `json:"scoring"`
+}
+
+// Results holds one result per object of the scanned definition.
+type Results []Result
@@ -0,0 +1,25 @@
+package scanner
+
+import "testing"
+
+// TestScoring_Score - tests the checks are scored as Kubesec does
+func TestScoring_Score(t *testing.T) {
+	passed := []Rule{{ID: "RunAsNonRoot", Points: 1}, {ID: "ServiceAccountName", Points: 3}}
+	tests := []struct {
+		name    string
+		scoring Scoring
+		want    int
+	}{
+		{name: "none", want: 0},
+		{name: "passed", scoring: Scoring{Passed: passed, Advise: []Rule{{ID: "ReadOnlyRootFilesystem", Points: 1}}}, want: 4},
+		{name: "critical", scoring: Scoring{Critical: []Rule{{ID: "Privileged", Points: -30}, {ID: "HostPID", Points: -9}}, Passed: passed}, want: -39},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scoring.Score(); got != tt.want {
+				t.Fatalf("Score - want %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
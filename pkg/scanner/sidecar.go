@@ -0,0 +1,84 @@
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Sidecar scores definitions with a Kubesec scanner container of the same pod,
+// e.g. running `kubesec http`, reached over plaintext HTTP on a loopback
+// address so the definitions never leave the pod.
+type Sidecar struct {
+	*Client
+
+	healthURL  string
+	httpClient *http.Client
+}
+
+// NewSidecar returns a client for the scanner listening on addr, a loopback
+// host and port such as 127.0.0.1:8080.
+func NewSidecar(addr string, timeout time.Duration, cfg TransportConfig) (*Sidecar, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sidecar address %q: %w", addr, err)
+	}
+	if !loopback(host) {
+		return nil, fmt.Errorf("sidecar address %q is not a loopback address", addr)
+	}
+
+	return &Sidecar{
+		Client:     NewClientWithTransport("http://"+addr+"/scan", timeout, cfg),
+		healthURL:  "http://" + addr + "/health",
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Healthy returns an error unless the sidecar answers its health endpoint.
+func (s *Sidecar) Healthy(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.healthURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("kubesec sidecar is not reachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kubesec sidecar is unhealthy, got %v response from %v", resp.StatusCode, s.healthURL)
+	}
+	return nil
+}
+
+// WaitHealthy polls the health endpoint every interval until the sidecar is
+// healthy, and returns the last error once the context is done.
+func (s *Sidecar) WaitHealthy(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := s.Healthy(ctx)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-ticker.C:
+		}
+	}
+}
+
+func loopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,82 @@
+package scanner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestNewSidecar - tests only loopback addresses are accepted
+func TestNewSidecar(t *testing.T) {
+	tests := []struct {
+		addr    string
+		wantErr bool
+	}{
+		{addr: "127.0.0.1:8080"},
+		{addr: "localhost:8080"},
+		{addr: "[::1]:8080"},
+		{addr: "10.0.0.1:8080", wantErr: true},
+		{addr: "kubesec.kubesec.svc:8080", wantErr: true},
+		{addr: "127.0.0.1", wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := NewSidecar(tt.addr, time.Second, DefaultTransportConfig())
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("NewSidecar(%q) - want error %v, got %v", tt.addr, tt.wantErr, err)
+		}
+	}
+}
+
+// TestSidecar_WaitHealthy - tests the webhook waits for the sidecar to be healthy before scanning with it
+func TestSidecar_WaitHealthy(t *testing.T) {
+	var checks, scans int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/health":
+			// Healthy from the third check on.
+			if atomic.AddInt32(&checks, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/scan":
+			atomic.AddInt32(&scans, 1)
+			_, _ = w.Write([]byte(`[{"score": 3}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := NewSidecar(strings.TrimPrefix(srv.URL, "http://"), time.Second, DefaultTransportConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Healthy(context.Background()); err == nil {
+		t.Fatalf("Healthy - want error while the sidecar starts")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.WaitHealthy(ctx, 10*time.Millisecond); err != nil {
+		t.Fatalf("WaitHealthy - unexpected error %v", err)
+	}
+
+	results, err := s.Scan(context.Background(), []byte("kind: Pod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Score != 3 || atomic.LoadInt32(&scans) != 1 {
+		t.Fatalf("Scan - want the sidecar result, got %+v", results)
+	}
+
+	cancel()
+	srv.Close()
+	if err := s.WaitHealthy(ctx, 10*time.Millisecond); err == nil {
+		t.Fatalf("WaitHealthy - want error once the context is done")
+	}
+}
@@ -0,0 +1,57 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Outcomes of the timed scans.
+const (
+	OutcomeSuccess   = "success"
+	OutcomeError     = "error"
+	OutcomeThrottled = "throttled"
+)
+
+// TimerRecorder records the duration of the scans.
+type TimerRecorder interface {
+	// ObserveScan observes the duration of a scan of the backend by outcome.
+	ObserveScan(backend, outcome string, d time.Duration)
+}
+
+// Timer is a scanner observing the duration of every scan of next, the
+// backend itself, apart from the overhead of the webhook. It sits right in
+// front of the backend so the cached scans are not observed and every retry
+// is.
+type Timer struct {
+	next     Scanner
+	backend  string
+	recorder TimerRecorder
+	now      func() time.Time
+}
+
+// NewTimer returns a scanner timing the scans of the backend next.
+func NewTimer(next Scanner, backend string, recorder TimerRecorder) *Timer {
+	return &Timer{next: next, backend: backend, recorder: recorder, now: time.Now}
+}
+
+// Next returns the timed scanner.
+func (t *Timer) Next() Scanner {
+	return t.next
+}
+
+// Scan satisfies Scanner interface.
+func (t *Timer) Scan(ctx context.Context, def []byte) (Results, error) {
+	start := t.now()
+	results, err := t.next.Scan(ctx, def)
+
+	outcome := OutcomeSuccess
+	switch {
+	case errors.Is(err, ErrThrottled):
+		outcome = OutcomeThrottled
+	case err != nil:
+		outcome = OutcomeError
+	}
+	t.recorder.ObserveScan(t.backend, outcome, t.now().Sub(start))
+	return results, err
+}
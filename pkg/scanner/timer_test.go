@@ -0,0 +1,50 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// durations records the observed scans.
+type durations []string
+
+func (d *durations) ObserveScan(backend, outcome string, dur time.Duration) {
+	*d = append(*d, fmt.Sprintf("%s %s %s", backend, outcome, dur))
+}
+
+// TestTimer_Scan - tests the duration of the scans is observed by backend and outcome
+func TestTimer_Scan(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		wantErr bool
+		want    string
+	}{
+		{name: "success", want: "remote success 1s"},
+		{name: "throttled", err: fmt.Errorf("scan: %w", ErrThrottled), wantErr: true, want: "remote throttled 1s"},
+		{name: "error", err: errors.New("connection refused"), wantErr: true, want: "remote error 1s"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := &durations{}
+			timer := NewTimer(&stubScanner{err: tt.err}, "remote", got)
+			now := time.Now()
+			timer.now = func() time.Time {
+				now = now.Add(time.Second)
+				return now
+			}
+
+			_, err := timer.Scan(context.Background(), []byte("abc"))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Scan - want error %v, got %v", tt.wantErr, err)
+			}
+			if len(*got) != 1 || (*got)[0] != tt.want {
+				t.Fatalf("Scan - want %q observed, got %v", tt.want, *got)
+			}
+		})
+	}
+}
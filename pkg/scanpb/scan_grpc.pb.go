@@ -0,0 +1,174 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.2.0
+// - protoc             v3.21.12
+// source: scan.proto
+
+package scanpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ScannerClient is the client API for Scanner service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ScannerClient interface {
+	Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (*ScanResponse, error)
+	ScanStream(ctx context.Context, opts ...grpc.CallOption) (Scanner_ScanStreamClient, error)
+}
+
+type scannerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewScannerClient(cc grpc.ClientConnInterface) ScannerClient {
+	return &scannerClient{cc}
+}
+
+func (c *scannerClient) Scan(ctx context.Context, in *ScanRequest, opts ...grpc.CallOption) (*ScanResponse, error) {
+	out := new(ScanResponse)
+	err := c.cc.Invoke(ctx, "/kubesec.scan.v1.Scanner/Scan", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *scannerClient) ScanStream(ctx context.Context, opts ...grpc.CallOption) (Scanner_ScanStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Scanner_ServiceDesc.Streams[0], "/kubesec.scan.v1.Scanner/ScanStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &scannerScanStreamClient{stream}
+	return x, nil
+}
+
+type Scanner_ScanStreamClient interface {
+	Send(*ScanRequest) error
+	Recv() (*ScanResponse, error)
+	grpc.ClientStream
+}
+
+type scannerScanStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *scannerScanStreamClient) Send(m *ScanRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *scannerScanStreamClient) Recv() (*ScanResponse, error) {
+	m := new(ScanResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ScannerServer is the server API for Scanner service.
+// All implementations must embed UnimplementedScannerServer
+// for forward compatibility
+type ScannerServer interface {
+	Scan(context.Context, *ScanRequest) (*ScanResponse, error)
+	ScanStream(Scanner_ScanStreamServer) error
+	mustEmbedUnimplementedScannerServer()
+}
+
+// UnimplementedScannerServer must be embedded to have forward compatible implementations.
+type UnimplementedScannerServer struct {
+}
+
+func (UnimplementedScannerServer) Scan(context.Context, *ScanRequest) (*ScanResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Scan not implemented")
+}
+func (UnimplementedScannerServer) ScanStream(Scanner_ScanStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method ScanStream not implemented")
+}
+func (UnimplementedScannerServer) mustEmbedUnimplementedScannerServer() {}
+
+// UnsafeScannerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ScannerServer will
+// result in compilation errors.
+type UnsafeScannerServer interface {
+	mustEmbedUnimplementedScannerServer()
+}
+
+func RegisterScannerServer(s grpc.ServiceRegistrar, srv ScannerServer) {
+	s.RegisterService(&Scanner_ServiceDesc, srv)
+}
+
+func _Scanner_Scan_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScanRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScannerServer).Scan(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/kubesec.scan.v1.Scanner/Scan",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScannerServer).Scan(ctx, req.(*ScanRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Scanner_ScanStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ScannerServer).ScanStream(&scannerScanStreamServer{stream})
+}
+
+type Scanner_ScanStreamServer interface {
+	Send(*ScanResponse) error
+	Recv() (*ScanRequest, error)
+	grpc.ServerStream
+}
+
+type scannerScanStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *scannerScanStreamServer) Send(m *ScanResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *scannerScanStreamServer) Recv() (*ScanRequest, error) {
+	m := new(ScanRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Scanner_ServiceDesc is the grpc.ServiceDesc for Scanner service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Scanner_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kubesec.scan.v1.Scanner",
+	HandlerType: (*ScannerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Scan",
+			Handler:    _Scanner_Scan_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ScanStream",
+			Handler:       _Scanner_ScanStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "scan.proto",
+}
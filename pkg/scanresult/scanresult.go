@@ -0,0 +1,250 @@
+// Package scanresult keeps the history of the admission decisions as
+// KubesecScanResult custom resources in the namespaces of the reviewed
+// workloads.
+package scanresult
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// KubesecScanResultKind is the kind of the scan results, a namespaced custom
+// resource defined by deploy/kubesec-scanresult-crd.yaml.
+var KubesecScanResultKind = schema.GroupVersionKind{Group: "kubesec.io", Version: "v1alpha1", Kind: "KubesecScanResult"}
+
+// Labels of the scan results, selecting those of a workload.
+const (
+	WorkloadKindLabel = "kubesec.io/workload-kind"
+	WorkloadNameLabel = "kubesec.io/workload-name"
+)
+
+// queueSize is the number of decisions waiting to be written before new ones
+// are dropped.
+const queueSize = 256
+
+// Workload is the reviewed workload.
+type Workload struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	UID        string `json:"uid,omitempty"`
+}
+
+// KubesecScanResultSpec is the outcome of one review of a workload.
+type KubesecScanResultSpec struct {
+	Workload  Workload    `json:"workload"`
+	Time      metav1.Time `json:"time"`
+	Operation string      `json:"operation,omitempty"`
+	RequestID string      `json:"requestID,omitempty"`
+	// Decision is allowed or denied.
+	Decision string `json:"decision"`
+	// Scanned is unset when the workload was allowed without a scan, or could
+	// not be scanned, the score is then meaningless.
+	Scanned  bool `json:"scanned"`
+	Score    int  `json:"score"`
+	MinScore int  `json:"minScore"`
+	// Passed, Failed and Advised are the IDs of the checks passed, of the
+	// failed critical checks and of the advised checks missed.
+	Passed        []string `json:"passed,omitempty"`
+	Failed        []string `json:"failed,omitempty"`
+	Advised       []string `json:"advised,omitempty"`
+	MissingChecks []string `json:"missingChecks,omitempty"`
+	DeniedRules   []string `json:"deniedRules,omitempty"`
+	Exemption     string   `json:"exemption,omitempty"`
+	Audit         bool     `json:"audit,omitempty"`
+	Error         string   `json:"error,omitempty"`
+}
+
+// NewSpec returns the spec of the scan result of the decision.
+func NewSpec(rec decision.Record) KubesecScanResultSpec {
+	spec := KubesecScanResultSpec{
+		Workload:      Workload{APIVersion: rec.APIVersion, Kind: rec.ObjectKind, Name: rec.Name, UID: string(rec.UID)},
+		Time:          metav1.NewTime(rec.Time),
+		Operation:     rec.Operation,
+		RequestID:     rec.RequestID,
+		Decision:      "denied",
+		Scanned:       rec.Scan != nil,
+		Score:         rec.Score,
+		MinScore:      rec.MinScore,
+		MissingChecks: rec.MissingChecks,
+		DeniedRules:   rec.DeniedRules,
+		Exemption:     rec.Exemption,
+		Audit:         rec.Audit,
+		Error:         rec.Error,
+	}
+	if rec.Allowed {
+		spec.Decision = "allowed"
+	}
+	if rec.Scan != nil {
+		for _, r := range rec.Scan.Scoring.Passed {
+			spec.Passed = append(spec.Passed, r.ID)
+		}
+		for _, r := range rec.Scan.Scoring.Critical {
+			spec.Failed = append(spec.Failed, r.ID)
+		}
+		for _, r := range rec.Scan.Scoring.Advise {
+			spec.Advised = append(spec.Advised, r.ID)
+		}
+	}
+	return spec
+}
+
+// Writer writes a scan result per decision and deletes the oldest ones of the
+// workload past the history. It satisfies decision.Sink and must be started
+// to write the scan results.
+type Writer struct {
+	client  client.Client
+	history int
+	logger  log.Logger
+	queue   chan decision.Record
+}
+
+// NewWriter returns a writer keeping the latest history scan results of every
+// workload.
+func NewWriter(c client.Client, history int, logger log.Logger) (*Writer, error) {
+	if history < 1 {
+		return nil, fmt.Errorf("scan result history must be at least 1")
+	}
+	return &Writer{client: c, history: history, logger: logger, queue: make(chan decision.Record, queueSize)}, nil
+}
+
+// Write satisfies decision.Sink interface. The decisions of cluster scoped
+// objects are not written.
+func (w *Writer) Write(_ context.Context, rec decision.Record) error {
+	if rec.Namespace == "" {
+		return nil
+	}
+	select {
+	case w.queue <- rec:
+		return nil
+	default:
+		return fmt.Errorf("scan result queue is full, dropping decision of %s", rec.Key())
+	}
+}
+
+// Start writes the scan results until the context is done.
+func (w *Writer) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case rec := <-w.queue:
+			if err := w.Record(ctx, rec); err != nil {
+				w.logger.Warningf("could not write the scan result of %s: %v", rec.Key(), err)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager every replica writes the decisions it took.
+func (w *Writer) NeedLeaderElection() bool {
+	return false
+}
+
+// Record writes the scan result of the decision and prunes the history of the
+// workload.
+func (w *Writer) Record(ctx context.Context, rec decision.Record) error {
+	spec := NewSpec(rec)
+	raw, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&spec)
+	if err != nil {
+		return err
+	}
+
+	res := &unstructured.Unstructured{Object: map[string]interface{}{"spec": raw}}
+	res.SetGroupVersionKind(KubesecScanResultKind)
+	res.SetNamespace(rec.Namespace)
+	res.SetGenerateName(generateName(rec))
+	res.SetLabels(Labels(rec))
+	// The history goes with the workload once its UID is known on UPDATE,
+	// the scan results of workloads never updated are only pruned.
+	if rec.UID != "" && rec.APIVersion != "" && rec.ObjectKind != "" {
+		res.SetOwnerReferences([]metav1.OwnerReference{{
+			APIVersion: rec.APIVersion,
+			Kind:       rec.ObjectKind,
+			Name:       rec.Name,
+			UID:        rec.UID,
+		}})
+	}
+	if err := w.client.Create(ctx, res); err != nil {
+		return fmt.Errorf("could not create scan result: %w", err)
+	}
+	return w.prune(ctx, rec)
+}
+
+// prune deletes the oldest scan results of the workload past the history.
+func (w *Writer) prune(ctx context.Context, rec decision.Record) error {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(KubesecScanResultKind.GroupVersion().WithKind(KubesecScanResultKind.Kind + "List"))
+	if err := w.client.List(ctx, list, client.InNamespace(rec.Namespace), client.MatchingLabels(Labels(rec))); err != nil {
+		return fmt.Errorf("could not list scan results: %w", err)
+	}
+	if len(list.Items) <= w.history {
+		return nil
+	}
+
+	items := list.Items
+	sort.Slice(items, func(i, j int) bool {
+		ti, tj := specTime(items[i]), specTime(items[j])
+		if ti != tj {
+			return ti > tj
+		}
+		return items[i].GetName() > items[j].GetName()
+	})
+	for i := range items[w.history:] {
+		old := &items[w.history+i]
+		if err := w.client.Delete(ctx, old); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("could not delete scan result %s: %w", old.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// specTime returns the time of the scan result, RFC 3339 sorts as the time.
+func specTime(u unstructured.Unstructured) string {
+	t, _, _ := unstructured.NestedString(u.Object, "spec", "time")
+	return t
+}
+
+// Labels returns the labels selecting the scan results of the workload of the
+// decision.
+func Labels(rec decision.Record) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/managed-by": "kubesec-webhook",
+		WorkloadKindLabel:              labelValue(strings.ToLower(rec.ObjectKind)),
+		WorkloadNameLabel:              labelValue(rec.Name),
+	}
+}
+
+// labelValue returns v, or a digest of it when it is not a valid label value.
+func labelValue(v string) string {
+	if len(validation.IsValidLabelValue(v)) == 0 {
+		return v
+	}
+	sum := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(sum[:16])
+}
+
+// generateName returns the prefix of the names of the scan results of the
+// workload.
+func generateName(rec decision.Record) string {
+	name := strings.ToLower(rec.ObjectKind) + "-" + rec.Name
+	if len(name) > 200 {
+		name = name[:200]
+	}
+	return name + "-"
+}
@@ -0,0 +1,126 @@
+package scanresult
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+func testScheme() *runtime.Scheme {
+	s := runtime.NewScheme()
+	s.AddKnownTypeWithName(KubesecScanResultKind, &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(KubesecScanResultKind.GroupVersion().WithKind(KubesecScanResultKind.Kind+"List"), &unstructured.UnstructuredList{})
+	return s
+}
+
+func list(t *testing.T, c client.Client, rec decision.Record) []unstructured.Unstructured {
+	t.Helper()
+	l := &unstructured.UnstructuredList{}
+	l.SetGroupVersionKind(KubesecScanResultKind.GroupVersion().WithKind(KubesecScanResultKind.Kind + "List"))
+	if err := c.List(context.Background(), l, client.InNamespace(rec.Namespace), client.MatchingLabels(Labels(rec))); err != nil {
+		t.Fatal(err)
+	}
+	return l.Items
+}
+
+// TestWriter_Record - tests the scan results are written and pruned to the history
+func TestWriter_Record(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(testScheme()).Build()
+	w, err := NewWriter(c, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec := decision.Record{
+		Time: now, Kind: "deployment", APIVersion: "apps/v1", ObjectKind: "Deployment", Namespace: "foo", Name: "test",
+		Operation: "CREATE", Score: -30,
+		Scan: &scanner.Result{Score: -30, Scoring: scanner.Scoring{
+			Critical: []scanner.Rule{{ID: "Privileged", Points: -30}},
+			Advise:   []scanner.Rule{{ID: "RunAsNonRoot", Points: 1}},
+		}},
+	}
+	if err := w.Record(context.Background(), rec); err != nil {
+		t.Fatal(err)
+	}
+
+	items := list(t, c, rec)
+	if len(items) != 1 {
+		t.Fatalf("Record - want 1 scan result, got %d", len(items))
+	}
+	spec, _, _ := unstructured.NestedMap(items[0].Object, "spec")
+	want := map[string]interface{}{
+		"workload":  map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": "test"},
+		"time":      "2020-01-02T03:04:05Z",
+		"operation": "CREATE",
+		"decision":  "denied",
+		"scanned":   true,
+		"score":     int64(-30),
+		"minScore":  int64(0),
+		"failed":    []interface{}{"Privileged"},
+		"advised":   []interface{}{"RunAsNonRoot"},
+	}
+	if !reflect.DeepEqual(spec, want) {
+		t.Fatalf("Record - want spec %v, got %v", want, spec)
+	}
+	if len(items[0].GetOwnerReferences()) != 0 {
+		t.Fatalf("Record - want no owner without UID, got %v", items[0].GetOwnerReferences())
+	}
+
+	// The updates are owned by the workload, the oldest result is pruned.
+	for i := 1; i <= 2; i++ {
+		rec.Time, rec.Operation, rec.UID, rec.Allowed = now.Add(time.Duration(i)*time.Minute), "UPDATE", "uid-1", true
+		if err := w.Record(context.Background(), rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	items = list(t, c, rec)
+	if len(items) != 2 {
+		t.Fatalf("Record - want 2 scan results, got %d", len(items))
+	}
+	for _, item := range items {
+		if op, _, _ := unstructured.NestedString(item.Object, "spec", "operation"); op != "UPDATE" {
+			t.Fatalf("Record - want the oldest scan result pruned, got %s %v", item.GetName(), item.Object["spec"])
+		}
+		if owners := item.GetOwnerReferences(); len(owners) != 1 || owners[0].UID != "uid-1" {
+			t.Fatalf("Record - want the workload as owner, got %v", owners)
+		}
+	}
+
+	// The history of the other workloads is kept apart.
+	other := rec
+	other.Name = "other"
+	if err := w.Record(context.Background(), other); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(list(t, c, rec)); got != 2 {
+		t.Fatalf("Record - want 2 scan results of test, got %d", got)
+	}
+}
+
+// TestNewWriter - tests the history is validated
+func TestNewWriter(t *testing.T) {
+	if _, err := NewWriter(nil, 0, nil); err == nil {
+		t.Fatal("NewWriter - want an error for an empty history")
+	}
+}
+
+// TestLabels - tests the invalid label values are hashed
+func TestLabels(t *testing.T) {
+	long := decision.Record{ObjectKind: "Deployment", Name: "a-very-long-name-of-a-deployment-well-past-the-63-characters-of-label-values"}
+	got := Labels(long)[WorkloadNameLabel]
+	if len(got) != 32 || got == long.Name {
+		t.Fatalf("Labels - want a digest of the name, got %q", got)
+	}
+	if got := Labels(long)[WorkloadKindLabel]; got != "deployment" {
+		t.Fatalf("Labels - want deployment, got %q", got)
+	}
+}
@@ -0,0 +1,132 @@
+// Package stream pushes the decision records to HTTP clients as they happen,
+// using Server-Sent Events.
+package stream
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+const (
+	// bufferSize is the number of records queued per client, records are
+	// dropped for clients too slow to keep up.
+	bufferSize = 64
+	// heartbeat keeps idle connections open through proxies.
+	heartbeat = 15 * time.Second
+)
+
+// Broker fans the decision records out to the connected clients. It satisfies
+// decision.Sink and http.Handler.
+type Broker struct {
+	token     string
+	heartbeat time.Duration
+
+	mu      sync.Mutex
+	clients map[chan decision.Record]struct{}
+}
+
+// NewBroker returns a broker serving the clients presenting the bearer token.
+func NewBroker(token string) (*Broker, error) {
+	if token == "" {
+		return nil, fmt.Errorf("stream token can't be empty")
+	}
+	return &Broker{
+		token:     token,
+		heartbeat: heartbeat,
+		clients:   map[chan decision.Record]struct{}{},
+	}, nil
+}
+
+// Write satisfies decision.Sink interface, it never blocks.
+func (b *Broker) Write(_ context.Context, r decision.Record) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for c := range b.clients {
+		select {
+		case c <- r:
+		default:
+		}
+	}
+	return nil
+}
+
+func (b *Broker) subscribe() chan decision.Record {
+	c := make(chan decision.Record, bufferSize)
+	b.mu.Lock()
+	b.clients[c] = struct{}{}
+	b.mu.Unlock()
+	return c
+}
+
+func (b *Broker) unsubscribe(c chan decision.Record) {
+	b.mu.Lock()
+	delete(b.clients, c)
+	b.mu.Unlock()
+}
+
+// ServeHTTP streams the records as "decision" events until the client goes
+// away. The namespace and kind query parameters filter the records.
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !b.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="kubesec-webhook"`)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	namespace, kind := r.URL.Query().Get("namespace"), r.URL.Query().Get("kind")
+
+	c := b.subscribe()
+	defer b.unsubscribe(c)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(b.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case rec := <-c:
+			if (namespace != "" && rec.Namespace != namespace) || (kind != "" && !strings.EqualFold(rec.Kind, kind)) {
+				continue
+			}
+			data, err := json.Marshal(rec)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: decision\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (b *Broker) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(token), []byte(b.token)) == 1
+}
@@ -0,0 +1,73 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// TestBroker - tests the records are streamed to authenticated clients
+func TestBroker(t *testing.T) {
+	b, err := NewBroker("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(b)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("ServeHTTP - want %d without token, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"?namespace=team-a", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("ServeHTTP - want event stream, got %q", ct)
+	}
+
+	// Wait for the client to be subscribed before writing.
+	for i := 0; ; i++ {
+		b.mu.Lock()
+		n := len(b.clients)
+		b.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		if i > 100 {
+			t.Fatal("ServeHTTP - client never subscribed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_ = b.Write(context.Background(), decision.Record{Kind: "pod", Namespace: "team-b", Name: "filtered"})
+	_ = b.Write(context.Background(), decision.Record{Kind: "pod", Namespace: "team-a", Name: "streamed"})
+
+	sc := bufio.NewScanner(resp.Body)
+	var lines []string
+	for sc.Scan() && len(lines) < 2 {
+		if sc.Text() != "" {
+			lines = append(lines, sc.Text())
+		}
+	}
+	if len(lines) != 2 || lines[0] != "event: decision" || !strings.Contains(lines[1], `"name":"streamed"`) {
+		t.Fatalf("ServeHTTP - unexpected events %q", lines)
+	}
+}
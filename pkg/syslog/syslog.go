@@ -0,0 +1,274 @@
+// Package syslog sends the admission decisions to a syslog server, e.g. the
+// collector of a SIEM, as RFC 5424 messages over TCP or TLS.
+package syslog
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+const (
+	// queueSize is the number of decisions waiting to be sent before new
+	// ones are dropped.
+	queueSize = 1024
+	// dialTimeout bounds the connections to the server.
+	dialTimeout = 10 * time.Second
+	// writeTimeout bounds the writes of a message.
+	writeTimeout = 10 * time.Second
+	// appName is the APP-NAME of the messages.
+	appName = "kubesec-webhook"
+	// sdID is the SD-ID of the structured data of the decisions, under the
+	// private enterprise number reserved for documentation.
+	sdID = "kubesec@32473"
+	// timestampFormat is RFC 3339 down to the microseconds of RFC 5424.
+	timestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// Outcomes of the decisions mapped to the severities.
+const (
+	OutcomeAllowed = "allowed"
+	OutcomeDenied  = "denied"
+	OutcomeError   = "error"
+)
+
+// SeverityNone is the severity of the outcomes not sent.
+const SeverityNone = "none"
+
+var facilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11, "ntp": 12, "security": 13, "console": 14,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+var severities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3, "warning": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// DefaultSeverities are the severities of the outcomes when none is set.
+var DefaultSeverities = map[string]string{OutcomeAllowed: "info", OutcomeDenied: "warning", OutcomeError: "err"}
+
+// ParseSeverities parses comma separated outcome=severity pairs, e.g.
+// allowed=none,denied=crit, over the DefaultSeverities.
+func ParseSeverities(v string) (map[string]string, error) {
+	sev := map[string]string{}
+	for k, s := range DefaultSeverities {
+		sev[k] = s
+	}
+	if v == "" {
+		return sev, nil
+	}
+	for _, pair := range strings.Split(v, ",") {
+		outcome, s, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid severity %q, want outcome=severity", pair)
+		}
+		if _, ok := DefaultSeverities[outcome]; !ok {
+			return nil, fmt.Errorf("unknown outcome %q, want %s, %s or %s", outcome, OutcomeAllowed, OutcomeDenied, OutcomeError)
+		}
+		if _, ok := severities[s]; !ok && s != SeverityNone {
+			return nil, fmt.Errorf("unknown severity %q of %s", s, outcome)
+		}
+		sev[outcome] = s
+	}
+	return sev, nil
+}
+
+// Writer sends a message per decision, asynchronously so an unavailable
+// server never delays the admissions, reconnecting to it on failures. The
+// messages are framed by octet counting as in RFC 6587. It satisfies
+// decision.Sink and must be started to send the messages.
+type Writer struct {
+	address    string
+	tlsConfig  *tls.Config
+	facility   int
+	severities map[string]string
+	hostname   string
+	logger     log.Logger
+	queue      chan decision.Record
+	conn       net.Conn
+}
+
+// NewWriter returns a writer sending the messages to the server at address,
+// over TLS when tlsConfig is set, with the facility and the severities of
+// the outcomes, the DefaultSeverities of those missing.
+func NewWriter(address string, tlsConfig *tls.Config, facility string, sev map[string]string, logger log.Logger) (*Writer, error) {
+	if address == "" {
+		return nil, fmt.Errorf("syslog address can't be empty")
+	}
+	f, ok := facilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", facility)
+	}
+	all := map[string]string{}
+	for outcome, s := range DefaultSeverities {
+		all[outcome] = s
+	}
+	for outcome, s := range sev {
+		if _, ok := severities[s]; !ok && s != SeverityNone {
+			return nil, fmt.Errorf("unknown severity %q of %s", s, outcome)
+		}
+		all[outcome] = s
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	return &Writer{
+		address:    address,
+		tlsConfig:  tlsConfig,
+		facility:   f,
+		severities: all,
+		hostname:   hostname,
+		logger:     logger,
+		queue:      make(chan decision.Record, queueSize),
+	}, nil
+}
+
+// Write satisfies decision.Sink interface.
+func (w *Writer) Write(_ context.Context, rec decision.Record) error {
+	if w.severity(rec) == SeverityNone {
+		return nil
+	}
+	select {
+	case w.queue <- rec:
+		return nil
+	default:
+		return fmt.Errorf("syslog queue is full, dropping decision of %s", rec.Key())
+	}
+}
+
+// Start sends the messages until the context is done.
+func (w *Writer) Start(ctx context.Context) error {
+	defer func() {
+		if w.conn != nil {
+			w.conn.Close()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case rec := <-w.queue:
+			if err := w.send(ctx, rec); err != nil {
+				w.logger.Warningf("could not send the decision of %s to syslog: %v", rec.Key(), err)
+			}
+		}
+	}
+}
+
+// NeedLeaderElection tells the manager every replica sends the decisions it took.
+func (w *Writer) NeedLeaderElection() bool {
+	return false
+}
+
+// send writes the message of the decision, on a new connection when the
+// current one failed.
+func (w *Writer) send(ctx context.Context, rec decision.Record) error {
+	msg, err := w.Format(rec)
+	if err != nil {
+		return err
+	}
+	frame := []byte(strconv.Itoa(len(msg)) + " " + msg)
+
+	for attempt := 0; ; attempt++ {
+		if w.conn == nil {
+			if w.conn, err = w.dial(ctx); err != nil {
+				return err
+			}
+		}
+		_ = w.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if _, err = w.conn.Write(frame); err == nil {
+			return nil
+		}
+		w.conn.Close()
+		w.conn = nil
+		if attempt > 0 {
+			return err
+		}
+	}
+}
+
+func (w *Writer) dial(ctx context.Context) (net.Conn, error) {
+	d := &net.Dialer{Timeout: dialTimeout}
+	if w.tlsConfig == nil {
+		return d.DialContext(ctx, "tcp", w.address)
+	}
+	return (&tls.Dialer{NetDialer: d, Config: w.tlsConfig}).DialContext(ctx, "tcp", w.address)
+}
+
+// Format returns the RFC 5424 message of the decision: its outcome as
+// MSGID, its main fields as structured data and its JSON, without the scan,
+// as MSG.
+func (w *Writer) Format(rec decision.Record) (string, error) {
+	short := rec
+	short.Scan = nil
+	raw, err := json.Marshal(short)
+	if err != nil {
+		return "", err
+	}
+
+	timestamp := rec.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	pri := w.facility*8 + severities[w.severity(rec)]
+
+	params := []string{
+		param("kind", rec.Kind),
+		param("namespace", rec.Namespace),
+		param("name", rec.Name),
+		param("operation", rec.Operation),
+		param("score", strconv.Itoa(rec.Score)),
+		param("minScore", strconv.Itoa(rec.MinScore)),
+		param("requestID", rec.RequestID),
+	}
+	var sd strings.Builder
+	sd.WriteString("[" + sdID)
+	for _, p := range params {
+		if p != "" {
+			sd.WriteString(" " + p)
+		}
+	}
+	sd.WriteString("]")
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		pri, timestamp.UTC().Format(timestampFormat), w.hostname, appName, os.Getpid(), outcome(rec), sd.String(), raw), nil
+}
+
+func (w *Writer) severity(rec decision.Record) string {
+	return w.severities[outcome(rec)]
+}
+
+// outcome returns the outcome of the decision, those of the audit mode are
+// denials.
+func outcome(rec decision.Record) string {
+	switch {
+	case rec.Error != "":
+		return OutcomeError
+	case rec.Allowed && !rec.Audit:
+		return OutcomeAllowed
+	}
+	return OutcomeDenied
+}
+
+// param returns the SD-PARAM, empty when the value is.
+func param(name, value string) string {
+	if value == "" {
+		return ""
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+	return name + `="` + r.Replace(value) + `"`
+}
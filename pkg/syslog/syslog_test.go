@@ -0,0 +1,143 @@
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// TestWriter_Format - tests the RFC 5424 messages of the decisions
+func TestWriter_Format(t *testing.T) {
+	at := time.Date(2022, 12, 1, 10, 0, 0, 123456789, time.UTC)
+	tests := []struct {
+		name string
+		rec  decision.Record
+		want string
+	}{
+		{
+			name: "denied",
+			rec:  decision.Record{Time: at, Kind: "deployment", Namespace: "foo", Name: `a"b`, Operation: "CREATE", Score: -30, RequestID: "abc"},
+			want: fmt.Sprintf(`<132>1 2022-12-01T10:00:00.123456Z host kubesec-webhook %d denied [kubesec@32473 kind="deployment" namespace="foo" name="a\"b" operation="CREATE" score="-30" minScore="0" requestID="abc"] {"time":`, os.Getpid()),
+		},
+		{
+			name: "allowed",
+			rec:  decision.Record{Time: at, Kind: "pod", Name: "test", Allowed: true, Score: 3},
+			want: fmt.Sprintf(`<134>1 2022-12-01T10:00:00.123456Z host kubesec-webhook %d allowed [kubesec@32473 kind="pod" name="test" score="3" minScore="0"] {`, os.Getpid()),
+		},
+		{
+			name: "audit",
+			rec:  decision.Record{Time: at, Kind: "pod", Name: "test", Allowed: true, Audit: true},
+			want: "<132>1 ",
+		},
+		{
+			name: "error",
+			rec:  decision.Record{Time: at, Kind: "pod", Name: "test", Allowed: true, Error: "scan failed"},
+			want: "<131>1 ",
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			w, err := NewWriter("localhost:514", nil, "local0", nil, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.hostname = "host"
+			got, err := w.Format(tt.rec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(got, tt.want) {
+				t.Fatalf("Format - want prefix %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestWriter - tests the messages are sent framed, but those of the outcomes
+// of severity none
+func TestWriter(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	msgs := make(chan string, 2)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(length))
+			if err != nil {
+				t.Errorf("server - invalid frame length %q", length)
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			msgs <- string(msg)
+		}
+	}()
+
+	sev, err := ParseSeverities("allowed=none,denied=crit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewWriter(l.Addr().String(), nil, "auth", sev, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = w.Start(ctx) }()
+
+	for _, rec := range []decision.Record{{Kind: "pod", Name: "a", Allowed: true}, {Kind: "pod", Name: "b"}} {
+		if err := w.Write(ctx, rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case msg := <-msgs:
+		if !strings.HasPrefix(msg, "<34>1 ") || !strings.Contains(msg, `name="b"`) {
+			t.Fatalf("Writer - unexpected message %q", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Writer - nothing sent")
+	}
+}
+
+// TestParseSeverities - tests the invalid severities are rejected
+func TestParseSeverities(t *testing.T) {
+	for _, v := range []string{"denied", "rejected=crit", "denied=loud"} {
+		if _, err := ParseSeverities(v); err == nil {
+			t.Fatalf("ParseSeverities - want error for %q", v)
+		}
+	}
+	sev, err := ParseSeverities("denied=alert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sev[OutcomeDenied] != "alert" || sev[OutcomeAllowed] != "info" {
+		t.Fatalf("ParseSeverities - unexpected severities %v", sev)
+	}
+}
@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/slok/kubewebhook/pkg/webhook"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/requestid"
+)
+
+// Audit annotations set on the admission responses.
+const (
+	requestIDAnnotation = "kubesec.io/request-id"
+	exemptionAnnotation = "kubesec.io/exemption"
+	auditAnnotation     = "kubesec.io/audit-denied"
+	decisionAnnotation  = "kubesec.io/decision"
+	scoreAnnotation     = "kubesec.io/score"
+	scannerAnnotation   = "kubesec.io/scanner-version"
+)
+
+// annotated decorates the responses of a webhook with the warnings and audit
+// annotations kubewebhook does not know how to set.
+type annotated struct {
+	webhook.Webhook
+}
+
+// withAnnotations wraps wh so its responses are annotated.
+func withAnnotations(wh webhook.Webhook, err error) (webhook.Webhook, error) {
+	if err != nil {
+		return nil, err
+	}
+	return &annotated{Webhook: wh}, nil
+}
+
+// Review satisfies webhook.Webhook interface.
+func (a *annotated) Review(ctx context.Context, ar *admissionv1beta1.AdmissionReview) *admissionv1beta1.AdmissionResponse {
+	extra := &responseExtra{annotations: map[string]string{}}
+	resp := a.Webhook.Review(context.WithValue(ctx, responseExtraKey{}, extra), ar)
+	if resp == nil {
+		return nil
+	}
+
+	if id := requestid.FromContext(ctx); id != "" {
+		extra.annotate(requestIDAnnotation, id)
+	}
+
+	extra.mu.Lock()
+	defer extra.mu.Unlock()
+	resp.Warnings = append(resp.Warnings, extra.warnings...)
+	if len(extra.annotations) > 0 {
+		if resp.AuditAnnotations == nil {
+			resp.AuditAnnotations = map[string]string{}
+		}
+		for k, v := range extra.annotations {
+			resp.AuditAnnotations[k] = v
+		}
+	}
+
+	return resp
+}
+
+type responseExtraKey struct{}
+
+// responseExtra collects what the validator wants added to the response.
+type responseExtra struct {
+	mu          sync.Mutex
+	warnings    []string
+	annotations map[string]string
+}
+
+func (e *responseExtra) annotate(key, value string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.annotations[key] = value
+}
+
+// warn adds a warning to the admission response of the request in ctx, it is
+// a no-op outside of an annotated webhook.
+func warn(ctx context.Context, warning string) {
+	if e, ok := ctx.Value(responseExtraKey{}).(*responseExtra); ok {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.warnings = append(e.warnings, warning)
+	}
+}
+
+// annotate adds an audit annotation to the admission response of the request
+// in ctx, it is a no-op outside of an annotated webhook.
+func annotate(ctx context.Context, key, value string) {
+	if e, ok := ctx.Value(responseExtraKey{}).(*responseExtra); ok {
+		e.annotate(key, value)
+	}
+}
+
+// annotateDecision adds the outcome of the review to the audit annotations of
+// the response, so the audit logs have it for the allowed requests too. The
+// score is only there when the object was scanned.
+func (o *Options) annotateDecision(ctx context.Context, rec decision.Record) {
+	verdict := "denied"
+	if rec.Allowed {
+		verdict = "allowed"
+	}
+	annotate(ctx, decisionAnnotation, verdict)
+	if rec.Scan != nil {
+		annotate(ctx, scoreAnnotation, strconv.Itoa(rec.Score))
+		annotate(ctx, minScoreAnnotation, strconv.Itoa(rec.MinScore))
+	}
+	if o != nil && o.ScannerVersion != "" {
+		annotate(ctx, scannerAnnotation, o.ScannerVersion)
+	}
+}
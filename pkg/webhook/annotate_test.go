@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/requestid"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+type staticReview struct{}
+
+func (staticReview) Review(context.Context, *admissionv1beta1.AdmissionReview) *admissionv1beta1.AdmissionResponse {
+	return &admissionv1beta1.AdmissionResponse{Allowed: true}
+}
+
+// Test_annotated_Review - tests the request ID is set as audit annotation
+func Test_annotated_Review(t *testing.T) {
+	wh, err := withAnnotations(staticReview{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := wh.Review(requestid.WithID(context.Background(), "abc"), &admissionv1beta1.AdmissionReview{})
+	if got := resp.AuditAnnotations[requestIDAnnotation]; got != "abc" {
+		t.Fatalf("Review - want request ID annotation %q, got %q", "abc", got)
+	}
+
+	resp = wh.Review(context.Background(), &admissionv1beta1.AdmissionReview{})
+	if resp.AuditAnnotations != nil {
+		t.Fatalf("Review - want no annotations without request ID, got %v", resp.AuditAnnotations)
+	}
+}
+
+// Test_review_annotateDecision - tests the outcome is set as audit annotations
+func Test_review_annotateDecision(t *testing.T) {
+	tests := []struct {
+		name    string
+		scanner *fakeScanner
+		want    map[string]string
+	}{
+		{
+			name:    "allowed",
+			scanner: &fakeScanner{result: scanner.Result{Score: 3}},
+			want:    map[string]string{decisionAnnotation: "allowed", scoreAnnotation: "3", minScoreAnnotation: "0", scannerAnnotation: "embedded kubesec-v2"},
+		},
+		{
+			name:    "denied",
+			scanner: &fakeScanner{result: scanner.Result{Score: -30}},
+			want:    map[string]string{decisionAnnotation: "denied", scoreAnnotation: "-30", minScoreAnnotation: "0", scannerAnnotation: "embedded kubesec-v2"},
+		},
+		{
+			name:    "not scanned",
+			scanner: &fakeScanner{err: errors.New("unreachable")},
+			want:    map[string]string{decisionAnnotation: "allowed", scannerAnnotation: "embedded kubesec-v2"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			extra := &responseExtra{annotations: map[string]string{}}
+			ctx := context.WithValue(context.Background(), responseExtraKey{}, extra)
+			opts := &Options{Scanner: tt.scanner, ScannerVersion: "embedded kubesec-v2"}
+			if _, _, err := opts.review(ctx, "pod", testPod("busybox"), 0, log.Dummy); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(extra.annotations, tt.want) {
+				t.Fatalf("review - want=%v, got=%v", tt.want, extra.annotations)
+			}
+		})
+	}
+}
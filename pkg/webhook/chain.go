@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/slok/kubewebhook/pkg/webhook/validating"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ValidatorChain runs validators in order in the same admission call. The
+// first validator denying the object, failing or stopping the chain decides,
+// the object is allowed when all of them allow it. It satisfies
+// validating.Validator.
+type ValidatorChain struct {
+	validators []validating.Validator
+}
+
+// NewValidatorChain returns the chain of the validators.
+func NewValidatorChain(validators ...validating.Validator) *ValidatorChain {
+	return &ValidatorChain{validators: validators}
+}
+
+// Register adds the validators at the end of the chain.
+func (c *ValidatorChain) Register(validators ...validating.Validator) {
+	c.validators = append(c.validators, validators...)
+}
+
+// Validate satisfies validating.Validator interface.
+func (c *ValidatorChain) Validate(ctx context.Context, obj metav1.Object) (bool, validating.ValidatorResult, error) {
+	for _, v := range c.validators {
+		stop, res, err := v.Validate(ctx, obj)
+		if stop || !res.Valid || err != nil {
+			return true, res, err
+		}
+	}
+	return false, validating.ValidatorResult{Valid: true}, nil
+}
+
+// chain returns the chain of the kubesec validator of a webhook and of the
+// validators of the options, the kubesec validator alone when there are none.
+func (o *Options) chain(kubesec validating.Validator) validating.Validator {
+	if o == nil || len(o.Validators) == 0 {
+		return kubesec
+	}
+	return NewValidatorChain(append([]validating.Validator{kubesec}, o.Validators...)...)
+}
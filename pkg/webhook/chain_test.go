@@ -0,0 +1,125 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"github.com/slok/kubewebhook/pkg/observability/metrics"
+	whcontext "github.com/slok/kubewebhook/pkg/webhook/context"
+	"github.com/slok/kubewebhook/pkg/webhook/validating"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// requireTeam denies the objects without a team label, and counts its calls.
+type requireTeam struct {
+	calls int
+}
+
+func (r *requireTeam) Validate(_ context.Context, obj metav1.Object) (bool, validating.ValidatorResult, error) {
+	r.calls++
+	if obj.GetLabels()["team"] == "" {
+		return true, validating.ValidatorResult{Valid: false, Message: obj.GetName() + " has no team label"}, nil
+	}
+	return false, validating.ValidatorResult{Valid: true}, nil
+}
+
+// TestValidatorChain_Validate - tests the first denying validator decides
+func TestValidatorChain_Validate(t *testing.T) {
+	allow := validating.ValidatorFunc(func(context.Context, metav1.Object) (bool, validating.ValidatorResult, error) {
+		return false, validating.ValidatorResult{Valid: true}, nil
+	})
+	stop := validating.ValidatorFunc(func(context.Context, metav1.Object) (bool, validating.ValidatorResult, error) {
+		return true, validating.ValidatorResult{Valid: true}, nil
+	})
+	tests := []struct {
+		name    string
+		chain   func(*requireTeam) *ValidatorChain
+		allowed bool
+		calls   int
+	}{
+		{name: "empty", chain: func(*requireTeam) *ValidatorChain { return NewValidatorChain() }, allowed: true},
+		{name: "denied", chain: func(r *requireTeam) *ValidatorChain { return NewValidatorChain(allow, r) }, calls: 1},
+		{name: "stopped", chain: func(r *requireTeam) *ValidatorChain { return NewValidatorChain(stop, r) }, allowed: true},
+		{
+			name: "registered",
+			chain: func(r *requireTeam) *ValidatorChain {
+				c := NewValidatorChain(allow)
+				c.Register(r)
+				return c
+			},
+			calls: 1,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := &requireTeam{}
+			_, res, err := tt.chain(r).Validate(context.Background(), testPod("busybox"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed || r.calls != tt.calls {
+				t.Fatalf("Validate - want allowed=%v after %d calls, got %v after %d: %s", tt.allowed, tt.calls, res.Valid, r.calls, res.Message)
+			}
+		})
+	}
+}
+
+// Test_webhook_validators - tests the validators of the options run after the kubesec check
+func Test_webhook_validators(t *testing.T) {
+	tests := []struct {
+		name    string
+		score   int
+		labels  map[string]string
+		allowed bool
+		message string
+		calls   int
+	}{
+		{name: "allowed", score: 1, labels: map[string]string{"team": "a"}, allowed: true, calls: 1},
+		{name: "denied by the validator", score: 1, message: "test has no team label", calls: 1},
+		{name: "denied by kubesec", score: -1, labels: map[string]string{"team": "a"}, message: "test score is -1"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			r := &requireTeam{}
+			opts := &Options{Scanner: &fakeScanner{result: scanner.Result{Score: tt.score}}, Validators: []validating.Validator{r}}
+			wh, err := NewPodWebhook(0, opts, metrics.Dummy, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			pod := testPod("busybox")
+			pod.Labels = tt.labels
+			raw, err := json.Marshal(pod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ar := &admissionv1beta1.AdmissionRequest{
+				UID:       "1",
+				Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+				Namespace: "foo",
+				Name:      "test",
+				Operation: admissionv1beta1.Create,
+				Object:    runtime.RawExtension{Raw: raw},
+			}
+			resp := wh.Review(whcontext.SetAdmissionRequest(context.Background(), ar), &admissionv1beta1.AdmissionReview{Request: ar})
+			if resp.Allowed != tt.allowed {
+				t.Fatalf("Review - want allowed=%v, got %v: %v", tt.allowed, resp.Allowed, resp.Result)
+			}
+			if !tt.allowed && (resp.Result == nil || !strings.Contains(resp.Result.Message, tt.message)) {
+				t.Fatalf("Review - want %q in message, got %v", tt.message, resp.Result)
+			}
+			if r.calls != tt.calls {
+				t.Fatalf("Review - want %d validator calls, got %d", tt.calls, r.calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// PolicyLister lists the cluster policies, see policy.KubesecPolicies.
+type PolicyLister interface {
+	List(ctx context.Context) ([]policy.KubesecPolicy, error)
+}
+
+// PolicyListers lists the cluster policies of every lister.
+type PolicyListers []PolicyLister
+
+// List satisfies PolicyLister interface.
+func (l PolicyListers) List(ctx context.Context) ([]policy.KubesecPolicy, error) {
+	var policies []policy.KubesecPolicy
+	var errs []string
+	for _, lister := range l {
+		p, err := lister.List(ctx)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		policies = append(policies, p...)
+	}
+	if len(errs) > 0 {
+		return policies, errors.New(strings.Join(errs, "; "))
+	}
+	return policies, nil
+}
+
+// clusterPolicy returns the outcome of the cluster policies applying to the
+// namespace, nil when none applies.
+func (o *Options) clusterPolicy(ctx context.Context, ns *corev1.Namespace, logger log.Logger) *policy.Cluster {
+	if o == nil || o.Policies == nil {
+		return nil
+	}
+
+	policies, err := o.Policies.List(ctx)
+	if err != nil {
+		logger.Warningf("%v", err)
+	}
+
+	var nsLabels labels.Labels
+	if ns != nil {
+		nsLabels = labels.Set(ns.Labels)
+	}
+	return policy.Resolve(policies, nsLabels)
+}
+
+// excludeRules returns the result without the rules, and scored again.
+func excludeRules(result scanner.Result, rules []string) scanner.Result {
+	if len(rules) == 0 {
+		return result
+	}
+	excluded := map[string]bool{}
+	for _, id := range rules {
+		excluded[id] = true
+	}
+
+	filter := func(in []scanner.Rule) []scanner.Rule {
+		var out []scanner.Rule
+		for _, r := range in {
+			if excluded[r.ID] {
+				continue
+			}
+			out = append(out, r)
+		}
+		return out
+	}
+	result.Scoring.Critical = filter(result.Scoring.Critical)
+	result.Scoring.Passed = filter(result.Scoring.Passed)
+	result.Scoring.Advise = filter(result.Scoring.Advise)
+	result.Score = result.Scoring.Score()
+
+	return result
+}
@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+type fakePolicies []policy.KubesecPolicy
+
+func (f fakePolicies) List(context.Context) ([]policy.KubesecPolicy, error) {
+	return f, nil
+}
+
+// Test_review_clusterPolicy - tests the cluster policies selecting the namespace override the flags
+func Test_review_clusterPolicy(t *testing.T) {
+	newPolicy := func(spec policy.KubesecPolicySpec) policy.KubesecPolicy {
+		spec.NamespaceSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}
+		p, err := policy.NewKubesecPolicy("prod", spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	five, minusTen := 5, -10
+	privileged := scanner.Result{
+		Score:   -30,
+		Scoring: scanner.Scoring{Critical: []scanner.Rule{{ID: "Privileged", Points: -30}}},
+	}
+
+	tests := []struct {
+		name        string
+		labels      map[string]string
+		annotations map[string]string
+		spec        policy.KubesecPolicySpec
+		result      scanner.Result
+		err         error
+		allowed     bool
+	}{
+		{name: "raised min score", labels: map[string]string{"env": "prod"}, spec: policy.KubesecPolicySpec{MinScore: &five}, result: scanner.Result{Score: 3}, allowed: false},
+		{name: "unselected namespace", labels: map[string]string{"env": "dev"}, spec: policy.KubesecPolicySpec{MinScore: &five}, result: scanner.Result{Score: 3}, allowed: true},
+		{name: "lowered min score", labels: map[string]string{"env": "prod"}, spec: policy.KubesecPolicySpec{MinScore: &minusTen}, result: scanner.Result{Score: -5}, allowed: true},
+		{
+			name:        "namespace annotation prevails",
+			labels:      map[string]string{"env": "prod"},
+			annotations: map[string]string{minScoreAnnotation: "0"},
+			spec:        policy.KubesecPolicySpec{MinScore: &minusTen},
+			result:      scanner.Result{Score: -5},
+			allowed:     false,
+		},
+		{name: "closed failure mode", labels: map[string]string{"env": "prod"}, spec: policy.KubesecPolicySpec{FailureMode: FailClosed}, err: errors.New("connection refused"), allowed: false},
+		{name: "excluded rule", labels: map[string]string{"env": "prod"}, spec: policy.KubesecPolicySpec{ExcludedRules: []string{"Privileged"}}, result: privileged, allowed: true},
+		{name: "not excluded rule", labels: map[string]string{"env": "prod"}, spec: policy.KubesecPolicySpec{ExcludedRules: []string{"HostPID"}}, result: privileged, allowed: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "foo", Labels: tt.labels, Annotations: tt.annotations}}
+			opts := &Options{
+				Scanner:           &fakeScanner{result: tt.result, err: tt.err},
+				Namespaces:        fake.NewClientBuilder().WithObjects(ns).Build(),
+				NamespaceMinScore: true,
+				Policies:          fakePolicies{newPolicy(tt.spec)},
+			}
+			_, res, err := opts.review(context.Background(), "pod", testPod("busybox"), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.allowed, res.Valid, res.Message)
+			}
+		})
+	}
+}
+
+// Test_excludeRules - tests the result is scored again without the excluded rules
+func Test_excludeRules(t *testing.T) {
+	passed := []scanner.Rule{{ID: "RunAsNonRoot", Points: 1}, {ID: "ServiceAccountName", Points: 3}}
+	tests := []struct {
+		name   string
+		result scanner.Result
+		rules  []string
+		want   int
+	}{
+		{name: "passed rule", result: scanner.Result{Score: 4, Scoring: scanner.Scoring{Passed: passed}}, rules: []string{"ServiceAccountName"}, want: 1},
+		{
+			name:   "passed rule of a critical result",
+			result: scanner.Result{Score: -30, Scoring: scanner.Scoring{Critical: []scanner.Rule{{ID: "Privileged", Points: -30}}, Passed: passed}},
+			rules:  []string{"ServiceAccountName"},
+			want:   -30,
+		},
+		{
+			name:   "last critical",
+			result: scanner.Result{Score: -30, Scoring: scanner.Scoring{Critical: []scanner.Rule{{ID: "Privileged", Points: -30}}, Passed: passed}},
+			rules:  []string{"Privileged"},
+			want:   4,
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if got := excludeRules(tt.result, tt.rules); got.Score != tt.want {
+				t.Fatalf("excludeRules - want score %d, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"github.com/slok/kubewebhook/pkg/observability/metrics"
+	"github.com/slok/kubewebhook/pkg/webhook"
+	"github.com/slok/kubewebhook/pkg/webhook/validating"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// cronJobValidator validates the definition against the Kubesec.io score.
+type cronJobValidator struct {
+	minScore int
+	logger   log.Logger
+	opts     *Options
+}
+
+func (d *cronJobValidator) Validate(ctx context.Context, obj metav1.Object) (bool, validating.ValidatorResult, error) {
+	kObj, ok := obj.(*batchv1.CronJob)
+	if !ok {
+		reviewLogger(ctx, d.logger).Errorf("received invalid CronJob object %v", obj)
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+
+	kObj.TypeMeta = metav1.TypeMeta{
+		Kind:       "CronJob",
+		APIVersion: "batch/v1",
+	}
+
+	return d.opts.review(ctx, "cronjob", kObj, d.minScore, d.logger)
+}
+
+// NewCronJobWebhook returns a new CronJob validating webhook.
+func NewCronJobWebhook(minScore int, opts *Options, mrec metrics.Recorder, logger log.Logger) (webhook.Webhook, error) {
+
+	// Create validators.
+	val := &cronJobValidator{
+		minScore: minScore,
+		logger:   logger,
+		opts:     opts,
+	}
+
+	cfg := validating.WebhookConfig{
+		Name: "kubesec-cronjob",
+		Obj:  &batchv1.CronJob{},
+	}
+
+	return withAnnotations(validating.NewWebhook(cfg, opts.chain(val), mrec, logger))
+}
@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Test_cronJobValidator_Validate - tests the pod template of a CronJob is scored
+// The hardened manifest should be allowed by the webhook and the insecure should be blocked
+func Test_cronJobValidator_Validate(t *testing.T) {
+	tests := []struct {
+		name     string // name of the test
+		score    int    // score returned by the scanner
+		result   bool   // response/result we expect from the webhook
+		minScore int    // minimum score used for initialisation
+		cjSpec   string // cronjob specification in string
+	}{
+		{
+			name:     "Hardened CronJob Spec",
+			score:    7,
+			result:   true,
+			minScore: 0,
+			cjSpec: `
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: hello
+  namespace: foo
+spec:
+  schedule: "* * * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          restartPolicy: OnFailure
+          containers:
+          - name: hello
+            image: busybox
+            securityContext:
+              readOnlyRootFilesystem: true
+              runAsNonRoot: true
+`,
+		},
+		{
+			name:     "Insecure CronJob Spec",
+			score:    -30,
+			result:   false,
+			minScore: 0,
+			cjSpec: `
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: hello
+  namespace: foo
+spec:
+  schedule: "* * * * *"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          restartPolicy: OnFailure
+          containers:
+          - name: hello
+            image: busybox
+            securityContext:
+              privileged: true
+`,
+		},
+	}
+	for _, tt := range tests {
+
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			cv := cronJobValidator{
+				minScore: tt.minScore,
+				logger:   log.Dummy,
+				opts:     &Options{Scanner: &fakeScanner{result: scanner.Result{Score: tt.score}}},
+			}
+
+			decoder := serializer.NewCodecFactory(scheme.Scheme).UniversalDecoder()
+
+			cj := &batchv1.CronJob{}
+
+			if err := runtime.DecodeInto(decoder, []byte(tt.cjSpec), cj); err != nil {
+				t.Fatalf("unable to convert %q into CronJob object - %v", tt.cjSpec, err)
+			}
+
+			_, resp, err := cv.Validate(context.Background(), cj)
+			if err != nil {
+				t.Fatalf("CronJob validator - got error %v", err)
+			}
+
+			if got := resp.Valid; got != tt.result {
+				t.Fatalf("CronJob validator - result mismatch, want=%v, got=%v", tt.result, got)
+			}
+			if images := images(cj); len(images) != 1 || images[0] != "busybox" {
+				t.Fatalf("CronJob validator - want the job template containers, got %v", images)
+			}
+		})
+	}
+}
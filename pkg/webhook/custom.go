@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"github.com/slok/kubewebhook/pkg/observability/metrics"
+	"github.com/slok/kubewebhook/pkg/webhook"
+	"github.com/slok/kubewebhook/pkg/webhook/validating"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// PodTemplatePaths maps the kinds of custom resources to the JSONPath of the
+// pod template they embed.
+type PodTemplatePaths map[schema.GroupVersionKind]string
+
+// ParsePodTemplatePath parses a "group/version/Kind=jsonpath" mapping, e.g.
+// "argoproj.io/v1alpha1/Rollout=.spec.template". The path points either at
+// a pod template or directly at a pod spec, the braces are optional.
+func ParsePodTemplatePath(s string) (schema.GroupVersionKind, string, error) {
+	kind, path, ok := strings.Cut(s, "=")
+	if !ok || path == "" {
+		return schema.GroupVersionKind{}, "", fmt.Errorf("invalid pod template path %q, want group/version/Kind=jsonpath", s)
+	}
+
+	parts := strings.Split(kind, "/")
+	if len(parts) != 3 || parts[1] == "" || parts[2] == "" {
+		return schema.GroupVersionKind{}, "", fmt.Errorf("invalid kind %q, want group/version/Kind", kind)
+	}
+	gvk := schema.GroupVersionKind{Group: parts[0], Version: parts[1], Kind: parts[2]}
+
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+	if err := jsonpath.New(gvk.Kind).Parse(path); err != nil {
+		return schema.GroupVersionKind{}, "", fmt.Errorf("invalid pod template path of %s: %w", gvk, err)
+	}
+
+	return gvk, path, nil
+}
+
+// customResource is a custom resource with the pod template found at its
+// configured path.
+type customResource struct {
+	*unstructured.Unstructured
+
+	path     string
+	template corev1.PodTemplateSpec
+}
+
+// newCustomResource returns the custom resource with the pod template found
+// at path.
+func newCustomResource(u *unstructured.Unstructured, path string) (*customResource, error) {
+	jp := jsonpath.New(u.GetKind())
+	if err := jp.Parse(path); err != nil {
+		return nil, err
+	}
+	res, err := jp.FindResults(u.Object)
+	if err != nil {
+		return nil, fmt.Errorf("no pod template at %s: %w", path, err)
+	}
+	if len(res) != 1 || len(res[0]) != 1 {
+		return nil, fmt.Errorf("pod template path %s must match exactly one value", path)
+	}
+
+	raw, err := json.Marshal(res[0][0].Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	// A pod spec has the containers at its root, a pod template in its spec.
+	var root struct {
+		Containers json.RawMessage `json:"containers"`
+	}
+	if err := json.Unmarshal(raw, &root); err != nil {
+		return nil, fmt.Errorf("pod template at %s is not an object: %w", path, err)
+	}
+
+	cr := &customResource{Unstructured: u, path: path}
+	if root.Containers != nil {
+		err = json.Unmarshal(raw, &cr.template.Spec)
+	} else {
+		err = json.Unmarshal(raw, &cr.template)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid pod template at %s: %w", path, err)
+	}
+	if len(cr.template.Spec.Containers) == 0 {
+		return nil, fmt.Errorf("pod template at %s has no containers", path)
+	}
+
+	return cr, nil
+}
+
+// decode returns the custom resource of the same kind encoded in raw.
+func (c *customResource) decode(raw []byte) (runtime.Object, error) {
+	u := &unstructured.Unstructured{}
+	if err := u.UnmarshalJSON(raw); err != nil {
+		return nil, err
+	}
+	return newCustomResource(u, c.path)
+}
+
+// customResourceValidator validates the pod template of custom resources
+// against the Kubesec.io score.
+type customResourceValidator struct {
+	minScore int
+	paths    PodTemplatePaths
+	logger   log.Logger
+	opts     *Options
+}
+
+func (d *customResourceValidator) Validate(ctx context.Context, obj metav1.Object) (bool, validating.ValidatorResult, error) {
+	logger := reviewLogger(ctx, d.logger)
+	u, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		logger.Errorf("received invalid custom resource %v", obj)
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+
+	gvk := u.GroupVersionKind()
+	path, ok := d.paths[gvk]
+	if !ok {
+		logger.Warningf("allowing %s %q, no pod template path configured for the kind", gvk, u.GetName())
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+
+	kind := strings.ToLower(gvk.Kind)
+	cr, err := newCustomResource(u, path)
+	if err != nil {
+		logger.Errorf("allowing %s %q without scanning: %v", kind, u.GetName(), err)
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+
+	return d.opts.review(ctx, kind, cr, d.minScore, d.logger)
+}
+
+// NewCustomResourceWebhook returns a new validating webhook scoring the pod
+// template of the custom resources found at the configured paths.
+func NewCustomResourceWebhook(minScore int, paths PodTemplatePaths, opts *Options, mrec metrics.Recorder, logger log.Logger) (webhook.Webhook, error) {
+
+	// Create validators.
+	val := &customResourceValidator{
+		minScore: minScore,
+		paths:    paths,
+		logger:   logger,
+		opts:     opts,
+	}
+
+	cfg := validating.WebhookConfig{
+		Name: "kubesec-custom-resource",
+		Obj:  &unstructured.Unstructured{},
+	}
+
+	return withAnnotations(validating.NewWebhook(cfg, opts.chain(val), mrec, logger))
+}
@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// TestParsePodTemplatePath - tests the parsing of the kind to pod template path mappings
+func TestParsePodTemplatePath(t *testing.T) {
+	tests := []struct {
+		in      string
+		gvk     schema.GroupVersionKind
+		path    string
+		wantErr bool
+	}{
+		{in: "argoproj.io/v1alpha1/Rollout=.spec.template", gvk: schema.GroupVersionKind{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}, path: "{.spec.template}"},
+		{in: "example.com/v1/Runner={.spec.pod.spec}", gvk: schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Runner"}, path: "{.spec.pod.spec}"},
+		{in: "/v1/Thing=.spec", gvk: schema.GroupVersionKind{Version: "v1", Kind: "Thing"}, path: "{.spec}"},
+		{in: "argoproj.io/v1alpha1/Rollout", wantErr: true},
+		{in: "argoproj.io/Rollout=.spec.template", wantErr: true},
+		{in: "argoproj.io/v1alpha1/Rollout=.spec[", wantErr: true},
+	}
+	for _, tt := range tests {
+		gvk, path, err := ParsePodTemplatePath(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("ParsePodTemplatePath(%q) - want error %v, got %v", tt.in, tt.wantErr, err)
+		}
+		if gvk != tt.gvk || path != tt.path {
+			t.Fatalf("ParsePodTemplatePath(%q) - want %v %q, got %v %q", tt.in, tt.gvk, tt.path, gvk, path)
+		}
+	}
+}
+
+// Test_customResourceValidator_Validate - tests the pod template of custom resources is scored
+func Test_customResourceValidator_Validate(t *testing.T) {
+	paths := PodTemplatePaths{
+		{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}: "{.spec.template}",
+		{Group: "example.com", Version: "v1", Kind: "Runner"}:        "{.spec.runner}",
+	}
+
+	tests := []struct {
+		name    string // name of the test
+		score   int    // score returned by the scanner
+		result  bool   // response/result we expect from the webhook
+		scanned bool   // whether the object is expected to be scanned
+		spec    string // custom resource specification in string
+	}{
+		{
+			name:    "Insecure Rollout pod template",
+			score:   -30,
+			result:  false,
+			scanned: true,
+			spec: `
+apiVersion: argoproj.io/v1alpha1
+kind: Rollout
+metadata:
+  name: rollout
+  namespace: foo
+spec:
+  strategy:
+    canary: {}
+  template:
+    metadata:
+      labels:
+        app: rollout
+    spec:
+      containers:
+      - name: app
+        image: nginx
+        securityContext:
+          privileged: true
+`,
+		},
+		{
+			name:    "Hardened pod spec",
+			score:   5,
+			result:  true,
+			scanned: true,
+			spec: `
+apiVersion: example.com/v1
+kind: Runner
+metadata:
+  name: runner
+spec:
+  runner:
+    containers:
+    - name: app
+      image: nginx
+`,
+		},
+		{
+			name:    "Kind without pod template path",
+			score:   -30,
+			result:  true,
+			scanned: false,
+			spec: `
+apiVersion: example.com/v2
+kind: Runner
+metadata:
+  name: runner
+spec:
+  runner:
+    containers:
+    - name: app
+      image: nginx
+`,
+		},
+		{
+			name:    "No pod template at the path",
+			score:   -30,
+			result:  true,
+			scanned: false,
+			spec: `
+apiVersion: argoproj.io/v1alpha1
+kind: Rollout
+metadata:
+  name: rollout
+spec:
+  workloadRef:
+    kind: Deployment
+    name: app
+`,
+		},
+	}
+	for _, tt := range tests {
+
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fs := &countingScanner{fakeScanner: fakeScanner{result: scanner.Result{Score: tt.score}}}
+			wh, err := NewCustomResourceWebhook(0, paths, &Options{Scanner: fs}, nil, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			raw, err := yaml.YAMLToJSON([]byte(tt.spec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp := wh.Review(context.Background(), &admissionv1beta1.AdmissionReview{
+				Request: &admissionv1beta1.AdmissionRequest{
+					UID:       "1",
+					Operation: admissionv1beta1.Create,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+
+			if got := resp.Allowed; got != tt.result {
+				t.Fatalf("custom resource validator - result mismatch, want=%v, got=%v: %v", tt.result, got, resp.Result)
+			}
+			if got := fs.scans > 0; got != tt.scanned {
+				t.Fatalf("custom resource validator - want scanned=%v, got=%v", tt.scanned, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// decisionPolicyExemption is the exemption of the objects the decision policy
+// allowed in spite of their scan.
+const decisionPolicyExemption = "decision policy"
+
+// DecisionPolicy has the final say on the scanned objects, see policy.Rego
+// and policy.Expressions.
+type DecisionPolicy interface {
+	// Decide returns its verdict, nil to leave the decision to the scan.
+	Decide(ctx context.Context, input policy.DecisionInput) (*policy.Verdict, error)
+}
+
+// decide returns the verdict of the decision policy on the scanned object, nil
+// when there is none or it failed, the scan then decides.
+func (o *Options) decide(ctx context.Context, obj object, ns *corev1.Namespace, rec decision.Record, result scanner.Result, reasons []string, logger log.Logger) *policy.Verdict {
+	if o == nil || o.DecisionPolicy == nil {
+		return nil
+	}
+
+	input := policy.DecisionInput{
+		Operation: rec.Operation,
+		Kind:      rec.Kind,
+		Score:     result.Score,
+		MinScore:  rec.MinScore,
+		Scan:      result,
+		Allowed:   len(reasons) == 0,
+		Reasons:   reasons,
+	}
+	var err error
+	if input.Object, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj); err != nil {
+		logger.Warningf("could not convert %s %q for the decision policy: %v", rec.Kind, rec.Name, err)
+		return nil
+	}
+	if ns != nil {
+		if input.Namespace, err = runtime.DefaultUnstructuredConverter.ToUnstructured(ns); err != nil {
+			logger.Warningf("could not convert namespace %q for the decision policy: %v", ns.Name, err)
+			return nil
+		}
+	}
+
+	v, err := o.DecisionPolicy.Decide(ctx, input)
+	if err != nil {
+		logger.Warningf("leaving the decision on %s %q to its scan: %v", rec.Kind, rec.Name, err)
+		return nil
+	}
+	return v
+}
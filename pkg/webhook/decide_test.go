@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// prodPolicy denies the objects of the prod namespaces scoring below 5 only,
+// and warns about the privileged ones.
+const prodPolicy = `package kubesec
+
+default decision := {"result": "allow"}
+
+decision := {"result": "deny", "message": "prod workloads must score 5"} {
+	input.score < 5
+	input.namespace.metadata.labels.tier == "prod"
+}
+
+decision := {"result": "warn", "message": "privileged outside of prod"} {
+	input.scan.scoring.critical[_].id == "Privileged"
+	not input.namespace.metadata.labels.tier == "prod"
+}
+`
+
+// Test_review_decisionPolicy - tests the decision policy has the final say on the scanned objects
+func Test_review_decisionPolicy(t *testing.T) {
+	rp, err := policy.ParseRego(context.Background(), "prod.rego", []byte(prodPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		tier      string
+		result    scanner.Result
+		allowed   bool
+		exemption string
+		warnings  int
+	}{
+		{name: "low score in prod", tier: "prod", result: scanner.Result{Score: 3}},
+		{name: "low score elsewhere", tier: "dev", result: scanner.Result{Score: -3}, allowed: true, exemption: decisionPolicyExemption, warnings: 1},
+		{name: "passing score", tier: "prod", result: scanner.Result{Score: 7}, allowed: true},
+		{
+			name: "warned", tier: "dev", allowed: true, warnings: 1,
+			result: scanner.Result{Score: 7, Scoring: scanner.Scoring{Critical: []scanner.Rule{{ID: "Privileged"}}}},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "foo", Labels: map[string]string{"tier": tt.tier}}}
+			opts := &Options{
+				Scanner:        &fakeScanner{result: tt.result},
+				Namespaces:     fake.NewClientBuilder().WithObjects(ns).Build(),
+				DecisionPolicy: rp,
+			}
+			extra := &responseExtra{annotations: map[string]string{}}
+			ctx := context.WithValue(context.Background(), responseExtraKey{}, extra)
+			_, res, err := opts.review(ctx, "pod", testPod("busybox"), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.allowed, res.Valid, res.Message)
+			}
+			if extra.annotations[exemptionAnnotation] != tt.exemption {
+				t.Fatalf("review - want exemption %q, got %v", tt.exemption, extra.annotations)
+			}
+			if len(extra.warnings) != tt.warnings {
+				t.Fatalf("review - want %d warnings, got %v", tt.warnings, extra.warnings)
+			}
+		})
+	}
+}
@@ -2,6 +2,6 @@ package webhook
 
 // Default URL and timeout values associated with the upstream Kubesec v2 service
 const (
-	kubesecScanURL = `https://v2.kubesec.io`
+	DefaultScanURL = `https://v2.kubesec.io`
 	timeOut        = 15
 )
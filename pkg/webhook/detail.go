@@ -0,0 +1,111 @@
+package webhook
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Details of the denial messages, see Options.DenyDetail.
+const (
+	// DenyDetailFull appends the remediation advice and the whole scan result
+	// to the reasons.
+	DenyDetailFull = "full"
+	// DenyDetailSummary appends the failed critical checks and the remediation
+	// advice to the reasons.
+	DenyDetailSummary = "summary"
+	// DenyDetailMinimal only gives the reasons, without the scanning errors.
+	DenyDetailMinimal = "minimal"
+)
+
+// truncated ends the denial messages cut to the maximum length.
+const truncated = "\n... truncated, see the webhook logs for the full details"
+
+func (o *Options) denyDetail() string {
+	if o == nil || o.DenyDetail == "" {
+		return DenyDetailFull
+	}
+	return o.DenyDetail
+}
+
+// formatCritical renders the failed critical checks of the result for a
+// denial message, empty when there is none.
+func formatCritical(result scanner.Result) string {
+	if len(result.Scoring.Critical) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Failed critical checks:")
+	for _, r := range result.Scoring.Critical {
+		fmt.Fprintf(&b, "\n  - %s: %s", r.ID, r.Reason)
+	}
+	return b.String()
+}
+
+// formatAdvice renders the advised checks of the result as remediation steps
+// for the denial of a score below minScore, the most rewarding first, empty
+// when there is none.
+func formatAdvice(result scanner.Result, minScore int) string {
+	advise := append([]scanner.Rule{}, result.Scoring.Advise...)
+	sort.SliceStable(advise, func(i, j int) bool { return advise[i].Points > advise[j].Points })
+	var steps []string
+	for _, r := range advise {
+		if r.Points > 0 {
+			steps = append(steps, remediation(r))
+		}
+	}
+	if len(steps) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "To reach the minimum score %d, %s short:", minScore, points(minScore-result.Score))
+	for i, step := range steps {
+		if i == maxDiffLines {
+			fmt.Fprintf(&b, "\n  ... and %d more", len(steps)-maxDiffLines)
+			break
+		}
+		fmt.Fprintf(&b, "\n  - %s", step)
+	}
+	return b.String()
+}
+
+// remediation turns the selector of an advised check into the step to take,
+// e.g. "set containers[].securityContext.runAsNonRoot=true: +1 point".
+func remediation(r scanner.Rule) string {
+	sel := strings.ReplaceAll(r.Selector, " .", ".")
+	sel = strings.Replace(sel, " == ", "=", 1)
+	sel = strings.Replace(sel, " -gt ", " > ", 1)
+	return fmt.Sprintf("set %s: +%s", sel, points(r.Points))
+}
+
+func points(n int) string {
+	if n == 1 {
+		return "1 point"
+	}
+	return fmt.Sprintf("%d points", n)
+}
+
+// denyMessage caps msg to the maximum length of the denial messages and ends
+// it with the request ID, kept whatever the cut so the details can be found
+// in the logs.
+func (o *Options) denyMessage(msg, requestID string) string {
+	var id string
+	if requestID != "" {
+		id = "\nRequest ID: " + requestID
+	}
+	if o != nil && o.DenyMessageMaxLength > 0 && len(msg)+len(id) > o.DenyMessageMaxLength {
+		n := o.DenyMessageMaxLength - len(truncated) - len(id)
+		if n < 0 {
+			n = 0
+		}
+		for n > 0 && !utf8.RuneStart(msg[n]) {
+			n--
+		}
+		msg = msg[:n] + truncated
+	}
+	return msg + id
+}
@@ -0,0 +1,128 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Test_review_denyDetail - tests how much of the scan the denials return
+func Test_review_denyDetail(t *testing.T) {
+	result := scanner.Result{
+		Score: -30,
+		Scoring: scanner.Scoring{
+			Critical: []scanner.Rule{{ID: "Privileged", Reason: "Privileged containers can allow almost completely unrestricted host access"}},
+			Advise:   []scanner.Rule{{ID: "RunAsNonRoot", Selector: "containers[] .securityContext .runAsNonRoot == true", Points: 1}},
+		},
+	}
+	tests := []struct {
+		name    string
+		detail  string
+		scanner *fakeScanner
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "default",
+			scanner: &fakeScanner{result: result},
+			want:    []string{"minimum accepted score is 0", "To reach the minimum score 0, 30 points short:", "Scan Result:", `"score": -30`},
+		},
+		{
+			name:    "summary",
+			detail:  DenyDetailSummary,
+			scanner: &fakeScanner{result: result},
+			want:    []string{"minimum accepted score is 0", "Failed critical checks:\n  - Privileged: Privileged containers", "set containers[].securityContext.runAsNonRoot=true: +1 point"},
+			notWant: []string{"Scan Result:"},
+		},
+		{
+			name:    "minimal",
+			detail:  DenyDetailMinimal,
+			scanner: &fakeScanner{result: result},
+			want:    []string{"minimum accepted score is 0"},
+			notWant: []string{"Scan Result:", "Failed critical checks:", "To reach"},
+		},
+		{
+			name:    "minimal scanning error",
+			detail:  DenyDetailMinimal,
+			scanner: &fakeScanner{err: errors.New("backend at 10.0.0.1 unreachable")},
+			want:    []string{"could not be scanned, denied as the failure mode is closed"},
+			notWant: []string{"10.0.0.1"},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Options{Scanner: tt.scanner, DenyDetail: tt.detail, FailureMode: FailClosed}
+			_, res, err := opts.review(context.Background(), "pod", testPod("busybox"), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid {
+				t.Fatalf("review - want denied, got allowed")
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(res.Message, w) {
+					t.Fatalf("review - want %q in the message, got %s", w, res.Message)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(res.Message, w) {
+					t.Fatalf("review - want no %q in the message, got %s", w, res.Message)
+				}
+			}
+		})
+	}
+}
+
+// Test_formatAdvice - tests the remediation steps of the advised checks
+func Test_formatAdvice(t *testing.T) {
+	result := scanner.Result{Score: 1, Scoring: scanner.Scoring{Advise: []scanner.Rule{
+		{ID: "RunAsUser", Selector: "containers[] .securityContext .runAsUser -gt 10000", Points: 1},
+		{ID: "ServiceAccountName", Selector: ".spec .serviceAccountName", Points: 3},
+		{ID: "Penalty", Selector: ".spec .hostPID == true", Points: -9},
+	}}}
+
+	want := "To reach the minimum score 3, 2 points short:\n" +
+		"  - set .spec.serviceAccountName: +3 points\n" +
+		"  - set containers[].securityContext.runAsUser > 10000: +1 point"
+	if got := formatAdvice(result, 3); got != want {
+		t.Fatalf("formatAdvice - want=%q, got=%q", want, got)
+	}
+	if got := formatAdvice(scanner.Result{}, 3); got != "" {
+		t.Fatalf("formatAdvice - want no advice, got %q", got)
+	}
+}
+
+// TestOptions_denyMessage - tests the cap of the denial messages
+func TestOptions_denyMessage(t *testing.T) {
+	long := strings.Repeat("é", 200)
+	tests := []struct {
+		name      string
+		max       int
+		msg       string
+		requestID string
+		want      string
+	}{
+		{name: "uncapped", msg: long, requestID: "abc", want: long + "\nRequest ID: abc"},
+		{name: "short enough", max: 100, msg: "denied", requestID: "abc", want: "denied\nRequest ID: abc"},
+		{name: "truncated", max: 100, msg: long, requestID: "abc", want: strings.Repeat("é", 13) + truncated + "\nRequest ID: abc"},
+		{name: "truncated on a rune boundary", max: 100, msg: long, want: strings.Repeat("é", 21) + truncated},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			got := (&Options{DenyMessageMaxLength: tt.max}).denyMessage(tt.msg, tt.requestID)
+			if got != tt.want {
+				t.Fatalf("denyMessage - want=%q, got=%q", tt.want, got)
+			}
+			if tt.max > 0 && len(got) > tt.max {
+				t.Fatalf("denyMessage - want at most %d bytes, got %d", tt.max, len(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	whcontext "github.com/slok/kubewebhook/pkg/webhook/context"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// maxDiffLines caps the number of changes reported in a denial message.
+const maxDiffLines = 15
+
+// podSpec returns the pod specification embedded in the object, or nil for
+// kinds that do not carry one.
+func podSpec(obj runtime.Object) *corev1.PodSpec {
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		return &o.Spec
+	case *appsv1.Deployment:
+		return &o.Spec.Template.Spec
+	case *appsv1.DaemonSet:
+		return &o.Spec.Template.Spec
+	case *appsv1.StatefulSet:
+		return &o.Spec.Template.Spec
+	case *appsv1.ReplicaSet:
+		return &o.Spec.Template.Spec
+	case *batchv1.Job:
+		return &o.Spec.Template.Spec
+	case *knativeService:
+		return &o.Spec.Template.Spec
+	case *customResource:
+		return &o.template.Spec
+	case *batchv1.CronJob:
+		return &o.Spec.JobTemplate.Spec.Template.Spec
+	}
+	return nil
+}
+
+// updateDiff returns the security relevant changes between the object stored
+// in the cluster and the one being admitted. It is empty for anything but an
+// UPDATE of a known kind.
+func updateDiff(ctx context.Context, obj runtime.Object, logger log.Logger) []string {
+	ar := whcontext.GetAdmissionRequest(ctx)
+	if ar == nil || ar.Operation != admissionv1beta1.Update || len(ar.OldObject.Raw) == 0 {
+		return nil
+	}
+
+	old, err := decodeOld(ar.OldObject.Raw, obj)
+	if err != nil {
+		logger.Warningf("could not decode old object: %v", err)
+		return nil
+	}
+
+	oldSpec, newSpec := podSpec(old), podSpec(obj)
+	if oldSpec == nil || newSpec == nil {
+		return nil
+	}
+
+	return securityDiff(oldSpec, newSpec)
+}
+
+// decodeOld decodes the old object into the type of the new one, so kinds
+// the scheme does not know about, e.g. Knative Services, are decoded too.
+func decodeOld(raw []byte, obj runtime.Object) (runtime.Object, error) {
+	if cr, ok := obj.(*customResource); ok {
+		return cr.decode(raw)
+	}
+
+	into, ok := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(runtime.Object)
+	if !ok {
+		return nil, fmt.Errorf("unsupported object type %T", obj)
+	}
+	old, _, err := scheme.Codecs.UniversalDeserializer().Decode(raw, nil, into)
+	return old, err
+}
+
+// securityDiff lists the changes to the fields Kubesec.io scores between two
+// pod specifications, one "path: old -> new" entry per changed field.
+func securityDiff(old, new *corev1.PodSpec) []string {
+	before, after := securityFields(old), securityFields(new)
+
+	paths := map[string]struct{}{}
+	for p := range before {
+		paths[p] = struct{}{}
+	}
+	for p := range after {
+		paths[p] = struct{}{}
+	}
+
+	var diff []string
+	for p := range paths {
+		b, a := before[p], after[p]
+		if b == a {
+			continue
+		}
+		diff = append(diff, fmt.Sprintf("%s: %s -> %s", p, orUnset(b), orUnset(a)))
+	}
+	sort.Strings(diff)
+
+	return diff
+}
+
+// formatDiff renders the changes for a denial message.
+func formatDiff(diff []string) string {
+	var b strings.Builder
+	b.WriteString("Security relevant changes since the last accepted revision:")
+	for i, d := range diff {
+		if i == maxDiffLines {
+			fmt.Fprintf(&b, "\n  ... and %d more", len(diff)-maxDiffLines)
+			break
+		}
+		fmt.Fprintf(&b, "\n  - %s", d)
+	}
+	return b.String()
+}
+
+// securityFields flattens the security relevant parts of a pod specification
+// into a map of field path to value.
+func securityFields(spec *corev1.PodSpec) map[string]string {
+	fields := map[string]string{}
+
+	flatten(fields, ".spec.hostNetwork", spec.HostNetwork)
+	flatten(fields, ".spec.hostPID", spec.HostPID)
+	flatten(fields, ".spec.hostIPC", spec.HostIPC)
+	flatten(fields, ".spec.serviceAccountName", spec.ServiceAccountName)
+	flatten(fields, ".spec.automountServiceAccountToken", spec.AutomountServiceAccountToken)
+	flatten(fields, ".spec.securityContext", spec.SecurityContext)
+
+	for _, v := range spec.Volumes {
+		if v.HostPath != nil {
+			flatten(fields, fmt.Sprintf(".spec.volumes[%s].hostPath", v.Name), v.HostPath.Path)
+		}
+	}
+
+	containers := map[string][]corev1.Container{
+		"initContainers": spec.InitContainers,
+		"containers":     spec.Containers,
+	}
+	for field, cs := range containers {
+		for _, c := range cs {
+			prefix := fmt.Sprintf(".spec.%s[%s]", field, c.Name)
+			flatten(fields, prefix+".securityContext", c.SecurityContext)
+			flatten(fields, prefix+".resources", c.Resources)
+		}
+	}
+
+	return fields
+}
+
+// flatten stores every leaf of v under path, using its JSON representation.
+func flatten(fields map[string]string, path string, v interface{}) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return
+	}
+	flattenValue(fields, path, generic)
+}
+
+func flattenValue(fields map[string]string, path string, v interface{}) {
+	switch val := v.(type) {
+	case nil:
+		return
+	case map[string]interface{}:
+		for k, sub := range val {
+			flattenValue(fields, path+"."+k, sub)
+		}
+	case []interface{}:
+		items := make([]string, 0, len(val))
+		for _, item := range val {
+			raw, _ := json.Marshal(item)
+			items = append(items, string(raw))
+		}
+		sort.Strings(items)
+		if len(items) > 0 {
+			fields[path] = "[" + strings.Join(items, ",") + "]"
+		}
+	default:
+		raw, _ := json.Marshal(val)
+		if s := string(raw); s != `""` {
+			fields[path] = s
+		}
+	}
+}
+
+func orUnset(s string) string {
+	if s == "" {
+		return "<unset>"
+	}
+	return s
+}
@@ -0,0 +1,124 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	whcontext "github.com/slok/kubewebhook/pkg/webhook/context"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Test_securityDiff - tests that only changes to scored fields are reported
+func Test_securityDiff(t *testing.T) {
+	yes, no := true, false
+
+	tests := []struct {
+		name string          // name of the test
+		old  *corev1.PodSpec // last accepted pod spec
+		new  *corev1.PodSpec // pod spec being admitted
+		want []string        // expected changes
+	}{
+		{
+			name: "No change",
+			old:  &corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "nginx"}}},
+			new:  &corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "nginx:1.23"}}},
+		},
+		{
+			name: "Privileged container and host network",
+			old: &corev1.PodSpec{Containers: []corev1.Container{{
+				Name:            "main",
+				SecurityContext: &corev1.SecurityContext{Privileged: &no},
+			}}},
+			new: &corev1.PodSpec{HostNetwork: true, Containers: []corev1.Container{{
+				Name:            "main",
+				SecurityContext: &corev1.SecurityContext{Privileged: &yes},
+			}}},
+			want: []string{
+				".spec.containers[main].securityContext.privileged: false -> true",
+				".spec.hostNetwork: false -> true",
+			},
+		},
+		{
+			name: "Dropped capabilities removed",
+			old: &corev1.PodSpec{Containers: []corev1.Container{{
+				Name: "main",
+				SecurityContext: &corev1.SecurityContext{
+					Capabilities: &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+				},
+			}}},
+			new: &corev1.PodSpec{Containers: []corev1.Container{{Name: "main"}}},
+			want: []string{
+				`.spec.containers[main].securityContext.capabilities.drop: ["ALL"] -> <unset>`,
+			},
+		},
+	}
+	for _, tt := range tests {
+
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := securityDiff(tt.old, tt.new)
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("securityDiff - result mismatch, want=%v, got=%v", tt.want, got)
+			}
+		})
+	}
+}
+
+// Test_updateDiff - tests that the diff is only computed for UPDATE requests
+func Test_updateDiff(t *testing.T) {
+	deployment := func(privileged bool) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			TypeMeta: metav1.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:            "main",
+					SecurityContext: &corev1.SecurityContext{Privileged: &privileged},
+				}},
+			}}},
+		}
+	}
+
+	raw, err := json.Marshal(deployment(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string                     // name of the test
+		operation admissionv1beta1.Operation // admission operation
+		old       []byte                     // raw old object
+		want      int                        // expected number of changes
+	}{
+		{name: "Update", operation: admissionv1beta1.Update, old: raw, want: 1},
+		{name: "Create", operation: admissionv1beta1.Create, old: nil, want: 0},
+		{name: "Undecodable old object", operation: admissionv1beta1.Update, old: []byte("{"), want: 0},
+	}
+	for _, tt := range tests {
+
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := whcontext.SetAdmissionRequest(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Operation: tt.operation,
+				OldObject: runtime.RawExtension{Raw: tt.old},
+			})
+
+			got := updateDiff(ctx, deployment(true), log.Dummy)
+
+			if len(got) != tt.want {
+				t.Fatalf("updateDiff - result mismatch, want=%d changes, got=%v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"github.com/slok/kubewebhook/pkg/webhook"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// dispatcher hands every review over to the webhook of the reviewed kind, so
+// all the kinds can be served on a single path.
+type dispatcher struct {
+	webhooks map[schema.GroupKind]webhook.Webhook
+	logger   log.Logger
+}
+
+// NewDispatchWebhook returns a webhook reviewing the objects with the webhook
+// of their kind. Objects of kinds without a webhook are allowed.
+func NewDispatchWebhook(webhooks map[schema.GroupKind]webhook.Webhook, logger log.Logger) webhook.Webhook {
+	return &dispatcher{
+		webhooks: webhooks,
+		logger:   logger,
+	}
+}
+
+// Review satisfies webhook.Webhook interface.
+func (d *dispatcher) Review(ctx context.Context, ar *admissionv1beta1.AdmissionReview) *admissionv1beta1.AdmissionResponse {
+	gk := schema.GroupKind{Group: ar.Request.Kind.Group, Kind: ar.Request.Kind.Kind}
+	wh, ok := d.webhooks[gk]
+	if !ok {
+		reviewLogger(ctx, d.logger).Warningf("allowing %s %s/%s, no webhook for the kind", gk, ar.Request.Namespace, ar.Request.Name)
+		return &admissionv1beta1.AdmissionResponse{UID: ar.Request.UID, Allowed: true}
+	}
+
+	return wh.Review(ctx, ar)
+}
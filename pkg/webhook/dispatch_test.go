@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"github.com/slok/kubewebhook/pkg/webhook"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// kindWebhook answers every review with the kind it was registered for.
+type kindWebhook string
+
+func (k kindWebhook) Review(_ context.Context, ar *admissionv1beta1.AdmissionReview) *admissionv1beta1.AdmissionResponse {
+	return &admissionv1beta1.AdmissionResponse{UID: ar.Request.UID, Result: &metav1.Status{Message: string(k)}}
+}
+
+// Test_dispatcher_Review - tests the reviews are handed over to the webhook of their kind
+func Test_dispatcher_Review(t *testing.T) {
+	wh := NewDispatchWebhook(map[schema.GroupKind]webhook.Webhook{
+		{Kind: "Pod"}:                        kindWebhook("pod"),
+		{Group: "apps", Kind: "Deployment"}:  kindWebhook("deployment"),
+		{Group: "batch", Kind: "Deployment"}: kindWebhook("batch deployment"),
+	}, log.Dummy)
+
+	tests := []struct {
+		name    string
+		kind    metav1.GroupVersionKind
+		want    string
+		allowed bool
+	}{
+		{name: "core kind", kind: metav1.GroupVersionKind{Version: "v1", Kind: "Pod"}, want: "pod"},
+		{name: "any version of a kind", kind: metav1.GroupVersionKind{Group: "apps", Version: "v1beta2", Kind: "Deployment"}, want: "deployment"},
+		{name: "kind of another group", kind: metav1.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Deployment"}, want: "batch deployment"},
+		{name: "unknown kind", kind: metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ControllerRevision"}, allowed: true},
+	}
+	for _, tt := range tests {
+		resp := wh.Review(context.Background(), &admissionv1beta1.AdmissionReview{
+			Request: &admissionv1beta1.AdmissionRequest{UID: "1", Kind: tt.kind},
+		})
+		if resp.UID != "1" {
+			t.Fatalf("%s - want the request UID, got %q", tt.name, resp.UID)
+		}
+		if tt.allowed {
+			if !resp.Allowed || resp.Result != nil {
+				t.Fatalf("%s - want an allowed response, got %+v", tt.name, resp)
+			}
+			continue
+		}
+		if resp.Result == nil || resp.Result.Message != tt.want {
+			t.Fatalf("%s - want the %s webhook, got %+v", tt.name, tt.want, resp)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package webhook
+
+// Enforcement modes, see Options.Enforcement.
+const (
+	// EnforcementEnforce denies the objects falling short of the admission bar.
+	EnforcementEnforce = "enforce"
+	// EnforcementAudit admits every object, those that would have been denied
+	// with a warning.
+	EnforcementAudit = "audit"
+)
+
+func (o *Options) enforcement() string {
+	if o == nil || o.Enforcement == "" {
+		return EnforcementEnforce
+	}
+	return o.Enforcement
+}
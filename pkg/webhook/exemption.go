@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"k8s.io/apimachinery/pkg/labels"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+)
+
+// Exemption modes, see Options.ExemptionMode.
+const (
+	// ExemptionAllow admits exempted workloads without scanning them.
+	ExemptionAllow = "allow"
+	// ExemptionWarn scans exempted workloads but only warns when they fall
+	// short of the admission bar.
+	ExemptionWarn = "warn"
+)
+
+// DefaultExemptPriorityClasses are the priority classes of the cluster
+// critical addons.
+var DefaultExemptPriorityClasses = []string{"system-node-critical", "system-cluster-critical"}
+
+// ExemptionLister lists the exemptions of a namespace, see
+// policy.KubesecExemptions.
+type ExemptionLister interface {
+	List(ctx context.Context, namespace string) ([]policy.KubesecExemption, error)
+}
+
+// exemption returns why the object of the kind and namespace is exempted,
+// empty when it is not.
+func (o *Options) exemption(ctx context.Context, kind string, obj object, namespace string, logger log.Logger) string {
+	if o == nil {
+		return ""
+	}
+	if spec := podSpec(obj); spec != nil && spec.PriorityClassName != "" {
+		for _, pc := range o.ExemptPriorityClasses {
+			if spec.PriorityClassName == pc {
+				return "priority class " + pc
+			}
+		}
+	}
+
+	if o.Exemptions == nil || namespace == "" {
+		return ""
+	}
+	exemptions, err := o.Exemptions.List(ctx, namespace)
+	if err != nil {
+		logger.Warningf("%v", err)
+	}
+	objLabels := []labels.Set{obj.GetLabels()}
+	if pod := effectivePod(obj); pod != nil {
+		objLabels = append(objLabels, pod.Labels)
+	}
+	// The kind of the webhooks is lowercase and may differ from the Kind.
+	if k := obj.GetObjectKind().GroupVersionKind().Kind; k != "" {
+		kind = k
+	}
+	now := time.Now()
+	for _, e := range exemptions {
+		if e.Expired(now) || !e.Matches(kind, obj.GetName(), objLabels...) {
+			continue
+		}
+		return fmt.Sprintf("kubesec exemption %s until %s", e.Name, e.Spec.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	return ""
+}
+
+func (o *Options) exemptionMode() string {
+	if o == nil || o.ExemptionMode == "" {
+		return ExemptionAllow
+	}
+	return o.ExemptionMode
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+type fakeExemptions []policy.KubesecExemption
+
+func (f fakeExemptions) List(_ context.Context, namespace string) ([]policy.KubesecExemption, error) {
+	var res []policy.KubesecExemption
+	for _, e := range f {
+		if e.Namespace == namespace {
+			res = append(res, e)
+		}
+	}
+	return res, nil
+}
+
+// Test_review_kubesecExemption - tests the KubesecExemptions exempt the named workloads until they expire
+func Test_review_kubesecExemption(t *testing.T) {
+	newExemption := func(namespace, name string, expiresAt time.Time) policy.KubesecExemption {
+		e, err := policy.NewKubesecExemption(namespace, "legacy", policy.KubesecExemptionSpec{
+			Workloads: []policy.ExemptionWorkload{{Kind: "Pod", Name: name}},
+			ExpiresAt: metav1.NewTime(expiresAt),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+
+	tests := []struct {
+		name       string
+		exemptions fakeExemptions
+		mode       string
+		allowed    bool
+		message    string
+	}{
+		{name: "exempted", exemptions: fakeExemptions{newExemption("foo", "test", time.Now().Add(time.Hour))}, allowed: true},
+		{name: "expired", exemptions: fakeExemptions{newExemption("foo", "test", time.Now().Add(-time.Hour))}, allowed: false, message: "test score is -30"},
+		{name: "other workload", exemptions: fakeExemptions{newExemption("foo", "other", time.Now().Add(time.Hour))}, allowed: false},
+		{name: "other namespace", exemptions: fakeExemptions{newExemption("bar", "test", time.Now().Add(time.Hour))}, allowed: false},
+		{name: "warn mode", exemptions: fakeExemptions{newExemption("foo", "test", time.Now().Add(time.Hour))}, mode: ExemptionWarn, allowed: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Options{
+				Scanner:       &fakeScanner{result: scanner.Result{Score: -30}},
+				Exemptions:    tt.exemptions,
+				ExemptionMode: tt.mode,
+			}
+			_, res, err := opts.review(context.Background(), "pod", testPod("busybox"), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.allowed, res.Valid, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.message) {
+				t.Fatalf("review - want %q in message, got %q", tt.message, res.Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package webhook
+
+// Failure modes, see Options.FailureMode.
+const (
+	// FailOpen admits the objects that could not be scanned.
+	FailOpen = "open"
+	// FailClosed denies the objects that could not be scanned.
+	FailClosed = "closed"
+)
+
+func (o *Options) failureMode() string {
+	if o == nil || o.FailureMode == "" {
+		return FailOpen
+	}
+	return o.FailureMode
+}
@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	whcontext "github.com/slok/kubewebhook/pkg/webhook/context"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// adjust returns the result scored as the policies of the webhook require.
+func (o *Options) adjust(result scanner.Result, cluster *policy.Cluster) scanner.Result {
+	result = reweight(result, o.weights())
+	if cluster != nil {
+		result = excludeRules(result, cluster.ExcludedRules)
+	}
+	return result
+}
+
+// oldScore returns the score of the object stored in the cluster on UPDATE,
+// ok is false for other operations or when it could not be scored.
+func (o *Options) oldScore(ctx context.Context, kind string, obj object, cluster *policy.Cluster, logger log.Logger) (score int, ok bool) {
+	ar := whcontext.GetAdmissionRequest(ctx)
+	if ar == nil || ar.Operation != admissionv1beta1.Update || len(ar.OldObject.Raw) == 0 {
+		return 0, false
+	}
+
+	decoded, err := decodeOld(ar.OldObject.Raw, obj)
+	if err != nil {
+		logger.Warningf("could not decode old object: %v", err)
+		return 0, false
+	}
+	old, ok := decoded.(object)
+	if !ok {
+		return 0, false
+	}
+
+	result, err := o.scan(ctx, kind, old, logger)
+	if err == nil {
+		result, err = o.extend(ctx, kind, old, result)
+	}
+	if err != nil {
+		logger.Warningf("could not score the old %s %q: %v", kind, obj.GetName(), err)
+		return 0, false
+	}
+	return o.adjust(result, cluster).Score, true
+}
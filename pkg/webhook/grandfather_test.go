@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	whcontext "github.com/slok/kubewebhook/pkg/webhook/context"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// imageScanner scores the definitions after the image they run.
+type imageScanner map[string]int
+
+func (s imageScanner) Scan(_ context.Context, def []byte) (scanner.Results, error) {
+	for image, score := range s {
+		if bytes.Contains(def, []byte("image: "+image+"\n")) {
+			return scanner.Results{{Score: score}}, nil
+		}
+	}
+	return scanner.Results{{Score: 0}}, nil
+}
+
+// Test_review_grandfatherUpdates - tests the updates not decreasing the score are allowed
+func Test_review_grandfatherUpdates(t *testing.T) {
+	sc := imageScanner{"legacy:1": -5, "legacy:2": -5, "legacy:3": -9, "hardened": 3}
+
+	tests := []struct {
+		name        string
+		grandfather bool
+		op          admissionv1beta1.Operation
+		old, new    string
+		allowed     bool
+	}{
+		{name: "same score", grandfather: true, op: admissionv1beta1.Update, old: "legacy:1", new: "legacy:2", allowed: true},
+		{name: "decreasing score", grandfather: true, op: admissionv1beta1.Update, old: "legacy:1", new: "legacy:3", allowed: false},
+		{name: "increasing score", grandfather: true, op: admissionv1beta1.Update, old: "legacy:3", new: "legacy:1", allowed: true},
+		{name: "disabled", op: admissionv1beta1.Update, old: "legacy:1", new: "legacy:2", allowed: false},
+		{name: "create", grandfather: true, op: admissionv1beta1.Create, new: "legacy:2", allowed: false},
+		{name: "above minimum", grandfather: true, op: admissionv1beta1.Update, old: "legacy:1", new: "hardened", allowed: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ar := &admissionv1beta1.AdmissionRequest{Operation: tt.op}
+			if tt.old != "" {
+				raw, err := json.Marshal(testPod(tt.old))
+				if err != nil {
+					t.Fatal(err)
+				}
+				ar.OldObject = runtime.RawExtension{Raw: raw}
+			}
+			ctx := whcontext.SetAdmissionRequest(context.Background(), ar)
+
+			opts := &Options{Scanner: sc, GrandfatherUpdates: tt.grandfather}
+			_, res, err := opts.review(ctx, "pod", testPod(tt.new), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.allowed, res.Valid, res.Message)
+			}
+		})
+	}
+}
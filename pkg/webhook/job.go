@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"github.com/slok/kubewebhook/pkg/observability/metrics"
+	"github.com/slok/kubewebhook/pkg/webhook"
+	"github.com/slok/kubewebhook/pkg/webhook/validating"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// jobValidator validates the definition against the Kubesec.io score.
+type jobValidator struct {
+	minScore int
+	logger   log.Logger
+	opts     *Options
+}
+
+func (d *jobValidator) Validate(ctx context.Context, obj metav1.Object) (bool, validating.ValidatorResult, error) {
+	kObj, ok := obj.(*batchv1.Job)
+	if !ok {
+		reviewLogger(ctx, d.logger).Errorf("received invalid Job object %v", obj)
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+
+	kObj.TypeMeta = metav1.TypeMeta{
+		Kind:       "Job",
+		APIVersion: "batch/v1",
+	}
+
+	return d.opts.review(ctx, "job", kObj, d.minScore, d.logger)
+}
+
+// NewJobWebhook returns a new Job validating webhook.
+func NewJobWebhook(minScore int, opts *Options, mrec metrics.Recorder, logger log.Logger) (webhook.Webhook, error) {
+
+	// Create validators.
+	val := &jobValidator{
+		minScore: minScore,
+		logger:   logger,
+		opts:     opts,
+	}
+
+	cfg := validating.WebhookConfig{
+		Name: "kubesec-job",
+		Obj:  &batchv1.Job{},
+	}
+
+	return withAnnotations(validating.NewWebhook(cfg, opts.chain(val), mrec, logger))
+}
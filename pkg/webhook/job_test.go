@@ -0,0 +1,104 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	batchv1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Test_jobValidator_Validate - tests the pod template of a Job is scored
+// The hardened manifest should be allowed by the webhook and the insecure should be blocked
+func Test_jobValidator_Validate(t *testing.T) {
+	tests := []struct {
+		name     string // name of the test
+		score    int    // score returned by the scanner
+		result   bool   // response/result we expect from the webhook
+		minScore int    // minimum score used for initialisation
+		jobSpec  string // job specification in string
+	}{
+		{
+			name:     "Hardened Job Spec",
+			score:    7,
+			result:   true,
+			minScore: 0,
+			jobSpec: `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: hello
+  namespace: foo
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: hello
+        image: busybox
+        securityContext:
+          readOnlyRootFilesystem: true
+          runAsNonRoot: true
+`,
+		},
+		{
+			name:     "Insecure Job Spec",
+			score:    -30,
+			result:   false,
+			minScore: 0,
+			jobSpec: `
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: hello
+  namespace: foo
+spec:
+  template:
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: hello
+        image: busybox
+        securityContext:
+          privileged: true
+`,
+		},
+	}
+	for _, tt := range tests {
+
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			jv := jobValidator{
+				minScore: tt.minScore,
+				logger:   log.Dummy,
+				opts:     &Options{Scanner: &fakeScanner{result: scanner.Result{Score: tt.score}}},
+			}
+
+			decoder := serializer.NewCodecFactory(scheme.Scheme).UniversalDecoder()
+
+			job := &batchv1.Job{}
+
+			if err := runtime.DecodeInto(decoder, []byte(tt.jobSpec), job); err != nil {
+				t.Fatalf("unable to convert %q into Job object - %v", tt.jobSpec, err)
+			}
+
+			_, resp, err := jv.Validate(context.Background(), job)
+			if err != nil {
+				t.Fatalf("Job validator - got error %v", err)
+			}
+
+			if got := resp.Valid; got != tt.result {
+				t.Fatalf("Job validator - result mismatch, want=%v, got=%v", tt.result, got)
+			}
+			if images := images(job); len(images) != 1 || images[0] != "busybox" {
+				t.Fatalf("Job validator - want the job template containers, got %v", images)
+			}
+		})
+	}
+}
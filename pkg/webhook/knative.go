@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"github.com/slok/kubewebhook/pkg/observability/metrics"
+	"github.com/slok/kubewebhook/pkg/webhook"
+	"github.com/slok/kubewebhook/pkg/webhook/validating"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// knativeService is the part of a serving.knative.dev/v1 Service the webhook
+// scores. The revision template embeds a pod spec, the Knative specific
+// fields are ignored when decoding.
+type knativeService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec knativeServiceSpec `json:"spec"`
+}
+
+type knativeServiceSpec struct {
+	Template corev1.PodTemplateSpec `json:"template"`
+}
+
+// DeepCopyObject satisfies runtime.Object interface.
+func (k *knativeService) DeepCopyObject() runtime.Object {
+	if k == nil {
+		return nil
+	}
+	out := &knativeService{TypeMeta: k.TypeMeta}
+	k.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	k.Spec.Template.DeepCopyInto(&out.Spec.Template)
+	return out
+}
+
+// knativeServiceValidator validates the definition against the Kubesec.io score.
+type knativeServiceValidator struct {
+	minScore int
+	logger   log.Logger
+	opts     *Options
+}
+
+func (d *knativeServiceValidator) Validate(ctx context.Context, obj metav1.Object) (bool, validating.ValidatorResult, error) {
+	kObj, ok := obj.(*knativeService)
+	if !ok {
+		reviewLogger(ctx, d.logger).Errorf("received invalid Knative Service object %v", obj)
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+
+	kObj.TypeMeta = metav1.TypeMeta{
+		Kind:       "Service",
+		APIVersion: "serving.knative.dev/v1",
+	}
+
+	return d.opts.review(ctx, "knative service", kObj, d.minScore, d.logger)
+}
+
+// NewKnativeServiceWebhook returns a new Knative Service validating webhook.
+func NewKnativeServiceWebhook(minScore int, opts *Options, mrec metrics.Recorder, logger log.Logger) (webhook.Webhook, error) {
+
+	// Create validators.
+	val := &knativeServiceValidator{
+		minScore: minScore,
+		logger:   logger,
+		opts:     opts,
+	}
+
+	cfg := validating.WebhookConfig{
+		Name: "kubesec-knative-service",
+		Obj:  &knativeService{},
+	}
+
+	return withAnnotations(validating.NewWebhook(cfg, opts.chain(val), mrec, logger))
+}
@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Test_knativeServiceValidator_Validate - tests the revision template of a Knative Service is scored
+// The Knative only fields of the revision must not prevent the decoding
+func Test_knativeServiceValidator_Validate(t *testing.T) {
+	tests := []struct {
+		name      string // name of the test
+		score     int    // score returned by the scanner
+		result    bool   // response/result we expect from the webhook
+		minScore  int    // minimum score used for initialisation
+		ksvcSpec  string // knative service specification in string
+		wantImage string // image of the scored container
+	}{
+		{
+			name:      "Hardened Knative Service Spec",
+			score:     5,
+			result:    true,
+			minScore:  0,
+			wantImage: "gcr.io/knative-samples/helloworld-go",
+			ksvcSpec: `
+apiVersion: serving.knative.dev/v1
+kind: Service
+metadata:
+  name: hello
+  namespace: foo
+spec:
+  template:
+    metadata:
+      annotations:
+        autoscaling.knative.dev/target: "10"
+    spec:
+      containerConcurrency: 10
+      timeoutSeconds: 300
+      containers:
+      - image: gcr.io/knative-samples/helloworld-go
+        securityContext:
+          runAsNonRoot: true
+`,
+		},
+		{
+			name:      "Insecure Knative Service Spec",
+			score:     -30,
+			result:    false,
+			minScore:  0,
+			wantImage: "gcr.io/knative-samples/helloworld-go",
+			ksvcSpec: `
+apiVersion: serving.knative.dev/v1
+kind: Service
+metadata:
+  name: hello
+  namespace: foo
+spec:
+  template:
+    spec:
+      containers:
+      - image: gcr.io/knative-samples/helloworld-go
+        securityContext:
+          privileged: true
+`,
+		},
+	}
+	for _, tt := range tests {
+
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fs := &fakeScanner{result: scanner.Result{Score: tt.score}}
+			wh, err := NewKnativeServiceWebhook(tt.minScore, &Options{Scanner: fs}, nil, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			raw, err := yaml.YAMLToJSON([]byte(tt.ksvcSpec))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp := wh.Review(context.Background(), &admissionv1beta1.AdmissionReview{
+				Request: &admissionv1beta1.AdmissionRequest{
+					UID:       "1",
+					Operation: admissionv1beta1.Create,
+					Object:    runtime.RawExtension{Raw: raw},
+				},
+			})
+
+			if got := resp.Allowed; got != tt.result {
+				t.Fatalf("Knative Service validator - result mismatch, want=%v, got=%v: %v", tt.result, got, resp.Result)
+			}
+
+			ksvc := &knativeService{}
+			if err := yaml.Unmarshal([]byte(tt.ksvcSpec), ksvc); err != nil {
+				t.Fatal(err)
+			}
+			if images := images(ksvc); len(images) != 1 || images[0] != tt.wantImage {
+				t.Fatalf("Knative Service validator - want the revision containers, got %v", images)
+			}
+		})
+	}
+}
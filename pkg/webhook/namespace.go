@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// minScoreAnnotation set on a namespace overrides the minimum score of the
+// objects of the namespace.
+const minScoreAnnotation = "kubesec.io/min-score"
+
+// namespaceTimeout bounds the read of a namespace, the cache may have to be
+// synced first.
+const namespaceTimeout = 2 * time.Second
+
+// DefaultExcludeNamespaces are the namespaces of the cluster critical
+// components.
+var DefaultExcludeNamespaces = []string{"kube-system", "kube-public"}
+
+// excludedNamespace tells whether the objects of the namespace are never
+// scanned.
+func (o *Options) excludedNamespace(name string) bool {
+	if o == nil || name == "" {
+		return false
+	}
+	for _, ns := range o.ExcludeNamespaces {
+		if ns == name {
+			return true
+		}
+	}
+	return false
+}
+
+// namespace returns the namespace, nil when it can't be read.
+func (o *Options) namespace(ctx context.Context, name string, logger log.Logger) *corev1.Namespace {
+	if o == nil || o.Namespaces == nil || name == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, namespaceTimeout)
+	defer cancel()
+
+	ns := &corev1.Namespace{}
+	if err := o.Namespaces.Get(ctx, client.ObjectKey{Name: name}, ns); err != nil {
+		logger.Warningf("could not read namespace %q: %v", name, err)
+		return nil
+	}
+	return ns
+}
+
+// namespaceMinScore returns the minimum score of the namespace, minScore
+// unless the namespace overrides it.
+func namespaceMinScore(ns *corev1.Namespace, minScore int, logger log.Logger) int {
+	if ns == nil {
+		return minScore
+	}
+
+	v, ok := ns.Annotations[minScoreAnnotation]
+	if !ok {
+		return minScore
+	}
+	score, err := strconv.Atoi(v)
+	if err != nil {
+		logger.Warningf("ignoring invalid %s annotation %q of namespace %q", minScoreAnnotation, v, ns.Name)
+		return minScore
+	}
+
+	return score
+}
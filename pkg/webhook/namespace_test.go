@@ -0,0 +1,79 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Test_review_namespaceMinScore - tests the namespace annotation overrides the minimum score
+func Test_review_namespaceMinScore(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		namespace   string
+		disabled    bool
+		allowed     bool
+	}{
+		{name: "lowered", namespace: "foo", annotations: map[string]string{minScoreAnnotation: "-10"}, allowed: true},
+		{name: "disabled", namespace: "foo", annotations: map[string]string{minScoreAnnotation: "-10"}, disabled: true, allowed: false},
+		{name: "raised", namespace: "foo", annotations: map[string]string{minScoreAnnotation: "-1"}, allowed: false},
+		{name: "not annotated", namespace: "foo", allowed: false},
+		{name: "invalid annotation", namespace: "foo", annotations: map[string]string{minScoreAnnotation: "low"}, allowed: false},
+		{name: "missing namespace", namespace: "bar", annotations: map[string]string{minScoreAnnotation: "-10"}, allowed: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: tt.namespace, Annotations: tt.annotations}}
+			opts := &Options{
+				Scanner:           &fakeScanner{result: scanner.Result{Score: -5}},
+				Namespaces:        fake.NewClientBuilder().WithObjects(ns).Build(),
+				NamespaceMinScore: !tt.disabled,
+			}
+			_, res, err := opts.review(context.Background(), "pod", testPod("busybox"), -2, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.allowed, res.Valid, res.Message)
+			}
+		})
+	}
+}
+
+// Test_review_excludeNamespaces - tests the objects of the excluded namespaces are not scanned
+func Test_review_excludeNamespaces(t *testing.T) {
+	tests := []struct {
+		name     string
+		excluded []string
+		scans    int
+	}{
+		{name: "other namespaces excluded", excluded: DefaultExcludeNamespaces, scans: 1},
+		{name: "object namespace excluded", excluded: []string{"kube-system", "foo"}, scans: 0},
+		{name: "no exclusion", scans: 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			sc := &countingScanner{fakeScanner: fakeScanner{result: scanner.Result{Score: -30}}}
+			opts := &Options{Scanner: sc, ExcludeNamespaces: tt.excluded}
+			_, res, err := opts.review(context.Background(), "pod", testPod("busybox"), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sc.scans != tt.scans {
+				t.Fatalf("review - want %d scans, got %d", tt.scans, sc.scans)
+			}
+			if allowed := tt.scans == 0; res.Valid != allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", allowed, res.Valid, res.Message)
+			}
+		})
+	}
+}
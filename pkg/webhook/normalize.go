@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultServiceAccount is set on Pods by the ServiceAccount admission plugin
+// when the template does not name one.
+const defaultServiceAccount = "default"
+
+// tokenVolumePrefix names the volume projecting the service account token,
+// added to Pods by the ServiceAccount admission plugin.
+const tokenVolumePrefix = "kube-api-access-"
+
+// effectivePod returns the Pod the object runs, so a workload and the Pods it
+// creates are scored on the same definition and can never disagree. Fields a
+// Pod only gets once created, e.g. the default service account or the
+// projected token volume, are removed. It returns nil for unknown kinds.
+func effectivePod(obj object) *corev1.Pod {
+	var pod *corev1.Pod
+	switch o := obj.(type) {
+	case *corev1.Pod:
+		pod = &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        o.Name,
+				Namespace:   o.Namespace,
+				Labels:      o.Labels,
+				Annotations: o.Annotations,
+			},
+			Spec: *o.Spec.DeepCopy(),
+		}
+	case *appsv1.Deployment:
+		pod = fromTemplate(o, &o.Spec.Template)
+	case *appsv1.DaemonSet:
+		pod = fromTemplate(o, &o.Spec.Template)
+	case *appsv1.StatefulSet:
+		pod = fromTemplate(o, &o.Spec.Template)
+	case *appsv1.ReplicaSet:
+		pod = fromTemplate(o, &o.Spec.Template)
+	case *batchv1.Job:
+		pod = fromTemplate(o, &o.Spec.Template)
+	case *knativeService:
+		pod = fromTemplate(o, &o.Spec.Template)
+	case *customResource:
+		pod = fromTemplate(o, &o.template)
+	case *batchv1.CronJob:
+		pod = fromTemplate(o, &o.Spec.JobTemplate.Spec.Template)
+	default:
+		return nil
+	}
+
+	pod.TypeMeta = metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"}
+	normalizePodSpec(&pod.Spec)
+
+	return pod
+}
+
+// fromTemplate returns the Pod of the template, named after the workload.
+func fromTemplate(obj metav1.Object, tpl *corev1.PodTemplateSpec) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        obj.GetName(),
+			Namespace:   obj.GetNamespace(),
+			Labels:      tpl.Labels,
+			Annotations: tpl.Annotations,
+		},
+		Spec: *tpl.Spec.DeepCopy(),
+	}
+}
+
+// normalizePodSpec removes the fields set on Pods by the API server and the
+// built in admission plugins.
+func normalizePodSpec(spec *corev1.PodSpec) {
+	spec.NodeName = ""
+	if spec.ServiceAccountName == defaultServiceAccount {
+		spec.ServiceAccountName = ""
+	}
+	if spec.DeprecatedServiceAccount == defaultServiceAccount || spec.DeprecatedServiceAccount == spec.ServiceAccountName {
+		spec.DeprecatedServiceAccount = ""
+	}
+
+	var token string
+	volumes := spec.Volumes[:0]
+	for _, v := range spec.Volumes {
+		if strings.HasPrefix(v.Name, tokenVolumePrefix) && v.Projected != nil {
+			token = v.Name
+			continue
+		}
+		volumes = append(volumes, v)
+	}
+	if len(volumes) == 0 {
+		volumes = nil
+	}
+	spec.Volumes = volumes
+	if token == "" {
+		return
+	}
+
+	for _, containers := range [][]corev1.Container{spec.InitContainers, spec.Containers} {
+		for i := range containers {
+			mounts := containers[i].VolumeMounts[:0]
+			for _, m := range containers[i].VolumeMounts {
+				if m.Name != token {
+					mounts = append(mounts, m)
+				}
+			}
+			if len(mounts) == 0 {
+				mounts = nil
+			}
+			containers[i].VolumeMounts = mounts
+		}
+	}
+}
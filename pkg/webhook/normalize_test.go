@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Test_effectivePod - tests a workload and the Pods it creates are scored on the same definition
+func Test_effectivePod(t *testing.T) {
+	yes := true
+	spec := corev1.PodSpec{
+		Containers: []corev1.Container{{
+			Name:            "main",
+			Image:           "busybox",
+			SecurityContext: &corev1.SecurityContext{RunAsNonRoot: &yes},
+		}},
+	}
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "foo"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "app"}},
+			Spec:       spec,
+		}},
+	}
+
+	// The Pod as created by the ReplicaSet and mutated by the API server.
+	created := spec.DeepCopy()
+	created.NodeName = "node-1"
+	created.ServiceAccountName = "default"
+	created.DeprecatedServiceAccount = "default"
+	created.Volumes = []corev1.Volume{{
+		Name:         "kube-api-access-x2x7k",
+		VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{}},
+	}}
+	created.Containers[0].VolumeMounts = []corev1.VolumeMount{{Name: "kube-api-access-x2x7k", MountPath: "/var/run/secrets/kubernetes.io/serviceaccount"}}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "foo", Labels: map[string]string{"app": "app"}},
+		Spec:       *created,
+	}
+
+	fromDeploy, fromPod := effectivePod(deploy), effectivePod(pod)
+	if !reflect.DeepEqual(fromDeploy, fromPod) {
+		t.Fatalf("effectivePod - definitions disagree\ndeployment: %+v\npod:        %+v", fromDeploy.Spec, fromPod.Spec)
+	}
+	if fromPod.Kind != "Pod" {
+		t.Fatalf("effectivePod - want kind Pod, got %q", fromPod.Kind)
+	}
+	if pod.Spec.NodeName != "node-1" || len(pod.Spec.Volumes) != 1 {
+		t.Fatalf("effectivePod - the admitted object must not be modified, got %+v", pod.Spec)
+	}
+}
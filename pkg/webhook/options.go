@@ -0,0 +1,182 @@
+package webhook
+
+import (
+	"fmt"
+	neturl "net/url"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/webhook/validating"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	kubesecmetrics "github.com/controlplaneio/kubesec-webhook/pkg/metrics"
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// defaultScanner is used by validators that were not given a scanner. It is
+// shared so rate limiting applies to all of them.
+var defaultScanner = scanner.NewClient(DefaultScanURL, timeOut*time.Second)
+
+// NewScanner returns a client for the Kubesec API at url, e.g. DefaultScanURL
+// or a self-hosted instance, whose connections are tuned by cfg, to be set as
+// Options.Scanner.
+func NewScanner(url string, cfg scanner.TransportConfig) (*scanner.Client, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid kubesec url %q: %w", url, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid kubesec url %q, want an http or https url", url)
+	}
+	return scanner.NewClientWithTransport(url, timeOut*time.Second, cfg), nil
+}
+
+// NewSidecarScanner returns a client for the Kubesec scanner container of the
+// pod listening on addr, to be set as Options.Scanner.
+func NewSidecarScanner(addr string, cfg scanner.TransportConfig) (*scanner.Sidecar, error) {
+	return scanner.NewSidecar(addr, timeOut*time.Second, cfg)
+}
+
+// Options are the settings shared by all the validators. A nil *Options is
+// valid and uses the defaults.
+type Options struct {
+	// Scanner scores the objects, defaults to the Kubesec.io API.
+	Scanner scanner.Scanner
+	// Recorder records the kubesec specific metrics.
+	Recorder kubesecmetrics.Recorder
+	// Sink receives every decision taken, optional.
+	Sink decision.Sink
+	// Images overrides the admission bar based on the images, optional.
+	Images *policy.Images
+	// ExcludeNamespaces are the namespaces whose objects are allowed without
+	// being scanned, whatever the namespace selector of the webhooks.
+	ExcludeNamespaces []string
+	// TrustedUsers and TrustedGroups are the users, service accounts
+	// included, and the groups whose requests are allowed without being
+	// scanned.
+	TrustedUsers  []string
+	TrustedGroups []string
+	// ExemptPriorityClasses are the priority classes of the workloads that
+	// are never denied, see ExemptionMode.
+	ExemptPriorityClasses []string
+	// Exemptions lists the KubesecExemptions of the namespaces, optional.
+	Exemptions ExemptionLister
+	// ExemptionMode is ExemptionAllow (default) or ExemptionWarn.
+	ExemptionMode string
+	// Weights override the points of the Kubesec checks, optional.
+	Weights policy.Weights
+	// WarnScore is the score below which allowed objects get an admission
+	// warning, optional.
+	WarnScore *int
+	// RequiredChecks are the IDs of the checks every object must pass,
+	// whatever its score, on top of those of the image policy.
+	RequiredChecks []string
+	// DeniedRules are the IDs of the critical checks denying the objects
+	// failing them, whatever their score.
+	DeniedRules []string
+	// SkipSelector selects the objects opting out of scanning with their
+	// labels, or those of their pod template, optional.
+	SkipSelector labels.Selector
+	// SkipNamespaces are the namespaces whose workloads may opt out of
+	// scanning, with the kubesec.io/skip annotation or the SkipSelector, any
+	// when empty.
+	SkipNamespaces []string
+	// GrandfatherUpdates allows the updates of objects scoring below the
+	// minimum score as long as their score does not decrease.
+	GrandfatherUpdates bool
+	// SkipUnchangedUpdates allows the updates leaving the pod template of
+	// the objects unchanged without scanning them.
+	SkipUnchangedUpdates bool
+	// Enforcement is EnforcementEnforce (default) or EnforcementAudit.
+	Enforcement string
+	// FailureMode is FailOpen (default) or FailClosed, it decides the fate
+	// of the objects that could not be scanned.
+	FailureMode string
+	// DenyDetail is DenyDetailFull (default), DenyDetailSummary or
+	// DenyDetailMinimal, it decides how much of the scan the denial messages
+	// return to the user.
+	DenyDetail string
+	// ScannerVersion identifies the scanner in the audit annotations of
+	// the responses, omitted when empty.
+	ScannerVersion string
+	// DenyMessageMaxLength caps the length in bytes of the denial messages,
+	// request ID included, zero leaves them whole.
+	DenyMessageMaxLength int
+	// Namespaces reads the namespaces of the objects, for their minimum score
+	// annotation, the namespace selectors of the Policies and the decision
+	// policy, optional.
+	Namespaces client.Reader
+	// NamespaceMinScore overrides the minimum score of the objects with the
+	// annotation of their namespace.
+	NamespaceMinScore bool
+	// Policies lists the KubesecPolicies overriding the flags in the
+	// namespaces they select, optional.
+	Policies PolicyLister
+	// DecisionPolicy has the final say on the scanned objects, optional.
+	DecisionPolicy DecisionPolicy
+	// Plugins add their checks to the scans, in order, optional.
+	Plugins []Plugin
+	// Validators run after the kubesec check in the same admission call, in
+	// a ValidatorChain, e.g. to require labels. They are given the object
+	// decoded by the webhook of its kind, unstructured for the custom
+	// resources, optional.
+	Validators []validating.Validator
+}
+
+func (o *Options) scanner() scanner.Scanner {
+	if o == nil || o.Scanner == nil {
+		return defaultScanner
+	}
+	return o.Scanner
+}
+
+func (o *Options) recorder() kubesecmetrics.Recorder {
+	if o == nil || o.Recorder == nil {
+		return kubesecmetrics.Dummy
+	}
+	return o.Recorder
+}
+
+func (o *Options) images() *policy.Images {
+	if o == nil {
+		return nil
+	}
+	return o.Images
+}
+
+func (o *Options) warnScore() *int {
+	if o == nil {
+		return nil
+	}
+	return o.WarnScore
+}
+
+func (o *Options) weights() policy.Weights {
+	if o == nil {
+		return nil
+	}
+	return o.Weights
+}
+
+func (o *Options) requiredChecks() []string {
+	if o == nil {
+		return nil
+	}
+	return o.RequiredChecks
+}
+
+func (o *Options) deniedRules() []string {
+	if o == nil {
+		return nil
+	}
+	return o.DeniedRules
+}
+
+func (o *Options) sink() decision.Sink {
+	if o == nil {
+		return nil
+	}
+	return o.Sink
+}
@@ -0,0 +1,27 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// TestNewScanner - tests the validation of the Kubesec API url
+func TestNewScanner(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{url: DefaultScanURL},
+		{url: "http://kubesec.kubesec.svc:8080/scan"},
+		{url: "kubesec.kubesec.svc:8080", wantErr: true},
+		{url: "ftp://kubesec.example.com", wantErr: true},
+		{url: "https://", wantErr: true},
+	}
+	for _, tt := range tests {
+		_, err := NewScanner(tt.url, scanner.DefaultTransportConfig())
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("NewScanner(%q) - want error %v, got %v", tt.url, tt.wantErr, err)
+		}
+	}
+}
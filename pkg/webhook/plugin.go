@@ -0,0 +1,37 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/plugin"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Plugin adds its own checks to the scans, see plugin.WASM.
+type Plugin interface {
+	Name() string
+	Check(ctx context.Context, in plugin.Input) ([]plugin.Finding, error)
+}
+
+// extend returns the result with the findings of the plugins, run in order
+// on the scan of the previous ones. A failing plugin fails the scan.
+func (o *Options) extend(ctx context.Context, kind string, obj object, result scanner.Result) (scanner.Result, error) {
+	if o == nil || len(o.Plugins) == 0 {
+		return result, nil
+	}
+
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return scanner.Result{}, fmt.Errorf("%s serialization failed %w", kind, err)
+	}
+	for _, p := range o.Plugins {
+		findings, err := p.Check(ctx, plugin.Input{Kind: kind, Object: raw, Scan: result})
+		if err != nil {
+			return scanner.Result{}, err
+		}
+		result = plugin.Apply(result, findings)
+	}
+	return result, nil
+}
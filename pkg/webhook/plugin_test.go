@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/plugin"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// fakePlugin returns the same findings for every object.
+type fakePlugin struct {
+	findings []plugin.Finding
+	err      error
+	inputs   []plugin.Input
+}
+
+func (f *fakePlugin) Name() string { return "fake" }
+
+func (f *fakePlugin) Check(_ context.Context, in plugin.Input) ([]plugin.Finding, error) {
+	f.inputs = append(f.inputs, in)
+	return f.findings, f.err
+}
+
+// Test_review_plugins - tests the findings of the plugins are part of the scan
+func Test_review_plugins(t *testing.T) {
+	tests := []struct {
+		name        string
+		plugin      *fakePlugin
+		failureMode string
+		allowed     bool
+		message     string
+	}{
+		{name: "no finding", plugin: &fakePlugin{}, allowed: true},
+		{
+			name:    "critical finding",
+			plugin:  &fakePlugin{findings: []plugin.Finding{{ID: "NoLatestTag", Points: -3, Severity: plugin.SeverityCritical}}},
+			message: "test fails the denied checks NoLatestTag",
+		},
+		{name: "failing plugin", plugin: &fakePlugin{err: errors.New("plugin fake failed")}, allowed: true},
+		{name: "failing plugin closed", plugin: &fakePlugin{err: errors.New("plugin fake failed")}, failureMode: FailClosed, message: "plugin fake failed"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Options{
+				Scanner:     &fakeScanner{result: scanner.Result{Score: 1}},
+				Plugins:     []Plugin{tt.plugin},
+				DeniedRules: []string{"NoLatestTag"},
+				FailureMode: tt.failureMode,
+			}
+			_, res, err := opts.review(context.Background(), "pod", testPod("busybox:latest"), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.allowed, res.Valid, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.message) {
+				t.Fatalf("review - want %q in message, got %q", tt.message, res.Message)
+			}
+			if in := tt.plugin.inputs; len(in) != 1 || in[0].Kind != "pod" || in[0].Scan.Score != 1 || !strings.Contains(string(in[0].Object), "busybox:latest") {
+				t.Fatalf("review - want the object and its scan given to the plugin, got %+v", in)
+			}
+		})
+	}
+}
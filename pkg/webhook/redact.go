@@ -0,0 +1,109 @@
+package webhook
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+const (
+	// redacted replaces the sensitive values in the logs and messages.
+	redacted = "[redacted]"
+	// minRedactedLen is the length under which the values are not redacted,
+	// they would mangle the messages without hiding anything.
+	minRedactedLen = 4
+)
+
+// redactor returns a replacer of the sensitive values of the object, the
+// environment variable values and arguments of its containers, as the backend
+// may echo them in its scan results and errors.
+func redactor(obj runtime.Object) *strings.Replacer {
+	spec := podSpec(obj)
+	if spec == nil {
+		return strings.NewReplacer()
+	}
+
+	seen := map[string]bool{}
+	var values []string
+	add := func(v string) {
+		if len(v) >= minRedactedLen && !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	addContainer := func(c corev1.Container) {
+		for _, e := range c.Env {
+			add(e.Value)
+		}
+		for _, a := range c.Args {
+			add(a)
+		}
+	}
+	for _, c := range spec.InitContainers {
+		addContainer(c)
+	}
+	for _, c := range spec.Containers {
+		addContainer(c)
+	}
+	for _, c := range spec.EphemeralContainers {
+		addContainer(corev1.Container(c.EphemeralContainerCommon))
+	}
+
+	// The longest values go first, so a value holding another is redacted
+	// whole.
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	pairs := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		pairs = append(pairs, v, redacted)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// redactResult returns the result with the sensitive values replaced.
+func redactResult(r *strings.Replacer, result scanner.Result) scanner.Result {
+	result.Object = r.Replace(result.Object)
+	result.Message = r.Replace(result.Message)
+	result.Error = r.Replace(result.Error)
+	result.Scoring = scanner.Scoring{
+		Critical: redactRules(r, result.Scoring.Critical),
+		Passed:   redactRules(r, result.Scoring.Passed),
+		Advise:   redactRules(r, result.Scoring.Advise),
+	}
+	return result
+}
+
+func redactRules(r *strings.Replacer, rules []scanner.Rule) []scanner.Rule {
+	if rules == nil {
+		return nil
+	}
+	res := make([]scanner.Rule, len(rules))
+	for i, rule := range rules {
+		rule.Selector = r.Replace(rule.Selector)
+		rule.Reason = r.Replace(rule.Reason)
+		res[i] = rule
+	}
+	return res
+}
+
+// redactedError is an error with the sensitive values replaced in its message,
+// it still wraps the original error.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func redactError(r *strings.Replacer, err error) error {
+	return &redactedError{msg: r.Replace(err.Error()), err: err}
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,72 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Test_review_redact - tests the env values and args are not echoed back
+func Test_review_redact(t *testing.T) {
+	const secret = "s3cr3t-token"
+	tests := []struct {
+		name    string
+		scanner *fakeScanner
+	}{
+		{
+			name: "result",
+			scanner: &fakeScanner{result: scanner.Result{
+				Score:   -30,
+				Message: "failed with TOKEN=" + secret,
+				Scoring: scanner.Scoring{Critical: []scanner.Rule{{ID: "Privileged", Reason: "--token=" + secret + " is set"}}},
+			}},
+		},
+		{
+			name:    "error",
+			scanner: &fakeScanner{err: errors.New("could not parse " + secret)},
+		},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			pod := testPod("busybox")
+			pod.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "TOKEN", Value: secret}, {Name: "DEBUG", Value: "1"}}
+			pod.Spec.Containers[0].Args = []string{"--token=" + secret}
+			sink := &recordingSink{}
+			opts := &Options{Scanner: tt.scanner, Sink: sink, FailureMode: FailClosed}
+
+			_, res, err := opts.review(context.Background(), "pod", pod, 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid {
+				t.Fatalf("review - want denied, got allowed")
+			}
+			if strings.Contains(res.Message, secret) || !strings.Contains(res.Message, redacted) {
+				t.Fatalf("review - want the secret redacted from the message, got %s", res.Message)
+			}
+			rec := sink.records[0]
+			if strings.Contains(rec.Error, secret) || (rec.Scan != nil && strings.Contains(rec.Scan.Message+rec.Scan.Scoring.Critical[0].Reason, secret)) {
+				t.Fatalf("review - want the secret redacted from the record, got %+v", rec)
+			}
+		})
+	}
+}
+
+// Test_redactor - tests the short values are kept and the longest go first
+func Test_redactor(t *testing.T) {
+	pod := testPod("busybox")
+	pod.Spec.InitContainers = []corev1.Container{{Name: "init", Env: []corev1.EnvVar{{Name: "A", Value: "abcd"}}}}
+	pod.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "B", Value: "abcdef"}, {Name: "C", Value: "on"}}
+
+	got := redactor(pod).Replace("abcdef abcd on")
+	if want := redacted + " " + redacted + " on"; got != want {
+		t.Fatalf("redactor - want=%q, got=%q", want, got)
+	}
+}
@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"context"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"github.com/slok/kubewebhook/pkg/observability/metrics"
+	"github.com/slok/kubewebhook/pkg/webhook"
+	"github.com/slok/kubewebhook/pkg/webhook/validating"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// replicaSetValidator validates the definition against the Kubesec.io score.
+type replicaSetValidator struct {
+	minScore int
+	logger   log.Logger
+	opts     *Options
+}
+
+func (d *replicaSetValidator) Validate(ctx context.Context, obj metav1.Object) (bool, validating.ValidatorResult, error) {
+	kObj, ok := obj.(*appsv1.ReplicaSet)
+	if !ok {
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+
+	// ReplicaSets of a Deployment run its template, which was already scored
+	// when the Deployment was admitted.
+	if owner := metav1.GetControllerOf(kObj); owner != nil && owner.Kind == "Deployment" {
+		reviewLogger(ctx, d.logger).Debugf("skipping replicaset %q controlled by deployment %q", kObj.Name, owner.Name)
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+
+	kObj.TypeMeta = metav1.TypeMeta{
+		Kind:       "ReplicaSet",
+		APIVersion: "apps/v1",
+	}
+
+	return d.opts.review(ctx, "replicaset", kObj, d.minScore, d.logger)
+}
+
+// NewReplicaSetWebhook returns a new replicaset validating webhook.
+func NewReplicaSetWebhook(minScore int, opts *Options, mrec metrics.Recorder, logger log.Logger) (webhook.Webhook, error) {
+
+	// Create validators.
+	val := &replicaSetValidator{
+		minScore: minScore,
+		logger:   logger,
+		opts:     opts,
+	}
+
+	cfg := validating.WebhookConfig{
+		Name: "kubesec-replicaset",
+		Obj:  &appsv1.ReplicaSet{},
+	}
+
+	return withAnnotations(validating.NewWebhook(cfg, opts.chain(val), mrec, logger))
+}
@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Test_replicaSetValidator_Validate - tests the validation of ReplicaSets created directly
+// ReplicaSets controlled by a Deployment are not scored again
+func Test_replicaSetValidator_Validate(t *testing.T) {
+	tests := []struct {
+		name    string // name of the test
+		result  bool   // response/result we expect from the webhook
+		scanned bool   // whether the replicaset must be scanned
+		rsSpec  string // replicaset specification in string
+	}{
+		{
+			name:    "Insecure ReplicaSet Spec",
+			result:  false,
+			scanned: true,
+			rsSpec: `
+apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  name: frontend
+  namespace: foo
+spec:
+  selector:
+    matchLabels:
+      app: frontend
+  template:
+    metadata:
+      labels:
+        app: frontend
+    spec:
+      containers:
+      - name: main
+        image: busybox
+        securityContext:
+          privileged: true
+`,
+		},
+		{
+			name:    "ReplicaSet of a Deployment",
+			result:  true,
+			scanned: false,
+			rsSpec: `
+apiVersion: apps/v1
+kind: ReplicaSet
+metadata:
+  name: frontend-5d4f8
+  namespace: foo
+  ownerReferences:
+  - apiVersion: apps/v1
+    kind: Deployment
+    name: frontend
+    uid: 2c8c7b5e-6a0e-4a43-9b14-1d0f3c5a9f11
+    controller: true
+spec:
+  selector:
+    matchLabels:
+      app: frontend
+  template:
+    metadata:
+      labels:
+        app: frontend
+    spec:
+      containers:
+      - name: main
+        image: busybox
+        securityContext:
+          privileged: true
+`,
+		},
+	}
+	for _, tt := range tests {
+
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			fs := &countingScanner{fakeScanner: fakeScanner{result: scanner.Result{Score: -30}}}
+			rv := replicaSetValidator{
+				logger: log.Dummy,
+				opts:   &Options{Scanner: fs},
+			}
+
+			decoder := serializer.NewCodecFactory(scheme.Scheme).UniversalDecoder()
+
+			rs := &appsv1.ReplicaSet{}
+
+			if err := runtime.DecodeInto(decoder, []byte(tt.rsSpec), rs); err != nil {
+				t.Fatalf("unable to convert %q into ReplicaSet object - %v", tt.rsSpec, err)
+			}
+
+			_, resp, err := rv.Validate(context.Background(), rs)
+			if err != nil {
+				t.Fatalf("ReplicaSet validator - got error %v", err)
+			}
+
+			if got := resp.Valid; got != tt.result {
+				t.Fatalf("ReplicaSet validator - result mismatch, want=%v, got=%v", tt.result, got)
+			}
+			if scanned := fs.scans > 0; scanned != tt.scanned {
+				t.Fatalf("ReplicaSet validator - want scanned=%v, got %v", tt.scanned, scanned)
+			}
+		})
+	}
+}
+
+// countingScanner counts the scans.
+type countingScanner struct {
+	fakeScanner
+	scans int
+}
+
+func (c *countingScanner) Scan(ctx context.Context, def []byte) (scanner.Results, error) {
+	c.scans++
+	if len(def) == 0 {
+		return nil, errors.New("empty definition")
+	}
+	return c.fakeScanner.Scan(ctx, def)
+}
@@ -0,0 +1,67 @@
+package webhook
+
+import (
+	"context"
+	"strings"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	whcontext "github.com/slok/kubewebhook/pkg/webhook/context"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+)
+
+// Rescan reviews an existing object, e.g. one admitted before the webhook
+// was deployed, as if it was created and returns the decision. The object
+// must have its TypeMeta set, the Knative Services may be unstructured. The
+// decision is written to the sink with the decision.OperationRescan
+// operation, nothing is blocked.
+func (o *Options) Rescan(ctx context.Context, obj client.Object, minScore int, logger log.Logger) decision.Record {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	kind := strings.ToLower(gvk.Kind)
+	if gvk.Group == "serving.knative.dev" && gvk.Kind == "Service" {
+		kind = "knative service"
+		if u, ok := obj.(*unstructured.Unstructured); ok {
+			ks := &knativeService{}
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, ks); err != nil {
+				logger.Errorf("could not decode knative service %q: %v", u.GetName(), err)
+				return decision.Record{}
+			}
+			obj = ks
+		}
+	}
+	ar := &admissionv1beta1.AdmissionRequest{
+		Kind:      metav1.GroupVersionKind(gvk),
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+		Operation: admissionv1beta1.Operation(decision.OperationRescan),
+	}
+
+	var cp Options
+	if o != nil {
+		cp = *o
+	}
+	last := &lastRecord{next: cp.Sink}
+	cp.Sink = last
+	_, _, _ = cp.review(whcontext.SetAdmissionRequest(ctx, ar), kind, obj, minScore, logger)
+	return last.rec
+}
+
+// lastRecord keeps the last decision written to it, and passes it on to next.
+type lastRecord struct {
+	rec  decision.Record
+	next decision.Sink
+}
+
+// Write satisfies decision.Sink interface.
+func (l *lastRecord) Write(ctx context.Context, rec decision.Record) error {
+	l.rec = rec
+	if l.next == nil {
+		return nil
+	}
+	return l.next.Write(ctx, rec)
+}
@@ -0,0 +1,65 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// TestOptions_Rescan - tests the existing objects are reviewed and their decision written
+func TestOptions_Rescan(t *testing.T) {
+	tests := []struct {
+		name        string
+		score       int
+		enforcement string
+		allowed     bool
+		audit       bool
+	}{
+		{name: "passing", score: 3, allowed: true},
+		{name: "failing", score: -30, allowed: false},
+		{name: "failing audited", score: -30, enforcement: EnforcementAudit, allowed: true, audit: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			opts := &Options{Scanner: &fakeScanner{result: scanner.Result{Score: tt.score}}, Sink: sink, Enforcement: tt.enforcement}
+			rec := opts.Rescan(context.Background(), testPod("busybox"), 0, log.Dummy)
+			if rec.Allowed != tt.allowed || rec.Audit != tt.audit || rec.Score != tt.score {
+				t.Fatalf("Rescan - want allowed=%v audit=%v score=%d, got %+v", tt.allowed, tt.audit, tt.score, rec)
+			}
+			if rec.Operation != decision.OperationRescan || rec.Kind != "pod" || rec.ObjectKind != "Pod" {
+				t.Fatalf("Rescan - want a RESCAN of the pod, got %+v", rec)
+			}
+			if len(sink.records) != 1 || sink.records[0].Operation != decision.OperationRescan {
+				t.Fatalf("Rescan - want the decision written, got %+v", sink.records)
+			}
+			if opts.Sink != sink {
+				t.Fatal("Rescan - want the options unchanged")
+			}
+		})
+	}
+}
+
+// TestOptions_Rescan_knative - tests the unstructured Knative Services are reviewed
+func TestOptions_Rescan_knative(t *testing.T) {
+	svc := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "serving.knative.dev/v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"namespace": "foo", "name": "hello"},
+		"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+			"containers": []interface{}{map[string]interface{}{"image": "busybox"}},
+		}}},
+	}}
+	opts := &Options{Scanner: &fakeScanner{result: scanner.Result{Score: 3}}}
+
+	rec := opts.Rescan(context.Background(), svc, 0, log.Dummy)
+	if !rec.Allowed || rec.Score != 3 || rec.Kind != "knative service" || rec.Name != "hello" || rec.SpecHash == "" {
+		t.Fatalf("Rescan - want the knative service scanned, got %+v", rec)
+	}
+}
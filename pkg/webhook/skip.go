@@ -0,0 +1,37 @@
+package webhook
+
+import (
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// skipAnnotation set to "true" on a workload, or on its pod template, admits
+// it without scanning, see Options.SkipNamespaces.
+const skipAnnotation = "kubesec.io/skip"
+
+// skipped returns how the object of the namespace opted out of scanning,
+// empty when it did not.
+func (o *Options) skipped(obj object, namespace string) string {
+	pod := effectivePod(obj)
+
+	var reason string
+	switch {
+	case obj.GetAnnotations()[skipAnnotation] == "true",
+		pod != nil && pod.Annotations[skipAnnotation] == "true":
+		reason = "the " + skipAnnotation + " annotation"
+	case o != nil && o.SkipSelector != nil && !o.SkipSelector.Empty() &&
+		(o.SkipSelector.Matches(labels.Set(obj.GetLabels())) || pod != nil && o.SkipSelector.Matches(labels.Set(pod.Labels))):
+		reason = "the labels " + o.SkipSelector.String()
+	default:
+		return ""
+	}
+
+	if o == nil || len(o.SkipNamespaces) == 0 {
+		return reason
+	}
+	for _, ns := range o.SkipNamespaces {
+		if ns == namespace {
+			return reason
+		}
+	}
+	return ""
+}
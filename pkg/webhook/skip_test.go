@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	kubesecmetrics "github.com/controlplaneio/kubesec-webhook/pkg/metrics"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+type skipRecorder struct {
+	kubesecmetrics.Recorder
+	skipped []string
+}
+
+func (r *skipRecorder) IncScanSkipped(kind, namespace string) {
+	r.skipped = append(r.skipped, kind+"/"+namespace)
+}
+
+// Test_review_skip - tests the skip annotation bypasses the scan in the allowed namespaces
+func Test_review_skip(t *testing.T) {
+	annotated := testPod("busybox")
+	annotated.Annotations = map[string]string{skipAnnotation: "true"}
+
+	deployment := &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "foo"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{skipAnnotation: "true"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: "busybox"}}},
+		}},
+	}
+
+	notTrue := testPod("busybox")
+	notTrue.Annotations = map[string]string{skipAnnotation: "yes"}
+
+	labeled := testPod("busybox")
+	labeled.Labels = map[string]string{"kubesec.io/enforce": "false"}
+	selector, err := labels.Parse("kubesec.io/enforce=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		obj        object
+		selector   labels.Selector
+		namespaces []string
+		skipped    bool
+	}{
+		{name: "selected", obj: labeled, selector: selector, skipped: true},
+		{name: "selector unset", obj: labeled, skipped: false},
+		{name: "unselected", obj: testPod("busybox"), selector: selector, skipped: false},
+		{name: "selected in other namespace", obj: labeled, selector: selector, namespaces: []string{"bar"}, skipped: false},
+		{name: "annotated", obj: annotated, skipped: true},
+		{name: "annotated template", obj: deployment, skipped: true},
+		{name: "not annotated", obj: testPod("busybox"), skipped: false},
+		{name: "not true", obj: notTrue, skipped: false},
+		{name: "allowed namespace", obj: annotated, namespaces: []string{"bar", "foo"}, skipped: true},
+		{name: "other namespace", obj: annotated, namespaces: []string{"bar"}, skipped: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &skipRecorder{Recorder: kubesecmetrics.Dummy}
+			opts := &Options{
+				Scanner:        &fakeScanner{result: scanner.Result{Score: -30}},
+				Recorder:       rec,
+				SkipSelector:   tt.selector,
+				SkipNamespaces: tt.namespaces,
+			}
+			_, res, err := opts.review(context.Background(), "pod", tt.obj, 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.skipped {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.skipped, res.Valid, res.Message)
+			}
+			if got := len(rec.skipped) == 1; got != tt.skipped {
+				t.Fatalf("review - want skip counted=%v, got %v", tt.skipped, rec.skipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package webhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	whcontext "github.com/slok/kubewebhook/pkg/webhook/context"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+)
+
+// templateUnchanged tells whether the object is updated without changing the
+// Pod it runs, e.g. when only its replicas or its annotations change.
+func templateUnchanged(ctx context.Context, obj object, logger log.Logger) bool {
+	ar := whcontext.GetAdmissionRequest(ctx)
+	if ar == nil || ar.Operation != admissionv1beta1.Update || len(ar.OldObject.Raw) == 0 {
+		return false
+	}
+
+	decoded, err := decodeOld(ar.OldObject.Raw, obj)
+	if err != nil {
+		logger.Warningf("could not decode old object: %v", err)
+		return false
+	}
+	old, ok := decoded.(object)
+	if !ok {
+		return false
+	}
+
+	oldSum, ok := podHash(old)
+	if !ok {
+		return false
+	}
+	newSum, ok := podHash(obj)
+	return ok && oldSum == newSum
+}
+
+// podHash returns the hash of the effective Pod of the object.
+func podHash(obj object) ([sha256.Size]byte, bool) {
+	pod := effectivePod(obj)
+	if pod == nil {
+		return [sha256.Size]byte{}, false
+	}
+	// The name of the workload is not part of its template.
+	pod.Name = ""
+
+	raw, err := json.Marshal(pod)
+	if err != nil {
+		return [sha256.Size]byte{}, false
+	}
+	return sha256.Sum256(raw), true
+}
@@ -0,0 +1,74 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	whcontext "github.com/slok/kubewebhook/pkg/webhook/context"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Test_review_skipUnchangedUpdates - tests the updates leaving the pod template unchanged are not scanned
+func Test_review_skipUnchangedUpdates(t *testing.T) {
+	deployment := func(replicas int32, image string, annotations map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			TypeMeta:   metav1.TypeMeta{Kind: "Deployment", APIVersion: "apps/v1"},
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "foo", Annotations: annotations},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "main", Image: image}},
+				}},
+			},
+		}
+	}
+	old := deployment(1, "nginx:1", nil)
+
+	tests := []struct {
+		name    string
+		enabled bool
+		op      admissionv1beta1.Operation
+		new     *appsv1.Deployment
+		scans   int
+	}{
+		{name: "scaled", enabled: true, op: admissionv1beta1.Update, new: deployment(3, "nginx:1", nil), scans: 0},
+		{name: "annotated", enabled: true, op: admissionv1beta1.Update, new: deployment(1, "nginx:1", map[string]string{"team": "web"}), scans: 0},
+		{name: "new image", enabled: true, op: admissionv1beta1.Update, new: deployment(1, "nginx:2", nil), scans: 1},
+		{name: "create", enabled: true, op: admissionv1beta1.Create, new: deployment(1, "nginx:1", nil), scans: 1},
+		{name: "disabled", op: admissionv1beta1.Update, new: deployment(3, "nginx:1", nil), scans: 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			raw, err := json.Marshal(old)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := whcontext.SetAdmissionRequest(context.Background(), &admissionv1beta1.AdmissionRequest{
+				Operation: tt.op,
+				OldObject: runtime.RawExtension{Raw: raw},
+			})
+
+			sc := &countingScanner{fakeScanner: fakeScanner{result: scanner.Result{Score: -30}}}
+			opts := &Options{Scanner: sc, SkipUnchangedUpdates: tt.enabled}
+			_, res, err := opts.review(ctx, "deployment", tt.new, 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sc.scans != tt.scans {
+				t.Fatalf("review - want %d scans, got %d", tt.scans, sc.scans)
+			}
+			if allowed := tt.scans == 0; res.Valid != allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", allowed, res.Valid, res.Message)
+			}
+		})
+	}
+}
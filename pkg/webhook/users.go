@@ -0,0 +1,33 @@
+package webhook
+
+import (
+	"context"
+
+	whcontext "github.com/slok/kubewebhook/pkg/webhook/context"
+)
+
+// trustedRequester returns the trusted user or group of the requester of the
+// request in ctx, empty when it is not trusted.
+func (o *Options) trustedRequester(ctx context.Context) string {
+	if o == nil || (len(o.TrustedUsers) == 0 && len(o.TrustedGroups) == 0) {
+		return ""
+	}
+	ar := whcontext.GetAdmissionRequest(ctx)
+	if ar == nil {
+		return ""
+	}
+
+	for _, u := range o.TrustedUsers {
+		if ar.UserInfo.Username == u {
+			return "user " + u
+		}
+	}
+	for _, g := range o.TrustedGroups {
+		for _, rg := range ar.UserInfo.Groups {
+			if rg == g {
+				return "group " + g
+			}
+		}
+	}
+	return ""
+}
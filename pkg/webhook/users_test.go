@@ -0,0 +1,50 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	whcontext "github.com/slok/kubewebhook/pkg/webhook/context"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Test_review_trustedRequester - tests the requests of trusted users and groups are not scanned
+func Test_review_trustedRequester(t *testing.T) {
+	operator := authenticationv1.UserInfo{Username: "system:serviceaccount:ops:deployer", Groups: []string{"system:serviceaccounts", "system:authenticated"}}
+	admin := authenticationv1.UserInfo{Username: "jane", Groups: []string{"platform-admins", "system:authenticated"}}
+
+	tests := []struct {
+		name    string
+		user    authenticationv1.UserInfo
+		users   []string
+		groups  []string
+		allowed bool
+	}{
+		{name: "trusted user", user: operator, users: []string{"system:serviceaccount:ops:deployer"}, allowed: true},
+		{name: "trusted group", user: admin, groups: []string{"platform-admins"}, allowed: true},
+		{name: "untrusted", user: admin, users: []string{"system:serviceaccount:ops:deployer"}, groups: []string{"system:masters"}, allowed: false},
+		{name: "nobody trusted", user: operator, allowed: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := whcontext.SetAdmissionRequest(context.Background(), &admissionv1beta1.AdmissionRequest{UserInfo: tt.user})
+			opts := &Options{
+				Scanner:       &fakeScanner{result: scanner.Result{Score: -30}},
+				TrustedUsers:  tt.users,
+				TrustedGroups: tt.groups,
+			}
+			_, res, err := opts.review(ctx, "pod", testPod("busybox"), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.allowed, res.Valid, res.Message)
+			}
+		})
+	}
+}
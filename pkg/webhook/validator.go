@@ -0,0 +1,477 @@
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	whcontext "github.com/slok/kubewebhook/pkg/webhook/context"
+	"github.com/slok/kubewebhook/pkg/webhook/validating"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kjson "k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes/scheme"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/logging"
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+	"github.com/controlplaneio/kubesec-webhook/pkg/requestid"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// object is a Kubernetes resource the validators are able to score.
+type object interface {
+	metav1.Object
+	runtime.Object
+}
+
+// review scores the object against Kubesec.io and turns the result into an
+// admission decision. kind is the lower case resource kind used in logs and
+// messages. Scanning errors let the object through unless the failure mode is
+// closed.
+func (o *Options) review(ctx context.Context, kind string, obj object, minScore int, logger log.Logger) (bool, validating.ValidatorResult, error) {
+	logger = reviewLogger(ctx, logger)
+	rec := newRecord(ctx, kind, obj, minScore)
+	if o.excludedNamespace(rec.Namespace) {
+		logger.Debugf("allowing %s %q without scanning, namespace %s is excluded", kind, obj.GetName(), rec.Namespace)
+		rec.Allowed = true
+		rec.Exemption = "excluded namespace " + rec.Namespace
+		o.write(ctx, rec, logger)
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+
+	if requester := o.trustedRequester(ctx); requester != "" {
+		logger.Warningf("allowing %s %q without scanning, requested by the trusted %s", kind, obj.GetName(), requester)
+		exemption := "trusted " + requester
+		annotate(ctx, exemptionAnnotation, exemption)
+		rec.Allowed = true
+		rec.Exemption = exemption
+		o.write(ctx, rec, logger)
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+
+	ns := o.namespace(ctx, rec.Namespace, logger)
+	cluster := o.clusterPolicy(ctx, ns, logger)
+	failureMode := o.failureMode()
+	if cluster != nil {
+		if cluster.MinScore != nil {
+			minScore = *cluster.MinScore
+		}
+		if cluster.FailureMode != "" {
+			failureMode = cluster.FailureMode
+		}
+	}
+	if o != nil && o.NamespaceMinScore {
+		minScore = namespaceMinScore(ns, minScore, logger)
+	}
+	req := o.images().Requirement(images(obj), minScore)
+	rec.MinScore = req.MinScore
+
+	if skip := o.skipped(obj, rec.Namespace); skip != "" {
+		logger.Warningf("allowing %s %q without scanning, skipped by %s", kind, obj.GetName(), skip)
+		o.recorder().IncScanSkipped(kind, rec.Namespace)
+		exemption := "skipped by " + skip
+		annotate(ctx, exemptionAnnotation, exemption)
+		rec.Allowed = true
+		rec.Exemption = exemption
+		o.write(ctx, rec, logger)
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+
+	if o != nil && o.SkipUnchangedUpdates && templateUnchanged(ctx, obj, logger) {
+		logger.Infof("allowing %s %q without scanning, its pod template did not change", kind, obj.GetName())
+		rec.Allowed = true
+		o.write(ctx, rec, logger)
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+
+	exemption := o.exemption(ctx, kind, obj, rec.Namespace, logger)
+	if exemption != "" && o.exemptionMode() == ExemptionAllow {
+		logger.Infof("allowing %s %q without scanning, exempted by %s", kind, obj.GetName(), exemption)
+		annotate(ctx, exemptionAnnotation, exemption)
+		rec.Allowed = true
+		rec.Exemption = exemption
+		o.write(ctx, rec, logger)
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+
+	result, err := o.scan(ctx, kind, obj, logger)
+	if err == nil {
+		result, err = o.extend(ctx, kind, obj, result)
+	}
+	if err != nil {
+		if errors.Is(err, scanner.ErrThrottled) {
+			o.recorder().IncScanThrottled(kind)
+			logger.Warningf("could not scan %s %q: %v", kind, obj.GetName(), err)
+		} else {
+			logger.Errorf("%v", err)
+		}
+		rec.Error = err.Error()
+		if failureMode == FailClosed && exemption == "" {
+			logger.Warningf("denying %s %q without scanning, the failure mode is closed", kind, obj.GetName())
+			reason := fmt.Sprintf("%s %q could not be scanned, denied as the failure mode is closed: %v", kind, obj.GetName(), err)
+			msg := reason
+			if o.denyDetail() == DenyDetailMinimal {
+				msg = fmt.Sprintf("%s %q could not be scanned, denied as the failure mode is closed", kind, obj.GetName())
+			}
+			return o.deny(ctx, kind, obj, rec, []string{reason}, msg, logger)
+		}
+		logger.Warningf("allowing %s %q without scanning", kind, obj.GetName())
+		rec.Allowed = true
+		o.write(ctx, rec, logger)
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+
+	result = o.adjust(result, cluster)
+
+	rec.Score = result.Score
+	rec.Scan = &result
+	for _, r := range result.Scoring.Critical {
+		rec.FailedRules = append(rec.FailedRules, r.ID)
+	}
+
+	jq, err := json.MarshalIndent(scanner.Results{result}, "", "  ")
+	if err != nil {
+		logger.Errorf("kubesec.io pretty printing issue %v", err)
+		rec.Allowed = true
+		o.write(ctx, rec, logger)
+		return false, validating.ValidatorResult{Valid: true}, nil
+	}
+	logger.Infof("Scan Result:\n%s", jq)
+
+	required := append(append([]string{}, o.requiredChecks()...), req.RequiredChecks...)
+	rec.MissingChecks = missingChecks(result, required)
+	rec.DeniedRules = deniedRules(result, o.deniedRules())
+
+	lowScore := result.Score < req.MinScore
+	grandfathered := false
+	if lowScore && o != nil && o.GrandfatherUpdates {
+		if old, ok := o.oldScore(ctx, kind, obj, cluster, logger); ok && result.Score >= old {
+			logger.Infof("grandfathering %s %q, its score %d did not decrease from %d", kind, obj.GetName(), result.Score, old)
+			lowScore, grandfathered = false, true
+		}
+	}
+
+	var reasons []string
+	if lowScore {
+		reasons = append(reasons, fmt.Sprintf("%s score is %d, %s", obj.GetName(), result.Score, minScoreText(kind, req.MinScore)))
+	}
+	if len(rec.MissingChecks) > 0 {
+		reasons = append(reasons, fmt.Sprintf("%s does not pass the required checks %s", obj.GetName(), strings.Join(rec.MissingChecks, ", ")))
+	}
+	if len(rec.DeniedRules) > 0 {
+		reasons = append(reasons, fmt.Sprintf("%s fails the denied checks %s", obj.GetName(), strings.Join(rec.DeniedRules, ", ")))
+	}
+
+	if v := o.decide(ctx, obj, ns, rec, result, reasons, logger); v != nil {
+		switch v.Result {
+		case policy.VerdictDeny:
+			reason := fmt.Sprintf("%s is denied by the decision policy", obj.GetName())
+			if v.Message != "" {
+				reason = fmt.Sprintf("%s: %s", reason, v.Message)
+			}
+			reasons = append(reasons, reason)
+		default:
+			if len(reasons) > 0 {
+				logger.Infof("allowing %s %q by the decision policy: %s", kind, obj.GetName(), strings.Join(reasons, ", "))
+				annotate(ctx, exemptionAnnotation, decisionPolicyExemption)
+				for _, r := range reasons {
+					warn(ctx, fmt.Sprintf("kubesec: %s, allowed by the decision policy", r))
+				}
+				rec.Exemption = decisionPolicyExemption
+			}
+			if v.Result == policy.VerdictWarn && v.Message != "" {
+				warn(ctx, "kubesec: "+v.Message)
+			}
+			rec.Allowed = true
+			o.write(ctx, rec, logger)
+			return false, validating.ValidatorResult{Valid: true}, nil
+		}
+	}
+
+	if len(reasons) > 0 {
+		msg := strings.Join(reasons, "\n")
+		detail := o.denyDetail()
+		if detail != DenyDetailMinimal {
+			if diff := updateDiff(ctx, obj, logger); len(diff) > 0 {
+				msg = fmt.Sprintf("%s\n%s", msg, formatDiff(diff))
+			}
+		}
+		if critical := formatCritical(result); detail == DenyDetailSummary && critical != "" {
+			msg = fmt.Sprintf("%s\n%s", msg, critical)
+		}
+		if advice := formatAdvice(result, req.MinScore); lowScore && detail != DenyDetailMinimal && advice != "" {
+			msg = fmt.Sprintf("%s\n%s", msg, advice)
+		}
+		if detail == DenyDetailFull {
+			msg = fmt.Sprintf("%s\nScan Result:\n%s", msg, jq)
+		}
+
+		if exemption != "" {
+			logger.Warningf("allowing %s %q exempted by %s: %s", kind, obj.GetName(), exemption, strings.Join(reasons, ", "))
+			annotate(ctx, exemptionAnnotation, exemption)
+			for _, r := range reasons {
+				warn(ctx, fmt.Sprintf("kubesec: %s, allowed as exempted by %s", r, exemption))
+			}
+			rec.Allowed = true
+			rec.Exemption = exemption
+			o.write(ctx, rec, logger)
+			return false, validating.ValidatorResult{Valid: true}, nil
+		}
+
+		return o.deny(ctx, kind, obj, rec, reasons, msg, logger)
+	}
+
+	if grandfathered {
+		warn(ctx, fmt.Sprintf("kubesec: %s score is %d, %s, allowed as the score did not decrease", obj.GetName(), result.Score, minScoreText(kind, req.MinScore)))
+	} else if ws := o.warnScore(); ws != nil && result.Score < *ws {
+		logger.Infof("allowing %s %q with a warning, its score %d is below the warning score %d", kind, obj.GetName(), result.Score, *ws)
+		warn(ctx, fmt.Sprintf("kubesec: %s score is %d, below the warning score %d, %s", obj.GetName(), result.Score, *ws, minScoreText(kind, req.MinScore)))
+	}
+
+	rec.Allowed = true
+	o.write(ctx, rec, logger)
+	return false, validating.ValidatorResult{Valid: true}, nil
+}
+
+// minScoreText returns the minimum accepted score of the messages, named after
+// the kind but for the Pods whose messages always read without it.
+func minScoreText(kind string, minScore int) string {
+	if kind == "pod" {
+		return fmt.Sprintf("minimum accepted score is %d", minScore)
+	}
+	return fmt.Sprintf("%s minimum accepted score is %d", kind, minScore)
+}
+
+// deny records the denial of the object and returns it, with msg capped and
+// ended by the request ID, unless the enforcement is audit: the object is then
+// allowed with a warning.
+func (o *Options) deny(ctx context.Context, kind string, obj object, rec decision.Record, reasons []string, msg string, logger log.Logger) (bool, validating.ValidatorResult, error) {
+	if o.enforcement() != EnforcementAudit {
+		o.write(ctx, rec, logger)
+		return true, validating.ValidatorResult{Valid: false, Message: o.denyMessage(msg, rec.RequestID)}, nil
+	}
+
+	logger.Warningf("allowing %s %q as the enforcement is audit: %s", kind, obj.GetName(), strings.Join(reasons, ", "))
+	o.recorder().IncAuditDenied(kind)
+	annotate(ctx, auditAnnotation, strings.Join(reasons, "; "))
+	for _, r := range reasons {
+		warn(ctx, fmt.Sprintf("kubesec: %s, allowed as the enforcement is audit", r))
+	}
+	rec.Allowed = true
+	rec.Audit = true
+	o.write(ctx, rec, logger)
+	return false, validating.ValidatorResult{Valid: true}, nil
+}
+
+// scan serializes the effective Pod of the object and scores it.
+func (o *Options) scan(ctx context.Context, kind string, obj object, logger log.Logger) (scanner.Result, error) {
+	serializer := kjson.NewYAMLSerializer(kjson.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+	var buffer bytes.Buffer
+	writer := bufio.NewWriter(&buffer)
+
+	var def runtime.Object = obj
+	if pod := effectivePod(obj); pod != nil {
+		def = pod
+	}
+
+	if err := serializer.Encode(def, writer); err != nil {
+		return scanner.Result{}, fmt.Errorf("%s serialization failed %w", kind, err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return scanner.Result{}, fmt.Errorf("failed to flush buffer %w", err)
+	}
+
+	logger.Infof("Scanning %s %s", kind, obj.GetName())
+
+	// The results and errors are logged, recorded and returned to the user.
+	redact := redactor(obj)
+	result, err := o.scanner().Scan(ctx, buffer.Bytes())
+	if err != nil {
+		return scanner.Result{}, redactError(redact, fmt.Errorf("kubesec.io scan failed %w", err))
+	}
+
+	if len(result) != 1 {
+		return scanner.Result{}, fmt.Errorf("%s %q scan failed as result is empty", kind, obj.GetName())
+	}
+
+	if result[0].Error != "" {
+		return scanner.Result{}, fmt.Errorf("kubesec.io scan failed %v", redact.Replace(result[0].Error))
+	}
+
+	return redactResult(redact, result[0]), nil
+}
+
+// images returns the images of every container of the object.
+func images(obj runtime.Object) []string {
+	spec := podSpec(obj)
+	if spec == nil {
+		return nil
+	}
+
+	var res []string
+	for _, c := range spec.InitContainers {
+		res = append(res, c.Image)
+	}
+	for _, c := range spec.Containers {
+		res = append(res, c.Image)
+	}
+	return res
+}
+
+// missingChecks returns the required checks the scan did not report as passed.
+func missingChecks(result scanner.Result, required []string) []string {
+	if len(required) == 0 {
+		return nil
+	}
+
+	passed := map[string]bool{}
+	for _, r := range result.Scoring.Passed {
+		passed[r.ID] = true
+	}
+
+	var missing []string
+	for _, id := range required {
+		if !passed[id] {
+			missing = append(missing, id)
+			// Do not report a check required twice again.
+			passed[id] = true
+		}
+	}
+	return missing
+}
+
+// deniedRules returns the denied checks among the critical checks the scan
+// reported as failed.
+func deniedRules(result scanner.Result, denied []string) []string {
+	if len(denied) == 0 {
+		return nil
+	}
+
+	failed := map[string]bool{}
+	for _, r := range result.Scoring.Critical {
+		failed[r.ID] = true
+	}
+
+	var res []string
+	for _, id := range denied {
+		if failed[id] {
+			res = append(res, id)
+		}
+	}
+	return res
+}
+
+// newRecord returns the decision record of the review of obj, the outcome is
+// filled in by the caller.
+func newRecord(ctx context.Context, kind string, obj object, minScore int) decision.Record {
+	rec := decision.Record{
+		Time:       time.Now(),
+		Kind:       kind,
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+		UID:        obj.GetUID(),
+		Controlled: metav1.GetControllerOf(obj) != nil,
+		MinScore:   minScore,
+		RequestID:  requestid.FromContext(ctx),
+	}
+	rec.APIVersion, rec.ObjectKind = obj.GetObjectKind().GroupVersionKind().ToAPIVersionAndKind()
+	if sum, ok := podHash(obj); ok {
+		rec.SpecHash = hex.EncodeToString(sum[:])
+	}
+	if ar := whcontext.GetAdmissionRequest(ctx); ar != nil {
+		rec.Operation = string(ar.Operation)
+		if rec.Namespace == "" {
+			rec.Namespace = ar.Namespace
+		}
+		if rec.ObjectKind == "" {
+			rec.APIVersion, rec.ObjectKind = schema.GroupVersionKind(ar.Kind).ToAPIVersionAndKind()
+		}
+	}
+	return rec
+}
+
+// write hands the decision over to the configured sink.
+func (o *Options) write(ctx context.Context, rec decision.Record, logger log.Logger) {
+	logDecision(rec, logger)
+	o.annotateDecision(ctx, rec)
+	if !dryRun(ctx) {
+		o.count(rec)
+	}
+	sink := o.sink()
+	if sink == nil {
+		return
+	}
+	// Reports, streams and hooks are side effects dry runs must not have.
+	if dryRun(ctx) {
+		logger.Debugf("not recording the decision for %s %q of a dry run", rec.Kind, rec.Name)
+		return
+	}
+	if err := sink.Write(ctx, rec); err != nil {
+		logger.Warningf("could not record decision for %s %q: %v", rec.Kind, rec.Name, err)
+	}
+}
+
+// count records the metrics of the decision, the rescans only update the
+// last scores as they deny nothing. The objects managed by a controller, e.g.
+// the Pods of a Deployment, have no last score: it is that of their controller,
+// and their names would make a series per replica.
+func (o *Options) count(rec decision.Record) {
+	rc := o.recorder()
+	if rec.Scan != nil && !rec.Controlled {
+		rc.SetLastScore(rec.Kind, rec.Namespace, rec.Name, rec.Score)
+	}
+	if rec.Operation == decision.OperationRescan {
+		return
+	}
+	if !rec.Allowed {
+		rc.IncDenied(rec.Kind, rec.Namespace)
+	}
+	for _, id := range rec.FailedRules {
+		rc.IncRuleFailed(id)
+	}
+}
+
+// reviewLogger returns a logger tagging the lines with the request ID of ctx
+// and the UID, operation and namespace of its admission request, so a review
+// can be traced across the replicas.
+func reviewLogger(ctx context.Context, logger log.Logger) log.Logger {
+	logger = requestid.Logger(ctx, logger)
+	ar := whcontext.GetAdmissionRequest(ctx)
+	if ar == nil {
+		return logger
+	}
+	return logging.Tag(logger, "uid", ar.UID, "operation", ar.Operation, "namespace", ar.Namespace)
+}
+
+// logDecision logs the decision, with its fields when the logger supports
+// them so the denials can be queried.
+func logDecision(rec decision.Record, logger log.Logger) {
+	verdict := "denied"
+	if rec.Allowed {
+		verdict = "allowed"
+	}
+	logging.With(logger,
+		"namespace", rec.Namespace,
+		"kind", rec.Kind,
+		"name", rec.Name,
+		"score", rec.Score,
+		"minScore", rec.MinScore,
+		"decision", verdict,
+	).Infof("%s %s %s/%s, score %d, minimum score %d", verdict, rec.Kind, rec.Namespace, rec.Name, rec.Score, rec.MinScore)
+}
+
+// dryRun tells whether the request in ctx is a dry run.
+func dryRun(ctx context.Context) bool {
+	ar := whcontext.GetAdmissionRequest(ctx)
+	return ar != nil && ar.DryRun != nil && *ar.DryRun
+}
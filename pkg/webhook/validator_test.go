@@ -0,0 +1,518 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+	whcontext "github.com/slok/kubewebhook/pkg/webhook/context"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/decision"
+	"github.com/controlplaneio/kubesec-webhook/pkg/logging"
+	kubesecmetrics "github.com/controlplaneio/kubesec-webhook/pkg/metrics"
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// fakeScanner returns the same result for every definition.
+type fakeScanner struct {
+	result scanner.Result
+	err    error
+}
+
+func (f *fakeScanner) Scan(context.Context, []byte) (scanner.Results, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return scanner.Results{f.result}, nil
+}
+
+func testPod(image string) *corev1.Pod {
+	return &corev1.Pod{
+		TypeMeta:   metav1.TypeMeta{Kind: "Pod", APIVersion: "v1"},
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "foo"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "main", Image: image}}},
+	}
+}
+
+// Test_review_images - tests the image policy overrides the admission bar
+func Test_review_images(t *testing.T) {
+	nine := 9
+	images, err := policy.NewImages(
+		policy.ImageRule{Pattern: "registry.internal/hardened/*", MinScore: &nine},
+		policy.ImageRule{Pattern: "docker.io/*", RequiredChecks: []string{"RunAsNonRoot"}},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &Options{
+		Scanner: &fakeScanner{result: scanner.Result{
+			Score:   5,
+			Scoring: scanner.Scoring{Passed: []scanner.Rule{{ID: "ReadOnlyRootFilesystem"}}},
+		}},
+		Images: images,
+	}
+
+	tests := []struct {
+		name    string
+		image   string
+		allowed bool
+		message string
+	}{
+		{name: "default bar", image: "quay.io/app", allowed: true},
+		{name: "raised min score", image: "registry.internal/hardened/app", message: "test score is 5, minimum accepted score is 9"},
+		{name: "missing required check", image: "nginx", message: "does not pass the required checks RunAsNonRoot"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			_, res, err := opts.review(context.Background(), "pod", testPod(tt.image), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.allowed, res.Valid, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.message) {
+				t.Fatalf("review - want %q in message, got %q", tt.message, res.Message)
+			}
+		})
+	}
+}
+
+// Test_review_exemption - tests workloads of exempted priority classes are never denied
+func Test_review_exemption(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		class    string
+		allowed  bool
+		warnings int
+	}{
+		{name: "not exempted", mode: ExemptionAllow, class: "default", allowed: false},
+		{name: "allowed without scan", mode: ExemptionAllow, class: "system-node-critical", allowed: true},
+		{name: "warn only", mode: ExemptionWarn, class: "system-cluster-critical", allowed: true, warnings: 1},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			fs := &fakeScanner{result: scanner.Result{Score: -30}}
+			opts := &Options{
+				Scanner:               fs,
+				ExemptPriorityClasses: DefaultExemptPriorityClasses,
+				ExemptionMode:         tt.mode,
+			}
+			pod := testPod("busybox")
+			pod.Spec.PriorityClassName = tt.class
+
+			extra := &responseExtra{annotations: map[string]string{}}
+			ctx := context.WithValue(context.Background(), responseExtraKey{}, extra)
+			_, res, err := opts.review(ctx, "pod", pod, 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.allowed, res.Valid, res.Message)
+			}
+			if len(extra.warnings) != tt.warnings {
+				t.Fatalf("review - want %d warnings, got %v", tt.warnings, extra.warnings)
+			}
+			if tt.allowed && extra.annotations[exemptionAnnotation] != "priority class "+tt.class {
+				t.Fatalf("review - want exemption annotation, got %v", extra.annotations)
+			}
+		})
+	}
+}
+
+// Test_review_failureMode - tests the objects that could not be scanned are denied in closed mode only
+func Test_review_failureMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		err     error
+		score   int
+		allowed bool
+		message string
+	}{
+		{name: "open on scan error", mode: FailOpen, err: errors.New("connection refused"), allowed: true},
+		{name: "default on scan error", err: errors.New("connection refused"), allowed: true},
+		{name: "closed on scan error", mode: FailClosed, err: errors.New("connection refused"), allowed: false, message: `pod "test" could not be scanned, denied as the failure mode is closed`},
+		{name: "closed when throttled", mode: FailClosed, err: scanner.ErrThrottled, allowed: false, message: "could not be scanned"},
+		{name: "closed on low score", mode: FailClosed, score: -30, allowed: false, message: "test score is -30, minimum accepted score is 0"},
+		{name: "closed on scan success", mode: FailClosed, score: 1, allowed: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Options{
+				Scanner:     &fakeScanner{result: scanner.Result{Score: tt.score}, err: tt.err},
+				FailureMode: tt.mode,
+			}
+			_, res, err := opts.review(context.Background(), "pod", testPod("busybox"), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.allowed, res.Valid, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.message) {
+				t.Fatalf("review - want %q in message, got %q", tt.message, res.Message)
+			}
+		})
+	}
+}
+
+// Test_review_throttledLog - tests the throttled scans are logged as allowed or denied by the failure mode
+func Test_review_throttledLog(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+		not  string
+	}{
+		{mode: FailOpen, want: `allowing pod \"test\" without scanning`, not: "denying"},
+		{mode: FailClosed, want: `denying pod \"test\" without scanning`, not: "allowing"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.mode, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := &Options{Scanner: &fakeScanner{err: scanner.ErrThrottled}, FailureMode: tt.mode}
+			if _, _, err := opts.review(context.Background(), "pod", testPod("busybox"), 0, logging.NewJSON(&buf, false)); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); !strings.Contains(got, tt.want) || strings.Contains(got, tt.not) {
+				t.Fatalf("review - want %q and no %q logged, got %s", tt.want, tt.not, got)
+			}
+		})
+	}
+}
+
+// Test_review_deniedRules - tests the denied checks deny the object whatever its score
+func Test_review_deniedRules(t *testing.T) {
+	result := scanner.Result{
+		Score:   10,
+		Scoring: scanner.Scoring{Critical: []scanner.Rule{{ID: "HostNetwork", Points: -9}}},
+	}
+	tests := []struct {
+		name    string
+		denied  []string
+		allowed bool
+		message string
+	}{
+		{name: "no denied checks", allowed: true},
+		{name: "other denied check", denied: []string{"Privileged"}, allowed: true},
+		{name: "denied check", denied: []string{"Privileged", "HostNetwork"}, allowed: false, message: "test fails the denied checks HostNetwork"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Options{
+				Scanner:     &fakeScanner{result: result},
+				DeniedRules: tt.denied,
+			}
+			_, res, err := opts.review(context.Background(), "pod", testPod("busybox"), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.allowed, res.Valid, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.message) {
+				t.Fatalf("review - want %q in message, got %q", tt.message, res.Message)
+			}
+		})
+	}
+}
+
+// Test_review_requiredChecks - tests the required checks deny the object whatever its score
+func Test_review_requiredChecks(t *testing.T) {
+	result := scanner.Result{
+		Score:   10,
+		Scoring: scanner.Scoring{Passed: []scanner.Rule{{ID: "RunAsNonRoot", Points: 1}}},
+	}
+	tests := []struct {
+		name     string
+		required []string
+		allowed  bool
+		message  string
+	}{
+		{name: "no required checks", allowed: true},
+		{name: "passed", required: []string{"RunAsNonRoot"}, allowed: true},
+		{name: "missing", required: []string{"RunAsNonRoot", "ReadOnlyRootFilesystem"}, allowed: false, message: "test does not pass the required checks ReadOnlyRootFilesystem"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			opts := &Options{
+				Scanner:        &fakeScanner{result: result},
+				RequiredChecks: tt.required,
+			}
+			_, res, err := opts.review(context.Background(), "pod", testPod("busybox"), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.allowed, res.Valid, res.Message)
+			}
+			if !strings.Contains(res.Message, tt.message) {
+				t.Fatalf("review - want %q in message, got %q", tt.message, res.Message)
+			}
+		})
+	}
+}
+
+type recordingSink struct {
+	records []decision.Record
+}
+
+func (s *recordingSink) Write(_ context.Context, r decision.Record) error {
+	s.records = append(s.records, r)
+	return nil
+}
+
+// Test_review_enforcement - tests the audit enforcement allows the objects that would be denied
+func Test_review_enforcement(t *testing.T) {
+	tests := []struct {
+		name        string
+		enforcement string
+		mode        string
+		err         error
+		allowed     bool
+		audit       bool
+	}{
+		{name: "default denies", allowed: false},
+		{name: "enforce denies", enforcement: EnforcementEnforce, allowed: false},
+		{name: "audit allows", enforcement: EnforcementAudit, allowed: true, audit: true},
+		{name: "audit allows failing closed", enforcement: EnforcementAudit, mode: FailClosed, err: errors.New("connection refused"), allowed: true, audit: true},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			opts := &Options{
+				Scanner:     &fakeScanner{result: scanner.Result{Score: -30}, err: tt.err},
+				Sink:        sink,
+				Enforcement: tt.enforcement,
+				FailureMode: tt.mode,
+			}
+			_, res, err := opts.review(context.Background(), "pod", testPod("busybox"), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.allowed, res.Valid, res.Message)
+			}
+			if len(sink.records) != 1 {
+				t.Fatalf("review - want 1 record, got %d", len(sink.records))
+			}
+			if r := sink.records[0]; r.Allowed != tt.allowed || r.Audit != tt.audit {
+				t.Fatalf("review - want record allowed=%v audit=%v, got %+v", tt.allowed, tt.audit, r)
+			}
+		})
+	}
+}
+
+// Test_review_warnScore - tests the allowed objects scoring below the warning score get a warning
+func Test_review_warnScore(t *testing.T) {
+	three := 3
+	tests := []struct {
+		name      string
+		warnScore *int
+		score     int
+		allowed   bool
+		warning   string
+	}{
+		{name: "no warning score", score: 1, allowed: true},
+		{name: "above", warnScore: &three, score: 3, allowed: true},
+		{name: "borderline", warnScore: &three, score: 1, allowed: true, warning: "kubesec: test score is 1, below the warning score 3, minimum accepted score is 0"},
+		{name: "denied", warnScore: &three, score: -1, allowed: false},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			extra := &responseExtra{annotations: map[string]string{}}
+			ctx := context.WithValue(context.Background(), responseExtraKey{}, extra)
+			opts := &Options{
+				Scanner:   &fakeScanner{result: scanner.Result{Score: tt.score}},
+				WarnScore: tt.warnScore,
+			}
+			_, res, err := opts.review(ctx, "pod", testPod("busybox"), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid != tt.allowed {
+				t.Fatalf("review - want allowed=%v, got %v: %s", tt.allowed, res.Valid, res.Message)
+			}
+			var want []string
+			if tt.warning != "" {
+				want = []string{tt.warning}
+			}
+			if !reflect.DeepEqual(extra.warnings, want) {
+				t.Fatalf("review - want warnings %q, got %q", want, extra.warnings)
+			}
+		})
+	}
+}
+
+// Test_review_dryRun - tests the decisions of dry runs are not recorded
+func Test_review_dryRun(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name    string
+		dryRun  *bool
+		records int
+	}{
+		{name: "unset", records: 1},
+		{name: "not a dry run", dryRun: &no, records: 1},
+		{name: "dry run", dryRun: &yes, records: 0},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := whcontext.SetAdmissionRequest(context.Background(), &admissionv1beta1.AdmissionRequest{DryRun: tt.dryRun})
+			sink := &recordingSink{}
+			opts := &Options{Scanner: &fakeScanner{result: scanner.Result{Score: -30}}, Sink: sink}
+			_, res, err := opts.review(ctx, "pod", testPod("busybox"), 0, log.Dummy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Valid {
+				t.Fatalf("review - want denied, got allowed")
+			}
+			if len(sink.records) != tt.records {
+				t.Fatalf("review - want %d records, got %d", tt.records, len(sink.records))
+			}
+		})
+	}
+}
+
+type scoreRecorder struct {
+	kubesecmetrics.Recorder
+	scores map[string]int
+	denied []string
+	failed []string
+}
+
+func (r *scoreRecorder) SetLastScore(kind, namespace, name string, score int) {
+	r.scores[kind+"/"+namespace+"/"+name] = score
+}
+
+func (r *scoreRecorder) IncDenied(kind, namespace string) {
+	r.denied = append(r.denied, kind+"/"+namespace)
+}
+
+func (r *scoreRecorder) IncRuleFailed(rule string) {
+	r.failed = append(r.failed, rule)
+}
+
+// Test_review_count - tests the last scores, denials and failed rules are recorded
+func Test_review_count(t *testing.T) {
+	yes := true
+	create := &admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Create}
+	privileged := scanner.Result{Score: -30, Scoring: scanner.Scoring{Critical: []scanner.Rule{{ID: "Privileged"}}}}
+	tests := []struct {
+		name    string
+		ar      *admissionv1beta1.AdmissionRequest
+		result  scanner.Result
+		scanErr error
+		owned   bool
+		scores  map[string]int
+		denied  []string
+		failed  []string
+	}{
+		{name: "denied", ar: create, result: privileged, scores: map[string]int{"pod/foo/test": -30}, denied: []string{"pod/foo"}, failed: []string{"Privileged"}},
+		{name: "allowed", ar: create, result: scanner.Result{Score: 3}, scores: map[string]int{"pod/foo/test": 3}},
+		{name: "not scanned", ar: create, scanErr: errors.New("offline"), scores: map[string]int{}},
+		{name: "dry run", ar: &admissionv1beta1.AdmissionRequest{Operation: admissionv1beta1.Create, DryRun: &yes}, result: privileged, scores: map[string]int{}},
+		{name: "rescan", ar: &admissionv1beta1.AdmissionRequest{Operation: decision.OperationRescan}, result: privileged, scores: map[string]int{"pod/foo/test": -30}},
+		{name: "controlled", ar: create, result: privileged, owned: true, scores: map[string]int{}, denied: []string{"pod/foo"}, failed: []string{"Privileged"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &scoreRecorder{Recorder: kubesecmetrics.Dummy, scores: map[string]int{}}
+			opts := &Options{Scanner: &fakeScanner{result: tt.result, err: tt.scanErr}, Recorder: rec}
+			pod := testPod("busybox")
+			if tt.owned {
+				pod.OwnerReferences = []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "test-5d4f", Controller: &yes}}
+			}
+			if _, _, err := opts.review(whcontext.SetAdmissionRequest(context.Background(), tt.ar), "pod", pod, 0, log.Dummy); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rec.scores, tt.scores) || !reflect.DeepEqual(rec.denied, tt.denied) || !reflect.DeepEqual(rec.failed, tt.failed) {
+				t.Fatalf("review - want scores %v, denials %v and failed rules %v, got %v, %v and %v", tt.scores, tt.denied, tt.failed, rec.scores, rec.denied, rec.failed)
+			}
+		})
+	}
+}
+
+// Test_review_logDecision - tests the decision is logged with its fields
+func Test_review_logDecision(t *testing.T) {
+	tests := []struct {
+		name     string
+		score    int
+		decision string
+	}{
+		{name: "allowed", score: 3, decision: "allowed"},
+		{name: "denied", score: -30, decision: "denied"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := &Options{Scanner: &fakeScanner{result: scanner.Result{Score: tt.score}}}
+			if _, _, err := opts.review(context.Background(), "pod", testPod("busybox"), 0, logging.NewJSON(&buf, false)); err != nil {
+				t.Fatal(err)
+			}
+
+			var got map[string]interface{}
+			for dec := json.NewDecoder(&buf); dec.More(); {
+				entry := map[string]interface{}{}
+				if err := dec.Decode(&entry); err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := entry["decision"]; ok {
+					got = entry
+				}
+			}
+			want := map[string]interface{}{"namespace": "foo", "kind": "pod", "name": "test", "score": float64(tt.score), "decision": tt.decision}
+			for k, v := range want {
+				if got[k] != v {
+					t.Fatalf("review - want decision fields %v, got %v", want, got)
+				}
+			}
+		})
+	}
+}
+
+// Test_review_logger - tests the review lines carry the admission request
+func Test_review_logger(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := whcontext.SetAdmissionRequest(context.Background(), &admissionv1beta1.AdmissionRequest{UID: "123", Operation: admissionv1beta1.Create, Namespace: "foo"})
+	opts := &Options{Scanner: &fakeScanner{result: scanner.Result{Score: 3}}}
+	if _, _, err := opts.review(ctx, "pod", testPod("busybox"), 0, logging.NewJSON(&buf, false)); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := 0
+	for dec := json.NewDecoder(&buf); dec.More(); lines++ {
+		entry := map[string]interface{}{}
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry["uid"] != "123" || entry["operation"] != "CREATE" || entry["namespace"] != "foo" {
+			t.Fatalf("review - want the uid, operation and namespace of the request, got %v", entry)
+		}
+	}
+	if lines == 0 {
+		t.Fatalf("review - want log lines, got none")
+	}
+}
@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// reweight returns the result with the points of the weighted rules replaced,
+// and scored again.
+func reweight(result scanner.Result, weights policy.Weights) scanner.Result {
+	if len(weights) == 0 {
+		return result
+	}
+
+	// The rules may be shared with a cached result, they are copied.
+	apply := func(in []scanner.Rule) []scanner.Rule {
+		if in == nil {
+			return nil
+		}
+		out := make([]scanner.Rule, len(in))
+		for i, r := range in {
+			if w, ok := weights[r.ID]; ok {
+				r.Points = w
+			}
+			out[i] = r
+		}
+		return out
+	}
+	result.Scoring.Critical = apply(result.Scoring.Critical)
+	result.Scoring.Passed = apply(result.Scoring.Passed)
+	result.Score = result.Scoring.Score()
+
+	return result
+}
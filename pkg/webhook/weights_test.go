@@ -0,0 +1,73 @@
+package webhook
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/slok/kubewebhook/pkg/log"
+
+	"github.com/controlplaneio/kubesec-webhook/pkg/policy"
+	"github.com/controlplaneio/kubesec-webhook/pkg/scanner"
+)
+
+// Test_reweight - tests the weighted rules replace the points of the checks in the score
+func Test_reweight(t *testing.T) {
+	result := scanner.Result{
+		Score: 3,
+		Scoring: scanner.Scoring{
+			Passed: []scanner.Rule{{ID: "RequestsCPU", Points: 1}, {ID: "RunAsNonRoot", Points: 1}, {ID: "ServiceAccountName", Points: 1}},
+		},
+	}
+	orig := scanner.Result{Score: result.Score, Scoring: scanner.Scoring{Passed: append([]scanner.Rule{}, result.Scoring.Passed...)}}
+
+	got := reweight(result, policy.Weights{"RequestsCPU": 0, "RunAsNonRoot": 5, "HostPID": -100})
+	want := scanner.Result{
+		Score: 6,
+		Scoring: scanner.Scoring{
+			Passed: []scanner.Rule{{ID: "RequestsCPU", Points: 0}, {ID: "RunAsNonRoot", Points: 5}, {ID: "ServiceAccountName", Points: 1}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("reweight - want=%+v, got=%+v", want, got)
+	}
+	if !reflect.DeepEqual(result, orig) {
+		t.Fatalf("reweight - modified the scan result %+v", result)
+	}
+}
+
+// Test_reweight_critical - tests a result with criticals is scored by its criticals only
+func Test_reweight_critical(t *testing.T) {
+	result := scanner.Result{
+		Score: -30,
+		Scoring: scanner.Scoring{
+			Critical: []scanner.Rule{{ID: "Privileged", Points: -30}},
+			Passed:   []scanner.Rule{{ID: "RunAsNonRoot", Points: 1}},
+		},
+	}
+
+	if got := reweight(result, policy.Weights{"RunAsNonRoot": 5}); got.Score != -30 {
+		t.Fatalf("reweight - want the passed rule not to change the score, got %d", got.Score)
+	}
+	if got := reweight(result, policy.Weights{"Privileged": -100, "RunAsNonRoot": 5}); got.Score != -100 {
+		t.Fatalf("reweight - want the weighted critical score, got %d", got.Score)
+	}
+}
+
+// Test_review_weights - tests a weighted check can deny an object clearing the default score
+func Test_review_weights(t *testing.T) {
+	opts := &Options{
+		Scanner: &fakeScanner{result: scanner.Result{
+			Score:   -9,
+			Scoring: scanner.Scoring{Critical: []scanner.Rule{{ID: "HostPID", Points: -9}}},
+		}},
+		Weights: policy.Weights{"HostPID": -100},
+	}
+	_, res, err := opts.review(context.Background(), "pod", testPod("busybox"), -10, log.Dummy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Valid {
+		t.Fatalf("review - want denied, got allowed")
+	}
+}